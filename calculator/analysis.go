@@ -0,0 +1,237 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+)
+
+// overall is the application name of the summary of all the pods of a run.
+const overall = "all"
+
+// sample is a pod of an experiment.
+type sample struct {
+	pod      string
+	duration time.Duration
+	// start and finish are zero unless the input records them.
+	start, finish time.Time
+}
+
+// run is the pods of an experiment, e.g. a workload scheduled with one
+// policy.
+type run struct {
+	name    string
+	samples []sample
+}
+
+// parseRun reads the pods of a run, one per line as
+//
+//	<pod> <duration in seconds> [<start> <finish>]
+//
+// where start and finish are RFC 3339 timestamps. Empty lines and lines
+// starting with # are ignored.
+func parseRun(name string, r io.Reader) (*run, error) {
+	res := &run{name: name}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 && len(fields) != 4 {
+			return nil, fmt.Errorf("%s:%d: expected <pod> <duration> [<start> <finish>], got %d fields", name, line, len(fields))
+		}
+		seconds, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("%s:%d: invalid duration %q", name, line, fields[1])
+		}
+		s := sample{pod: fields[0], duration: time.Duration(seconds * float64(time.Second))}
+		if len(fields) == 4 {
+			if s.start, err = time.Parse(time.RFC3339, fields[2]); err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid start: %v", name, line, err)
+			}
+			if s.finish, err = time.Parse(time.RFC3339, fields[3]); err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid finish: %v", name, line, err)
+			}
+			if s.finish.Before(s.start) {
+				return nil, fmt.Errorf("%s:%d: pod finished before it started", name, line)
+			}
+		}
+		res.samples = append(res.samples, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", name, err)
+	}
+	return res, nil
+}
+
+// application returns the name of the application run by a pod and its
+// profile: the longest prefix of the pod name, cut at a dash, that has a
+// profile with a duration. This drops the suffixes of the pods of Jobs and
+// Deployments whatever their length.
+func application(pod string, lister profiles.Lister) (string, *profiles.ApplicationProfile, bool) {
+	for name := pod; ; {
+		if p, ok := lister.Get(name); ok && p.Duration > 0 {
+			return name, p, true
+		}
+		i := strings.LastIndex(name, "-")
+		if i <= 0 {
+			return "", nil, false
+		}
+		name = name[:i]
+	}
+}
+
+// Summary is the performance of the pods of an application in a run,
+// normalised to the duration of the application in isolation: 1 means as
+// fast as in isolation, 0.5 twice as slow.
+type Summary struct {
+	Run         string  `json:"run"`
+	Application string  `json:"application"`
+	Pods        int     `json:"pods"`
+	Mean        float64 `json:"mean"`
+	Median      float64 `json:"median"`
+	P90         float64 `json:"p90"`
+	P99         float64 `json:"p99"`
+	// Makespan is the time from the first start to the last finish of the
+	// pods, in seconds, or 0 if the run does not record them.
+	Makespan float64 `json:"makespan"`
+	// RelativeMean is the mean divided by the mean of the same application
+	// in the first run compared, or 0 if that run has no such pods.
+	RelativeMean float64 `json:"relativeMean"`
+}
+
+// analyze summarises a run per application, sorted by name, followed by the
+// summary of all its pods. It also returns the pods without a profile, which
+// are left out of the summaries.
+func analyze(r *run, lister profiles.Lister) ([]Summary, []string) {
+	byApp := map[string][]sample{}
+	normalized := map[string][]float64{}
+	var unknown []string
+	for _, s := range r.samples {
+		name, p, ok := application(s.pod, lister)
+		if !ok {
+			unknown = append(unknown, s.pod)
+			continue
+		}
+		perf := p.Duration.Seconds() / s.duration.Seconds()
+		byApp[name] = append(byApp[name], s)
+		normalized[name] = append(normalized[name], perf)
+		byApp[overall] = append(byApp[overall], s)
+		normalized[overall] = append(normalized[overall], perf)
+	}
+
+	var names []string
+	for name := range byApp {
+		if name != overall {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(byApp[overall]) > 0 {
+		names = append(names, overall)
+	}
+	summaries := make([]Summary, 0, len(names))
+	for _, name := range names {
+		values := normalized[name]
+		sort.Float64s(values)
+		summaries = append(summaries, Summary{
+			Run:         r.name,
+			Application: name,
+			Pods:        len(values),
+			Mean:        mean(values),
+			Median:      percentile(values, 50),
+			P90:         percentile(values, 90),
+			P99:         percentile(values, 99),
+			Makespan:    makespan(byApp[name]).Seconds(),
+		})
+	}
+	return summaries, unknown
+}
+
+// compare sets the relative mean of the summaries of several runs against
+// the first run.
+func compare(runs [][]Summary) {
+	if len(runs) == 0 {
+		return
+	}
+	base := map[string]float64{}
+	for _, s := range runs[0] {
+		base[s.Application] = s.Mean
+	}
+	for _, summaries := range runs {
+		for i := range summaries {
+			if b := base[summaries[i].Application]; b > 0 {
+				summaries[i].RelativeMean = summaries[i].Mean / b
+			}
+		}
+	}
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// percentile returns the p-th percentile of sorted values, interpolating
+// linearly between the closest ranks, so that the 50th percentile of an even
+// number of values is the mean of the middle two.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	frac := rank - float64(lower)
+	return sorted[lower] + frac*(sorted[lower+1]-sorted[lower])
+}
+
+// makespan returns the time from the first start to the last finish of
+// samples, or 0 if any of them has no timestamps.
+func makespan(samples []sample) time.Duration {
+	var first, last time.Time
+	for i, s := range samples {
+		if s.start.IsZero() || s.finish.IsZero() {
+			return 0
+		}
+		if i == 0 || s.start.Before(first) {
+			first = s.start
+		}
+		if i == 0 || s.finish.After(last) {
+			last = s.finish
+		}
+	}
+	return last.Sub(first)
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+)
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		values   []float64
+		p        float64
+		expected float64
+	}{
+		{values: nil, p: 50, expected: 0},
+		{values: []float64{3}, p: 99, expected: 3},
+		{values: []float64{1, 2, 3}, p: 50, expected: 2},
+		{values: []float64{1, 2, 3, 4}, p: 50, expected: 2.5},
+		{values: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, p: 90, expected: 10},
+		{values: []float64{1, 2}, p: 100, expected: 2},
+	}
+	for _, test := range tests {
+		if got := percentile(test.values, test.p); got != test.expected {
+			t.Errorf("percentile(%v, %v): expected %v, got %v", test.values, test.p, test.expected, got)
+		}
+	}
+}
+
+func TestApplication(t *testing.T) {
+	store := profiles.NewStore()
+	tests := []struct {
+		pod      string
+		expected string
+		ok       bool
+	}{
+		{pod: "scikit-lasso-5d8f7c9b6d-x2k4p", expected: "scikit-lasso", ok: true},
+		{pod: "spec-astar-q8v2z", expected: "spec-astar", ok: true},
+		{pod: "cloudsuite-web-serving-client", expected: "cloudsuite-web-serving-client", ok: true},
+		{pod: "unknown-app-5d8f7c9b6d-x2k4p", ok: false},
+	}
+	for _, test := range tests {
+		name, _, ok := application(test.pod, store)
+		if ok != test.ok || name != test.expected {
+			t.Errorf("%s: expected %q (%v), got %q (%v)", test.pod, test.expected, test.ok, name, ok)
+		}
+	}
+}
+
+func TestParseRun(t *testing.T) {
+	for _, input := range []string{
+		"scikit-lasso-x2k4p",
+		"scikit-lasso-x2k4p abc",
+		"scikit-lasso-x2k4p 0",
+		"scikit-lasso-x2k4p 69 2020-01-01T00:00:00Z",
+		"scikit-lasso-x2k4p 69 2020-01-01T00:01:00Z 2020-01-01T00:00:00Z",
+	} {
+		if _, err := parseRun("run", strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	store := profiles.NewStore()
+	store.Load(&profiles.Config{Applications: map[string]*profiles.ApplicationProfile{
+		"app-a": {Metrics: map[string]float64{"ipc": 1}, Duration: 100 * time.Second},
+		"app-b": {Metrics: map[string]float64{"ipc": 1}, Duration: 60 * time.Second},
+	}})
+	input := `# pod duration start finish
+app-a-1 100 2020-01-01T00:00:00Z 2020-01-01T00:01:40Z
+app-a-2 200 2020-01-01T00:00:10Z 2020-01-01T00:03:30Z
+
+app-b-1 120 2020-01-01T00:00:20Z 2020-01-01T00:02:20Z
+other-1 10 2020-01-01T00:00:00Z 2020-01-01T00:00:10Z
+`
+	r, err := parseRun("policy-a", strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	summaries, unknown := analyze(r, store)
+	if !reflect.DeepEqual(unknown, []string{"other-1"}) {
+		t.Errorf("expected other-1 to be unknown, got %v", unknown)
+	}
+	expected := []Summary{
+		{Run: "policy-a", Application: "app-a", Pods: 2, Mean: 0.75, Median: 0.75, P90: 0.95, P99: 0.995, Makespan: 210},
+		{Run: "policy-a", Application: "app-b", Pods: 1, Mean: 0.5, Median: 0.5, P90: 0.5, P99: 0.5, Makespan: 120},
+		{Run: "policy-a", Application: overall, Pods: 3, Mean: 2.0 / 3, Median: 0.5, P90: 0.9, P99: 0.99, Makespan: 210},
+	}
+	if len(summaries) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, summaries)
+	}
+	for i := range expected {
+		if !approxEqual(expected[i], summaries[i]) {
+			t.Errorf("expected %+v, got %+v", expected[i], summaries[i])
+		}
+	}
+
+	// Without timestamps there is no makespan.
+	r, err = parseRun("policy-b", strings.NewReader("app-a-1 50\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := analyze(r, store)
+	if other[0].Makespan != 0 {
+		t.Errorf("expected no makespan, got %v", other[0].Makespan)
+	}
+	compare([][]Summary{summaries, other})
+	if other[0].RelativeMean != 2/0.75 || summaries[0].RelativeMean != 1 {
+		t.Errorf("expected policy-b to be %v times faster on app-a, got %+v", 2/0.75, other[0])
+	}
+}
+
+func TestWrite(t *testing.T) {
+	summaries := []Summary{{Run: "policy-a", Application: overall, Pods: 2, Mean: 0.5, Median: 0.5, P90: 0.6, P99: 0.7, Makespan: 30, RelativeMean: 1}}
+
+	var buf bytes.Buffer
+	if err := write(&buf, formatCSV, summaries); err != nil {
+		t.Fatal(err)
+	}
+	expected := "run,application,pods,mean,median,p90,p99,makespan,relative_mean\npolicy-a,all,2,0.5,0.5,0.6,0.7,30,1\n"
+	if buf.String() != expected {
+		t.Errorf("expected CSV %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := write(&buf, formatJSON, summaries); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Summary
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, summaries) {
+		t.Errorf("expected JSON to round trip %+v, got %+v", summaries, decoded)
+	}
+
+	if err := write(&buf, "xml", summaries); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
+
+func approxEqual(a, b Summary) bool {
+	const epsilon = 1e-9
+	near := func(x, y float64) bool { return x-y < epsilon && y-x < epsilon }
+	return a.Run == b.Run && a.Application == b.Application && a.Pods == b.Pods &&
+		near(a.Mean, b.Mean) && near(a.Median, b.Median) && near(a.P90, b.P90) && near(a.P99, b.P99) &&
+		near(a.Makespan, b.Makespan) && near(a.RelativeMean, b.RelativeMean)
+}
@@ -1,52 +1,125 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command calculator analyses the runs of an experiment: the performance of
+// every pod normalised to the duration of its application in isolation, per
+// application and overall, and the makespan of the run. Several runs, e.g. a
+// workload scheduled with two policies, are compared against the first.
+//
+// The durations in isolation are those of the application profiles of the
+// scheduler, the Benchmarks unless a profiles file in the format of the
+// profiles ConfigMap is given.
+//
+// Usage:
+//
+//	calculator [-profiles profiles.yaml] [-format text|csv|json] [[name=]run-file ...]
+//
+// A run file holds a pod per line as "<pod> <duration in seconds>", optionally
+// followed by the RFC 3339 start and finish of the pod for the makespan.
+// Without run files a single run is read from the standard input.
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
-	"sort"
-	"strconv"
+	"path/filepath"
 	"strings"
+
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
 )
 
-var apps map[string]int = map[string]int{
-	"scikit-lasso":        69,
-	"scikit-lda":          53,
-	"scikit-ada":          138,
-	"scikit-linregr":      45,
-	"scikit-rfc":          38,
-	"scikit-rfr":          115,
-	"spec-astar":          468,
-	"spec-leslie":         378,
-	"spec-cactus":         780,
-	"spec-sphinx":         592,
-	"in-memory-analytics": 47,
-	"data-serving-client": 72,
-	"web-serving-client":  203,
+func main() {
+	profilesFile := flag.String("profiles", "", "file of application profiles, in the format of the profiles ConfigMap; the built-in benchmarks if empty")
+	format := flag.String("format", formatText, "output format: text, csv or json")
+	flag.Parse()
+
+	if err := analyzeRuns(*profilesFile, *format, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "calculator: %v\n", err)
+		os.Exit(1)
+	}
 }
 
-var normalized []float64
+func analyzeRuns(profilesFile, format string, args []string) error {
+	switch format {
+	case formatText, formatCSV, formatJSON:
+	default:
+		return fmt.Errorf("unknown format %q, expected one of %s, %s, %s", format, formatText, formatCSV, formatJSON)
+	}
+	store := profiles.NewStore()
+	if profilesFile != "" {
+		data, err := ioutil.ReadFile(profilesFile)
+		if err != nil {
+			return err
+		}
+		cfg, err := profiles.Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", profilesFile, err)
+		}
+		store.Load(cfg)
+	}
+
+	var runs []*run
+	if len(args) == 0 {
+		r, err := parseRun("stdin", os.Stdin)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, r)
+	}
+	for _, arg := range args {
+		r, err := readRun(arg)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, r)
+	}
 
-func main() {
-	scanner := bufio.NewScanner(os.Stdin)
-	//var counter int
-	for scanner.Scan() {
-		s := scanner.Text()
-		//fmt.Println("Full line\n", s)
-		s1 := strings.Split(s, " ")
-		appName := s1[0][0 : len(s1[0])-19]
-		appTime, _ := strconv.Atoi(s1[1])
-		normalized = append(normalized, float64(apps[appName])/float64(appTime))
-	}
-	//sort the numbers
-	sort.Float64s(normalized)
-	mNum := len(normalized) / 2
-	if isOdd(len(normalized)) {
-		fmt.Println(normalized[mNum])
-	} else {
-		fmt.Println("Median:", (normalized[mNum-1]+normalized[mNum])/2)
+	var results [][]Summary
+	for _, r := range runs {
+		summaries, unknown := analyze(r, store)
+		if len(unknown) > 0 {
+			fmt.Fprintf(os.Stderr, "calculator: %s: skipping %d pods without a profile: %s\n", r.name, len(unknown), strings.Join(unknown, ", "))
+		}
+		results = append(results, summaries)
+	}
+	compare(results)
+
+	var all []Summary
+	for _, summaries := range results {
+		all = append(all, summaries...)
 	}
+	return write(os.Stdout, format, all)
 }
-func isOdd(n int) bool {
-	return n%2 == 1
+
+// readRun reads a run from a "[name=]file" argument. The run is named after
+// the file unless a name is given.
+func readRun(arg string) (*run, error) {
+	name, path := "", arg
+	if i := strings.Index(arg, "="); i >= 0 {
+		name, path = arg[:i], arg[i+1:]
+	}
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseRun(name, f)
 }
@@ -0,0 +1,96 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// Output formats.
+const (
+	formatText = "text"
+	formatCSV  = "csv"
+	formatJSON = "json"
+)
+
+var csvHeader = []string{"run", "application", "pods", "mean", "median", "p90", "p99", "makespan", "relative_mean"}
+
+// write writes the summaries in the given format.
+func write(w io.Writer, format string, summaries []Summary) error {
+	switch format {
+	case formatText:
+		return writeText(w, summaries)
+	case formatCSV:
+		return writeCSV(w, summaries)
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(summaries)
+	}
+	return fmt.Errorf("unknown format %q, expected one of %s, %s, %s", format, formatText, formatCSV, formatJSON)
+}
+
+func writeText(w io.Writer, summaries []Summary) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "RUN\tAPPLICATION\tPODS\tMEAN\tMEDIAN\tP90\tP99\tMAKESPAN\tVS FIRST RUN")
+	for _, s := range summaries {
+		makespan, relative := "-", "-"
+		if s.Makespan > 0 {
+			makespan = fmt.Sprintf("%.0fs", s.Makespan)
+		}
+		if s.RelativeMean > 0 {
+			relative = fmt.Sprintf("%+.1f%%", (s.RelativeMean-1)*100)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.3f\t%.3f\t%.3f\t%.3f\t%s\t%s\n",
+			s.Run, s.Application, s.Pods, s.Mean, s.Median, s.P90, s.P99, makespan, relative)
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, summaries []Summary) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, s := range summaries {
+		record := []string{
+			s.Run,
+			s.Application,
+			strconv.Itoa(s.Pods),
+			formatFloat(s.Mean),
+			formatFloat(s.Median),
+			formatFloat(s.P90),
+			formatFloat(s.P99),
+			formatFloat(s.Makespan),
+			formatFloat(s.RelativeMean),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
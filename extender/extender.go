@@ -0,0 +1,207 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command extender serves the hardware-counter predicates and priorities of
+// the scheduler as an HTTP scheduler extender, so that a stock kube-scheduler
+// can filter and score the nodes with them.
+//
+// Usage:
+//
+//	extender [-address :8888] [-kubeconfig config] [-topology scheduler-topology.yaml] [-monitoring-config scheduler-monitoringDB.yaml] [-policy policy.json] [-profiles-configmap namespace/name] [-bind]
+//
+// The extender is declared in the policy of the kube-scheduler as
+//
+//	"extenders": [{
+//		"urlPrefix": "http://extender:8888",
+//		"filterVerb": "filter",
+//		"prioritizeVerb": "prioritize",
+//		"weight": 1,
+//		"nodeCacheCapable": true
+//	}]
+//
+// with "bindVerb": "bind" if it runs with -bind. The priorities of the policy
+// given with -policy, CustomRequestedPriority and EnergyPriority, score the
+// nodes; CustomRequestedPriority alone if none is given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	latestschedulerapi "k8s.io/kubernetes/pkg/scheduler/api/latest"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/extender"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+func main() {
+	klog.InitFlags(nil)
+	address := flag.String("address", ":8888", "address the extender listens on")
+	master := flag.String("master", "", "address of the Kubernetes API server, overrides any value in kubeconfig")
+	kubeconfig := flag.String("kubeconfig", "", "kubeconfig file of the cluster; the in-cluster config if empty")
+	topologyFile := flag.String("topology", topology.DefaultConfigFile, "topology file of the cluster")
+	monitoringFile := flag.String("monitoring-config", monitoring.DefaultConfigFile, "config file of the monitoring database")
+	policyFile := flag.String("policy", "", "scheduler policy file whose priorities score the nodes; CustomRequestedPriority if empty")
+	profilesConfigMap := flag.String("profiles-configmap", "", "namespace/name of the ConfigMap of the application profiles; the built-in benchmarks if empty")
+	refreshPeriod := flag.Duration("metrics-refresh-period", customcache.DefaultRefreshPeriod, "how often the hardware counters are refreshed")
+	bind := flag.Bool("bind", false, "serve the bind verb, binding the pods to their node")
+	flag.Parse()
+
+	if err := serve(*address, *master, *kubeconfig, *topologyFile, *monitoringFile, *policyFile, *profilesConfigMap, *refreshPeriod, *bind); err != nil {
+		fmt.Fprintf(os.Stderr, "extender: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func serve(address, master, kubeconfig, topologyFile, monitoringFile, policyFile, profilesConfigMap string, refreshPeriod time.Duration, bind bool) error {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	restConfig, err := clientcmd.BuildConfigFromFlags(master, kubeconfig)
+	if err != nil {
+		return err
+	}
+	client, err := clientset.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	nodeInformer := informerFactory.Core().V1().Nodes()
+
+	config := extender.Config{NodeLister: nodeInformer.Lister()}
+	if bind {
+		config.Client = client
+	}
+	topo, err := topology.NewSource(topologyFile, &nodeLister{nodeInformer.Lister()})
+	if err != nil {
+		return fmt.Errorf("couldn't initialize the topology: %v", err)
+	}
+	config.Topology = topo
+	if config.MetricsSource, config.Degradation, err = initMetricsSource(monitoringFile, stopCh); err != nil {
+		return err
+	}
+	config.MetricsCache = customcache.New(customcache.DefaultTTL)
+	if config.Degradation != nil {
+		config.MetricsCache.SetRetention(config.Degradation.MaxAge)
+	}
+	store, profilesSynced, err := initProfiles(client, profilesConfigMap, stopCh)
+	if err != nil {
+		return err
+	}
+	config.Profiles = store
+	if policyFile != "" {
+		data, err := ioutil.ReadFile(policyFile)
+		if err != nil {
+			return err
+		}
+		policy := &schedulerapi.Policy{}
+		if err := runtime.DecodeInto(latestschedulerapi.Codec, data, policy); err != nil {
+			return fmt.Errorf("%s: %v", policyFile, err)
+		}
+		config.Priorities = policy.Priorities
+	}
+	server, err := extender.NewServer(config)
+	if err != nil {
+		return err
+	}
+
+	informerFactory.Start(stopCh)
+	synced := []cache.InformerSynced{nodeInformer.Informer().HasSynced}
+	if profilesSynced != nil {
+		synced = append(synced, profilesSynced)
+	}
+	if !cache.WaitForCacheSync(stopCh, synced...) {
+		return fmt.Errorf("couldn't sync the caches")
+	}
+	go topo.Run(stopCh)
+	if config.MetricsSource != nil {
+		go customcache.NewRefresher(config.MetricsCache, topo, config.MetricsSource, refreshPeriod).Run(stopCh)
+	}
+
+	klog.Infof("Serving the scheduler extender on %s", address)
+	return http.ListenAndServe(address, server)
+}
+
+// nodeLister adapts a lister of the node informer to an
+// algorithm.NodeLister.
+type nodeLister struct {
+	corelisters.NodeLister
+}
+
+func (n *nodeLister) List() ([]*v1.Node, error) {
+	return n.NodeLister.List(labels.Everything())
+}
+
+// initMetricsSource connects to the monitoring database described by the
+// config file, behind a circuit breaker. Without the file the nodes are
+// scored from the load assumed by the extender only.
+func initMetricsSource(monitoringFile string, stopCh <-chan struct{}) (monitoring.MetricsSource, *monitoring.Degradation, error) {
+	if _, err := os.Stat(monitoringFile); len(monitoringFile) == 0 || os.IsNotExist(err) {
+		klog.Warningf("Missing monitoring config file %q, the hardware counters are not read", monitoringFile)
+		return nil, nil, nil
+	}
+	cfg, err := monitoring.ReadConfig(monitoringFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	backend, err := monitoring.New(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't connect to the monitoring database: %v", err)
+	}
+	health := monitoring.NewHealthCheckedBackend(backend)
+	go health.Run(stopCh)
+	return monitoring.NewCircuitBreaker(health, cfg.CircuitBreaker, nil), &cfg.Degradation, nil
+}
+
+// initProfiles returns the store of the application profiles, kept in sync
+// with the ConfigMap namespace/name if given, and the HasSynced of its
+// informer.
+func initProfiles(client clientset.Interface, configMap string, stopCh <-chan struct{}) (*profiles.Store, cache.InformerSynced, error) {
+	store := profiles.NewStore()
+	if len(configMap) == 0 {
+		return store, nil, nil
+	}
+	parts := strings.SplitN(configMap, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, nil, fmt.Errorf("invalid profiles ConfigMap %q, expected namespace/name", configMap)
+	}
+	informer := coreinformers.NewFilteredConfigMapInformer(client, parts[0], 0, cache.Indexers{}, func(options *metav1.ListOptions) {
+		options.FieldSelector = fields.OneTermEqualSelector("metadata.name", parts[1]).String()
+	})
+	informer.AddEventHandler(store)
+	go informer.Run(stopCh)
+	return store, informer.HasSynced, nil
+}
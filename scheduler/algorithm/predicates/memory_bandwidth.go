@@ -0,0 +1,101 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package predicates
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// MemoryBandwidthChecker rejects the nodes whose socket would exceed its
+// memory bandwidth if the pod was placed on it.
+type MemoryBandwidthChecker struct {
+	topology topology.Interface
+	cache    *customcache.Cache
+	profiles profiles.Lister
+}
+
+// NewMemoryBandwidthPredicate creates a predicate projecting the memory
+// traffic of the socket of each node with the pod added. The traffic of the
+// socket is read from cache, which accounts for the pods assumed since it was
+// last measured, and that of the pod from its profile in lister. The
+// projected traffic is compared to the MemoryBandwidth of the server in topo.
+// Nodes outside of the topology, on servers of unknown memory bandwidth or
+// without cached metrics fit.
+func NewMemoryBandwidthPredicate(topo topology.Interface, cache *customcache.Cache, lister profiles.Lister) FitPredicate {
+	c := &MemoryBandwidthChecker{
+		topology: topo,
+		cache:    cache,
+		profiles: lister,
+	}
+	return c.CheckMemoryBandwidth
+}
+
+// CheckMemoryBandwidth checks if the socket of the node has the memory
+// bandwidth left for the pod.
+func (c *MemoryBandwidthChecker) CheckMemoryBandwidth(pod *v1.Pod, meta PredicateMetadata, nodeInfo *schedulernodeinfo.NodeInfo) (bool, []PredicateFailureReason, error) {
+	node := nodeInfo.Node()
+	if node == nil {
+		return false, nil, fmt.Errorf("node not found")
+	}
+	if c.topology == nil || c.cache == nil {
+		return true, nil, nil
+	}
+	n, ok := c.topology.Node(node.Name)
+	if !ok {
+		return true, nil, nil
+	}
+	server, ok := c.topology.Server(n.Server)
+	if !ok || server.MemoryBandwidth <= 0 {
+		return true, nil, nil
+	}
+	traffic, ok := c.socketTraffic(n)
+	if !ok {
+		klog.V(4).Infof("No memory traffic cached for the socket of node %v, assuming it fits", node.Name)
+		return true, nil, nil
+	}
+
+	profile := profiles.DefaultProfile
+	if c.profiles != nil {
+		profile = c.profiles.ForPod(pod)
+	}
+	projected := traffic + profile.Metrics["mem_read"] + profile.Metrics["mem_write"]
+	if projected > float64(server.MemoryBandwidth) {
+		klog.V(4).Infof("Pod %v/%v would bring the memory traffic of the socket of node %v to %v, above its bandwidth %v",
+			pod.Namespace, pod.Name, node.Name, projected, server.MemoryBandwidth)
+		return false, []PredicateFailureReason{ErrMemoryBandwidthSaturated}, nil
+	}
+	return true, nil, nil
+}
+
+// socketTraffic returns the memory traffic of the socket of n, cached for
+// any of the nodes of the socket.
+func (c *MemoryBandwidthChecker) socketTraffic(n *topology.Node) (float64, bool) {
+	names := append([]string{n.Name}, c.topology.SocketNodes(n.Server, n.Socket)...)
+	for _, name := range names {
+		if metrics, ok := c.cache.GetAll(name, "mem_read", "mem_write"); ok {
+			return metrics["mem_read"] + metrics["mem_write"], true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package predicates
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+func TestCheckMemoryBandwidth(t *testing.T) {
+	topo := topology.New(
+		[]topology.Server{{UUID: "server-a", MemoryBandwidth: 10}, {UUID: "server-b"}},
+		[]topology.Node{
+			{Name: "kube-01", Server: "server-a", Socket: 0, Cores: []int{0, 1}},
+			{Name: "kube-02", Server: "server-a", Socket: 0, Cores: []int{2, 3}},
+			{Name: "kube-03", Server: "server-a", Socket: 1, Cores: []int{4, 5}},
+			{Name: "kube-04", Server: "server-b", Socket: 0, Cores: []int{0, 1}},
+		},
+	)
+	store := profiles.NewStore()
+	store.Load(&profiles.Config{Applications: map[string]*profiles.ApplicationProfile{
+		"redis": {Metrics: map[string]float64{"mem_read": 1.5, "mem_write": 1}},
+	}})
+	redis := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{profiles.ApplicationKey: "redis"}}}
+
+	newCache := func() *customcache.Cache {
+		cache := customcache.New(customcache.DefaultTTL)
+		// The socket metrics of socket 0 are only cached for kube-02.
+		cache.Update("kube-02", map[string]float64{"mem_read": 4, "mem_write": 4})
+		cache.Update("kube-03", map[string]float64{"mem_read": 2, "mem_write": 2})
+		cache.Update("kube-04", map[string]float64{"mem_read": 20, "mem_write": 20})
+		return cache
+	}
+
+	tests := []struct {
+		name  string
+		node  string
+		cache *customcache.Cache
+		fits  bool
+	}{
+		{
+			// 8 + 2.5 over the bandwidth of 10.
+			name:  "saturated socket",
+			node:  "kube-01",
+			cache: newCache(),
+			fits:  false,
+		},
+		{
+			name:  "socket with bandwidth left",
+			node:  "kube-03",
+			cache: newCache(),
+			fits:  true,
+		},
+		{
+			// A pod assumed on kube-03 brings the traffic of its socket to 8.
+			name: "assumed pod",
+			node: "kube-03",
+			cache: func() *customcache.Cache {
+				cache := newCache()
+				cache.Assume("assumed", "kube-03", map[string]float64{"mem_read": 3, "mem_write": 1}, topo)
+				return cache
+			}(),
+			fits: false,
+		},
+		{
+			name:  "unknown memory bandwidth",
+			node:  "kube-04",
+			cache: newCache(),
+			fits:  true,
+		},
+		{
+			name:  "node outside of the topology",
+			node:  "kube-09",
+			cache: newCache(),
+			fits:  true,
+		},
+		{
+			name:  "no cached metrics",
+			node:  "kube-01",
+			cache: customcache.New(customcache.DefaultTTL),
+			fits:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nodeInfo := schedulernodeinfo.NewNodeInfo()
+			nodeInfo.SetNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: test.node}})
+			fits, reasons, err := NewMemoryBandwidthPredicate(topo, test.cache, store)(redis, nil, nodeInfo)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fits != test.fits {
+				t.Errorf("expected fits %v, got %v", test.fits, fits)
+			}
+			var expectedReasons []PredicateFailureReason
+			if !test.fits {
+				expectedReasons = []PredicateFailureReason{ErrMemoryBandwidthSaturated}
+			}
+			if !reflect.DeepEqual(expectedReasons, reasons) {
+				t.Errorf("expected failure reasons %v, got %v", expectedReasons, reasons)
+			}
+		})
+	}
+}
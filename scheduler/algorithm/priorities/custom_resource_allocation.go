@@ -17,304 +17,141 @@ limitations under the License.
 package priorities
 
 import (
-	"encoding/json"
 	"fmt"
-	"strings"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
-	client "github.com/influxdata/influxdb1-client/v2"
-	customcache "github.com/iwita/kube-scheduler/customcache"
 	"k8s.io/klog"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
 )
 
-var (
-	customResourcePriority = &CustomAllocationPriority{"CustomResourceAllocation", customResourceScorer}
-	//customResourcePriority = &CustomAllocationPriority{"CustomRequestedPriority", customResourceScorer}
-	// LeastRequestedPriorityMap is a priority function that favors nodes with fewer requested resources.
-	// It calculates the percentage of memory and CPU requested by pods scheduled on the node, and
-	// prioritizes based on the minimum of the average of the fraction of requested to capacity.
-	//
-	// Details:
-	// (cpu((capacity-sum(requested))*10/capacity) + memory((capacity-sum(requested))*10/capacity))/2
-	CustomRequestedPriorityMap = customResourcePriority.PriorityMap
-)
-
-func customScoreFn(si scorerInput) float64 {
-	return si.metrics["ipc"] / (si.metrics["mem_read"] + si.metrics["mem_write"])
-}
-
-func onlyIPC(metrics map[string]float64) float64 {
-	return metrics["ipc"]
-}
-
-func onlyL3(metrics map[string]float64) float64 {
-	return 1 / metrics["l3m"]
-}
-
-func onlyNrg(metrics map[string]float64) float64 {
-	return 1 / metrics["procnrg"]
-}
-
-func calculateScore(si scorerInput,
-	logicFn func(scorerInput) float64) float64 {
-
-	res := logicFn(si)
-	//klog.Infof("Has score (in float) %v\n", res)
-
-	return res
-}
-
-func calculateWeightedAverage(response *client.Response,
-	numberOfRows, numberOfMetrics int) (map[string]float64, error) {
-	// initialize the metrics map with a constant size
-	metrics := make(map[string]float64, numberOfMetrics)
-	rows := response.Results[0].Series[0]
-	for i := 1; i < len(rows.Columns); i++ {
-		for j := 0; j < numberOfRows; j++ {
-			val, err := rows.Values[j][i].(json.Number).Float64()
-			if err != nil {
-				klog.Infof("Error while calculating %v", rows.Columns[i])
-				return nil, err
-			}
-			metrics[rows.Columns[i]] += val * float64(numberOfRows-j)
-		}
-		metrics[rows.Columns[i]] = metrics[rows.Columns[i]] / float64((numberOfRows * (numberOfRows + 1) / 2))
-		//klog.Infof("%v : %v", rows.Columns[i], metrics[rows.Columns[i]])
+// NewCustomRequestedPriority creates the CustomRequestedPriority map and
+// reduce functions, which look up the placement of each node in the given
+// topology and read its hardware counters from the snapshot of the metrics
+// cache in the priority metadata. The sockets are scored with the formula of
+// score. While the counters of a node are missing, the nodes are scored as
+// set by degradation, or get the NeutralScore if it is nil.
+func NewCustomRequestedPriority(topo topology.Interface, score *CustomScore, degradation *monitoring.Degradation) (PriorityMapFunction, PriorityReduceFunction) {
+	customResourcePriority := &CustomAllocationPriority{
+		Name:        "CustomResourceAllocation",
+		topology:    topo,
+		scorer:      score.scorer,
+		degradation: degradation,
 	}
-	// TODO better handling for the returning errors
-	return metrics, nil
+	return customResourcePriority.PriorityMap, customResourcePriority.PriorityReduce
 }
 
-func customScoreInfluxDB(metrics []string, uuid string, socket,
-	numberOfRows int, cfg Config, c client.Client) (map[string]float64, error) {
-
-	// calculate the number of rows needed
-	// i.e. 20sec / 0.5s interval => 40rows
-	//numberOfRows := int(float32(time) / cfg.MonitoringSpecs.TimeInterval)
-	// merge all the required columns
-	columns := strings.Join(metrics, ", ")
-	// build the coommand
-	var command strings.Builder
-	fmt.Fprintf(&command, "SELECT %s from socket_metrics where uuid = '%s' and socket_id='%d' order by time desc limit %d", columns, uuid, socket, numberOfRows)
-	//klog.Infof("%s", command.String())
-	//q := client.NewQuery("select ipc from system_metrics", "evolve", "")
-	q := client.NewQuery(command.String(), cfg.Database.Name, "")
-	response, err := c.Query(q)
-	if err != nil {
-		klog.Infof("Error while executing the query: %v", err.Error())
-		return nil, err
-	}
-
-	// Calculate the average for the metrics provided
-	return calculateWeightedAverage(response, numberOfRows, len(metrics))
-}
-
-func InvalidateCache() {
-	// Check if the cache needs update
-	select {
-	// clean the cache if 10 seconds are passed
-	case <-customcache.LabCache.Timeout.C:
-		klog.Infof("Time to erase")
-		klog.Infof("Cache: %v", customcache.LabCache.Cache)
-		//customcache.LabCache.Timeout.Stop()
-		customcache.LabCache.CleanCache()
-
-	default:
-	}
-}
-
-func customResourceScorer(nodeName string) (float64, error) {
-
-	//InvalidateCache()
-	//klog.Infof("The value of the Ticker: %v", customcache.LabCache.Timeout.C)
-	//cores, _ := Cores[nodeName]
-
-	var results map[string]float64
-	// Check the cache
-	customcache.LabCache.Mux.Lock()
-	ipc, ok := customcache.LabCache.Cache[nodeName]["ipc"]
+// scorer scores the socket of a node from the counters of the scheduling
+// cycle in snapshot. If lastKnown is positive, the counters up to lastKnown
+// old are used, whether they have expired or not.
+func (s *CustomScore) scorer(topo topology.Interface, snapshot customcache.Reader, nodeName string, lastKnown time.Duration) (float64, error) {
+	node, ok := topo.Node(nodeName)
 	if !ok {
-		klog.Infof("IPC is nil")
+		klog.V(4).Infof("Node %v is not part of the topology", nodeName)
+		return 0, nil
 	}
-	reads, ok := customcache.LabCache.Cache[nodeName]["mem_read"]
+	server, ok := topo.Server(node.Server)
 	if !ok {
-		klog.Infof("Memory Reads is nil")
+		klog.V(4).Infof("Server %v of node %v is not part of the topology", node.Server, nodeName)
+		return 0, nil
 	}
-	writes, ok := customcache.LabCache.Cache[nodeName]["mem_write"]
+	// The socket is scored as a whole, from the cores of all its nodes.
+	group, ok := topo.Tree().Group(topology.LevelSocket, nodeName)
 	if !ok {
-		klog.Infof("Memory Writes is nil")
+		return 0, nil
 	}
-
-	socket, _ := Sockets[nodeName]
-	curr_uuid, ok := Nodes[nodeName]
-
-	// If the cache has value use it
-	if ipc != -1 && reads != -1 && writes != -1 {
-		results := map[string]float64{
-			"ipc":       ipc,
-			"mem_read":  reads,
-			"mem_write": writes,
-			//"c6res":     c6res,
-		}
-
-		var socketNodes []string
-		for kubenode, s := range Sockets {
-			if s == socket && Nodes[kubenode] == curr_uuid {
-				socketNodes = append(socketNodes, kubenode)
-			}
+	if lastKnown > 0 {
+		c6, err := readLastKnownSocketC6(topo, snapshot, group.Nodes, lastKnown)
+		if err != nil {
+			return 0, err
 		}
-		//var float64 currentNodeC6res
-		socketSum := 0.0
-		socketCores := 0
-		sum := 0
-
-		for _,snode := range socketNodes {
-			c6res, ok := customcache.LabCache.Cache[snode]["c6res"]
-			if !ok {
-				klog.Infof("C6 state is nil")
-			}
-			if c6res * float64(len(Cores[snode])) > 1 {
-				sum++
-			}
-			socketSum += c6res * float64(len(Cores[snode]))
-			socketCores += len(Cores[snode])
-			// if snode == nodeName {
-			// 	currentNodeC6res = c6res
-			// }
+		results, age, ok := snapshot.GetLastKnown(nodeName, lastKnown, s.metrics...)
+		if !ok {
+			return 0, fmt.Errorf("no counters of node %v in the last %v", nodeName, lastKnown)
 		}
-		customcache.LabCache.Mux.Unlock()
-
-		klog.Infof("Found in the cache: ipc: %v, reads: %v, writes: %v, c6: %v\n", ipc, reads, writes,socketSum/float64(socketCores))
-		results["c6res"] = socketSum/float64(socketCores)
-		res := calculateScore(scorerInput{metrics: results}, customScoreFn)
-
-		if sum < 1 {
-			//klog.Infof("Average C6 is less than 1, so we get: %v", average["c6res"])
-			res = res * socketSum/float64(socketCores)
-		} else {
-			res = res * 1
-		}
-
-		//Apply heterogeneity
-		speed := links[Nodes[nodeName]][0] * links[Nodes[nodeName]][1]
-		maxFreq := maxSpeed[Nodes[nodeName]]
-		res = res * float64(speed) * float64(maxFreq)
-
-		// Select Node
-		klog.Infof("Using the cached values, Node name %s, has score %v\n", nodeName, res)
-		return res, nil
+		klog.V(4).Infof("Using the counters of node %v read %v ago: %v, c6: %v", nodeName, age, results, c6.residency())
+		return s.evaluate(results, c6.residency(), c6.idle, server)
 	}
-
-	//read database information
-	var cfg Config
-	err := readFile(&cfg, "/etc/kubernetes/scheduler-monitoringDB.yaml")
+	c6, err := readSocketC6(topo, snapshot, group.Nodes)
 	if err != nil {
 		return 0, err
 	}
-
-	/*-------------------------------------
-	//TODO read also nodes to uuid mappings for EVOLVE
-	-------------------------------------*/
-
-	// InfluxDB
-	c, err := connectToInfluxDB(cfg)
+	results, ok := snapshot.GetAll(nodeName, s.metrics...)
+	if !ok {
+		return 0, notCached(snapshot, "the counters %v of node %v are not cached", s.metrics, nodeName)
+	}
+	klog.V(5).Infof("Counters of node %v: %v, c6: %v, idle core: %v", nodeName, results, c6.residency(), c6.idle)
+	res, err := s.evaluate(results, c6.residency(), c6.idle, server)
 	if err != nil {
 		return 0, err
 	}
-	// close the connection in the end of execution
-	defer c.Close()
-
-	//Get the uuid of this node in order to query in the database
-	//socket, _ := Sockets[nodeName]
-	// cores, _ := Cores[nodeName]
-	var socketNodes []string
-
-	if ok {
-
-		metrics := []string{"c6res"}
-		time := 20
-
-		numberOfRows := int(float32(time) / cfg.MonitoringSpecs.TimeInterval)
-
-		// Define Core availability
-		// Select all the nodes belonging to the current socket
-		for kubenode, s := range Sockets {
-			if s == socket && Nodes[kubenode] == curr_uuid {
-				socketNodes = append(socketNodes, kubenode)
-			}
-		}
-		sum := 0
-		socketSum := 0.0
-		socketCores := 0.0
-		//space := 0
-		currentNodeC6res := 0.0
-
-		for _, snode := range socketNodes {
-			currCores, _ := Cores[snode]
-			r, err := queryInfluxDbCores(metrics, curr_uuid, socket, numberOfRows, cfg, c, currCores)
-			//r, err := queryInfluxDbSocket(metrics, curr_uuid, socket, numberOfRows, cfg, c)
-			if err != nil {
-				klog.Infof("Error in querying or calculating core availability in the first stage: %v", err.Error())
-			}
-			average, err := calculateWeightedAverageCores(r, numberOfRows, len(metrics), len(currCores))
-			if err != nil {
-				klog.Infof("Error defining core availability")
-			}
-			if average["c6res"]*float64(len(currCores)) >= 1 {
-				klog.Infof("Node %v has C6 sum: %v", snode, average["c6res"]*float64(len(currCores)))
-				sum++
-			}
-			if snode == nodeName {
-				currentNodeC6res = average["c6res"] * float64(len(currCores))
-			}
-			socketSum += average["c6res"] * float64(len(currCores))
-			socketCores += float64(len(currCores))
-			klog.Infof("Inside the for loop")
-		}
-
-		// Select Socket
-		results, err = customScoreInfluxDB([]string{"ipc", "mem_read", "mem_write"}, curr_uuid, socket, numberOfRows, cfg, c)
-		if err != nil {
-			klog.Infof("Error in querying or calculating average for the custom score in the first stage: %v", err.Error())
-			return 0, nil
-		}
+	klog.V(4).Infof("Node %v has score %v", nodeName, res)
+	return res, nil
+}
 
-		klog.Infof("Node: %v, Calculating score...", nodeName)
-		res := calculateScore(scorerInput{metrics: results}, customScoreFn)
-		klog.Infof("Node: %v, Finished calculating score ", nodeName)
+// socketC6 is the C6 residency of the cores of a socket.
+type socketC6 struct {
+	// sum is the C6 residency summed over the cores, i.e. the number of
+	// idle cores.
+	sum   float64
+	cores int
+	// idle is set if the cores of a node of the socket add up to a fully
+	// idle core.
+	idle bool
+}
 
-		//klog.Infof("Node: %v\t res before: %v", nodeName, res)
+// residency returns the C6 residency of the socket averaged over its cores.
+func (c socketC6) residency() float64 {
+	return c.sum / float64(c.cores)
+}
 
-		if sum < 1 {
-			klog.Infof("Less than 1 node is available\nC6contribution: %v", socketSum/socketCores)
-			res = res * socketSum / socketCores
-		} else {
-			res = res * 1
+// readSocketC6 returns the C6 residency of the cores of the given nodes of a
+// socket from snapshot. Nodes without cached samples are left out of the
+// socket rather than counted as busy.
+func readSocketC6(topo topology.Interface, snapshot customcache.Reader, socketNodes []string) (socketC6, error) {
+	var c6 socketC6
+	for _, snode := range socketNodes {
+		// The C6 residency is cached summed over the cores of the node.
+		c6res, ok := snapshot.Get(snode, "c6res")
+		if !ok {
+			klog.V(5).Infof("C6 state of node %v is not cached", snode)
+			continue
 		}
+		c6.add(topo, snode, c6res)
+	}
+	if c6.cores == 0 {
+		return socketC6{}, notCached(snapshot, "the core availability of the socket of %v is not cached", socketNodes)
+	}
+	return c6, nil
+}
 
-		//Update the cache with the new metrics
-		klog.Infof("Node: %v, Updating the cache ", nodeName)
-		err = customcache.LabCache.UpdateCache(results, currentNodeC6res, nodeName)
-		klog.Infof("Node: %v, Finishing updating the cache.... ", nodeName)
-
-		if err != nil {
-			klog.Infof(err.Error())
-		} else {
-			klog.Infof("Cache updated successfully for %v", nodeName)
+// readLastKnownSocketC6 returns the C6 residency of the cores of the given
+// nodes of a socket from the counters in snapshot up to maxAge old. Nodes
+// without such counters are left out of the socket.
+func readLastKnownSocketC6(topo topology.Interface, snapshot customcache.Reader, socketNodes []string, maxAge time.Duration) (socketC6, error) {
+	var c6 socketC6
+	for _, snode := range socketNodes {
+		last, _, ok := snapshot.GetLastKnown(snode, maxAge, "c6res")
+		if !ok {
+			continue
 		}
-
-		//Apply heterogeneity
-		speed := links[Nodes[nodeName]][0] * links[Nodes[nodeName]][1]
-		maxFreq := maxSpeed[Nodes[nodeName]]
-		res = res * float64(speed) * float64(maxFreq)
-
-		// Select Node
-		klog.Infof("Node name %s, has score %v\n", nodeName, res)
-		return res, nil
-	} else {
-		klog.Infof("Error finding the uuid: %v", ok)
-		return 0, nil
+		c6.add(topo, snode, last["c6res"])
 	}
-
+	if c6.cores == 0 {
+		return socketC6{}, fmt.Errorf("no core availability of the socket of %v in the last %v", socketNodes, maxAge)
+	}
+	return c6, nil
 }
 
+// add accounts for the C6 residency of the cores of a node, summed over them.
+func (c *socketC6) add(topo topology.Interface, node string, c6res float64) {
+	if c6res >= 1 {
+		klog.V(5).Infof("Node %v has C6 sum: %v", node, c6res)
+		c.idle = true
+	}
+	c.sum += c6res
+	if n, ok := topo.Node(node); ok {
+		c.cores += len(n.Cores)
+	}
+}
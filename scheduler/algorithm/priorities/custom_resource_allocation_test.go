@@ -0,0 +1,403 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priorities
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring/fake"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring/synthetic"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	schedulertesting "k8s.io/kubernetes/pkg/scheduler/testing"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+const testServer = "server-a"
+
+// newTestTopology returns a server with a link bandwidth of 20 and a max
+// frequency of 2, hosting kube-01 and kube-02 on socket 0 and kube-03 on
+// socket 1.
+func newTestTopology() topology.Interface {
+	return topology.New(
+		[]topology.Server{{UUID: testServer, Links: 2, LinkSpeed: 10, MaxFrequency: 2}},
+		[]topology.Node{
+			{Name: "kube-01", Server: testServer, Socket: 0, Cores: []int{0, 1}},
+			{Name: "kube-02", Server: testServer, Socket: 0, Cores: []int{2, 3}},
+			{Name: "kube-03", Server: testServer, Socket: 1, Cores: []int{4, 5}},
+		},
+	)
+}
+
+// newTestMetricsSource returns a busy socket 0, whose cores are rarely in C6,
+// and a mostly idle socket 1.
+func newTestMetricsSource() *fake.MetricsSource {
+	source := &fake.MetricsSource{
+		Sockets: map[fake.Socket]map[string]float64{
+			{Server: testServer, Socket: 0}: {"ipc": 2, "mem_read": 0.5, "mem_write": 0.5},
+			{Server: testServer, Socket: 1}: {"ipc": 1, "mem_read": 0.25, "mem_write": 0.25},
+		},
+		Cores: map[fake.Core]map[string]float64{},
+	}
+	for core := 0; core < 4; core++ {
+		source.Cores[fake.Core{Server: testServer, Socket: 0, Core: core}] = map[string]float64{"c6res": 0.2}
+	}
+	for core := 4; core < 6; core++ {
+		source.Cores[fake.Core{Server: testServer, Socket: 1, Core: core}] = map[string]float64{"c6res": 0.9}
+	}
+	return source
+}
+
+func expectHostPriorities(t *testing.T, expected, got schedulerapi.HostPriorityList) {
+	if len(expected) != len(got) {
+		t.Fatalf("expected %#v, got %#v", expected, got)
+	}
+	for i := range expected {
+		if expected[i].Host != got[i].Host || math.Abs(expected[i].Score-got[i].Score) > 1e-9 {
+			t.Errorf("expected %#v, got %#v", expected, got)
+			return
+		}
+	}
+}
+
+func TestCustomRequestedPriority(t *testing.T) {
+	nodes := []*v1.Node{
+		makeNode("kube-01", 4000, 10000),
+		makeNode("kube-02", 4000, 10000),
+		makeNode("kube-03", 4000, 10000),
+		makeNode("kube-09", 4000, 10000),
+	}
+	tests := []struct {
+		// source refreshes the cache before the nodes are scored.
+		source       monitoring.MetricsSource
+		cached       map[string]map[string]float64
+		score        *CustomScore
+		expectedList schedulerapi.HostPriorityList
+		name         string
+	}{
+		{
+			// Socket 0: ipc / (mem_read + mem_write) = 2, no node has a full
+			// core in C6 so the score is scaled by the C6 residency 0.2, and
+			// by the link bandwidth and frequency of the server: 2 * 0.2 * 40.
+			// Socket 1: 2 * 40, the cores of kube-03 add up to more than one idle core.
+			// kube-09 is not part of the topology.
+			source:       newTestMetricsSource(),
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 16}, {Host: "kube-02", Score: 16}, {Host: "kube-03", Score: 80}, {Host: "kube-09", Score: 0}},
+			name:         "busy and idle sockets",
+		},
+		{
+			// kube-02 has no samples, so the C6 residency of socket 0 is
+			// averaged over the cores of kube-01 only.
+			source: func() monitoring.MetricsSource {
+				source := newTestMetricsSource()
+				delete(source.Cores, fake.Core{Server: testServer, Socket: 0, Core: 2})
+				delete(source.Cores, fake.Core{Server: testServer, Socket: 0, Core: 3})
+				return source
+			}(),
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 16}, {Host: "kube-02", Score: 16}, {Host: "kube-03", Score: 80}, {Host: "kube-09", Score: 0}},
+			name:         "node without samples",
+		},
+		{
+			source:       &fake.MetricsSource{Err: fmt.Errorf("connection refused")},
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 0}, {Host: "kube-02", Score: 0}, {Host: "kube-03", Score: 0}, {Host: "kube-09", Score: 0}},
+			name:         "monitoring backend unavailable",
+		},
+		{
+			source:       nil,
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 0}, {Host: "kube-02", Score: 0}, {Host: "kube-03", Score: 0}, {Host: "kube-09", Score: 0}},
+			name:         "no monitoring backend",
+		},
+		{
+			// The C6 residency is cached summed over the cores of each node:
+			// the socket average is (0.4 + 0.4) / 4.
+			source: nil,
+			cached: map[string]map[string]float64{
+				"kube-01": {"ipc": 2, "mem_read": 0.5, "mem_write": 0.5, "c6res": 0.4},
+				"kube-02": {"c6res": 0.4},
+			},
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 16}, {Host: "kube-02", Score: 0}, {Host: "kube-03", Score: 0}, {Host: "kube-09", Score: 0}},
+			name:         "cached metrics",
+		},
+		{
+			// Socket 0: 2 * 0.2 / 0.1, socket 1: 1 * 1 / 0.1.
+			source: newTestMetricsSource(),
+			score: mustNewCustomScore(&schedulerapi.CustomScoreArguments{
+				Expression: "ipc * c6factor / scale",
+				Parameters: map[string]float64{"scale": 0.1},
+			}),
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 4}, {Host: "kube-02", Score: 4}, {Host: "kube-03", Score: 10}, {Host: "kube-09", Score: 0}},
+			name:         "score expression",
+		},
+		{
+			// The energy of the sockets is zero, the division by zero gets the
+			// neutral score.
+			source:       newTestMetricsSource(),
+			score:        mustNewCustomScore(&schedulerapi.CustomScoreArguments{Strategy: "energy"}),
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 0}, {Host: "kube-02", Score: 0}, {Host: "kube-03", Score: 0}, {Host: "kube-09", Score: 0}},
+			name:         "division by zero",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := customcache.New(customcache.DefaultTTL)
+			if test.source != nil {
+				customcache.NewRefresher(cache, newTestTopology(), test.source, time.Minute).Refresh()
+			}
+			for node, metrics := range test.cached {
+				cache.Update(node, metrics)
+			}
+			nodeNameToInfo := schedulernodeinfo.CreateNodeNameToInfoMap(nil, nodes)
+			score := test.score
+			if score == nil {
+				score = DefaultCustomScore
+			}
+			mapFn, reduceFn := NewCustomRequestedPriority(newTestTopology(), score, nil)
+			list, err := priorityFunction(mapFn, reduceFn, NewMetricsPriorityMetadataProducer(cache)(&v1.Pod{}, nodeNameToInfo))(&v1.Pod{}, nodeNameToInfo, nodes)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			expectHostPriorities(t, test.expectedList, list)
+		})
+	}
+}
+
+func TestCustomRequestedPriorityDegradation(t *testing.T) {
+	nodes := []*v1.Node{
+		makeNode("kube-01", 4000, 10000),
+		makeNode("kube-02", 4000, 10000),
+		makeNode("kube-03", 4000, 10000),
+		makeNode("kube-09", 4000, 10000),
+	}
+	unavailable := &fake.MetricsSource{Err: &monitoring.UnavailableError{Err: fmt.Errorf("connection refused")}}
+	tests := []struct {
+		// source refreshes the cache before the nodes are scored.
+		source    monitoring.MetricsSource
+		mode      monitoring.DegradationMode
+		lastKnown map[string]map[string]float64
+		// cached are the fresh counters of the nodes.
+		cached       map[string]map[string]float64
+		expectedList schedulerapi.HostPriorityList
+		expectErr    bool
+		name         string
+	}{
+		{
+			// All the nodes, kube-09 outside of the topology included, get
+			// the best least requested and balanced resource scores of the
+			// empty nodes.
+			source:       unavailable,
+			mode:         monitoring.FallbackToResources,
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 10}, {Host: "kube-02", Score: 10}, {Host: "kube-03", Score: 10}, {Host: "kube-09", Score: 10}},
+			name:         "fallback to resources",
+		},
+		{
+			// The sockets are scored with their expired counters, as in the
+			// cached metrics case.
+			source: unavailable,
+			mode:   monitoring.LastKnownGood,
+			lastKnown: map[string]map[string]float64{
+				"kube-01": {"ipc": 2, "mem_read": 0.5, "mem_write": 0.5, "c6res": 0.4},
+				"kube-02": {"ipc": 2, "mem_read": 0.5, "mem_write": 0.5, "c6res": 0.4},
+				"kube-03": {"ipc": 2, "mem_read": 0.5, "mem_write": 0.5, "c6res": 0.4},
+			},
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 16}, {Host: "kube-02", Score: 16}, {Host: "kube-03", Score: 16}, {Host: "kube-09", Score: 0}},
+			name:         "last known good",
+		},
+		{
+			// kube-02 has no socket metrics and kube-03 no C6 residency. The
+			// counters of kube-01 would not compare with their resource
+			// scores, so all the nodes fall back to their resources.
+			source: unavailable,
+			mode:   monitoring.LastKnownGood,
+			lastKnown: map[string]map[string]float64{
+				"kube-01": {"ipc": 2, "mem_read": 0.5, "mem_write": 0.5, "c6res": 0.4},
+				"kube-02": {"c6res": 0.4},
+			},
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 10}, {Host: "kube-02", Score: 10}, {Host: "kube-03", Score: 10}, {Host: "kube-09", Score: 10}},
+			name:         "last known good falling back to resources",
+		},
+		{
+			source:    unavailable,
+			mode:      monitoring.FailClosed,
+			expectErr: true,
+			name:      "fail closed",
+		},
+		{
+			// The counters are missing before the first refresh, while the
+			// backend is not known to be down.
+			mode:         monitoring.FailClosed,
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 10}, {Host: "kube-02", Score: 10}, {Host: "kube-03", Score: 10}, {Host: "kube-09", Score: 10}},
+			name:         "fail closed before the first refresh",
+		},
+		{
+			// Counters that cannot be scored are not missing, so they keep
+			// the neutral score.
+			mode: monitoring.FailClosed,
+			cached: map[string]map[string]float64{
+				"kube-01": {"ipc": 2, "mem_read": 0, "mem_write": 0, "c6res": 0.4},
+				"kube-02": {"ipc": 2, "mem_read": 0, "mem_write": 0, "c6res": 0.4},
+				"kube-03": {"ipc": 2, "mem_read": 0, "mem_write": 0, "c6res": 0.4},
+			},
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: NeutralScore}, {Host: "kube-02", Score: NeutralScore}, {Host: "kube-03", Score: NeutralScore}, {Host: "kube-09", Score: 0}},
+			name:         "unrelated failure",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := customcache.New(customcache.DefaultTTL)
+			cache.SetRetention(time.Minute)
+			for node, metrics := range test.lastKnown {
+				cache.UpdateWithTTL(node, metrics, 0)
+			}
+			for node, metrics := range test.cached {
+				cache.Update(node, metrics)
+			}
+			if test.source != nil {
+				customcache.NewRefresher(cache, newTestTopology(), test.source, time.Minute).Refresh()
+			}
+			degradation := &monitoring.Degradation{Mode: test.mode, MaxAge: time.Minute}
+			nodeNameToInfo := schedulernodeinfo.CreateNodeNameToInfoMap(nil, nodes)
+			mapFn, reduceFn := NewCustomRequestedPriority(newTestTopology(), DefaultCustomScore, degradation)
+			list, err := priorityFunction(mapFn, reduceFn, NewMetricsPriorityMetadataProducer(cache)(&v1.Pod{}, nodeNameToInfo))(&v1.Pod{}, nodeNameToInfo, nodes)
+			if (err != nil) != test.expectErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.expectErr {
+				if !monitoring.IsUnavailable(err) {
+					t.Errorf("expected the monitoring backend to be unavailable, got %v", err)
+				}
+				return
+			}
+			expectHostPriorities(t, test.expectedList, list)
+		})
+	}
+}
+
+func TestCustomRequestedPrioritySyntheticCounters(t *testing.T) {
+	nodes := []*v1.Node{
+		makeNode("kube-01", 4000, 10000),
+		makeNode("kube-02", 4000, 10000),
+		makeNode("kube-03", 4000, 10000),
+	}
+	source := synthetic.NewSource(newTestTopology(), synthetic.DefaultContentionModel)
+	score := func() map[string]float64 {
+		cache := customcache.New(customcache.DefaultTTL)
+		if err := customcache.NewRefresher(cache, newTestTopology(), source, time.Minute).Refresh(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		nodeNameToInfo := schedulernodeinfo.CreateNodeNameToInfoMap(nil, nodes)
+		mapFn, reduceFn := NewCustomRequestedPriority(newTestTopology(), DefaultCustomScore, nil)
+		list, err := priorityFunction(mapFn, reduceFn, NewMetricsPriorityMetadataProducer(cache)(&v1.Pod{}, nodeNameToInfo))(&v1.Pod{}, nodeNameToInfo, nodes)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		scores := map[string]float64{}
+		for _, hp := range list {
+			scores[hp.Host] = hp.Score
+		}
+		return scores
+	}
+
+	idle := score()
+	if idle["kube-01"] != idle["kube-03"] {
+		t.Errorf("expected idle sockets to score the same, got %v", idle)
+	}
+	// Memory-bound pods on socket 0 cut its ipc per memory access and keep
+	// its cores out of C6.
+	for i := 0; i < 2; i++ {
+		source.Add("kube-01", types.UID(fmt.Sprintf("pod-%d", i)), map[string]float64{"ipc": 0.8, "mem_read": 1, "mem_write": 0.5, "c6res": 0})
+	}
+	busy := score()
+	if busy["kube-01"] != busy["kube-02"] {
+		t.Errorf("expected the nodes of a socket to score the same, got %v", busy)
+	}
+	if busy["kube-01"] >= busy["kube-03"] {
+		t.Errorf("expected the idle socket to score higher than the loaded one, got %v", busy)
+	}
+	source.Remove("kube-01", "pod-0")
+	source.Remove("kube-01", "pod-1")
+	if after := score(); after["kube-01"] != idle["kube-01"] {
+		t.Errorf("expected socket 0 to score as idle once its pods are gone, got %v", after)
+	}
+}
+
+func TestCustomRequestedPriorityReadsSnapshot(t *testing.T) {
+	nodes := []*v1.Node{makeNode("kube-01", 4000, 10000)}
+	cache := customcache.New(customcache.DefaultTTL)
+	cache.Update("kube-01", map[string]float64{"ipc": 2, "mem_read": 0.5, "mem_write": 0.5, "c6res": 0.4})
+	cache.Update("kube-02", map[string]float64{"c6res": 0.4})
+	meta := &priorityMetadata{metrics: cache.Snapshot()}
+	// The counters dropped during the scheduling cycle are still scored.
+	cache.Clear()
+
+	nodeNameToInfo := schedulernodeinfo.CreateNodeNameToInfoMap(nil, nodes)
+	mapFn, reduceFn := NewCustomRequestedPriority(newTestTopology(), DefaultCustomScore, nil)
+	list, err := priorityFunction(mapFn, reduceFn, meta)(&v1.Pod{}, nodeNameToInfo, nodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectHostPriorities(t, []schedulerapi.HostPriority{{Host: "kube-01", Score: 16}}, list)
+}
+
+func TestCustomRequestedPriorityNodeMetadata(t *testing.T) {
+	// The node and its server are described by the node labels only.
+	node := makeNode("kube-01", 4000, 10000)
+	node.Labels = map[string]string{
+		topology.ServerUUIDKey:   testServer,
+		topology.SocketKey:       "0",
+		topology.CPUSetKey:       "0-1",
+		topology.LinksKey:        "2",
+		topology.LinkSpeedKey:    "10",
+		topology.MaxFrequencyKey: "2",
+	}
+	nodes := []*v1.Node{node}
+	topo, err := topology.NewSource("", schedulertesting.FakeNodeLister(nodes))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cache := customcache.New(customcache.DefaultTTL)
+	cache.Update("kube-01", map[string]float64{"ipc": 2, "mem_read": 0.5, "mem_write": 0.5, "c6res": 0.4})
+
+	nodeNameToInfo := schedulernodeinfo.CreateNodeNameToInfoMap(nil, nodes)
+	mapFn, reduceFn := NewCustomRequestedPriority(topo, DefaultCustomScore, nil)
+	list, err := priorityFunction(mapFn, reduceFn, NewMetricsPriorityMetadataProducer(cache)(&v1.Pod{}, nodeNameToInfo))(&v1.Pod{}, nodeNameToInfo, nodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 2 * 0.2 * 40, as for the same node described by the topology file.
+	expectHostPriorities(t, []schedulerapi.HostPriority{{Host: "kube-01", Score: 16}}, list)
+}
+
+func TestCustomRequestedPriorityRequiresSnapshot(t *testing.T) {
+	nodes := []*v1.Node{makeNode("kube-01", 4000, 10000)}
+	nodeNameToInfo := schedulernodeinfo.CreateNodeNameToInfoMap(nil, nodes)
+	mapFn, reduceFn := NewCustomRequestedPriority(newTestTopology(), DefaultCustomScore, nil)
+	// Without a snapshot in the metadata, no counter is read.
+	list, err := priorityFunction(mapFn, reduceFn, nil)(&v1.Pod{}, nodeNameToInfo, nodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectHostPriorities(t, []schedulerapi.HostPriority{{Host: "kube-01", Score: NeutralScore}}, list)
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priorities
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// The variables of a score expression that are not metrics of the socket.
+const (
+	// c6resVariable is the C6 residency of the socket, averaged over its cores.
+	c6resVariable = "c6res"
+	// c6FactorVariable is the C6 residency of the socket if none of its nodes
+	// adds up to a fully idle core, 1 otherwise.
+	c6FactorVariable = "c6factor"
+	// linkBandwidthVariable is the link count times the link speed of the server.
+	linkBandwidthVariable = "link_bandwidth"
+	// maxFrequencyVariable is the max frequency of the server.
+	maxFrequencyVariable = "max_frequency"
+)
+
+var derivedVariables = map[string]bool{
+	c6resVariable:         true,
+	c6FactorVariable:      true,
+	linkBandwidthVariable: true,
+	maxFrequencyVariable:  true,
+}
+
+// DefaultScoreStrategy is the strategy of CustomRequestedPriority unless the
+// policy sets another formula.
+const DefaultScoreStrategy = "bandwidth"
+
+// ScoreStrategy is a named formula of CustomRequestedPriority, with the
+// default values of the parameters it refers to.
+type ScoreStrategy struct {
+	Expression string
+	Parameters map[string]float64
+}
+
+var (
+	scoreStrategiesMutex sync.RWMutex
+	scoreStrategies      = map[string]ScoreStrategy{
+		// Instructions per memory access, favouring the sockets that are not
+		// memory-bound.
+		DefaultScoreStrategy: {Expression: "ipc / (mem_read + mem_write) * c6factor * link_bandwidth * max_frequency"},
+		"ipc":                {Expression: "ipc * c6factor * link_bandwidth * max_frequency"},
+		"l3":                 {Expression: "c6factor * link_bandwidth * max_frequency / l3m"},
+		"energy":             {Expression: "c6factor * link_bandwidth * max_frequency / procnrg"},
+		// Instructions per energy spent, the weight trading one for the other.
+		"ipc-energy": {
+			Expression: "ipc / pow(procnrg, energy_weight) * c6factor * link_bandwidth * max_frequency",
+			Parameters: map[string]float64{"energy_weight": 1},
+		},
+	}
+)
+
+// RegisterScoreStrategy registers a named formula that policies can refer to.
+// It fails if the expression is invalid.
+func RegisterScoreStrategy(name string, strategy ScoreStrategy) error {
+	if _, err := ParseScoreExpression(strategy.Expression); err != nil {
+		return err
+	}
+	scoreStrategiesMutex.Lock()
+	defer scoreStrategiesMutex.Unlock()
+	scoreStrategies[name] = strategy
+	return nil
+}
+
+// ListScoreStrategies returns the sorted names of the registered strategies.
+func ListScoreStrategies() []string {
+	scoreStrategiesMutex.RLock()
+	defer scoreStrategiesMutex.RUnlock()
+	names := make([]string, 0, len(scoreStrategies))
+	for name := range scoreStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CustomScore is the formula CustomRequestedPriority scores the sockets with.
+// Besides the metrics of the socket and the parameters, it may refer to
+// c6res, the C6 residency of the socket averaged over its cores, c6factor,
+// which is c6res unless a node of the socket adds up to a fully idle core and
+// 1 otherwise, and to the link_bandwidth and max_frequency of the server.
+type CustomScore struct {
+	expression *ScoreExpression
+	parameters map[string]float64
+	// metrics are the metrics of the socket the expression refers to.
+	metrics []string
+}
+
+// DefaultCustomScore is the formula of the DefaultScoreStrategy.
+var DefaultCustomScore = mustNewCustomScore(&schedulerapi.CustomScoreArguments{Strategy: DefaultScoreStrategy})
+
+func mustNewCustomScore(args *schedulerapi.CustomScoreArguments) *CustomScore {
+	score, err := NewCustomScore(args)
+	if err != nil {
+		panic(err)
+	}
+	return score
+}
+
+// NewCustomScore returns the formula of the given strategy or expression.
+func NewCustomScore(args *schedulerapi.CustomScoreArguments) (*CustomScore, error) {
+	var strategy ScoreStrategy
+	switch {
+	case args.Strategy != "" && args.Expression != "":
+		return nil, fmt.Errorf("only one of a score strategy and expression may be specified")
+	case args.Strategy != "":
+		scoreStrategiesMutex.RLock()
+		s, ok := scoreStrategies[args.Strategy]
+		scoreStrategiesMutex.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown score strategy %s, expected one of %v", args.Strategy, ListScoreStrategies())
+		}
+		strategy = s
+	case args.Expression != "":
+		strategy.Expression = args.Expression
+	default:
+		return nil, fmt.Errorf("a score strategy or expression is required")
+	}
+
+	expr, err := ParseScoreExpression(strategy.Expression)
+	if err != nil {
+		return nil, err
+	}
+	parameters := make(map[string]float64, len(strategy.Parameters)+len(args.Parameters))
+	for name, val := range strategy.Parameters {
+		parameters[name] = val
+	}
+	for name, val := range args.Parameters {
+		if derivedVariables[name] {
+			return nil, fmt.Errorf("parameter %s is reserved", name)
+		}
+		parameters[name] = val
+	}
+	score := &CustomScore{expression: expr, parameters: parameters}
+	for _, name := range expr.Variables() {
+		if _, ok := parameters[name]; !ok && !derivedVariables[name] {
+			score.metrics = append(score.metrics, name)
+		}
+	}
+	// The counters are scored from the cache, so the refreshers read them.
+	customcache.RegisterSocketMetrics(score.metrics...)
+	return score, nil
+}
+
+// String returns the expression of the formula.
+func (s *CustomScore) String() string {
+	return s.expression.String()
+}
+
+// evaluate scores a socket of server from its metrics and C6 residency. idle
+// is set if a node of the socket adds up to a fully idle core.
+func (s *CustomScore) evaluate(metrics map[string]float64, c6res float64, idle bool, server *topology.Server) (float64, error) {
+	vars := make(map[string]float64, len(metrics)+len(s.parameters)+len(derivedVariables))
+	for name, val := range metrics {
+		vars[name] = val
+	}
+	for name, val := range s.parameters {
+		vars[name] = val
+	}
+	vars[c6resVariable] = c6res
+	vars[c6FactorVariable] = c6res
+	if idle {
+		vars[c6FactorVariable] = 1
+	}
+	vars[linkBandwidthVariable] = float64(server.LinkBandwidth())
+	vars[maxFrequencyVariable] = float64(server.MaxFrequency)
+	return s.expression.Evaluate(vars)
+}
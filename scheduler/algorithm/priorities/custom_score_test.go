@@ -0,0 +1,99 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priorities
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+func TestNewCustomScore(t *testing.T) {
+	server := topology.Server{Links: 2, LinkSpeed: 10, MaxFrequency: 2}
+	metrics := map[string]float64{"ipc": 2, "mem_read": 0.5, "mem_write": 0.5, "procnrg": 4}
+	tests := []struct {
+		name      string
+		args      schedulerapi.CustomScoreArguments
+		metrics   []string
+		expected  float64
+		expectErr bool
+	}{
+		{
+			// 2 * 0.5 * 40
+			name:     "default strategy",
+			args:     schedulerapi.CustomScoreArguments{Strategy: DefaultScoreStrategy},
+			metrics:  []string{"ipc", "mem_read", "mem_write"},
+			expected: 40,
+		},
+		{
+			// 2 / 4^0.5 * 0.5 * 40
+			name:     "strategy with parameters",
+			args:     schedulerapi.CustomScoreArguments{Strategy: "ipc-energy", Parameters: map[string]float64{"energy_weight": 0.5}},
+			metrics:  []string{"ipc", "procnrg"},
+			expected: 20,
+		},
+		{
+			name:     "expression",
+			args:     schedulerapi.CustomScoreArguments{Expression: "ipc * c6res / w", Parameters: map[string]float64{"w": 4}},
+			metrics:  []string{"ipc"},
+			expected: 0.25,
+		},
+		{
+			name:      "unknown strategy",
+			args:      schedulerapi.CustomScoreArguments{Strategy: "unknown"},
+			expectErr: true,
+		},
+		{
+			name:      "strategy and expression",
+			args:      schedulerapi.CustomScoreArguments{Strategy: "ipc", Expression: "ipc"},
+			expectErr: true,
+		},
+		{
+			name:      "neither strategy nor expression",
+			expectErr: true,
+		},
+		{
+			name:      "reserved parameter",
+			args:      schedulerapi.CustomScoreArguments{Expression: "ipc", Parameters: map[string]float64{"c6factor": 1}},
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			score, err := NewCustomScore(&test.args)
+			if (err != nil) != test.expectErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(test.metrics, score.metrics) {
+				t.Errorf("expected the metrics %v, got %v", test.metrics, score.metrics)
+			}
+			res, err := score.evaluate(metrics, 0.5, false, &server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(res-test.expected) > 1e-9 {
+				t.Errorf("expected %v, got %v", test.expected, res)
+			}
+		})
+	}
+}
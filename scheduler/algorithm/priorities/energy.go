@@ -0,0 +1,142 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priorities
+
+import (
+	"fmt"
+	"math"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// PackagePowerMetric is the socket metric holding the power drawn by the
+// package, in watts, as measured by RAPL.
+const PackagePowerMetric = "procnrg"
+
+// energyPriority scores the sockets by the energy a pod is projected to
+// consume until it completes. The marginal power of the pod is the power of
+// a busy core of the socket, i.e. the package power divided among its busy
+// cores, times the fraction of the time the pod keeps its core busy. A socket
+// with no busy core has to leave its idle state for the pod, which costs its
+// whole package power. The projected energy is the marginal power times the
+// profiled duration of the pod, so that short pods weigh less than long ones.
+type energyPriority struct {
+	topology topology.Interface
+	profiles profiles.Lister
+}
+
+// NewEnergyPriority creates the EnergyPriority map and reduce functions, that
+// look up the placement of each node in the given topology, its counters in
+// the snapshot of the metrics cache in the priority metadata, and the profile
+// of the pods in lister.
+// The nodes of the socket with the lowest projected energy get MaxPriority,
+// the others a score inversely proportional to their projected energy.
+func NewEnergyPriority(topo topology.Interface, lister profiles.Lister) (PriorityMapFunction, PriorityReduceFunction) {
+	customcache.RegisterSocketMetrics(PackagePowerMetric)
+	p := &energyPriority{
+		topology: topo,
+		profiles: lister,
+	}
+	return p.PriorityMap, p.PriorityReduce
+}
+
+// PriorityMap returns the projected energy of pod on the socket of the node,
+// in joules, or 0 if it can't be estimated.
+func (e *energyPriority) PriorityMap(pod *v1.Pod, meta interface{}, nodeInfo *schedulernodeinfo.NodeInfo) (schedulerapi.HostPriority, error) {
+	node := nodeInfo.Node()
+	if node == nil {
+		return schedulerapi.HostPriority{}, fmt.Errorf("node not found")
+	}
+	energy, err := e.projectedEnergy(pod, metricsReader(meta), node.Name)
+	if err != nil {
+		klog.Warningf("EnergyPriority: no energy estimate for node %v: %v", node.Name, err)
+		energy = 0
+	}
+	return schedulerapi.HostPriority{Host: node.Name, Score: energy}, nil
+}
+
+// PriorityReduce scores the nodes in inverse proportion to their projected
+// energy. Nodes without an estimate get 0.
+func (e *energyPriority) PriorityReduce(pod *v1.Pod, meta interface{}, nodeNameToInfo map[string]*schedulernodeinfo.NodeInfo, result schedulerapi.HostPriorityList) error {
+	lowest := math.Inf(1)
+	for _, hp := range result {
+		if hp.Score > 0 && hp.Score < lowest {
+			lowest = hp.Score
+		}
+	}
+	for i := range result {
+		if result[i].Score > 0 {
+			result[i].Score = float64(schedulerapi.MaxPriority) * lowest / result[i].Score
+		}
+	}
+	return nil
+}
+
+// projectedEnergy returns the energy pod is projected to consume on the socket
+// of the named node until it completes, from the counters in snapshot.
+func (e *energyPriority) projectedEnergy(pod *v1.Pod, snapshot customcache.Reader, nodeName string) (float64, error) {
+	node, ok := e.topology.Node(nodeName)
+	if !ok {
+		klog.V(4).Infof("Node %v is not part of the topology", nodeName)
+		return 0, nil
+	}
+	group, ok := e.topology.Tree().Group(topology.LevelSocket, nodeName)
+	if !ok {
+		return 0, nil
+	}
+	c6, err := readSocketC6(e.topology, snapshot, group.Nodes)
+	if err != nil {
+		return 0, err
+	}
+	power, err := e.packagePower(snapshot, node)
+	if err != nil {
+		return 0, err
+	}
+
+	busyCores := math.Max(float64(c6.cores)-c6.sum, 1)
+	profile := profiles.DefaultProfile
+	if e.profiles != nil {
+		profile = e.profiles.ForPod(pod)
+	}
+	// The profile holds the C6 residency of the pod in percent.
+	busy := math.Min(math.Max((100-profile.Metrics["c6res"])/100, 0), 1)
+	watts := power / busyCores * busy
+
+	// Without a profiled duration the pods are compared by power alone.
+	duration := profile.Duration.Seconds()
+	if duration <= 0 {
+		duration = 1
+	}
+	klog.V(4).Infof("Pod %v/%v on node %v: %v W for %v s", pod.Namespace, pod.Name, nodeName, watts, duration)
+	return watts * duration, nil
+}
+
+// packagePower returns the power drawn by the package of the socket of node,
+// from snapshot.
+func (e *energyPriority) packagePower(snapshot customcache.Reader, node *topology.Node) (float64, error) {
+	power, ok := snapshot.Get(node.Name, PackagePowerMetric)
+	if !ok {
+		return 0, notCached(snapshot, "the package power of node %v is not cached", node.Name)
+	}
+	return power, nil
+}
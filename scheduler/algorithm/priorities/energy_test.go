@@ -0,0 +1,109 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priorities
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring/fake"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+)
+
+func TestEnergyPriority(t *testing.T) {
+	nodes := []*v1.Node{
+		makeNode("kube-01", 4000, 10000),
+		makeNode("kube-02", 4000, 10000),
+		makeNode("kube-03", 4000, 10000),
+		makeNode("kube-09", 4000, 10000),
+	}
+	store := profiles.NewStore()
+	store.Load(&profiles.Config{Applications: map[string]*profiles.ApplicationProfile{
+		"redis": {Metrics: map[string]float64{"c6res": 50}, Duration: 10 * time.Second},
+	}})
+	redis := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{profiles.ApplicationKey: "redis"}}}
+
+	// Socket 0 draws 48W over 3.2 busy cores, 15W per core, and socket 1 20W
+	// with no busy core.
+	newSource := func() *fake.MetricsSource {
+		source := newTestMetricsSource()
+		source.Sockets[fake.Socket{Server: testServer, Socket: 0}][PackagePowerMetric] = 48
+		source.Sockets[fake.Socket{Server: testServer, Socket: 1}][PackagePowerMetric] = 20
+		return source
+	}
+
+	tests := []struct {
+		name         string
+		pod          *v1.Pod
+		source       monitoring.MetricsSource
+		expectedList schedulerapi.HostPriorityList
+	}{
+		{
+			// Half a core for 10s: 75J on socket 0, 100J on socket 1.
+			name:         "profiled pod",
+			pod:          redis,
+			source:       newSource(),
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 10}, {Host: "kube-02", Score: 10}, {Host: "kube-03", Score: 7.5}, {Host: "kube-09", Score: 0}},
+		},
+		{
+			// The default profile keeps a core busy: 15W on socket 0, 20W on
+			// socket 1.
+			name:         "unknown pod",
+			pod:          &v1.Pod{},
+			source:       newSource(),
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 10}, {Host: "kube-02", Score: 10}, {Host: "kube-03", Score: 7.5}, {Host: "kube-09", Score: 0}},
+		},
+		{
+			name: "socket without power samples",
+			pod:  redis,
+			source: func() monitoring.MetricsSource {
+				source := newSource()
+				delete(source.Sockets, fake.Socket{Server: testServer, Socket: 1})
+				return source
+			}(),
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 10}, {Host: "kube-02", Score: 10}, {Host: "kube-03", Score: 0}, {Host: "kube-09", Score: 0}},
+		},
+		{
+			name:         "monitoring backend unavailable",
+			pod:          redis,
+			source:       &fake.MetricsSource{Err: fmt.Errorf("connection refused")},
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 0}, {Host: "kube-02", Score: 0}, {Host: "kube-03", Score: 0}, {Host: "kube-09", Score: 0}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := customcache.New(customcache.DefaultTTL)
+			nodeNameToInfo := schedulernodeinfo.CreateNodeNameToInfoMap(nil, nodes)
+			// The package power is refreshed once the priority registers it.
+			mapFn, reduceFn := NewEnergyPriority(newTestTopology(), store)
+			customcache.NewRefresher(cache, newTestTopology(), test.source, time.Minute).Refresh()
+			list, err := priorityFunction(mapFn, reduceFn, NewMetricsPriorityMetadataProducer(cache)(test.pod, nodeNameToInfo))(test.pod, nodeNameToInfo, nodes)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			expectHostPriorities(t, test.expectedList, list)
+		})
+	}
+}
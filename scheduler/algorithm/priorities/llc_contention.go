@@ -0,0 +1,211 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priorities
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// DefaultLLCPenalties are the penalties of the pairs of classes sharing a
+// socket unless the policy sets others. A sensitive application suffers the
+// most from a polluting neighbour, and a polluting one placed next to a
+// sensitive application slows it down as well.
+var DefaultLLCPenalties = map[string]map[string]float64{
+	string(profiles.LLCSensitive): {
+		string(profiles.LLCSensitive): 0.25,
+		string(profiles.LLCPolluting): 1,
+	},
+	string(profiles.LLCPolluting): {
+		string(profiles.LLCSensitive): 0.5,
+		string(profiles.LLCPolluting): 0.25,
+	},
+}
+
+// LLCContention classifies the applications by their last-level cache
+// behaviour, and holds the penalty of each pair of classes sharing a socket.
+type LLCContention struct {
+	thresholds profiles.LLCThresholds
+	// penalties are indexed by the class of the placed application, then by
+	// that of its neighbour.
+	penalties map[profiles.LLCClass]map[profiles.LLCClass]float64
+}
+
+// DefaultLLCContention uses the DefaultLLCThresholds and DefaultLLCPenalties.
+var DefaultLLCContention = mustNewLLCContention(&schedulerapi.LLCContentionArguments{})
+
+func mustNewLLCContention(args *schedulerapi.LLCContentionArguments) *LLCContention {
+	contention, err := NewLLCContention(args)
+	if err != nil {
+		panic(err)
+	}
+	return contention
+}
+
+// NewLLCContention returns the classification and penalties of args. The
+// thresholds and penalties it leaves unset are the defaults.
+func NewLLCContention(args *schedulerapi.LLCContentionArguments) (*LLCContention, error) {
+	thresholds := profiles.DefaultLLCThresholds
+	if args.SensitiveMissRate != 0 {
+		thresholds.Sensitive = args.SensitiveMissRate
+	}
+	if args.PollutingMissRate != 0 {
+		thresholds.Polluting = args.PollutingMissRate
+	}
+	if err := thresholds.Validate(); err != nil {
+		return nil, err
+	}
+
+	penalties := args.Penalties
+	if penalties == nil {
+		penalties = DefaultLLCPenalties
+	}
+	c := &LLCContention{
+		thresholds: thresholds,
+		penalties:  make(map[profiles.LLCClass]map[profiles.LLCClass]float64, len(penalties)),
+	}
+	for placed, row := range penalties {
+		if !profiles.LLCClass(placed).Valid() {
+			return nil, fmt.Errorf("unknown llc class %s, expected one of %v", placed, profiles.LLCClasses)
+		}
+		c.penalties[profiles.LLCClass(placed)] = make(map[profiles.LLCClass]float64, len(row))
+		for neighbour, penalty := range row {
+			if !profiles.LLCClass(neighbour).Valid() {
+				return nil, fmt.Errorf("unknown llc class %s, expected one of %v", neighbour, profiles.LLCClasses)
+			}
+			if penalty < 0 {
+				return nil, fmt.Errorf("negative penalty %v for %s next to %s", penalty, placed, neighbour)
+			}
+			c.penalties[profiles.LLCClass(placed)][profiles.LLCClass(neighbour)] = penalty
+		}
+	}
+	return c, nil
+}
+
+// Classify returns the last-level cache behaviour of the application profile.
+func (c *LLCContention) Classify(profile *profiles.ApplicationProfile) profiles.LLCClass {
+	return c.thresholds.Classify(profile)
+}
+
+// Penalty returns the penalty of placing an application of class placed on a
+// socket hosting one of class neighbour.
+func (c *LLCContention) Penalty(placed, neighbour profiles.LLCClass) float64 {
+	return c.penalties[placed][neighbour]
+}
+
+// llcContentionPriority scores the sockets by the contention on their
+// last-level cache the pod would take part in. The penalty of a socket is
+// the sum of the penalties of the pod with each of the pods assigned to the
+// kube nodes of the socket, assumed pods included.
+type llcContentionPriority struct {
+	topology   topology.Interface
+	profiles   profiles.Lister
+	contention *LLCContention
+}
+
+// NewLLCContentionPriority creates the LLCContentionPriority map and reduce
+// functions, that look up the sockets of the nodes in the given topology and
+// the profiles of the pods in lister. The nodes of the sockets without
+// penalty get MaxPriority, those of the socket with the highest penalty 0.
+func NewLLCContentionPriority(topo topology.Interface, lister profiles.Lister, contention *LLCContention) (PriorityMapFunction, PriorityReduceFunction) {
+	p := &llcContentionPriority{
+		topology:   topo,
+		profiles:   lister,
+		contention: contention,
+	}
+	return p.PriorityMap, p.PriorityReduce
+}
+
+// PriorityMap returns the penalty of pod with the pods of the node.
+func (l *llcContentionPriority) PriorityMap(pod *v1.Pod, meta interface{}, nodeInfo *schedulernodeinfo.NodeInfo) (schedulerapi.HostPriority, error) {
+	node := nodeInfo.Node()
+	if node == nil {
+		return schedulerapi.HostPriority{}, fmt.Errorf("node not found")
+	}
+	class := l.class(pod)
+	penalty := l.nodePenalty(pod, class, nodeInfo)
+	klog.V(4).Infof("Pod %v/%v of llc class %v has a penalty of %v on node %v", pod.Namespace, pod.Name, class, penalty, node.Name)
+	return schedulerapi.HostPriority{Host: node.Name, Score: penalty}, nil
+}
+
+// PriorityReduce adds the penalties of the other nodes of the socket of each
+// node, then scores the nodes in inverse proportion to the penalty of their
+// socket. Nodes outside of the topology keep the penalty of their own pods.
+func (l *llcContentionPriority) PriorityReduce(pod *v1.Pod, meta interface{}, nodeNameToInfo map[string]*schedulernodeinfo.NodeInfo, result schedulerapi.HostPriorityList) error {
+	if l.topology != nil {
+		class := l.class(pod)
+		socketPenalties := map[string]float64{}
+		for i := range result {
+			group, ok := l.topology.Tree().Group(topology.LevelSocket, result[i].Host)
+			if !ok {
+				continue
+			}
+			penalty, ok := socketPenalties[group.ID]
+			if !ok {
+				for _, name := range group.Nodes {
+					if nodeInfo, ok := nodeNameToInfo[name]; ok {
+						penalty += l.nodePenalty(pod, class, nodeInfo)
+					}
+				}
+				socketPenalties[group.ID] = penalty
+			}
+			result[i].Score = penalty
+		}
+	}
+
+	var highest float64
+	for _, hp := range result {
+		if hp.Score > highest {
+			highest = hp.Score
+		}
+	}
+	for i := range result {
+		if highest == 0 {
+			result[i].Score = float64(schedulerapi.MaxPriority)
+			continue
+		}
+		result[i].Score = float64(schedulerapi.MaxPriority) * (highest - result[i].Score) / highest
+	}
+	return nil
+}
+
+// nodePenalty returns the sum of the penalties of pod, of the given class,
+// with each of the pods of the node.
+func (l *llcContentionPriority) nodePenalty(pod *v1.Pod, class profiles.LLCClass, nodeInfo *schedulernodeinfo.NodeInfo) float64 {
+	var penalty float64
+	for _, existing := range nodeInfo.Pods() {
+		if existing.UID == pod.UID && existing.UID != "" {
+			continue
+		}
+		penalty += l.contention.Penalty(class, l.class(existing))
+	}
+	return penalty
+}
+
+func (l *llcContentionPriority) class(pod *v1.Pod) profiles.LLCClass {
+	profile := profiles.DefaultProfile
+	if l.profiles != nil {
+		profile = l.profiles.ForPod(pod)
+	}
+	return l.contention.Classify(profile)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priorities
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+)
+
+func TestLLCContentionPriority(t *testing.T) {
+	nodes := []*v1.Node{
+		makeNode("kube-01", 4000, 10000),
+		makeNode("kube-02", 4000, 10000),
+		makeNode("kube-03", 4000, 10000),
+		makeNode("kube-09", 4000, 10000),
+	}
+	store := profiles.NewStore()
+	store.Load(&profiles.Config{Applications: map[string]*profiles.ApplicationProfile{
+		"redis":  {Metrics: map[string]float64{profiles.L3MissMetric: 1}},
+		"stream": {Metrics: map[string]float64{profiles.L3MissMetric: 20}},
+		"nginx":  {Metrics: map[string]float64{profiles.L3MissMetric: 0.1}},
+		"cached": {Metrics: map[string]float64{profiles.L3MissMetric: 20}, LLC: profiles.LLCSensitive},
+	}})
+	newPod := func(application, node string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: application, Labels: map[string]string{profiles.ApplicationKey: application}},
+			Spec:       v1.PodSpec{NodeName: node},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		pod          *v1.Pod
+		pods         []*v1.Pod
+		contention   *LLCContention
+		expectedList schedulerapi.HostPriorityList
+	}{
+		{
+			// A polluter on kube-02 penalises socket 0 as a whole.
+			name:         "sensitive pod next to a polluter",
+			pod:          newPod("redis", ""),
+			pods:         []*v1.Pod{newPod("stream", "kube-02"), newPod("nginx", "kube-03")},
+			contention:   DefaultLLCContention,
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 0}, {Host: "kube-02", Score: 0}, {Host: "kube-03", Score: 10}, {Host: "kube-09", Score: 10}},
+		},
+		{
+			// 1 for the polluter on socket 0, 0.25 per sensitive pod on socket 1.
+			name:         "sensitive pod next to sensitive pods",
+			pod:          newPod("redis", ""),
+			pods:         []*v1.Pod{newPod("stream", "kube-01"), newPod("redis", "kube-03"), newPod("cached", "kube-03")},
+			contention:   DefaultLLCContention,
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 0}, {Host: "kube-02", Score: 0}, {Host: "kube-03", Score: 5}, {Host: "kube-09", Score: 10}},
+		},
+		{
+			name:         "insensitive pod",
+			pod:          newPod("nginx", ""),
+			pods:         []*v1.Pod{newPod("stream", "kube-02"), newPod("redis", "kube-03")},
+			contention:   DefaultLLCContention,
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 10}, {Host: "kube-02", Score: 10}, {Host: "kube-03", Score: 10}, {Host: "kube-09", Score: 10}},
+		},
+		{
+			// The polluter is penalised next to the sensitive pod on kube-03
+			// and next to the polluter on kube-09, outside of the topology.
+			name: "configured penalties",
+			pod:  newPod("stream", ""),
+			pods: []*v1.Pod{newPod("redis", "kube-03"), newPod("stream", "kube-09")},
+			contention: mustNewLLCContention(&schedulerapi.LLCContentionArguments{
+				Penalties: map[string]map[string]float64{"polluting": {"sensitive": 2, "polluting": 1}},
+			}),
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 10}, {Host: "kube-02", Score: 10}, {Host: "kube-03", Score: 0}, {Host: "kube-09", Score: 5}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nodeNameToInfo := schedulernodeinfo.CreateNodeNameToInfoMap(test.pods, nodes)
+			mapFn, reduceFn := NewLLCContentionPriority(newTestTopology(), store, test.contention)
+			list, err := priorityFunction(mapFn, reduceFn, nil)(test.pod, nodeNameToInfo, nodes)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			expectHostPriorities(t, test.expectedList, list)
+		})
+	}
+}
+
+func TestNewLLCContention(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      *schedulerapi.LLCContentionArguments
+		expectErr bool
+	}{
+		{
+			name: "defaults",
+			args: &schedulerapi.LLCContentionArguments{},
+		},
+		{
+			name:      "unordered thresholds",
+			args:      &schedulerapi.LLCContentionArguments{SensitiveMissRate: 10, PollutingMissRate: 1},
+			expectErr: true,
+		},
+		{
+			name:      "unknown class",
+			args:      &schedulerapi.LLCContentionArguments{Penalties: map[string]map[string]float64{"thrashing": {"sensitive": 1}}},
+			expectErr: true,
+		},
+		{
+			name:      "negative penalty",
+			args:      &schedulerapi.LLCContentionArguments{Penalties: map[string]map[string]float64{"sensitive": {"polluting": -1}}},
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewLLCContention(test.args); (err != nil) != test.expectErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -20,7 +20,9 @@ import (
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog"
 	"k8s.io/kubernetes/pkg/scheduler/algorithm"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
 	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
 )
 
@@ -30,15 +32,18 @@ type PriorityMetadataFactory struct {
 	controllerLister  algorithm.ControllerLister
 	replicaSetLister  algorithm.ReplicaSetLister
 	statefulSetLister algorithm.StatefulSetLister
+	metricsCache      *customcache.Cache
 }
 
-// NewPriorityMetadataFactory creates a PriorityMetadataFactory.
-func NewPriorityMetadataFactory(serviceLister algorithm.ServiceLister, controllerLister algorithm.ControllerLister, replicaSetLister algorithm.ReplicaSetLister, statefulSetLister algorithm.StatefulSetLister) PriorityMetadataProducer {
+// NewPriorityMetadataFactory creates a PriorityMetadataFactory. The metadata
+// holds a snapshot of metricsCache, if not nil.
+func NewPriorityMetadataFactory(serviceLister algorithm.ServiceLister, controllerLister algorithm.ControllerLister, replicaSetLister algorithm.ReplicaSetLister, statefulSetLister algorithm.StatefulSetLister, metricsCache *customcache.Cache) PriorityMetadataProducer {
 	factory := &PriorityMetadataFactory{
 		serviceLister:     serviceLister,
 		controllerLister:  controllerLister,
 		replicaSetLister:  replicaSetLister,
 		statefulSetLister: statefulSetLister,
+		metricsCache:      metricsCache,
 	}
 	return factory.PriorityMetadata
 }
@@ -53,6 +58,9 @@ type priorityMetadata struct {
 	controllerRef           *metav1.OwnerReference
 	podFirstServiceSelector labels.Selector
 	totalNumNodes           int
+	// metrics are the hardware counters of the nodes for the scheduling
+	// cycle.
+	metrics *customcache.Snapshot
 }
 
 // PriorityMetadata is a PriorityMetadataProducer.  Node info can be nil.
@@ -61,6 +69,10 @@ func (pmf *PriorityMetadataFactory) PriorityMetadata(pod *v1.Pod, nodeNameToInfo
 	if pod == nil {
 		return nil
 	}
+	var snapshot *customcache.Snapshot
+	if pmf.metricsCache != nil {
+		snapshot = pmf.metricsCache.Snapshot()
+	}
 	return &priorityMetadata{
 		nonZeroRequest:          getNonZeroRequests(pod),
 		podLimits:               getResourceLimits(pod),
@@ -70,7 +82,41 @@ func (pmf *PriorityMetadataFactory) PriorityMetadata(pod *v1.Pod, nodeNameToInfo
 		controllerRef:           metav1.GetControllerOf(pod),
 		podFirstServiceSelector: getFirstServiceSelector(pod, pmf.serviceLister),
 		totalNumNodes:           len(nodeNameToInfo),
+		metrics:                 snapshot,
+	}
+}
+
+// NewMetricsPriorityMetadataProducer returns a PriorityMetadataProducer for
+// the hardware-counter priorities alone, e.g. in an extender that knows no
+// services or controllers. The metadata holds the requests of the pod and a
+// snapshot of metricsCache.
+func NewMetricsPriorityMetadataProducer(metricsCache *customcache.Cache) PriorityMetadataProducer {
+	return func(pod *v1.Pod, nodeNameToInfo map[string]*schedulernodeinfo.NodeInfo) interface{} {
+		if pod == nil {
+			return nil
+		}
+		var snapshot *customcache.Snapshot
+		if metricsCache != nil {
+			snapshot = metricsCache.Snapshot()
+		}
+		return &priorityMetadata{
+			nonZeroRequest: getNonZeroRequests(pod),
+			podLimits:      getResourceLimits(pod),
+			totalNumNodes:  len(nodeNameToInfo),
+			metrics:        snapshot,
+		}
+	}
+}
+
+// metricsReader returns the snapshot of the hardware counters held by meta.
+// Without one, no counter is read: the metrics cache is never read while the
+// nodes are scored.
+func metricsReader(meta interface{}) customcache.Reader {
+	if priorityMeta, ok := meta.(*priorityMetadata); ok && priorityMeta.metrics != nil {
+		return priorityMeta.metrics
 	}
+	klog.Warningf("No snapshot of the hardware counters in the priority metadata, the counters are missing")
+	return (*customcache.Snapshot)(nil)
 }
 
 // getFirstServiceSelector returns one selector of services the given pod.
@@ -169,7 +169,8 @@ func TestPriorityMetadata(t *testing.T) {
 		schedulertesting.FakeServiceLister([]*v1.Service{}),
 		schedulertesting.FakeControllerLister([]*v1.ReplicationController{}),
 		schedulertesting.FakeReplicaSetLister([]*apps.ReplicaSet{}),
-		schedulertesting.FakeStatefulSetLister([]*apps.StatefulSet{}))
+		schedulertesting.FakeStatefulSetLister([]*apps.StatefulSet{}),
+		nil)
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			ptData := metaDataProducer(test.pod, nil)
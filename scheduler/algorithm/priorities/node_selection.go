@@ -17,178 +17,56 @@ limitations under the License.
 package priorities
 
 import (
-	"encoding/json"
 	"fmt"
-	"strings"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
-	client "github.com/influxdata/influxdb1-client/v2"
-	"github.com/iwita/kube-scheduler/customcache"
 	"k8s.io/klog"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
 )
 
-var (
-	nodeSelectionPriority = &CustomAllocationPriority{"NodeSelection", nodeSelectionScorer}
-	//customResourcePriority = &CustomAllocationPriority{"CustomRequestedPriority", customResourceScorer}
-	// LeastRequestedPriorityMap is a priority function that favors nodes with fewer requested resources.
-	// It calculates the percentage of memory and CPU requested by pods scheduled on the node, and
-	// prioritizes based on the minimum of the average of the fraction of requested to capacity.
-	//
-	// Details:
-	// (cpu((capacity-sum(requested))*10/capacity) + memory((capacity-sum(requested))*10/capacity))/2
-	NodeSelectionPriorityMap = nodeSelectionPriority.PriorityMap
-)
-
-func OneScorer(si scorerInput) float64 {
-	return si.metrics[si.metricName]
-}
-
-func calculateWeightedAverageCores(response *client.Response,
-	numberOfRows, numberOfMetrics, numberOfCores int) (map[string]float64, error) {
-	// initialize the metrics map with a constant size
-	metrics := make(map[string]float64, numberOfMetrics)
-	rows := response.Results[0].Series[0]
-	for i := 1; i < len(rows.Columns); i++ {
-		//klog.Infof("Name of column %v : %v\nrange of values: %v\nnumber of rows: %v\nnumber of cores %v\n", i, rows.Columns[i], len(rows.Values), numberOfRows, numberOfCores)
-		for j := 0; j < numberOfRows; j++ {
-			avg := 0.0
-			for k := 0; k < numberOfCores; k++ {
-				val, err := rows.Values[j*numberOfCores+k][i].(json.Number).Float64()
-				if err != nil {
-					klog.Infof("Error while calculating %v", rows.Columns[i])
-					return nil, err
-				}
-				//metrics[rows.Columns[i]] += val * float64(numberOfRows-j)
-				avg += val / float64(numberOfCores)
-			}
-			metrics[rows.Columns[i]] += avg * float64(numberOfRows-j)
-		}
-		metrics[rows.Columns[i]] = metrics[rows.Columns[i]] / float64((numberOfRows * (numberOfRows + 1) / 2))
-		//klog.Infof("%v : %v", rows.Columns[i], metrics[rows.Columns[i]])
-	}
-	// TODO better handling for the returning errors
-	return metrics, nil
-}
-
-// This function does the following:
-// 1. Queries the DB with the provided metrics and cores
-// 2. Calculates and returns the weighted average of each of those metrics
-func queryInfluxDbCores(metrics []string, uuid string, socket,
-	numberOfRows int, cfg Config, c client.Client, cores []int) (*client.Response, error) {
-
-	// calculate the number of rows needed
-	// i.e. 20sec / 0.2s interval => 100rows
-	//numberOfRows := int(float32(time) / cfg.MonitoringSpecs.TimeInterval)
-	// EDIT
-	// This time we will fetch data for multiple cores
-	// so we will need more rows, proportional to the core number
-	// merge all the required columns
-	columns := strings.Join(metrics, ", ")
-
-	// build the cores part of the command
-	var coresPart strings.Builder
-	fmt.Fprintf(&coresPart, "core_id='%d'", cores[0])
-	for i := 1; i < len(cores); i++ {
-		fmt.Fprintf(&coresPart, " or core_id='%d'", cores[i])
-	}
-
-	// build the coommand
-	var command strings.Builder
-	fmt.Fprintf(&command, "SELECT %s from core_metrics where uuid = '%s' and socket_id='%d' and %s order by time desc limit %d", columns, uuid, socket, coresPart.String(), numberOfRows*len(cores))
-	//klog.Infof("The query is: %v", command.String())
-	q := client.NewQuery(command.String(), cfg.Database.Name, "")
-	response, err := c.Query(q)
-	if err != nil {
-		klog.Infof("Error while executing the query: %v", err.Error())
-		return nil, err
+// NewNodeSelectionPriority creates the NodeSelectionPriority map and reduce
+// functions, which look up the cores of each node in the given topology and
+// read their hardware counters from the snapshot of the metrics cache in the
+// priority metadata. While the counters of a node are missing, the nodes are
+// scored as set by degradation, or get the NeutralScore if it is nil.
+func NewNodeSelectionPriority(topo topology.Interface, degradation *monitoring.Degradation) (PriorityMapFunction, PriorityReduceFunction) {
+	nodeSelectionPriority := &CustomAllocationPriority{
+		Name:        "NodeSelection",
+		topology:    topo,
+		scorer:      nodeSelectionScorer,
+		degradation: degradation,
 	}
-	// Calculate the average for the metrics provided
-	return response, nil
+	return nodeSelectionPriority.PriorityMap, nodeSelectionPriority.PriorityReduce
 }
 
-func nodeSelectionScorer(nodeName string) (float64, error) {
-	// check the cache
-	cores, _ := Cores[nodeName]
-
-	//var results map[string]float64
-	// Check the cache
-	customcache.LabCache.Mux.Lock()
-
-	c6res, ok := customcache.LabCache.Cache[nodeName]["c6res"]
+// nodeSelectionScorer scores a node by the number of its idle cores, from
+// snapshot. If lastKnown is positive, the counters up to lastKnown old are
+// used, whether they have expired or not.
+func nodeSelectionScorer(topo topology.Interface, snapshot customcache.Reader, nodeName string, lastKnown time.Duration) (float64, error) {
+	node, ok := topo.Node(nodeName)
 	if !ok {
-		klog.Infof("C6 res is nil")
-	}
-
-	klog.Infof("Node: %v, Socket: %v, Server: %v", nodeName, Sockets[nodeName], Nodes[nodeName])
-	// If the cache has value use it
-	if c6res != -1 {
-
-		customcache.LabCache.Mux.Unlock()
-		//results["c6res"] = socketSum/socketCores
-		//res := calculateScore(scorerInput{metrics: results}, customScoreFn)
-
-
-		//Apply heterogeneity
-		// speed := links[Nodes[nodeName]][0] * links[Nodes[nodeName]][1]
-		// maxFreq := maxSpeed[Nodes[nodeName]]
-		// res = res * float64(speed) * float64(maxFreq)
-
-		// Select Node
-		//klog.Infof("Using the cached values, Node name %s, has score %v\n", nodeName, res)
-		return c6res, nil
-	}
-
-	//read database information
-	var cfg Config
-	err := readFile(&cfg, "/etc/kubernetes/scheduler-monitoringDB.yaml")
-	if err != nil {
-		return 0, err
+		klog.V(4).Infof("Node %v is not part of the topology", nodeName)
+		return 0, nil
 	}
 
-	/*-------------------------------------
-	//TODO read also nodes to uuid mappings for EVOLVE
-	-------------------------------------*/
-
-	// InfluxDB
-	c, err := connectToInfluxDB(cfg)
-	if err != nil {
-		return 0, err
+	if lastKnown > 0 {
+		last, _, ok := snapshot.GetLastKnown(nodeName, lastKnown, "c6res")
+		if !ok {
+			return 0, fmt.Errorf("no core availability of node %v in the last %v", nodeName, lastKnown)
+		}
+		return last["c6res"], nil
 	}
-	// close the connection in the end of execution
-	defer c.Close()
 
-	//Get the uuid of this node in order to query in the database
-	curr_uuid, ok := Nodes[nodeName]
-	socket, _ := Sockets[nodeName]
-	//cores, _ := Cores[nodeName]
-	if len(cores) == 0 {
+	if len(node.Cores) == 0 {
 		return 0.0, nil
 	}
-
-	//klog.Infof("Node %v has %v cores", nodeName, len(cores))
-	if ok {
-
-		metrics := []string{"c6res"}
-		time := 20
-
-		numberOfRows := int(float32(time) / cfg.MonitoringSpecs.TimeInterval)
-		// Select Socket
-		r, err := queryInfluxDbCores(metrics, curr_uuid, socket, numberOfRows, cfg, c, cores)
-		if err != nil {
-			klog.Infof("Error in querying or calculating average: %v", err.Error())
-			return 0, nil
-		}
-
-		results, err := calculateWeightedAverageCores(r, numberOfRows, len(metrics), len(cores))
-
-		res := calculateScore(scorerInput{metricName: "c6res", metrics: results}, OneScorer) * float64(len(cores))
-
-		// Select Node
-
-		klog.Infof("Node name %s, Score %v\n", nodeName, res)
-		return res, nil
-	} else {
-		klog.Infof("Error finding the uuid: %v", ok)
-		return 0, nil
+	// The C6 residency is cached summed over the cores of the node.
+	c6res, ok := snapshot.Get(nodeName, "c6res")
+	if !ok {
+		return 0, notCached(snapshot, "the core availability of node %v is not cached", nodeName)
 	}
+	klog.V(4).Infof("Node %v has score %v", nodeName, c6res)
+	return c6res, nil
 }
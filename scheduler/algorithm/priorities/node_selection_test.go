@@ -0,0 +1,88 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priorities
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring/fake"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+)
+
+func TestNodeSelectionPriority(t *testing.T) {
+	nodes := []*v1.Node{
+		makeNode("kube-01", 4000, 10000),
+		makeNode("kube-03", 4000, 10000),
+		makeNode("kube-09", 4000, 10000),
+	}
+	tests := []struct {
+		// source refreshes the cache before the nodes are scored.
+		source       monitoring.MetricsSource
+		cached       map[string]float64
+		expectedList schedulerapi.HostPriorityList
+		name         string
+	}{
+		{
+			// The score is the C6 residency summed over the cores of the node.
+			source:       newTestMetricsSource(),
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 0.4}, {Host: "kube-03", Score: 1.8}, {Host: "kube-09", Score: 0}},
+			name:         "idle cores",
+		},
+		{
+			// kube-03 is not cached and gets the neutral score rather than
+			// waiting for the backend.
+			cached:       map[string]float64{"kube-01": 1.5},
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 1.5}, {Host: "kube-03", Score: NeutralScore}, {Host: "kube-09", Score: 0}},
+			name:         "cached C6 residency",
+		},
+		{
+			source:       &fake.MetricsSource{Err: fmt.Errorf("connection refused")},
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 0}, {Host: "kube-03", Score: 0}, {Host: "kube-09", Score: 0}},
+			name:         "monitoring backend unavailable",
+		},
+		{
+			source:       &fake.MetricsSource{Err: &monitoring.DataError{Reason: monitoring.StaleData, Message: "the most recent sample is 1m0s old"}},
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: NeutralScore}, {Host: "kube-03", Score: NeutralScore}, {Host: "kube-09", Score: 0}},
+			name:         "stale data",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := customcache.New(customcache.DefaultTTL)
+			if test.source != nil {
+				customcache.NewRefresher(cache, newTestTopology(), test.source, time.Minute).Refresh()
+			}
+			for node, c6res := range test.cached {
+				cache.Set(node, "c6res", c6res)
+			}
+			nodeNameToInfo := schedulernodeinfo.CreateNodeNameToInfoMap(nil, nodes)
+			mapFn, reduceFn := NewNodeSelectionPriority(newTestTopology(), nil)
+			list, err := priorityFunction(mapFn, reduceFn, NewMetricsPriorityMetadataProducer(cache)(&v1.Pod{}, nodeNameToInfo))(&v1.Pod{}, nodeNameToInfo, nodes)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			expectHostPriorities(t, test.expectedList, list)
+		})
+	}
+}
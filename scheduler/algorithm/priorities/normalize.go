@@ -0,0 +1,123 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priorities
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+)
+
+// zScoreClip is the number of standard deviations around the mean that
+// NormalizeZScore maps to 0-MaxPriority.
+const zScoreClip = 2
+
+// NormalizeScores maps the scores of result to [0, MaxPriority] with the given
+// method. When all the nodes score the same, they all get MaxPriority.
+func NormalizeScores(method schedulerapi.ScoreNormalization, result schedulerapi.HostPriorityList) error {
+	if len(result) == 0 {
+		return nil
+	}
+	maxPriority := float64(schedulerapi.MaxPriority)
+	switch method {
+	case schedulerapi.NormalizeMinMax:
+		lowest, highest := math.Inf(1), math.Inf(-1)
+		for _, hp := range result {
+			lowest = math.Min(lowest, hp.Score)
+			highest = math.Max(highest, hp.Score)
+		}
+		for i := range result {
+			if highest == lowest {
+				result[i].Score = maxPriority
+				continue
+			}
+			result[i].Score = maxPriority * (result[i].Score - lowest) / (highest - lowest)
+		}
+	case schedulerapi.NormalizeRank:
+		var distinct []float64
+		seen := map[float64]bool{}
+		for _, hp := range result {
+			if !seen[hp.Score] {
+				seen[hp.Score] = true
+				distinct = append(distinct, hp.Score)
+			}
+		}
+		sort.Float64s(distinct)
+		for i := range result {
+			if len(distinct) == 1 {
+				result[i].Score = maxPriority
+				continue
+			}
+			rank := sort.SearchFloat64s(distinct, result[i].Score)
+			result[i].Score = maxPriority * float64(rank) / float64(len(distinct)-1)
+		}
+	case schedulerapi.NormalizeZScore:
+		var mean float64
+		for _, hp := range result {
+			mean += hp.Score
+		}
+		mean /= float64(len(result))
+		var variance float64
+		for _, hp := range result {
+			variance += (hp.Score - mean) * (hp.Score - mean)
+		}
+		stddev := math.Sqrt(variance / float64(len(result)))
+		for i := range result {
+			if stddev == 0 {
+				result[i].Score = maxPriority
+				continue
+			}
+			z := math.Max(math.Min((result[i].Score-mean)/stddev, zScoreClip), -zScoreClip)
+			result[i].Score = maxPriority * (z + zScoreClip) / (2 * zScoreClip)
+		}
+	default:
+		return fmt.Errorf("unknown score normalization %s", method)
+	}
+	return nil
+}
+
+// NewNormalizedReduce returns a PriorityReduceFunction running reduce, if
+// any, then normalizing the scores with the given method.
+func NewNormalizedReduce(method schedulerapi.ScoreNormalization, reduce PriorityReduceFunction) PriorityReduceFunction {
+	return func(pod *v1.Pod, meta interface{}, nodeNameToInfo map[string]*schedulernodeinfo.NodeInfo, result schedulerapi.HostPriorityList) error {
+		if reduce != nil {
+			if err := reduce(pod, meta, nodeNameToInfo, result); err != nil {
+				return err
+			}
+		}
+		return NormalizeScores(method, result)
+	}
+}
+
+// NewNormalizedFunction returns a PriorityFunction normalizing the scores of
+// function with the given method.
+func NewNormalizedFunction(method schedulerapi.ScoreNormalization, function PriorityFunction) PriorityFunction {
+	return func(pod *v1.Pod, nodeNameToInfo map[string]*schedulernodeinfo.NodeInfo, nodes []*v1.Node) (schedulerapi.HostPriorityList, error) {
+		result, err := function(pod, nodeNameToInfo, nodes)
+		if err != nil {
+			return nil, err
+		}
+		if err := NormalizeScores(method, result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priorities
+
+import (
+	"testing"
+
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+)
+
+func TestNormalizeScores(t *testing.T) {
+	// Raw scores of the magnitude of CustomRequestedPriority.
+	newResult := func() schedulerapi.HostPriorityList {
+		return []schedulerapi.HostPriority{{Host: "kube-01", Score: 400}, {Host: "kube-02", Score: 100}, {Host: "kube-03", Score: 100}, {Host: "kube-04", Score: 40000}}
+	}
+
+	tests := []struct {
+		name         string
+		method       schedulerapi.ScoreNormalization
+		result       schedulerapi.HostPriorityList
+		expectedList schedulerapi.HostPriorityList
+		expectErr    bool
+	}{
+		{
+			name:         "min-max",
+			method:       schedulerapi.NormalizeMinMax,
+			result:       newResult(),
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 3000.0 / 39900}, {Host: "kube-02", Score: 0}, {Host: "kube-03", Score: 0}, {Host: "kube-04", Score: 10}},
+		},
+		{
+			// The outlier doesn't squash the others.
+			name:         "rank",
+			method:       schedulerapi.NormalizeRank,
+			result:       newResult(),
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 5}, {Host: "kube-02", Score: 0}, {Host: "kube-03", Score: 0}, {Host: "kube-04", Score: 10}},
+		},
+		{
+			// The mean is 2.5 and the standard deviation 1.5.
+			name:         "z-score",
+			method:       schedulerapi.NormalizeZScore,
+			result:       []schedulerapi.HostPriority{{Host: "kube-01", Score: 1}, {Host: "kube-02", Score: 4}},
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 2.5}, {Host: "kube-02", Score: 7.5}},
+		},
+		{
+			// The outlier, 3 standard deviations above the mean, is clipped to 2.
+			name:         "clipped z-score",
+			method:       schedulerapi.NormalizeZScore,
+			result:       []schedulerapi.HostPriority{{Host: "kube-01", Score: 0}, {Host: "kube-02", Score: 0}, {Host: "kube-03", Score: 0}, {Host: "kube-04", Score: 0}, {Host: "kube-05", Score: 0}, {Host: "kube-06", Score: 0}, {Host: "kube-07", Score: 0}, {Host: "kube-08", Score: 0}, {Host: "kube-09", Score: 0}, {Host: "kube-10", Score: 90}},
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 25.0 / 6}, {Host: "kube-02", Score: 25.0 / 6}, {Host: "kube-03", Score: 25.0 / 6}, {Host: "kube-04", Score: 25.0 / 6}, {Host: "kube-05", Score: 25.0 / 6}, {Host: "kube-06", Score: 25.0 / 6}, {Host: "kube-07", Score: 25.0 / 6}, {Host: "kube-08", Score: 25.0 / 6}, {Host: "kube-09", Score: 25.0 / 6}, {Host: "kube-10", Score: 10}},
+		},
+		{
+			name:         "equal scores",
+			method:       schedulerapi.NormalizeZScore,
+			result:       []schedulerapi.HostPriority{{Host: "kube-01", Score: 3}, {Host: "kube-02", Score: 3}},
+			expectedList: []schedulerapi.HostPriority{{Host: "kube-01", Score: 10}, {Host: "kube-02", Score: 10}},
+		},
+		{
+			name:      "unknown method",
+			method:    "Softmax",
+			result:    newResult(),
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := NormalizeScores(test.method, test.result)
+			if (err != nil) != test.expectErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !test.expectErr {
+				expectHostPriorities(t, test.expectedList, test.result)
+			}
+		})
+	}
+}
@@ -55,4 +55,10 @@ const (
 	CustomRequestedPriority = "CustomRequestedPriority"
 	// Node Selection Priority
 	NodeSelectionPriority = "NodeSelectionPriority"
+	// EnergyPriority defines the name of prioritizer function that prioritizes the sockets
+	// a pod is projected to consume the least energy on until it completes.
+	EnergyPriority = "EnergyPriority"
+	// LLCContentionPriority defines the name of prioritizer function that prioritizes the sockets
+	// where the pod would least contend for the last-level cache with the pods already there.
+	LLCContentionPriority = "LLCContentionPriority"
 )
@@ -18,6 +18,8 @@ package priorities
 
 import (
 	"fmt"
+	"math"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	utilfeature "k8s.io/apiserver/pkg/util/feature"
@@ -25,7 +27,11 @@ import (
 	"k8s.io/kubernetes/pkg/features"
 	priorityutil "k8s.io/kubernetes/pkg/scheduler/algorithm/priorities/util"
 	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/metrics"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
 	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
 )
 
 // ResourceAllocationPriority contains information to calculate resource allocation priority.
@@ -34,9 +40,45 @@ type ResourceAllocationPriority struct {
 	scorer func(requested, allocable *schedulernodeinfo.Resource, includeVolumes bool, requestedVolumes int, allocatableVolumes int) int64
 }
 
+// NeutralScore is the score of a node whose hardware counters are unusable,
+// e.g. because the monitoring backend is down or has no recent samples.
+const NeutralScore float64 = 0
+
+// degradedScore marks, in the result of the map function of a
+// CustomAllocationPriority, a node left to its reduce function to be scored
+// by its resources.
+var degradedScore = math.NaN()
+
+// CustomAllocationPriority contains information to calculate priorities from
+// the hardware counters of the server hosting each node.
 type CustomAllocationPriority struct {
-	Name   string
-	scorer func(nodeName string) (float64, error)
+	Name     string
+	topology topology.Interface
+	scorer   func(topo topology.Interface, snapshot customcache.Reader, nodeName string, lastKnown time.Duration) (float64, error)
+	// degradation is how the nodes are scored while their counters are
+	// missing.
+	degradation *monitoring.Degradation
+}
+
+// notCached returns the error of counters missing from snapshot: the
+// UnavailableError of the last refresh while the monitoring backend is down,
+// or else a DataError with no data, e.g. before the first refresh or for a
+// node added since.
+func notCached(snapshot customcache.Reader, format string, args ...interface{}) error {
+	if err := snapshot.Unavailable(); err != nil {
+		return err
+	}
+	return &monitoring.DataError{Reason: monitoring.NoData, Message: fmt.Sprintf(format, args...)}
+}
+
+// missingCounters returns whether err is about counters missing from the
+// cache or a monitoring backend that is unavailable, which the nodes are
+// degraded for.
+func missingCounters(err error) bool {
+	if reason, ok := monitoring.ReasonOf(err); ok && reason == monitoring.NoData {
+		return true
+	}
+	return monitoring.IsUnavailable(err)
 }
 
 // PriorityMap priorities nodes according to the resource allocations on the node.
@@ -116,7 +158,6 @@ func (r *CustomAllocationPriority) PriorityMap(
 
 	//requested.MilliCPU += nodeInfo.NonZeroRequest().MilliCPU
 	//requested.Memory += nodeInfo.NonZeroRequest().Memory
-	var score float64
 	// Check if the pod has volumes and this could be added to scorer function for balanced resource allocation.
 	// if len(pod.Spec.Volumes) >= 0 && utilfeature.DefaultFeatureGate.Enabled(features.BalanceAttachedNodeVolumes) && nodeInfo.TransientInfo != nil {
 	// 	score = r.scorer(&requested, &allocatable, true, nodeInfo.TransientInfo.TransNodeInfo.RequestedVolumes, nodeInfo.TransientInfo.TransNodeInfo.AllocatableVolumesCount)
@@ -124,7 +165,16 @@ func (r *CustomAllocationPriority) PriorityMap(
 	// 	score = r.scorer(&requested, &allocatable, false, 0, 0)
 	// }
 
-	score, _ = r.scorer(node.Name)
+	// The counters of the scheduling cycle are read without locking the cache.
+	snapshot := metricsReader(meta)
+	score, err := r.scorer(r.topology, snapshot, node.Name, 0)
+	if err != nil {
+		if r.degradation != nil && missingCounters(err) {
+			return r.degrade(pod, meta, nodeInfo, snapshot, err)
+		}
+		klog.Warningf("%v: using the neutral score %v for node %v: %v", r.Name, NeutralScore, node.Name, err)
+		score = NeutralScore
+	}
 
 	// if klog.V(10) {
 	// 	if len(pod.Spec.Volumes) >= 0 && utilfeature.DefaultFeatureGate.Enabled(features.BalanceAttachedNodeVolumes) && nodeInfo.TransientInfo != nil {
@@ -159,6 +209,76 @@ func (r *CustomAllocationPriority) PriorityMap(
 	// }, nil
 }
 
+// degrade scores a node as set by the degradation mode, after err left it
+// without counters. The nodes without last-known-good counters
+// are marked with the degradedScore, for PriorityReduce to score them by
+// their resources. FailClosed only fails the pod while the monitoring backend
+// is unavailable; a node merely missing from the cache is scored by its
+// resources.
+func (r *CustomAllocationPriority) degrade(pod *v1.Pod, meta interface{}, nodeInfo *schedulernodeinfo.NodeInfo, snapshot customcache.Reader, err error) (schedulerapi.HostPriority, error) {
+	node := nodeInfo.Node()
+	switch r.degradation.Mode {
+	case monitoring.FailClosed:
+		if monitoring.IsUnavailable(err) {
+			metrics.DegradedScores.WithLabelValues(string(monitoring.FailClosed)).Inc()
+			return schedulerapi.HostPriority{}, err
+		}
+	case monitoring.LastKnownGood:
+		score, lastKnownErr := r.scorer(r.topology, snapshot, node.Name, r.degradation.MaxAge)
+		if lastKnownErr == nil {
+			metrics.DegradedScores.WithLabelValues(string(monitoring.LastKnownGood)).Inc()
+			return schedulerapi.HostPriority{Host: node.Name, Score: score}, nil
+		}
+		klog.V(4).Infof("%v: no last known counters for node %v: %v", r.Name, node.Name, lastKnownErr)
+	}
+	klog.V(4).Infof("%v: node %v is left to be scored by its resources: %v", r.Name, node.Name, err)
+	return schedulerapi.HostPriority{Host: node.Name, Score: degradedScore}, nil
+}
+
+// PriorityReduce scores all the nodes by their resources once any of them is
+// marked with the degradedScore, since the scores of the resource priorities
+// do not compare with those of the hardware counters. The degradation is thus
+// decided once per scheduling cycle, for all the nodes.
+func (r *CustomAllocationPriority) PriorityReduce(pod *v1.Pod, meta interface{}, nodeNameToInfo map[string]*schedulernodeinfo.NodeInfo, result schedulerapi.HostPriorityList) error {
+	degraded := 0
+	for _, hp := range result {
+		if math.IsNaN(hp.Score) {
+			degraded++
+		}
+	}
+	if degraded == 0 {
+		return nil
+	}
+	klog.V(4).Infof("%v: scoring all %d nodes by their resources, %d have no usable counters", r.Name, len(result), degraded)
+	for i := range result {
+		nodeInfo, ok := nodeNameToInfo[result[i].Host]
+		if !ok {
+			return fmt.Errorf("node %v not found", result[i].Host)
+		}
+		score, err := resourceScore(pod, meta, nodeInfo)
+		if err != nil {
+			return err
+		}
+		metrics.DegradedScores.WithLabelValues(string(monitoring.FallbackToResources)).Inc()
+		result[i].Score = score
+	}
+	return nil
+}
+
+// resourceScore scores a node by the least requested and balanced resource
+// priorities.
+func resourceScore(pod *v1.Pod, meta interface{}, nodeInfo *schedulernodeinfo.NodeInfo) (float64, error) {
+	least, err := leastResourcePriority.PriorityMap(pod, meta, nodeInfo)
+	if err != nil {
+		return 0, err
+	}
+	balanced, err := balancedResourcePriority.PriorityMap(pod, meta, nodeInfo)
+	if err != nil {
+		return 0, err
+	}
+	return (least.Score + balanced.Score) / 2, nil
+}
+
 func getNonZeroRequests(pod *v1.Pod) *schedulernodeinfo.Resource {
 	result := &schedulernodeinfo.Resource{}
 	for i := range pod.Spec.Containers {
@@ -0,0 +1,392 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priorities
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode"
+)
+
+// ScoreExpression is a parsed arithmetic expression over named variables,
+// such as the metrics of a socket. Expressions support numbers, variables,
+// the + - * / operators, parentheses and the functions listed in
+// scoreFunctions, e.g. "ipc / (mem_read + mem_write) * max(c6res, 0.1)".
+// Evaluating an expression has no side effects.
+type ScoreExpression struct {
+	source    string
+	root      exprNode
+	variables []string
+}
+
+// ParseScoreExpression parses an arithmetic expression.
+func ParseScoreExpression(expr string) (*ScoreExpression, error) {
+	p := &exprParser{input: expr, variables: map[string]bool{}}
+	p.next()
+	root, err := p.parseSum()
+	if err != nil {
+		return nil, fmt.Errorf("invalid score expression %q: %v", expr, err)
+	}
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("invalid score expression %q: unexpected %s at offset %d", expr, p.tok, p.tok.pos)
+	}
+	variables := make([]string, 0, len(p.variables))
+	for name := range p.variables {
+		variables = append(variables, name)
+	}
+	sort.Strings(variables)
+	return &ScoreExpression{source: expr, root: root, variables: variables}, nil
+}
+
+// String returns the source of the expression.
+func (e *ScoreExpression) String() string {
+	return e.source
+}
+
+// Variables returns the sorted names of the variables the expression refers to.
+func (e *ScoreExpression) Variables() []string {
+	return e.variables
+}
+
+// Evaluate computes the expression with the given values of its variables.
+// It fails if a variable has no value or if the result is not a finite number,
+// e.g. because of a division by zero.
+func (e *ScoreExpression) Evaluate(vars map[string]float64) (float64, error) {
+	res, err := e.root.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(res) || math.IsInf(res, 0) {
+		return 0, fmt.Errorf("%q is not a finite number", e.source)
+	}
+	return res, nil
+}
+
+// scoreFunction is a function callable from a score expression, with its
+// number of arguments. A negative arity means at least -arity arguments.
+type scoreFunction struct {
+	arity int
+	fn    func(args []float64) float64
+}
+
+var scoreFunctions = map[string]scoreFunction{
+	"min": {-1, func(args []float64) float64 {
+		res := args[0]
+		for _, arg := range args[1:] {
+			res = math.Min(res, arg)
+		}
+		return res
+	}},
+	"max": {-1, func(args []float64) float64 {
+		res := args[0]
+		for _, arg := range args[1:] {
+			res = math.Max(res, arg)
+		}
+		return res
+	}},
+	"abs":  {1, func(args []float64) float64 { return math.Abs(args[0]) }},
+	"sqrt": {1, func(args []float64) float64 { return math.Sqrt(args[0]) }},
+	"log":  {1, func(args []float64) float64 { return math.Log(args[0]) }},
+	"pow":  {2, func(args []float64) float64 { return math.Pow(args[0], args[1]) }},
+}
+
+type exprNode interface {
+	eval(vars map[string]float64) (float64, error)
+}
+
+type numberNode float64
+
+func (n numberNode) eval(map[string]float64) (float64, error) {
+	return float64(n), nil
+}
+
+type variableNode string
+
+func (n variableNode) eval(vars map[string]float64) (float64, error) {
+	val, ok := vars[string(n)]
+	if !ok {
+		return 0, fmt.Errorf("no value for %s", string(n))
+	}
+	return val, nil
+}
+
+type negateNode struct {
+	operand exprNode
+}
+
+func (n negateNode) eval(vars map[string]float64) (float64, error) {
+	val, err := n.operand.eval(vars)
+	return -val, err
+}
+
+type binaryNode struct {
+	op          byte
+	left, right exprNode
+}
+
+func (n binaryNode) eval(vars map[string]float64) (float64, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	default:
+		if right == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return left / right, nil
+	}
+}
+
+type callNode struct {
+	fn   scoreFunction
+	args []exprNode
+}
+
+func (n callNode) eval(vars map[string]float64) (float64, error) {
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		val, err := arg.eval(vars)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = val
+	}
+	return n.fn.fn(args), nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOperator
+	tokInvalid
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// exprParser is a recursive descent parser of score expressions:
+//
+//	sum     = product { ("+" | "-") product }
+//	product = unary { ("*" | "/") unary }
+//	unary   = "-" unary | primary
+//	primary = number | ident | ident "(" sum { "," sum } ")" | "(" sum ")"
+type exprParser struct {
+	input     string
+	pos       int
+	tok       token
+	variables map[string]bool
+}
+
+// next reads the next token of the input.
+func (p *exprParser) next() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos == len(p.input) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+	c := p.input[p.pos]
+	switch {
+	case c == '.' || isDigit(c):
+		for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		// Exponents, e.g. 1e-3.
+		if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+			p.pos++
+			if p.pos < len(p.input) && (p.input[p.pos] == '+' || p.input[p.pos] == '-') {
+				p.pos++
+			}
+			for p.pos < len(p.input) && isDigit(p.input[p.pos]) {
+				p.pos++
+			}
+		}
+		p.tok = token{kind: tokNumber, text: p.input[start:p.pos], pos: start}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.input) && (p.input[p.pos] == '_' || isLetter(p.input[p.pos]) || isDigit(p.input[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, text: p.input[start:p.pos], pos: start}
+	case c == '+' || c == '-' || c == '*' || c == '/' || c == '(' || c == ')' || c == ',':
+		p.pos++
+		p.tok = token{kind: tokOperator, text: p.input[start:p.pos], pos: start}
+	default:
+		p.pos++
+		p.tok = token{kind: tokInvalid, text: p.input[start:p.pos], pos: start}
+	}
+}
+
+func (p *exprParser) unexpected() error {
+	return fmt.Errorf("unexpected %s at offset %d", p.tok, p.tok.pos)
+}
+
+func (p *exprParser) isOperator(ops ...string) bool {
+	if p.tok.kind != tokOperator {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("+", "-") {
+		op := p.tok.text[0]
+		p.next()
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("*", "/") {
+		op := p.tok.text[0]
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isOperator("-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negateNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokNumber:
+		val, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at offset %d", tok, tok.pos)
+		}
+		p.next()
+		return numberNode(val), nil
+	case tok.kind == tokIdent:
+		p.next()
+		if !p.isOperator("(") {
+			p.variables[tok.text] = true
+			return variableNode(tok.text), nil
+		}
+		return p.parseCall(tok)
+	case p.isOperator("("):
+		p.next()
+		node, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOperator(")") {
+			return nil, p.unexpected()
+		}
+		p.next()
+		return node, nil
+	default:
+		return nil, p.unexpected()
+	}
+}
+
+// parseCall parses the arguments of a call to the function named by tok,
+// starting at the opening parenthesis.
+func (p *exprParser) parseCall(tok token) (exprNode, error) {
+	fn, ok := scoreFunctions[tok.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s at offset %d", tok, tok.pos)
+	}
+	p.next()
+	var args []exprNode
+	for {
+		arg, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.isOperator(",") {
+			break
+		}
+		p.next()
+	}
+	if !p.isOperator(")") {
+		return nil, p.unexpected()
+	}
+	p.next()
+	if (fn.arity >= 0 && len(args) != fn.arity) || (fn.arity < 0 && len(args) < -fn.arity) {
+		return nil, fmt.Errorf("wrong number of arguments for %s at offset %d: %d", tok.text, tok.pos, len(args))
+	}
+	return callNode{fn: fn, args: args}, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
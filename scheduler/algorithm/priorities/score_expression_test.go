@@ -0,0 +1,93 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priorities
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseScoreExpression(t *testing.T) {
+	tests := []struct {
+		expr      string
+		variables []string
+		expectErr bool
+	}{
+		{expr: "ipc / (mem_read + mem_write)", variables: []string{"ipc", "mem_read", "mem_write"}},
+		{expr: "-1.5e-1 * max(c6res, 0.1, x2)", variables: []string{"c6res", "x2"}},
+		{expr: "pow(ipc, 2) - sqrt(abs(-4)) + log(1)", variables: []string{"ipc"}},
+		{expr: "2", variables: []string{}},
+		{expr: "", expectErr: true},
+		{expr: "ipc /", expectErr: true},
+		{expr: "(ipc", expectErr: true},
+		{expr: "ipc)", expectErr: true},
+		{expr: "ipc ^ 2", expectErr: true},
+		{expr: "exp(ipc)", expectErr: true},
+		{expr: "pow(ipc)", expectErr: true},
+		{expr: "min()", expectErr: true},
+		{expr: "1.2.3", expectErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			expr, err := ParseScoreExpression(test.expr)
+			if (err != nil) != test.expectErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && !reflect.DeepEqual(test.variables, expr.Variables()) {
+				t.Errorf("expected variables %v, got %v", test.variables, expr.Variables())
+			}
+		})
+	}
+}
+
+func TestEvaluateScoreExpression(t *testing.T) {
+	vars := map[string]float64{"ipc": 2, "mem_read": 0.5, "mem_write": 0.5, "zero": 0}
+	tests := []struct {
+		expr      string
+		expected  float64
+		expectErr bool
+	}{
+		{expr: "ipc / (mem_read + mem_write)", expected: 2},
+		{expr: "1 + 2 * 3 - 4 / 2", expected: 5},
+		{expr: "(1 + 2) * 3", expected: 9},
+		{expr: "8 / 4 / 2", expected: 1},
+		{expr: "--ipc", expected: 2},
+		{expr: "-ipc * 3", expected: -6},
+		{expr: "min(ipc, 3, mem_read) + max(1, ipc)", expected: 2.5},
+		{expr: "pow(ipc, 3) + sqrt(4) + abs(-1)", expected: 11},
+		{expr: "ipc / zero", expectErr: true},
+		{expr: "log(zero)", expectErr: true},
+		{expr: "sqrt(-1)", expectErr: true},
+		{expr: "l3m", expectErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			expr, err := ParseScoreExpression(test.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := expr.Evaluate(vars)
+			if (err != nil) != test.expectErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && math.Abs(res-test.expected) > 1e-9 {
+				t.Errorf("expected %v, got %v", test.expected, res)
+			}
+		})
+	}
+}
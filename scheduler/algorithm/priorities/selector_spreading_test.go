@@ -350,7 +350,8 @@ func TestSelectorSpreadPriority(t *testing.T) {
 				schedulertesting.FakeServiceLister(test.services),
 				schedulertesting.FakeControllerLister(test.rcs),
 				schedulertesting.FakeReplicaSetLister(test.rss),
-				schedulertesting.FakeStatefulSetLister(test.sss))
+				schedulertesting.FakeStatefulSetLister(test.sss),
+				nil)
 			metaData := metaDataProducer(test.pod, nodeNameToInfo)
 
 			ttp := priorityFunction(selectorSpread.CalculateSpreadPriorityMap, selectorSpread.CalculateSpreadPriorityReduce, metaData)
@@ -586,7 +587,8 @@ func TestZoneSelectorSpreadPriority(t *testing.T) {
 				schedulertesting.FakeServiceLister(test.services),
 				schedulertesting.FakeControllerLister(test.rcs),
 				schedulertesting.FakeReplicaSetLister(test.rss),
-				schedulertesting.FakeStatefulSetLister(test.sss))
+				schedulertesting.FakeStatefulSetLister(test.sss),
+				nil)
 			metaData := metaDataProducer(test.pod, nodeNameToInfo)
 			ttp := priorityFunction(selectorSpread.CalculateSpreadPriorityMap, selectorSpread.CalculateSpreadPriorityReduce, metaData)
 			list, err := ttp(test.pod, nodeNameToInfo, makeLabeledNodeList(labeledNodes))
@@ -773,7 +775,8 @@ func TestZoneSpreadPriority(t *testing.T) {
 				schedulertesting.FakeServiceLister(test.services),
 				schedulertesting.FakeControllerLister(rcs),
 				schedulertesting.FakeReplicaSetLister(rss),
-				schedulertesting.FakeStatefulSetLister(sss))
+				schedulertesting.FakeStatefulSetLister(sss),
+				nil)
 			metaData := metaDataProducer(test.pod, nodeNameToInfo)
 			ttp := priorityFunction(zoneSpread.CalculateAntiAffinityPriorityMap, zoneSpread.CalculateAntiAffinityPriorityReduce, metaData)
 			list, err := ttp(test.pod, nodeNameToInfo, makeLabeledNodeList(test.nodes))
@@ -25,7 +25,9 @@ import (
 	"k8s.io/kubernetes/pkg/features"
 	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
 	"k8s.io/kubernetes/pkg/scheduler/algorithm/priorities"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
 	"k8s.io/kubernetes/pkg/scheduler/factory"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
 )
 
 const (
@@ -103,6 +105,8 @@ func registerAlgorithmProvider(predSet, priSet sets.String) {
 	// Cluster autoscaler friendly scheduling algorithm.
 	factory.RegisterAlgorithmProvider(ClusterAutoscalerProvider, predSet,
 		copyAndReplace(priSet, priorities.LeastRequestedPriority, priorities.MostRequestedPriority))
+	factory.RegisterAlgorithmProviderScoringStages(factory.DefaultProvider, defaultScoringStages())
+	factory.RegisterAlgorithmProviderScoringStages(ClusterAutoscalerProvider, defaultScoringStages())
 }
 
 func defaultPriorities() sets.String {
@@ -115,11 +119,29 @@ func defaultPriorities() sets.String {
 		priorities.NodeAffinityPriority,
 		priorities.TaintTolerationPriority,
 		priorities.ImageLocalityPriority,
-		priorities.CustomRequestedPriority,
-		priorities.NodeSelectionPriority,
 	)
 }
 
+// defaultScoringStages selects the best socket by its hardware counters, then
+// the best node on that socket. The raw counter scores are min-max normalized
+// like the other priorities. The default priorities only run in a policy
+// stage without priorities. A policy may weigh the EnergyPriority in the
+// socket stage to trade performance for energy, or the LLCContentionPriority
+// to keep cache-sensitive pods away from cache-polluting ones.
+func defaultScoringStages() []schedulerapi.ScoringStage {
+	return []schedulerapi.ScoringStage{
+		{
+			Name:       "socket",
+			Priorities: []schedulerapi.PriorityPolicy{{Name: priorities.CustomRequestedPriority, Weight: 100, Normalization: schedulerapi.NormalizeMinMax}},
+			Selection:  schedulerapi.StageSelection{Rule: schedulerapi.KeepBestGroup, Level: string(topology.LevelSocket)},
+		},
+		{
+			Name:       "node",
+			Priorities: []schedulerapi.PriorityPolicy{{Name: priorities.NodeSelectionPriority, Weight: 100, Normalization: schedulerapi.NormalizeMinMax}},
+		},
+	}
+}
+
 func copyAndReplace(set sets.String, replaceWhat, replaceWith string) sets.String {
 	result := sets.NewString(set.List()...)
 	if result.Has(replaceWhat) {
@@ -128,4 +128,12 @@ func init() {
 			return predicates.NewVolumeBindingPredicate(args.VolumeBinder)
 		},
 	)
+
+	// Fit is determined by the memory bandwidth left on the socket of the node.
+	factory.RegisterFitPredicateFactory(
+		predicates.CheckMemoryBandwidthPred,
+		func(args factory.PluginFactoryArgs) predicates.FitPredicate {
+			return predicates.NewMemoryBandwidthPredicate(args.Topology, args.MetricsCache, args.Profiles)
+		},
+	)
 }
@@ -27,7 +27,7 @@ func init() {
 	// Register functions that extract metadata used by priorities computations.
 	factory.RegisterPriorityMetadataProducerFactory(
 		func(args factory.PluginFactoryArgs) priorities.PriorityMetadataProducer {
-			return priorities.NewPriorityMetadataFactory(args.ServiceLister, args.ControllerLister, args.ReplicaSetLister, args.StatefulSetLister)
+			return priorities.NewPriorityMetadataFactory(args.ServiceLister, args.ControllerLister, args.ReplicaSetLister, args.StatefulSetLister, args.MetricsCache)
 		})
 
 	// ServiceSpreadingPriority is a priority config factory that spreads pods by minimizing
@@ -80,10 +80,51 @@ func init() {
 	factory.RegisterPriorityFunction2(priorities.LeastRequestedPriority, priorities.LeastRequestedPriorityMap, nil, 1)
 
 	// Prioritize nodes by custom function from custom metrics
-	factory.SocketRegisterPriorityFunction2(priorities.CustomRequestedPriority, priorities.CustomRequestedPriorityMap, nil, 1000000, true)
+	factory.SocketRegisterPriorityConfigFactory(
+		priorities.CustomRequestedPriority,
+		factory.PriorityConfigFactory{
+			MapReduceFunction: func(args factory.PluginFactoryArgs) (priorities.PriorityMapFunction, priorities.PriorityReduceFunction) {
+				return priorities.NewCustomRequestedPriority(args.Topology, priorities.DefaultCustomScore, args.Degradation)
+			},
+			Weight: 1,
+		},
+	)
 
-	//
-	factory.RegisterPriorityFunction2(priorities.NodeSelectionPriority, priorities.NodeSelectionPriorityMap, nil, 1000000)
+	// Prioritizes the sockets a pod is projected to consume the least energy on,
+	// from the package power and the profile of the pod.
+	factory.RegisterPriorityConfigFactory(
+		priorities.EnergyPriority,
+		factory.PriorityConfigFactory{
+			MapReduceFunction: func(args factory.PluginFactoryArgs) (priorities.PriorityMapFunction, priorities.PriorityReduceFunction) {
+				return priorities.NewEnergyPriority(args.Topology, args.Profiles)
+			},
+			Weight: 1,
+		},
+	)
+
+	// Prioritizes the sockets where the pod would least contend for the
+	// last-level cache, from the cache behaviour of its profile and of the
+	// profiles of the pods already there.
+	factory.RegisterPriorityConfigFactory(
+		priorities.LLCContentionPriority,
+		factory.PriorityConfigFactory{
+			MapReduceFunction: func(args factory.PluginFactoryArgs) (priorities.PriorityMapFunction, priorities.PriorityReduceFunction) {
+				return priorities.NewLLCContentionPriority(args.Topology, args.Profiles, priorities.DefaultLLCContention)
+			},
+			Weight: 1,
+		},
+	)
+
+	// Selects the node on the winning socket
+	factory.RegisterPriorityConfigFactory(
+		priorities.NodeSelectionPriority,
+		factory.PriorityConfigFactory{
+			MapReduceFunction: func(args factory.PluginFactoryArgs) (priorities.PriorityMapFunction, priorities.PriorityReduceFunction) {
+				return priorities.NewNodeSelectionPriority(args.Topology, args.Degradation)
+			},
+			Weight: 1,
+		},
+	)
 	// Prioritizes nodes to help achieve balanced resource usage
 	factory.RegisterPriorityFunction2(priorities.BalancedResourceAllocation, priorities.BalancedResourceAllocationMap, nil, 1)
 
@@ -46,6 +46,14 @@ type Policy struct {
 	// When the flag is set to false, scheduler skips checking the rest
 	// of the predicates after it finds one predicate that failed.
 	AlwaysCheckAllPredicates bool `json:"alwaysCheckAllPredicates"`
+
+	// ScoringStages score the feasible nodes in successive stages, each
+	// stage narrowing down the nodes scored by the next one.
+	// If unspecified, the stages of the default algorithm provider are used
+	// when Priorities is unspecified too, otherwise Priorities are run as a
+	// single stage. The policy is the only way to configure the stages; the
+	// component configuration has no scoring stages.
+	ScoringStages []ScoringStage `json:"scoringStages,omitempty"`
 }
 
 // PredicatePolicy describes a struct of a predicate policy.
@@ -69,6 +77,69 @@ type PriorityPolicy struct {
 	Weight int `json:"weight"`
 	// Holds the parameters to configure the given priority function
 	Argument *PriorityArgument `json:"argument"`
+	// Normalization maps the scores of the priority function to the range
+	// 0-MaxPriority before they are weighted. The scores are kept as they are
+	// if empty.
+	Normalization ScoreNormalization `json:"normalization,omitempty"`
+}
+
+// ScoreNormalization is the method normalizing the scores of a priority
+// function.
+type ScoreNormalization string
+
+const (
+	// NormalizeMinMax maps the scores linearly, the lowest to 0 and the
+	// highest to MaxPriority.
+	NormalizeMinMax ScoreNormalization = "MinMax"
+	// NormalizeRank scores the nodes by the rank of their score, the lowest
+	// getting 0 and the highest MaxPriority. Equal scores share a rank.
+	NormalizeRank ScoreNormalization = "Rank"
+	// NormalizeZScore maps the standard score of the nodes, clipped to two
+	// standard deviations around the mean, linearly to 0-MaxPriority.
+	NormalizeZScore ScoreNormalization = "ZScore"
+)
+
+// ScoringStage describes a stage of the scoring of the nodes.
+type ScoringStage struct {
+	// Name of the stage, used in the logs
+	Name string `json:"name"`
+	// The priority functions scoring the nodes in this stage, with their weights
+	// If empty, the priority functions of the policy are used, so that the
+	// stock priority functions can run as the final stage
+	Priorities []PriorityPolicy `json:"priorities,omitempty"`
+	// Selection selects the nodes passed on to the next stage
+	// It is ignored for the last stage, which selects the best node
+	Selection StageSelection `json:"selection,omitempty"`
+}
+
+// StageSelectionRule is the rule selecting the nodes passed on to the next
+// scoring stage.
+type StageSelectionRule string
+
+const (
+	// KeepBestGroup keeps the nodes of the topology group with the best mean
+	// score, e.g. all the nodes of the best socket, or the nodes with the best
+	// score if no topology level is set. It is the default rule.
+	KeepBestGroup StageSelectionRule = "KeepBestGroup"
+	// KeepTopK keeps the K nodes with the best scores.
+	KeepTopK StageSelectionRule = "KeepTopK"
+	// KeepWithinPercent keeps the nodes scoring within Percent percent of the
+	// best score.
+	KeepWithinPercent StageSelectionRule = "KeepWithinPercent"
+)
+
+// StageSelection selects the nodes passed on to the next scoring stage.
+type StageSelection struct {
+	// Rule is the selection rule, KeepBestGroup if empty
+	Rule StageSelectionRule `json:"rule,omitempty"`
+	// K is the number of nodes kept by KeepTopK
+	K int `json:"k,omitempty"`
+	// Percent is the distance to the best score, in percent of it, of the
+	// nodes kept by KeepWithinPercent, in the range 0-100
+	Percent int `json:"percent,omitempty"`
+	// Level is the topology level (rack, server, socket or numa) of the
+	// groups compared by KeepBestGroup
+	Level string `json:"level,omitempty"`
 }
 
 // PredicateArgument represents the arguments to configure predicate functions in scheduler policy configuration.
@@ -93,6 +164,12 @@ type PriorityArgument struct {
 	LabelPreference *LabelPreference `json:"labelPreference"`
 	// The RequestedToCapacityRatio priority function is parametrized with function shape.
 	RequestedToCapacityRatioArguments *RequestedToCapacityRatioArguments `json:"requestedToCapacityRatioArguments"`
+	// The CustomRequestedPriority function is parametrized with the formula the
+	// sockets are scored with.
+	CustomScore *CustomScoreArguments `json:"customScore"`
+	// The LLCContentionPriority function is parametrized with the classification
+	// of the applications and the penalties of their pairs.
+	LLCContention *LLCContentionArguments `json:"llcContention"`
 }
 
 // ServiceAffinity holds the parameters that are used to configure the corresponding predicate in scheduler policy configuration.
@@ -141,6 +218,32 @@ type UtilizationShapePoint struct {
 	Score int `json:"score"`
 }
 
+// CustomScoreArguments holds the formula of the CustomRequestedPriority function,
+// either a registered strategy or an arithmetic expression over the metrics of
+// a socket. Only one of Strategy and Expression may be specified.
+type CustomScoreArguments struct {
+	// Name of a registered score strategy, e.g. "bandwidth" or "energy"
+	Strategy string `json:"strategy,omitempty"`
+	// Expression the score is computed with, e.g. "ipc / (mem_read + mem_write)"
+	Expression string `json:"expression,omitempty"`
+	// Named constants the expression may refer to. They override the defaults
+	// of the strategy.
+	Parameters map[string]float64 `json:"parameters,omitempty"`
+}
+
+// LLCContentionArguments holds the arguments of the LLCContentionPriority
+// function. The zero values fall back to the defaults.
+type LLCContentionArguments struct {
+	// L3 miss rate from which an application is cache-sensitive
+	SensitiveMissRate float64 `json:"sensitiveMissRate,omitempty"`
+	// L3 miss rate from which an application is cache-polluting
+	PollutingMissRate float64 `json:"pollutingMissRate,omitempty"`
+	// Penalty of placing an application of the outer class on a socket hosting
+	// an application of the inner class, e.g. {"sensitive": {"polluting": 1}}.
+	// The classes are "insensitive", "sensitive" and "polluting".
+	Penalties map[string]map[string]float64 `json:"penalties,omitempty"`
+}
+
 // ExtenderManagedResource describes the arguments of extended resources
 // managed by an extender.
 type ExtenderManagedResource struct {
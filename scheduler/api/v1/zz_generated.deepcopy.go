@@ -25,6 +25,29 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CustomScoreArguments) DeepCopyInto(out *CustomScoreArguments) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]float64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CustomScoreArguments.
+func (in *CustomScoreArguments) DeepCopy() *CustomScoreArguments {
+	if in == nil {
+		return nil
+	}
+	out := new(CustomScoreArguments)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExtenderArgs) DeepCopyInto(out *ExtenderArgs) {
 	*out = *in
@@ -342,6 +365,39 @@ func (in HostPriorityList) DeepCopy() HostPriorityList {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LLCContentionArguments) DeepCopyInto(out *LLCContentionArguments) {
+	*out = *in
+	if in.Penalties != nil {
+		in, out := &in.Penalties, &out.Penalties
+		*out = make(map[string]map[string]float64, len(*in))
+		for key, val := range *in {
+			var outVal map[string]float64
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[string]float64, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LLCContentionArguments.
+func (in *LLCContentionArguments) DeepCopy() *LLCContentionArguments {
+	if in == nil {
+		return nil
+	}
+	out := new(LLCContentionArguments)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LabelPreference) DeepCopyInto(out *LabelPreference) {
 	*out = *in
@@ -447,6 +503,13 @@ func (in *Policy) DeepCopyInto(out *Policy) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ScoringStages != nil {
+		in, out := &in.ScoringStages, &out.ScoringStages
+		*out = make([]ScoringStage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -533,6 +596,16 @@ func (in *PriorityArgument) DeepCopyInto(out *PriorityArgument) {
 		*out = new(RequestedToCapacityRatioArguments)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.CustomScore != nil {
+		in, out := &in.CustomScore, &out.CustomScore
+		*out = new(CustomScoreArguments)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LLCContention != nil {
+		in, out := &in.LLCContention, &out.LLCContention
+		*out = new(LLCContentionArguments)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -588,6 +661,30 @@ func (in *RequestedToCapacityRatioArguments) DeepCopy() *RequestedToCapacityRati
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScoringStage) DeepCopyInto(out *ScoringStage) {
+	*out = *in
+	if in.Priorities != nil {
+		in, out := &in.Priorities, &out.Priorities
+		*out = make([]PriorityPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.Selection = in.Selection
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScoringStage.
+func (in *ScoringStage) DeepCopy() *ScoringStage {
+	if in == nil {
+		return nil
+	}
+	out := new(ScoringStage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceAffinity) DeepCopyInto(out *ServiceAffinity) {
 	*out = *in
@@ -625,6 +722,22 @@ func (in *ServiceAntiAffinity) DeepCopy() *ServiceAntiAffinity {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StageSelection) DeepCopyInto(out *StageSelection) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StageSelection.
+func (in *StageSelection) DeepCopy() *StageSelection {
+	if in == nil {
+		return nil
+	}
+	out := new(StageSelection)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UtilizationShapePoint) DeepCopyInto(out *UtilizationShapePoint) {
 	*out = *in
@@ -25,7 +25,9 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation"
 	v1helper "k8s.io/kubernetes/pkg/apis/core/v1/helper"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/priorities"
 	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
 )
 
 // ValidatePolicy checks for errors in the Config
@@ -37,6 +39,12 @@ func ValidatePolicy(policy schedulerapi.Policy) error {
 		if priority.Weight <= 0 || priority.Weight >= schedulerapi.MaxWeight {
 			validationErrors = append(validationErrors, fmt.Errorf("Priority %s should have a positive weight applied to it or it has overflown", priority.Name))
 		}
+		validationErrors = append(validationErrors, validatePriorityArgument(priority)...)
+		validationErrors = append(validationErrors, validateNormalization(priority)...)
+	}
+
+	for _, stage := range policy.ScoringStages {
+		validationErrors = append(validationErrors, validateScoringStage(stage)...)
 	}
 
 	binders := 0
@@ -65,6 +73,64 @@ func ValidatePolicy(policy schedulerapi.Policy) error {
 	return utilerrors.NewAggregate(validationErrors)
 }
 
+// validateScoringStage checks the weights of the priorities and the selection
+// rule of a scoring stage.
+func validateScoringStage(stage schedulerapi.ScoringStage) []error {
+	var validationErrors []error
+	for _, priority := range stage.Priorities {
+		if priority.Weight <= 0 || priority.Weight >= schedulerapi.MaxWeight {
+			validationErrors = append(validationErrors, fmt.Errorf("Priority %s of scoring stage %s should have a positive weight applied to it or it has overflown", priority.Name, stage.Name))
+		}
+		validationErrors = append(validationErrors, validatePriorityArgument(priority)...)
+		validationErrors = append(validationErrors, validateNormalization(priority)...)
+	}
+	switch selection := stage.Selection; selection.Rule {
+	case "", schedulerapi.KeepBestGroup:
+		if selection.Level != "" && !topology.IsLevel(topology.Level(selection.Level)) {
+			validationErrors = append(validationErrors, fmt.Errorf("Scoring stage %s has an unknown topology level %s", stage.Name, selection.Level))
+		}
+	case schedulerapi.KeepTopK:
+		if selection.K <= 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("Scoring stage %s should keep a positive number of nodes, got %d", stage.Name, selection.K))
+		}
+	case schedulerapi.KeepWithinPercent:
+		if selection.Percent < 0 || selection.Percent > 100 {
+			validationErrors = append(validationErrors, fmt.Errorf("Scoring stage %s should keep the nodes within 0-100%% of the best score, got %d%%", stage.Name, selection.Percent))
+		}
+	default:
+		validationErrors = append(validationErrors, fmt.Errorf("Scoring stage %s has an unknown selection rule %s", stage.Name, selection.Rule))
+	}
+	return validationErrors
+}
+
+// validatePriorityArgument checks the formula of a CustomRequestedPriority
+// and the penalties of an LLCContentionPriority.
+func validatePriorityArgument(priority schedulerapi.PriorityPolicy) []error {
+	if priority.Argument == nil {
+		return nil
+	}
+	if priority.Argument.CustomScore != nil {
+		if _, err := priorities.NewCustomScore(priority.Argument.CustomScore); err != nil {
+			return []error{fmt.Errorf("Priority %s has an invalid score: %v", priority.Name, err)}
+		}
+	}
+	if priority.Argument.LLCContention != nil {
+		if _, err := priorities.NewLLCContention(priority.Argument.LLCContention); err != nil {
+			return []error{fmt.Errorf("Priority %s has an invalid llc contention: %v", priority.Name, err)}
+		}
+	}
+	return nil
+}
+
+// validateNormalization checks the score normalization of a priority.
+func validateNormalization(priority schedulerapi.PriorityPolicy) []error {
+	switch priority.Normalization {
+	case "", schedulerapi.NormalizeMinMax, schedulerapi.NormalizeRank, schedulerapi.NormalizeZScore:
+		return nil
+	}
+	return []error{fmt.Errorf("Priority %s has an unknown normalization %s", priority.Name, priority.Normalization)}
+}
+
 // validateExtendedResourceName checks whether the specified name is a valid
 // extended resource name.
 func validateExtendedResourceName(name v1.ResourceName) []error {
@@ -101,6 +101,78 @@ func TestValidatePolicy(t *testing.T) {
 				}},
 			expected: errors.New("kubernetes.io/foo is an invalid extended resource name"),
 		},
+		{
+			name: "valid scoring stages",
+			policy: api.Policy{ScoringStages: []api.ScoringStage{
+				{Name: "socket", Priorities: []api.PriorityPolicy{{Name: "WeightPriority", Weight: 2}}, Selection: api.StageSelection{Level: "socket"}},
+				{Name: "top", Priorities: []api.PriorityPolicy{{Name: "WeightPriority", Weight: 2}}, Selection: api.StageSelection{Rule: api.KeepTopK, K: 3}},
+				{Name: "close", Selection: api.StageSelection{Rule: api.KeepWithinPercent, Percent: 10}},
+			}},
+			expected: nil,
+		},
+		{
+			name:     "invalid weight in scoring stage",
+			policy:   api.Policy{ScoringStages: []api.ScoringStage{{Name: "socket", Priorities: []api.PriorityPolicy{{Name: "WeightPriority"}}}}},
+			expected: errors.New("Priority WeightPriority of scoring stage socket should have a positive weight applied to it or it has overflown"),
+		},
+		{
+			name:     "invalid top K in scoring stage",
+			policy:   api.Policy{ScoringStages: []api.ScoringStage{{Name: "top", Selection: api.StageSelection{Rule: api.KeepTopK}}}},
+			expected: errors.New("Scoring stage top should keep a positive number of nodes, got 0"),
+		},
+		{
+			name:     "invalid percent in scoring stage",
+			policy:   api.Policy{ScoringStages: []api.ScoringStage{{Name: "close", Selection: api.StageSelection{Rule: api.KeepWithinPercent, Percent: 150}}}},
+			expected: errors.New("Scoring stage close should keep the nodes within 0-100% of the best score, got 150%"),
+		},
+		{
+			name:     "unknown topology level in scoring stage",
+			policy:   api.Policy{ScoringStages: []api.ScoringStage{{Name: "socket", Selection: api.StageSelection{Level: "core"}}}},
+			expected: errors.New("Scoring stage socket has an unknown topology level core"),
+		},
+		{
+			name:     "unknown selection rule in scoring stage",
+			policy:   api.Policy{ScoringStages: []api.ScoringStage{{Name: "socket", Selection: api.StageSelection{Rule: "KeepWorst"}}}},
+			expected: errors.New("Scoring stage socket has an unknown selection rule KeepWorst"),
+		},
+		{
+			name: "valid score expression",
+			policy: api.Policy{Priorities: []api.PriorityPolicy{{Name: "IPCPriority", Weight: 1, Argument: &api.PriorityArgument{
+				CustomScore: &api.CustomScoreArguments{Expression: "ipc * c6factor"},
+			}}}},
+			expected: nil,
+		},
+		{
+			name: "invalid score expression in scoring stage",
+			policy: api.Policy{ScoringStages: []api.ScoringStage{{Name: "socket", Priorities: []api.PriorityPolicy{{Name: "IPCPriority", Weight: 1, Argument: &api.PriorityArgument{
+				CustomScore: &api.CustomScoreArguments{Expression: "ipc *"},
+			}}}}}},
+			expected: errors.New(`Priority IPCPriority has an invalid score: invalid score expression "ipc *": unexpected end of expression at offset 5`),
+		},
+		{
+			name: "unknown score strategy",
+			policy: api.Policy{Priorities: []api.PriorityPolicy{{Name: "IPCPriority", Weight: 1, Argument: &api.PriorityArgument{
+				CustomScore: &api.CustomScoreArguments{Strategy: "latency"},
+			}}}},
+			expected: errors.New("Priority IPCPriority has an invalid score: unknown score strategy latency, expected one of [bandwidth energy ipc ipc-energy l3]"),
+		},
+		{
+			name: "unknown llc class in penalties",
+			policy: api.Policy{Priorities: []api.PriorityPolicy{{Name: "LLCContentionPriority", Weight: 1, Argument: &api.PriorityArgument{
+				LLCContention: &api.LLCContentionArguments{Penalties: map[string]map[string]float64{"sensitive": {"thrashing": 1}}},
+			}}}},
+			expected: errors.New("Priority LLCContentionPriority has an invalid llc contention: unknown llc class thrashing, expected one of [insensitive sensitive polluting]"),
+		},
+		{
+			name:     "normalized priority in scoring stage",
+			policy:   api.Policy{ScoringStages: []api.ScoringStage{{Name: "socket", Priorities: []api.PriorityPolicy{{Name: "CustomRequestedPriority", Weight: 1, Normalization: api.NormalizeRank}}}}},
+			expected: nil,
+		},
+		{
+			name:     "unknown normalization",
+			policy:   api.Policy{Priorities: []api.PriorityPolicy{{Name: "CustomRequestedPriority", Weight: 1, Normalization: "Softmax"}}},
+			expected: errors.New("Priority CustomRequestedPriority has an unknown normalization Softmax"),
+		},
 	}
 
 	for _, test := range tests {
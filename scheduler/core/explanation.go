@@ -0,0 +1,155 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"sort"
+	"time"
+
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// DecisionAnnotationKey is the annotation of the bound pods holding the
+// compact explanation of their scheduling decision, if enabled.
+const DecisionAnnotationKey = "scheduler.kube-scheduler/decision"
+
+// hardwareMetrics are the hardware counters the ages of which are explained.
+var hardwareMetrics = []string{"ipc", "mem_read", "mem_write", "c6res"}
+
+// The sources of the hardware counters of a node.
+const (
+	// MetricsFromCache are counters read from the cache, fetched by an earlier
+	// scheduling cycle or by the background refresh.
+	MetricsFromCache = "cache"
+	// MetricsFromMonitoring are counters fetched from the monitoring database
+	// during the scheduling cycle.
+	MetricsFromMonitoring = "monitoring"
+)
+
+// Explanation records why a pod was scheduled on its node: the scores of the
+// candidate nodes in each scoring stage, and the hardware counters they were
+// scored with.
+type Explanation struct {
+	// Winner is the node the pod was scheduled on.
+	Winner string `json:"winner"`
+	// Socket is the ID of the socket of the winner, e.g. "<uuid>/1", if it is
+	// part of the topology.
+	Socket string `json:"socket,omitempty"`
+	// Stages are the scores of the nodes in each scoring stage. There are no
+	// stages if a single node was feasible.
+	Stages []StageExplanation `json:"stages,omitempty"`
+	// Metrics are the ages of the hardware counters of the candidate nodes.
+	Metrics []MetricsAge `json:"metrics,omitempty"`
+}
+
+// StageExplanation holds the scores of the nodes of a scoring stage.
+type StageExplanation struct {
+	Name  string      `json:"name"`
+	Nodes []NodeScore `json:"nodes"`
+}
+
+// NodeScore is the weighted score of a node, and the scores of each priority
+// function and extender it adds up.
+type NodeScore struct {
+	Node string `json:"node"`
+	// Socket is the ID of the socket of the node, if it is part of the
+	// topology.
+	Socket     string          `json:"socket,omitempty"`
+	Score      float64         `json:"score"`
+	Priorities []PriorityScore `json:"priorities,omitempty"`
+}
+
+// PriorityScore is the score of a node by a priority function, before and
+// after its reduce function.
+type PriorityScore struct {
+	Name       string  `json:"name"`
+	Weight     int     `json:"weight"`
+	Raw        float64 `json:"raw"`
+	Normalized float64 `json:"normalized"`
+}
+
+// MetricsAge is the age of the oldest of the hardware counters of a node,
+// and where they were read from.
+type MetricsAge struct {
+	Node       string  `json:"node"`
+	Source     string  `json:"source"`
+	AgeSeconds float64 `json:"ageSeconds"`
+}
+
+// explainNodes sets the socket of the nodes of each stage, the winner, and
+// the ages of the hardware counters of the candidate nodes in cache. The
+// counters updated after start were fetched during the scheduling cycle.
+func (e *Explanation) explainNodes(winner string, candidates []string, topo topology.Interface, cache *customcache.Cache, start time.Time) {
+	socket := func(node string) string {
+		if topo == nil {
+			return ""
+		}
+		if group, ok := topo.Tree().Group(topology.LevelSocket, node); ok {
+			return group.ID
+		}
+		return ""
+	}
+	e.Winner = winner
+	e.Socket = socket(winner)
+	for i := range e.Stages {
+		for j := range e.Stages[i].Nodes {
+			e.Stages[i].Nodes[j].Socket = socket(e.Stages[i].Nodes[j].Node)
+		}
+	}
+	if cache == nil {
+		return
+	}
+	elapsed := time.Since(start)
+	for _, node := range candidates {
+		age, ok := cache.Age(node, hardwareMetrics...)
+		if !ok {
+			continue
+		}
+		source := MetricsFromCache
+		if age < elapsed {
+			source = MetricsFromMonitoring
+		}
+		e.Metrics = append(e.Metrics, MetricsAge{Node: node, Source: source, AgeSeconds: age.Seconds()})
+	}
+}
+
+// Compact returns a copy of the explanation keeping the n best nodes of each
+// stage and the winner, and the ages of the counters of those nodes only.
+func (e *Explanation) Compact(n int) *Explanation {
+	compact := &Explanation{Winner: e.Winner, Socket: e.Socket}
+	kept := map[string]bool{e.Winner: true}
+	for _, stage := range e.Stages {
+		nodes := make([]NodeScore, len(stage.Nodes))
+		copy(nodes, stage.Nodes)
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Score > nodes[j].Score })
+		var best []NodeScore
+		for i, node := range nodes {
+			if i < n || node.Node == e.Winner {
+				best = append(best, node)
+				kept[node.Node] = true
+			}
+		}
+		compact.Stages = append(compact.Stages, StageExplanation{Name: stage.Name, Nodes: best})
+	}
+	for _, m := range e.Metrics {
+		if kept[m.Node] {
+			compact.Metrics = append(compact.Metrics, m)
+		}
+	}
+	return compact
+}
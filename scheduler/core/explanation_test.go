@@ -0,0 +1,102 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/priorities"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+func TestPrioritizeNodesExplanation(t *testing.T) {
+	rawScores := map[string]float64{"kube-01": 100, "kube-02": 400, "kube-03": 200}
+	var nodes []*v1.Node
+	for _, name := range []string{"kube-01", "kube-02", "kube-03"} {
+		nodes = append(nodes, &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	configs := []priorities.PriorityConfig{{
+		Name: "CustomRequestedPriority",
+		Map: func(pod *v1.Pod, meta interface{}, nodeInfo *schedulernodeinfo.NodeInfo) (schedulerapi.HostPriority, error) {
+			return schedulerapi.HostPriority{Host: nodeInfo.Node().Name, Score: rawScores[nodeInfo.Node().Name]}, nil
+		},
+		Reduce: priorities.NewNormalizedReduce(schedulerapi.NormalizeMinMax, nil),
+		Weight: 2,
+	}}
+
+	var explanation StageExplanation
+	_, err := prioritizeNodes(&v1.Pod{}, schedulernodeinfo.CreateNodeNameToInfoMap(nil, nodes), nil, configs, nodes, nil, &explanation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []NodeScore{
+		{Node: "kube-01", Score: 0, Priorities: []PriorityScore{{Name: "CustomRequestedPriority", Weight: 2, Raw: 100, Normalized: 0}}},
+		{Node: "kube-02", Score: 20, Priorities: []PriorityScore{{Name: "CustomRequestedPriority", Weight: 2, Raw: 400, Normalized: 10}}},
+		{Node: "kube-03", Score: 20.0 / 3, Priorities: []PriorityScore{{Name: "CustomRequestedPriority", Weight: 2, Raw: 200, Normalized: 10.0 / 3}}},
+	}
+	if !reflect.DeepEqual(expected, explanation.Nodes) {
+		t.Errorf("expected %+v, got %+v", expected, explanation.Nodes)
+	}
+}
+
+func TestExplainNodes(t *testing.T) {
+	topo := topology.New(
+		[]topology.Server{{UUID: "server-a"}},
+		[]topology.Node{
+			{Name: "kube-01", Server: "server-a", Socket: 0},
+			{Name: "kube-02", Server: "server-a", Socket: 1},
+		},
+	)
+	cache := customcache.New(customcache.DefaultTTL)
+	start := time.Now()
+	// kube-01 was fetched during the scheduling cycle, kube-03 has no
+	// counters.
+	cache.Update("kube-01", map[string]float64{"ipc": 1, "mem_read": 1, "mem_write": 1, "c6res": 0})
+
+	explanation := &Explanation{Stages: []StageExplanation{{Name: "socket", Nodes: []NodeScore{
+		{Node: "kube-01", Score: 10},
+		{Node: "kube-02", Score: 3},
+		{Node: "kube-03", Score: 7},
+	}}}}
+	explanation.explainNodes("kube-01", []string{"kube-01", "kube-02", "kube-03"}, topo, cache, start.Add(-time.Second))
+	if explanation.Winner != "kube-01" || explanation.Socket != "server-a/0" {
+		t.Errorf("expected kube-01 on socket server-a/0 to win, got %v on %v", explanation.Winner, explanation.Socket)
+	}
+	if socket := explanation.Stages[0].Nodes[1].Socket; socket != "server-a/1" {
+		t.Errorf("expected kube-02 on socket server-a/1, got %v", socket)
+	}
+	if len(explanation.Metrics) != 1 || explanation.Metrics[0].Node != "kube-01" || explanation.Metrics[0].Source != MetricsFromMonitoring {
+		t.Errorf("expected the counters of kube-01 from the monitoring database, got %+v", explanation.Metrics)
+	}
+
+	compact := explanation.Compact(1)
+	if nodes := compact.Stages[0].Nodes; len(nodes) != 1 || nodes[0].Node != "kube-01" {
+		t.Errorf("expected the compact explanation to keep kube-01 only, got %+v", nodes)
+	}
+	explanation.Winner = "kube-02"
+	compact = explanation.Compact(1)
+	if nodes := compact.Stages[0].Nodes; len(nodes) != 2 || nodes[0].Node != "kube-01" || nodes[1].Node != "kube-02" {
+		t.Errorf("expected the compact explanation to keep the best node and the winner, got %+v", nodes)
+	}
+}
@@ -29,10 +29,12 @@ import (
 	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
 	"k8s.io/kubernetes/pkg/scheduler/algorithm/priorities"
 	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
 	internalcache "k8s.io/kubernetes/pkg/scheduler/internal/cache"
 	internalqueue "k8s.io/kubernetes/pkg/scheduler/internal/queue"
 	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
 	schedulertesting "k8s.io/kubernetes/pkg/scheduler/testing"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
 	"k8s.io/kubernetes/pkg/scheduler/util"
 )
 
@@ -77,7 +79,7 @@ func errorPrioritizerExtender(pod *v1.Pod, nodes []*v1.Node) (*schedulerapi.Host
 func machine1PrioritizerExtender(pod *v1.Pod, nodes []*v1.Node) (*schedulerapi.HostPriorityList, error) {
 	result := schedulerapi.HostPriorityList{}
 	for _, node := range nodes {
-		score := 1
+		score := 1.0
 		if node.Name == "machine1" {
 			score = 10
 		}
@@ -89,7 +91,7 @@ func machine1PrioritizerExtender(pod *v1.Pod, nodes []*v1.Node) (*schedulerapi.H
 func machine2PrioritizerExtender(pod *v1.Pod, nodes []*v1.Node) (*schedulerapi.HostPriorityList, error) {
 	result := schedulerapi.HostPriorityList{}
 	for _, node := range nodes {
-		score := 1
+		score := 1.0
 		if node.Name == "machine2" {
 			score = 10
 		}
@@ -101,7 +103,7 @@ func machine2PrioritizerExtender(pod *v1.Pod, nodes []*v1.Node) (*schedulerapi.H
 func machine2Prioritizer(_ *v1.Pod, nodeNameToInfo map[string]*schedulernodeinfo.NodeInfo, nodes []*v1.Node) (schedulerapi.HostPriorityList, error) {
 	result := []schedulerapi.HostPriority{}
 	for _, node := range nodes {
-		score := 1
+		score := 1.0
 		if node.Name == "machine2" {
 			score = 10
 		}
@@ -291,7 +293,7 @@ func (f *FakeExtender) Filter(pod *v1.Pod, nodes []*v1.Node, nodeNameToInfo map[
 
 func (f *FakeExtender) Prioritize(pod *v1.Pod, nodes []*v1.Node) (*schedulerapi.HostPriorityList, int, error) {
 	result := schedulerapi.HostPriorityList{}
-	combinedScores := map[string]int{}
+	combinedScores := map[string]float64{}
 	for _, prioritizer := range f.prioritizers {
 		weight := prioritizer.weight
 		if weight == 0 {
@@ -303,7 +305,7 @@ func (f *FakeExtender) Prioritize(pod *v1.Pod, nodes []*v1.Node) (*schedulerapi.
 			return &schedulerapi.HostPriorityList{}, 0, err
 		}
 		for _, hostEntry := range *prioritizedList {
-			combinedScores[hostEntry.Host] += hostEntry.Score * weight
+			combinedScores[hostEntry.Host] += hostEntry.Score * float64(weight)
 		}
 	}
 	for host, score := range combinedScores {
@@ -550,7 +552,10 @@ func TestGenericSchedulerWithExtenders(t *testing.T) {
 				false,
 				false,
 				schedulerapi.DefaultPercentageOfNodesToScore,
-				false)
+				false,
+				topology.New(nil, nil),
+				customcache.New(customcache.DefaultTTL),
+				nil)
 			podIgnored := &v1.Pod{}
 			result, err := scheduler.Schedule(podIgnored, schedulertesting.FakeNodeLister(makeNodeList(test.nodes)))
 			if test.expectsErr {
@@ -563,6 +568,10 @@ func TestGenericSchedulerWithExtenders(t *testing.T) {
 					return
 				}
 
+				if result.Explanation == nil {
+					t.Errorf("Expected an explanation of the decision")
+				}
+				result.Explanation = nil
 				if !reflect.DeepEqual(result, test.expectedResult) {
 					t.Errorf("Expected: %+v, Saw: %+v", test.expectedResult, result)
 				}
@@ -571,6 +580,69 @@ func TestGenericSchedulerWithExtenders(t *testing.T) {
 	}
 }
 
+func TestScoringStagesWithExtenders(t *testing.T) {
+	// The extender records the nodes it is asked to score.
+	var calls [][]string
+	extender := &FakeExtender{
+		predicates: []fitPredicate{truePredicateExtender},
+		prioritizers: []priorityConfig{{func(pod *v1.Pod, nodes []*v1.Node) (*schedulerapi.HostPriorityList, error) {
+			var names []string
+			for _, node := range nodes {
+				names = append(names, node.Name)
+			}
+			calls = append(calls, names)
+			return machine1PrioritizerExtender(pod, nodes)
+		}, 10}},
+		weight: 1,
+	}
+	nodes := []string{"machine1", "machine2", "machine3"}
+	cache := internalcache.New(time.Duration(0), wait.NeverStop)
+	for _, name := range nodes {
+		cache.AddNode(createNode(name))
+	}
+	scheduler := NewGenericScheduler(
+		cache,
+		internalqueue.NewSchedulingQueue(nil, nil),
+		map[string]predicates.FitPredicate{"true": truePredicate},
+		predicates.EmptyPredicateMetadataProducer,
+		nil,
+		priorities.EmptyPriorityMetadataProducer,
+		emptyFramework,
+		[]algorithm.SchedulerExtender{extender},
+		nil,
+		schedulertesting.FakePersistentVolumeClaimLister{},
+		schedulertesting.FakePDBLister{},
+		false,
+		false,
+		schedulerapi.DefaultPercentageOfNodesToScore,
+		false,
+		topology.New(nil, nil),
+		customcache.New(customcache.DefaultTTL),
+		[]ScoringStage{
+			{
+				Name:         "first",
+				Prioritizers: []priorities.PriorityConfig{{Function: machine2Prioritizer, Weight: 1}},
+				Selection:    schedulerapi.StageSelection{Rule: schedulerapi.KeepTopK, K: 1},
+			},
+			{
+				Name:         "final",
+				Prioritizers: []priorities.PriorityConfig{{Map: EqualPriorityMap, Weight: 1}},
+			},
+		})
+	result, err := scheduler.Schedule(&v1.Pod{}, schedulertesting.FakeNodeLister(makeNodeList(nodes)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The extender would prefer machine1, but only scores the node kept by
+	// the first stage.
+	if result.SuggestedHost != "machine2" {
+		t.Errorf("Expected machine2, got %s", result.SuggestedHost)
+	}
+	if expected := [][]string{{"machine2"}}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected the extender to score %v, got %v", expected, calls)
+	}
+}
+
 func createNode(name string) *v1.Node {
 	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
 }
@@ -28,7 +28,6 @@ import (
 
 	"k8s.io/klog"
 
-	"github.com/iwita/kube-scheduler/customcache"
 	v1 "k8s.io/api/core/v1"
 	policy "k8s.io/api/policy/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -40,11 +39,13 @@ import (
 	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
 	"k8s.io/kubernetes/pkg/scheduler/algorithm/priorities"
 	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
 	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
 	internalcache "k8s.io/kubernetes/pkg/scheduler/internal/cache"
 	internalqueue "k8s.io/kubernetes/pkg/scheduler/internal/queue"
 	"k8s.io/kubernetes/pkg/scheduler/metrics"
 	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
 	"k8s.io/kubernetes/pkg/scheduler/util"
 	"k8s.io/kubernetes/pkg/scheduler/volumebinder"
 	utiltrace "k8s.io/utils/trace"
@@ -150,6 +151,8 @@ type ScheduleResult struct {
 	EvaluatedNodes int
 	// Number of feasible nodes on one pod scheduled
 	FeasibleNodes int
+	// Explanation of the scores of the nodes
+	Explanation *Explanation
 }
 
 type genericScheduler struct {
@@ -170,6 +173,9 @@ type genericScheduler struct {
 	disablePreemption        bool
 	percentageOfNodesToScore int32
 	enableNonPreempting      bool
+	topology                 topology.Interface
+	metricsCache             *customcache.Cache
+	scoringStages            []ScoringStage
 }
 
 // snapshot snapshots scheduler cache and node infos for all fit and priority
@@ -185,6 +191,7 @@ func (g *genericScheduler) snapshot() error {
 func (g *genericScheduler) Schedule(pod *v1.Pod, nodeLister algorithm.NodeLister) (result ScheduleResult, err error) {
 	trace := utiltrace.New(fmt.Sprintf("Scheduling %s/%s", pod.Namespace, pod.Name))
 	defer trace.LogIfLong(100 * time.Millisecond)
+	start := time.Now()
 
 	if err := podPassesBasicChecks(pod, g.pvcLister); err != nil {
 		return result, err
@@ -221,52 +228,26 @@ func (g *genericScheduler) Schedule(pod *v1.Pod, nodeLister algorithm.NodeLister
 	metrics.SchedulingLatency.WithLabelValues(metrics.PredicateEvaluation).Observe(metrics.SinceInSeconds(startPredicateEvalTime))
 	metrics.DeprecatedSchedulingLatency.WithLabelValues(metrics.PredicateEvaluation).Observe(metrics.SinceInSeconds(startPredicateEvalTime))
 
-	//trace.Step("Prioritizing")
-	//trace.Step("Prioritizing Sockets")
+	trace.Step("Prioritizing")
 
 	startPriorityEvalTime := time.Now()
 	// When only one node after predicate, just use it.
 	if len(filteredNodes) == 1 {
 		metrics.SchedulingAlgorithmPriorityEvaluationDuration.Observe(metrics.SinceInSeconds(startPriorityEvalTime))
 		metrics.DeprecatedSchedulingAlgorithmPriorityEvaluationDuration.Observe(metrics.SinceInMicroseconds(startPriorityEvalTime))
+		explanation := &Explanation{}
+		explanation.explainNodes(filteredNodes[0].Name, []string{filteredNodes[0].Name}, g.topology, g.metricsCache, start)
 		return ScheduleResult{
 			SuggestedHost:  filteredNodes[0].Name,
 			EvaluatedNodes: 1 + len(failedPredicateMap),
 			FeasibleNodes:  1,
+			Explanation:    explanation,
 		}, nil
 	}
 
 	metaPrioritiesInterface := g.priorityMetaProducer(pod, g.nodeInfoSnapshot.NodeInfoMap)
-
-	// default
-	//priorityList, err := PrioritizeNodes(pod, g.nodeInfoSnapshot.NodeInfoMap, metaPrioritiesInterface, g.prioritizers, filteredNodes, g.extenders)
-	// default
-
-	//start-custom
-
-	select {
-	// clean the cache if 10 seconds are passed
-	case <-customcache.LabCache.Timeout.C:
-		klog.Infof("Time to erase: %v", time.Now())
-		//customcache.LabCache.Timeout.Stop()
-		customcache.LabCache.CleanCache()
-		klog.Infof("Cache: %v", customcache.LabCache.Cache)
-	default:
-		klog.Infof("Cache is Valid, Time: %v", customcache.LabCache.Timeout.C)
-	}
-
-	socketPrioritizers := []priorities.PriorityConfig{
-		{
-			Name:   priorities.CustomRequestedPriority,
-			Map:    priorities.CustomRequestedPriorityMap,
-			Weight: 100,
-		},
-	}
-
-	klog.Infof("Selecting Socket")
-
-	priorityList, err := PrioritizeNodes(pod, g.nodeInfoSnapshot.NodeInfoMap, metaPrioritiesInterface, socketPrioritizers, filteredNodes, g.extenders)
-	//end-custom
+	explanation := &Explanation{}
+	host, err := g.prioritizeInStages(pod, metaPrioritiesInterface, filteredNodes, explanation)
 	if err != nil {
 		return result, err
 	}
@@ -275,84 +256,62 @@ func (g *genericScheduler) Schedule(pod *v1.Pod, nodeLister algorithm.NodeLister
 	metrics.SchedulingLatency.WithLabelValues(metrics.PriorityEvaluation).Observe(metrics.SinceInSeconds(startPriorityEvalTime))
 	metrics.DeprecatedSchedulingLatency.WithLabelValues(metrics.PriorityEvaluation).Observe(metrics.SinceInSeconds(startPriorityEvalTime))
 
-	// -----------------------------------------------------
-	// ------------------START-CUSTOM-----------------------
-	// -----------------------------------------------------
-	//trace.Step("Selecting socket")
-	hosts, err := g.selectHostOnWinningSocket(priorityList)
-
-	//declare a subset of the snapshot of all available nodes
-	// create a new map, containing only the subset of the nodes
-	//var winningSocketNodes map[string]*schedulernodeinfo.NodeInfo
-	var winningSocketNodes []*v1.Node
-
-	for _, wn := range hosts {
-		for _, n := range filteredNodes {
-			if n.Name == wn {
-				winningSocketNodes = append(winningSocketNodes, n)
-			}
-		}
-	}
-
-	//trace.Step("Selecting host")
-	klog.Infof("Selecting host")
-	nodePrioritizers := []priorities.PriorityConfig{
-		{
-			Name:   priorities.NodeSelectionPriority,
-			Map:    priorities.NodeSelectionPriorityMap,
-			Weight: 100,
-		},
-	}
-	priorityList, err = PrioritizeNodes(pod, g.nodeInfoSnapshot.NodeInfoMap, metaPrioritiesInterface, nodePrioritizers, winningSocketNodes, g.extenders)
-
-	// The winner host
-	host, err := g.selectHost(priorityList)
-
-	winningSocket := priorities.Sockets[host]
-	winningUuid := priorities.Nodes[host]
-
-	klog.Infof("Winning node: %v, Socket %v, UUID: %v", host, winningSocket, winningUuid)
-
-	var tmp []string
-	var socketNodes []string
-	for key, val := range priorities.Nodes {
-		if val == winningUuid {
-			tmp = append(tmp, key)
+	if g.metricsCache != nil {
+		if age, ok := g.metricsCache.Age(host, hardwareMetrics...); ok {
+			metrics.HardwareMetricsAge.Observe(age.Seconds())
 		}
 	}
-	for _, n := range tmp {
-		if priorities.Sockets[n] == winningSocket {
-			socketNodes = append(socketNodes, n)
+	if g.topology != nil {
+		if winner, ok := g.topology.Node(host); ok {
+			klog.V(2).Infof("Winning node: %v, Socket %v, UUID: %v", host, winner.Socket, winner.Server)
 		}
 	}
 
-	// Add pod's information (average metrics to the winning nodes metrics) and cache them
-	podName := pod.ObjectMeta.Name[0 : len(pod.ObjectMeta.Name)-19]
-	var win bool
-	for _, n := range socketNodes {
-
-		if n == host {
-			win = true
-		} else {
-			win = false
-		}
-		klog.Infof("Update Score for Node %v, using App: %v", n, podName)
-		klog.Infof("App: %v metrics: %v", podName, priorities.Applications[podName].Metrics)
-		numCores := len(priorities.Cores[n])
-		customcache.LabCache.AddAppMetrics(priorities.Applications[podName].Metrics, n, numCores, win)
+	candidates := make([]string, 0, len(filteredNodes))
+	for _, node := range filteredNodes {
+		candidates = append(candidates, node.Name)
 	}
+	explanation.explainNodes(host, candidates, g.topology, g.metricsCache, start)
 
-	// -----------------------------------------------------
-	// ------------------END-CUSTOM-----------------------
-	// -----------------------------------------------------
-	//trace.Step("Selecting host")
-
-	klog.Infof("Return (generic_scheduler.go)")
 	return ScheduleResult{
 		SuggestedHost:  host,
 		EvaluatedNodes: len(filteredNodes) + len(failedPredicateMap),
 		FeasibleNodes:  len(filteredNodes),
-	}, err
+		Explanation:    explanation,
+	}, nil
+}
+
+// prioritizeInStages scores the nodes in the scoring stages of the scheduler,
+// each stage scoring the nodes selected by the previous one, and returns the
+// best node of the last stage. The extenders only score the nodes of the last
+// stage. Without stages, the nodes are scored once by all the prioritizers.
+// The scores of each stage are added to explanation.
+func (g *genericScheduler) prioritizeInStages(pod *v1.Pod, meta interface{}, nodes []*v1.Node, explanation *Explanation) (string, error) {
+	stages := g.scoringStages
+	if len(stages) == 0 {
+		stages = []ScoringStage{{Name: "default", Prioritizers: g.prioritizers}}
+	}
+	var priorityList schedulerapi.HostPriorityList
+	for i, stage := range stages {
+		last := i == len(stages)-1
+		var extenders []algorithm.SchedulerExtender
+		if last {
+			extenders = g.extenders
+		}
+		var err error
+		stageExplanation := StageExplanation{Name: stage.Name}
+		priorityList, err = prioritizeNodes(pod, g.nodeInfoSnapshot.NodeInfoMap, meta, stage.Prioritizers, nodes, extenders, &stageExplanation)
+		if err != nil {
+			return "", err
+		}
+		explanation.Stages = append(explanation.Stages, stageExplanation)
+		if last {
+			break
+		}
+		nodes = selectNodes(priorityList, nodes, stage.Selection, g.topology)
+		klog.V(4).Infof("Scoring stage %s of pod %v/%v kept %d nodes", stage.Name, pod.Namespace, pod.Name, len(nodes))
+	}
+	return g.selectHost(priorityList)
 }
 
 // Prioritizers returns a slice containing all the scheduler's priority
@@ -397,47 +356,6 @@ func (g *genericScheduler) selectHost(priorityList schedulerapi.HostPriorityList
 	return priorityList[maxScores[ix]].Host, nil
 }
 
-//------------------------------------------------------------------------------------------------
-//------------------------------------------------------------------------------------------------
-// ---------START OF CUSTOMIZATION----------------------------------------------------------------
-//------------------------------------------------------------------------------------------------
-//------------------------------------------------------------------------------------------------
-
-// 1st level of filtering
-// returns a list of nodes that belong to the winning socket
-func (g *genericScheduler) selectHostOnWinningSocket(priorityList schedulerapi.HostPriorityList) ([]string, error) {
-	var res []string
-	if len(priorityList) == 0 {
-		return res, fmt.Errorf("empty priorityList")
-	}
-
-	maxScores := findMaxScores(priorityList)
-	//ix := int(g.lastNodeIndex % uint64(len(maxScores)))
-	g.lastNodeIndex++
-	for _, idx := range maxScores {
-		res = append(res, priorityList[idx].Host)
-	}
-	return res, nil
-}
-
-// func (g *genericScheduler) customSelectHost(priorityList schedulerapi.CustomHostPriorityList) (string, error) {
-// 	if len(priorityList) == 0 {
-// 		return "", fmt.Errorf("empty priorityList")
-// 	}
-
-// 	maxScores := findMaxScores(priorityList)
-// 	ix := int(g.lastNodeIndex % uint64(len(maxScores)))
-// 	g.lastNodeIndex++
-
-// 	return priorityList[maxScores[ix]].Host, nil
-// }
-
-//------------------------------------------------------------------------------------------------
-//------------------------------------------------------------------------------------------------
-// ---------END OF CUSTOMIZATION----------------------------------------------------------------
-//------------------------------------------------------------------------------------------------
-//------------------------------------------------------------------------------------------------
-
 // preempt finds nodes with pods that can be preempted to make room for "pod" to
 // schedule. It chooses one of the nodes and preempts the pods on the node and
 // returns 1) the node, 2) the list of preempted pods if such a node is found,
@@ -814,6 +732,20 @@ func PrioritizeNodes(
 	priorityConfigs []priorities.PriorityConfig,
 	nodes []*v1.Node,
 	extenders []algorithm.SchedulerExtender,
+) (schedulerapi.HostPriorityList, error) {
+	return prioritizeNodes(pod, nodeNameToInfo, meta, priorityConfigs, nodes, extenders, nil)
+}
+
+// prioritizeNodes is PrioritizeNodes, adding the scores of each node to
+// explanation unless it is nil.
+func prioritizeNodes(
+	pod *v1.Pod,
+	nodeNameToInfo map[string]*schedulernodeinfo.NodeInfo,
+	meta interface{},
+	priorityConfigs []priorities.PriorityConfig,
+	nodes []*v1.Node,
+	extenders []algorithm.SchedulerExtender,
+	explanation *StageExplanation,
 ) (schedulerapi.HostPriorityList, error) {
 	// If no priority configs are provided, then the EqualPriority function is applied
 	// This is required to generate the priority list in the required format
@@ -825,6 +757,9 @@ func PrioritizeNodes(
 				return nil, err
 			}
 			result = append(result, hostPriority)
+			if explanation != nil {
+				explanation.Nodes = append(explanation.Nodes, NodeScore{Node: hostPriority.Host, Score: hostPriority.Score})
+			}
 		}
 		return result, nil
 	}
@@ -877,6 +812,18 @@ func PrioritizeNodes(
 		}
 	})
 
+	// The scores of the Map functions, before they are reduced.
+	var rawResults []schedulerapi.HostPriorityList
+	if explanation != nil {
+		rawResults = make([]schedulerapi.HostPriorityList, len(priorityConfigs))
+		for i := range priorityConfigs {
+			if priorityConfigs[i].Function == nil {
+				rawResults[i] = make(schedulerapi.HostPriorityList, len(results[i]))
+				copy(rawResults[i], results[i])
+			}
+		}
+	}
+
 	for i := range priorityConfigs {
 		if priorityConfigs[i].Reduce == nil {
 			continue
@@ -889,7 +836,7 @@ func PrioritizeNodes(
 			}
 			if klog.V(10) {
 				for _, hostPriority := range results[index] {
-					klog.Infof("%v -> %v: %v, Score: (%d)", util.GetPodFullName(pod), hostPriority.Host, priorityConfigs[index].Name, hostPriority.Score)
+					klog.Infof("%v -> %v: %v, Score: (%v)", util.GetPodFullName(pod), hostPriority.Host, priorityConfigs[index].Name, hostPriority.Score)
 				}
 			}
 		}(i)
@@ -910,6 +857,15 @@ func PrioritizeNodes(
 		}
 	}
 
+	// The scores and weights of the extenders, by extender name.
+	var (
+		extenderScores  map[string]map[string]float64
+		extenderWeights map[string]int
+	)
+	if explanation != nil {
+		extenderScores = map[string]map[string]float64{}
+		extenderWeights = map[string]int{}
+	}
 	if len(extenders) != 0 && nodes != nil {
 		combinedScores := make(map[string]float64, len(nodeNameToInfo))
 		for i := range extenders {
@@ -928,9 +884,16 @@ func PrioritizeNodes(
 				for i := range *prioritizedList {
 					host, score := (*prioritizedList)[i].Host, (*prioritizedList)[i].Score
 					if klog.V(10) {
-						klog.Infof("%v -> %v: %v, Score: (%d)", util.GetPodFullName(pod), host, extenders[extIndex].Name(), score)
+						klog.Infof("%v -> %v: %v, Score: (%v)", util.GetPodFullName(pod), host, extenders[extIndex].Name(), score)
 					}
 					combinedScores[host] += score * float64(weight)
+					if extenderScores != nil {
+						if extenderScores[extenders[extIndex].Name()] == nil {
+							extenderScores[extenders[extIndex].Name()] = map[string]float64{}
+						}
+						extenderScores[extenders[extIndex].Name()][host] = score
+						extenderWeights[extenders[extIndex].Name()] = weight
+					}
 				}
 				mu.Unlock()
 			}(i)
@@ -944,7 +907,30 @@ func PrioritizeNodes(
 
 	if klog.V(10) {
 		for i := range result {
-			klog.Infof("Host %s => Score %d", result[i].Host, result[i].Score)
+			klog.Infof("Host %s => Score %v", result[i].Host, result[i].Score)
+		}
+	}
+	if explanation != nil {
+		for i := range result {
+			node := NodeScore{Node: result[i].Host, Score: result[i].Score}
+			for j := range priorityConfigs {
+				raw := results[j][i].Score
+				if rawResults[j] != nil {
+					raw = rawResults[j][i].Score
+				}
+				node.Priorities = append(node.Priorities, PriorityScore{
+					Name:       priorityConfigs[j].Name,
+					Weight:     priorityConfigs[j].Weight,
+					Raw:        raw,
+					Normalized: results[j][i].Score,
+				})
+			}
+			for _, extender := range extenders {
+				if score, ok := extenderScores[extender.Name()][result[i].Host]; ok {
+					node.Priorities = append(node.Priorities, PriorityScore{Name: extender.Name(), Weight: extenderWeights[extender.Name()], Raw: score, Normalized: score})
+				}
+			}
+			explanation.Nodes = append(explanation.Nodes, node)
 		}
 	}
 	return result, nil
@@ -1514,6 +1500,9 @@ func NewGenericScheduler(
 	disablePreemption bool,
 	percentageOfNodesToScore int32,
 	enableNonPreempting bool,
+	topology topology.Interface,
+	metricsCache *customcache.Cache,
+	scoringStages []ScoringStage,
 ) ScheduleAlgorithm {
 	return &genericScheduler{
 		cache:                    cache,
@@ -1532,5 +1521,8 @@ func NewGenericScheduler(
 		disablePreemption:        disablePreemption,
 		percentageOfNodesToScore: percentageOfNodesToScore,
 		enableNonPreempting:      enableNonPreempting,
+		topology:                 topology,
+		metricsCache:             metricsCache,
+		scoringStages:            scoringStages,
 	}
 }
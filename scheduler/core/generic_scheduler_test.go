@@ -39,11 +39,13 @@ import (
 	priorityutil "k8s.io/kubernetes/pkg/scheduler/algorithm/priorities/util"
 	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
 	schedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
 	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
 	internalcache "k8s.io/kubernetes/pkg/scheduler/internal/cache"
 	internalqueue "k8s.io/kubernetes/pkg/scheduler/internal/queue"
 	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
 	schedulertesting "k8s.io/kubernetes/pkg/scheduler/testing"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
 )
 
 var (
@@ -86,7 +88,7 @@ func numericPriority(pod *v1.Pod, nodeNameToInfo map[string]*schedulernodeinfo.N
 		}
 		result = append(result, schedulerapi.HostPriority{
 			Host:  node.Name,
-			Score: score,
+			Score: float64(score),
 		})
 	}
 	return result, nil
@@ -108,7 +110,7 @@ func reverseNumericPriority(pod *v1.Pod, nodeNameToInfo map[string]*schedulernod
 	for _, hostPriority := range result {
 		reverseResult = append(reverseResult, schedulerapi.HostPriority{
 			Host:  hostPriority.Host,
-			Score: int(maxScore + minScore - float64(hostPriority.Score)),
+			Score: maxScore + minScore - hostPriority.Score,
 		})
 	}
 
@@ -465,7 +467,10 @@ func TestGenericScheduler(t *testing.T) {
 				test.alwaysCheckAllPredicates,
 				false,
 				schedulerapi.DefaultPercentageOfNodesToScore,
-				false)
+				false,
+				topology.New(nil, nil),
+				customcache.New(customcache.DefaultTTL),
+				nil)
 			result, err := scheduler.Schedule(test.pod, schedulertesting.FakeNodeLister(makeNodeList(test.nodes)))
 
 			if !reflect.DeepEqual(err, test.wErr) {
@@ -495,7 +500,7 @@ func makeScheduler(predicates map[string]algorithmpredicates.FitPredicate, nodes
 		priorities.EmptyPriorityMetadataProducer,
 		emptyFramework,
 		nil, nil, nil, nil, false, false,
-		schedulerapi.DefaultPercentageOfNodesToScore, false)
+		schedulerapi.DefaultPercentageOfNodesToScore, false, topology.New(nil, nil), customcache.New(customcache.DefaultTTL), nil)
 	cache.UpdateNodeInfoSnapshot(s.(*genericScheduler).nodeInfoSnapshot)
 	return s.(*genericScheduler)
 
@@ -716,7 +721,8 @@ func TestZeroRequest(t *testing.T) {
 				schedulertesting.FakeServiceLister([]*v1.Service{}),
 				schedulertesting.FakeControllerLister([]*v1.ReplicationController{}),
 				schedulertesting.FakeReplicaSetLister([]*apps.ReplicaSet{}),
-				schedulertesting.FakeStatefulSetLister([]*apps.StatefulSet{}))
+				schedulertesting.FakeStatefulSetLister([]*apps.StatefulSet{}),
+				nil)
 			metaData := metaDataProducer(test.pod, nodeNameToInfo)
 
 			list, err := PrioritizeNodes(
@@ -726,7 +732,7 @@ func TestZeroRequest(t *testing.T) {
 				t.Errorf("unexpected error: %v", err)
 			}
 			for _, hp := range list {
-				if hp.Score != test.expectedScore {
+				if hp.Score != float64(test.expectedScore) {
 					t.Errorf("expected %d for all priorities, got list %#v", test.expectedScore, list)
 				}
 			}
@@ -1545,7 +1551,10 @@ func TestPreempt(t *testing.T) {
 				false,
 				false,
 				schedulerapi.DefaultPercentageOfNodesToScore,
-				true)
+				true,
+				topology.New(nil, nil),
+				customcache.New(customcache.DefaultTTL),
+				nil)
 			scheduler.(*genericScheduler).snapshot()
 			// Call Preempt and check the expected results.
 			node, victims, _, err := scheduler.Preempt(test.pod, schedulertesting.FakeNodeLister(makeNodeList(nodeNames)), error(&FitError{Pod: test.pod, FailedPredicates: failedPredMap}))
@@ -0,0 +1,146 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	algorithmpredicates "k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/priorities"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	internalcache "k8s.io/kubernetes/pkg/scheduler/internal/cache"
+	internalqueue "k8s.io/kubernetes/pkg/scheduler/internal/queue"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring/fake"
+	schedulertesting "k8s.io/kubernetes/pkg/scheduler/testing"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// TestScheduleMetricsSnapshotRace schedules pods concurrently while the
+// metrics cache is refreshed and pods are assumed on it, so that the race
+// detector checks the scorers reading the per-cycle snapshot.
+func TestScheduleMetricsSnapshotRace(t *testing.T) {
+	const (
+		schedulers = 8
+		cycles     = 50
+	)
+	var topoNodes []topology.Node
+	var nodeNames []string
+	source := &fake.MetricsSource{Sockets: map[fake.Socket]map[string]float64{}, Cores: map[fake.Core]map[string]float64{}}
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("kube-%02d", i)
+		socket := i / 2
+		cores := []int{2 * i, 2*i + 1}
+		topoNodes = append(topoNodes, topology.Node{Name: name, Server: "server-a", Socket: socket, Cores: cores})
+		nodeNames = append(nodeNames, name)
+		source.Sockets[fake.Socket{Server: "server-a", Socket: socket}] = map[string]float64{"ipc": float64(socket + 1), "mem_read": 0.5, "mem_write": 0.5}
+		for _, core := range cores {
+			source.Cores[fake.Core{Server: "server-a", Socket: socket, Core: core}] = map[string]float64{"c6res": 0.5}
+		}
+	}
+	topo := topology.New([]topology.Server{{UUID: "server-a", Links: 2, LinkSpeed: 10, MaxFrequency: 2}}, topoNodes)
+	// The metrics expire quickly, so that the scorers race the refresher
+	// reading the backend and writing to the cache.
+	metricsCache := customcache.New(time.Millisecond)
+	nodeCache := internalcache.New(time.Duration(0), wait.NeverStop)
+	for _, name := range nodeNames {
+		nodeCache.AddNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	metaProducer := priorities.NewPriorityMetadataFactory(
+		schedulertesting.FakeServiceLister([]*v1.Service{}),
+		schedulertesting.FakeControllerLister([]*v1.ReplicationController{}),
+		schedulertesting.FakeReplicaSetLister([]*apps.ReplicaSet{}),
+		schedulertesting.FakeStatefulSetLister([]*apps.StatefulSet{}),
+		metricsCache)
+	customRequestedMap, customRequestedReduce := priorities.NewCustomRequestedPriority(topo, priorities.DefaultCustomScore, nil)
+	nodeSelectionMap, nodeSelectionReduce := priorities.NewNodeSelectionPriority(topo, nil)
+	prioritizers := []priorities.PriorityConfig{
+		{Name: "CustomRequestedPriority", Map: customRequestedMap, Reduce: customRequestedReduce, Weight: 1},
+		{Name: "NodeSelectionPriority", Map: nodeSelectionMap, Reduce: nodeSelectionReduce, Weight: 1},
+	}
+	nodeLister := schedulertesting.FakeNodeLister(makeNodeList(nodeNames))
+
+	stop := make(chan struct{})
+	var background sync.WaitGroup
+	background.Add(2)
+	go func() {
+		defer background.Done()
+		customcache.NewRefresher(metricsCache, topo, source, time.Millisecond).Run(stop)
+	}()
+	go func() {
+		// Refresh the cache and assume pods on it, as the refresher and the
+		// binding cycles do.
+		defer background.Done()
+		profile := map[string]float64{"ipc": 1, "c6res": 50}
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			node := nodeNames[i%len(nodeNames)]
+			metricsCache.Update(node, map[string]float64{"ipc": 1, "mem_read": 0.5, "mem_write": 0.5, "c6res": 1})
+			uid := types.UID(fmt.Sprintf("assumed-%d", i))
+			metricsCache.Assume(uid, node, profile, topo)
+			if i%2 == 0 {
+				metricsCache.Forget(uid)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, schedulers*cycles)
+	for s := 0; s < schedulers; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			// A generic scheduler runs one cycle at a time, the schedulers
+			// share the node cache and the metrics cache.
+			scheduler := NewGenericScheduler(
+				nodeCache,
+				internalqueue.NewSchedulingQueue(nil, nil),
+				map[string]algorithmpredicates.FitPredicate{"true": truePredicate},
+				algorithmpredicates.EmptyPredicateMetadataProducer,
+				prioritizers,
+				metaProducer,
+				emptyFramework,
+				nil, nil, nil, nil, false, false,
+				schedulerapi.DefaultPercentageOfNodesToScore, false, topo, metricsCache, nil)
+			for c := 0; c < cycles; c++ {
+				pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pod-%d-%d", s, c), UID: types.UID(fmt.Sprintf("pod-%d-%d", s, c))}}
+				if _, err := scheduler.Schedule(pod, nodeLister); err != nil {
+					errs <- err
+				}
+			}
+		}(s)
+	}
+	wg.Wait()
+	close(stop)
+	background.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"math"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/priorities"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// ScoringStage is a stage of the scoring of the feasible nodes. The nodes are
+// scored by the Prioritizers of the stage, then the Selection picks those
+// scored by the next stage.
+type ScoringStage struct {
+	Name         string
+	Prioritizers []priorities.PriorityConfig
+	Selection    schedulerapi.StageSelection
+}
+
+// selectNodes returns the nodes kept by selection, in their original order.
+// The groups compared by KeepBestGroup are looked up in topo, nodes missing
+// from it are groups of their own.
+func selectNodes(priorityList schedulerapi.HostPriorityList, nodes []*v1.Node, selection schedulerapi.StageSelection, topo topology.Interface) []*v1.Node {
+	if len(priorityList) == 0 {
+		return nil
+	}
+	kept := make(map[string]bool, len(priorityList))
+	switch selection.Rule {
+	case schedulerapi.KeepTopK:
+		sorted := make(schedulerapi.HostPriorityList, len(priorityList))
+		copy(sorted, priorityList)
+		sort.Sort(sort.Reverse(sorted))
+		for i := 0; i < selection.K && i < len(sorted); i++ {
+			kept[sorted[i].Host] = true
+		}
+	case schedulerapi.KeepWithinPercent:
+		best := priorityList[findMaxScores(priorityList)[0]].Score
+		threshold := best - math.Abs(best)*float64(selection.Percent)/100
+		for _, hp := range priorityList {
+			if hp.Score >= threshold {
+				kept[hp.Host] = true
+			}
+		}
+	default:
+		for _, i := range findMaxScores(groupScores(priorityList, topology.Level(selection.Level), topo)) {
+			kept[priorityList[i].Host] = true
+		}
+	}
+
+	selected := make([]*v1.Node, 0, len(kept))
+	for _, node := range nodes {
+		if kept[node.Name] {
+			selected = append(selected, node)
+		}
+	}
+	return selected
+}
+
+// groupScores returns the mean score of the topology group of the given level
+// of each host of priorityList, in the same order.
+func groupScores(priorityList schedulerapi.HostPriorityList, level topology.Level, topo topology.Interface) schedulerapi.HostPriorityList {
+	if len(level) == 0 || topo == nil {
+		return priorityList
+	}
+	tree := topo.Tree()
+	groups := make([]string, len(priorityList))
+	sums := map[string]float64{}
+	counts := map[string]int{}
+	for i, hp := range priorityList {
+		groups[i] = hp.Host
+		if g, ok := tree.Group(level, hp.Host); ok {
+			groups[i] = string(level) + ":" + g.ID
+		}
+		sums[groups[i]] += hp.Score
+		counts[groups[i]]++
+	}
+	res := make(schedulerapi.HostPriorityList, len(priorityList))
+	for i, hp := range priorityList {
+		res[i] = schedulerapi.HostPriority{Host: hp.Host, Score: sums[groups[i]] / float64(counts[groups[i]])}
+	}
+	return res
+}
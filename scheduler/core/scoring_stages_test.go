@@ -0,0 +1,105 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+func TestSelectNodes(t *testing.T) {
+	var nodes []*v1.Node
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		nodes = append(nodes, &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	priorityList := schedulerapi.HostPriorityList{
+		{Host: "a", Score: 80},
+		{Host: "b", Score: 100},
+		{Host: "c", Score: 95},
+		{Host: "d", Score: 100},
+		{Host: "e", Score: 0},
+	}
+	// a and b share a socket, c and d the other one, e is not in the topology.
+	topo := topology.New(nil, []topology.Node{
+		{Name: "a", Server: "server-a", Socket: 0},
+		{Name: "b", Server: "server-a", Socket: 0},
+		{Name: "c", Server: "server-a", Socket: 1},
+		{Name: "d", Server: "server-a", Socket: 1},
+	})
+
+	tests := []struct {
+		name      string
+		selection schedulerapi.StageSelection
+		expected  []string
+	}{
+		{
+			name:     "best group by default",
+			expected: []string{"b", "d"},
+		},
+		{
+			name:      "best group",
+			selection: schedulerapi.StageSelection{Rule: schedulerapi.KeepBestGroup},
+			expected:  []string{"b", "d"},
+		},
+		{
+			name:      "best socket",
+			selection: schedulerapi.StageSelection{Rule: schedulerapi.KeepBestGroup, Level: "socket"},
+			expected:  []string{"c", "d"},
+		},
+		{
+			name:      "best server",
+			selection: schedulerapi.StageSelection{Level: "server"},
+			expected:  []string{"a", "b", "c", "d"},
+		},
+		{
+			name:      "top K",
+			selection: schedulerapi.StageSelection{Rule: schedulerapi.KeepTopK, K: 3},
+			expected:  []string{"b", "c", "d"},
+		},
+		{
+			name:      "top K larger than the nodes",
+			selection: schedulerapi.StageSelection{Rule: schedulerapi.KeepTopK, K: 10},
+			expected:  []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name:      "within percent",
+			selection: schedulerapi.StageSelection{Rule: schedulerapi.KeepWithinPercent, Percent: 5},
+			expected:  []string{"b", "c", "d"},
+		},
+		{
+			name:      "within zero percent",
+			selection: schedulerapi.StageSelection{Rule: schedulerapi.KeepWithinPercent},
+			expected:  []string{"b", "d"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, node := range selectNodes(priorityList, nodes, test.selection, topo) {
+				got = append(got, node.Name)
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -1,182 +1,249 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package customcache holds the hardware counters of the nodes between
+// scheduling cycles, so that the monitoring database is not queried for
+// every pod.
 package customcache
 
 import (
 	"sync"
 	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
 )
 
-var LabCache *MlabCache
-var duration int = 10
-
-type MlabCache struct {
-	Cache   map[string]map[string]float64
-	Mux     sync.Mutex
-	Timeout *time.Ticker
-}
-
-func init() {
-	LabCache = &MlabCache{
-		Cache: map[string]map[string]float64{
-			"kube-01": map[string]float64{
-				"ipc":       -1,
-				"mem_read":  -1,
-				"mem_write": -1,
-				"c6res":     -1,
-			},
-			"kube-02": map[string]float64{
-				"ipc":       -1,
-				"mem_read":  -1,
-				"mem_write": -1,
-				"c6res":     -1,
-			},
-			"kube-03": map[string]float64{
-				"ipc":       -1,
-				"mem_read":  -1,
-				"mem_write": -1,
-				"c6res":     -1,
-			},
-			"kube-04": map[string]float64{
-				"ipc":       -1,
-				"mem_read":  -1,
-				"mem_write": -1,
-				"c6res":     -1,
-			},
-			"kube-05": map[string]float64{
-				"ipc":       -1,
-				"mem_read":  -1,
-				"mem_write": -1,
-				"c6res":     -1,
-			},
-			"kube-06": map[string]float64{
-				"ipc":       -1,
-				"mem_read":  -1,
-				"mem_write": -1,
-				"c6res":     -1,
-			},
-			"kube-07": map[string]float64{
-				"ipc":       -1,
-				"mem_read":  -1,
-				"mem_write": -1,
-				"c6res":     -1,
-			},
-			"kube-08": map[string]float64{
-				"ipc":       -1,
-				"mem_read":  -1,
-				"mem_write": -1,
-				"c6res":     -1,
-			},
-		},
+// DefaultTTL is how long a metric is used before it is read again from the
+// monitoring database.
+const DefaultTTL = 10 * time.Second
+
+// minC6res is the C6 residency left on a node once the pods assumed on it
+// are expected to keep all of its cores busy.
+const minC6res = 0.00000001
+
+// entry is a cached metric.
+type entry struct {
+	value   float64
+	updated time.Time
+	ttl     time.Duration
+}
+
+func (e entry) expired(now time.Time) bool {
+	return now.Sub(e.updated) >= e.ttl
+}
+
+// Cache holds the most recent metrics of each node. Every metric expires on
+// its own, and expired metrics are dropped when they are next read. Nodes
+// are added the first time one of their metrics is stored. The metrics
+// returned include the expected load of the pods assumed since they were
+// read. It is safe for concurrent use.
+type Cache struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	// retention is how long the expired metrics are kept as the last known
+	// ones.
+	retention time.Duration
+	nodes     map[string]map[string]entry
+	assumed   map[types.UID]*assumedPod
+	// unavailable is the error of the monitoring database at the last
+	// refresh, nil if it answered.
+	unavailable error
+	now         func() time.Time
+}
+
+// New returns an empty Cache whose metrics expire after ttl.
+func New(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		nodes:   map[string]map[string]entry{},
+		assumed: map[types.UID]*assumedPod{},
+		now:     time.Now,
 	}
-	LabCache.Timeout = time.NewTicker(time.Duration(10) * time.Second)
 }
 
-// func New() {
+// NewWithClock returns an empty Cache whose metrics expire after ttl on clk,
+// e.g. the virtual clock of a simulation.
+func NewWithClock(ttl time.Duration, clk clock.Clock) *Cache {
+	c := New(ttl)
+	c.now = clk.Now
+	return c
+}
+
+// get returns the entry of a metric of a node, dropping it if it has
+// expired. The caller must hold c.mu.
+func (c *Cache) get(node, metric string, now time.Time) (entry, bool) {
+	e, ok := c.nodes[node][metric]
+	if !ok {
+		return entry{}, false
+	}
+	if e.expired(now) {
+		if now.Sub(e.updated) >= c.retention {
+			delete(c.nodes[node], metric)
+			if len(c.nodes[node]) == 0 {
+				delete(c.nodes, node)
+			}
+		}
+		return entry{}, false
+	}
+	return e, true
+}
+
+// SetRetention keeps the expired metrics up to retention old, so that
+// GetLastKnown can read them while the monitoring database is unavailable.
+func (c *Cache) SetRetention(retention time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retention = retention
+}
+
+// SetUnavailable records that the monitoring database failed with err at the
+// last refresh, or answered if err is nil.
+func (c *Cache) SetUnavailable(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unavailable = err
+}
 
-// }
+// Unavailable returns the error of the monitoring database at the last
+// refresh, nil if it answered or was never queried.
+func (c *Cache) Unavailable() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.unavailable
+}
 
-// var Τimeout *time.Ticker
+// Get returns a metric of a node and whether it is present and fresh.
+func (c *Cache) Get(node, metric string) (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.get(node, metric, c.now())
+	if !ok {
+		return 0, false
+	}
+	return c.adjust(node, metric, e), true
+}
 
-func (c *MlabCache) CleanCache() {
-	c.Mux.Lock()
+// GetAll returns the given metrics of a node, if all of them are present and
+// fresh.
+func (c *Cache) GetAll(node string, metrics ...string) (map[string]float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	res := make(map[string]float64, len(metrics))
+	for _, metric := range metrics {
+		e, ok := c.get(node, metric, now)
+		if !ok {
+			return nil, false
+		}
+		res[metric] = c.adjust(node, metric, e)
+	}
+	return res, true
+}
 
-	for k, v := range c.Cache {
-		for key, _ := range v {
-			c.Cache[k][key] = -1
+// Age returns the age of the oldest of the given metrics of a node, if all of
+// them are present and fresh.
+func (c *Cache) Age(node string, metrics ...string) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	var age time.Duration
+	for _, metric := range metrics {
+		e, ok := c.get(node, metric, now)
+		if !ok {
+			return 0, false
+		}
+		if a := now.Sub(e.updated); a > age {
+			age = a
 		}
 	}
-	// c.Cache = map[string]map[string]float64{
-	// 	"kube-01": map[string]float64{
-	// 		"ipc":       -1,
-	// 		"mem_read":  -1,
-	// 		"mem_write": -1,
-	// 		"c6res":     -1,
-	// 	},
-	// 	"kube-02": map[string]float64{
-	// 		"ipc":       -1,
-	// 		"mem_read":  -1,
-	// 		"mem_write": -1,
-	// 		"c6res":     -1,
-	// 	},
-	// 	"kube-03": map[string]float64{
-	// 		"ipc":       -1,
-	// 		"mem_read":  -1,
-	// 		"mem_write": -1,
-	// 		"c6res":     -1,
-	// 	},
-	// 	"kube-04": map[string]float64{
-	// 		"ipc":       -1,
-	// 		"mem_read":  -1,
-	// 		"mem_write": -1,
-	// 		"c6res":     -1,
-	// 	},
-	// 	"kube-05": map[string]float64{
-	// 		"ipc":       -1,
-	// 		"mem_read":  -1,
-	// 		"mem_write": -1,
-	// 		"c6res":     -1,
-	// 	},
-	// 	"kube-06": map[string]float64{
-	// 		"ipc":       -1,
-	// 		"mem_read":  -1,
-	// 		"mem_write": -1,
-	// 		"c6res":     -1,
-	// 	},
-	// 	"kube-07": map[string]float64{
-	// 		"ipc":       -1,
-	// 		"mem_read":  -1,
-	// 		"mem_write": -1,
-	// 		"c6res":     -1,
-	// 	},
-	// 	"kube-08": map[string]float64{
-	// 		"ipc":       -1,
-	// 		"mem_read":  -1,
-	// 		"mem_write": -1,
-	// 		"c6res":     -1,
-	// 	},
-	// }
-	//c.Timeout.Stop()
-	//Timeout := time.NewTicker(time.Duration(10 * time.Second))
-	c.Mux.Unlock()
-}
-
-func (c *MlabCache) UpdateCache(input map[string]float64, c6res float64, nodename string) error {
-	c.Mux.Lock()
-	c.Cache[nodename]["ipc"] = input["ipc"]
-	c.Cache[nodename]["mem_read"] = input["mem_read"]
-	c.Cache[nodename]["mem_write"] = input["mem_write"]
-	c.Cache[nodename]["c6res"] = c6res
-
-	// Reset the ticker
-	c.Timeout = time.NewTicker(time.Duration(duration) * time.Second)
-	//klog.Infof("Reset the Ticker")
-	c.Mux.Unlock()
-
-	//klog.Infof("After cache update")
-	c.printCached(nodename)
-	return nil
-}
-
-func (c *MlabCache) AddAppMetrics(app map[string]float64, nodename string, numCores int, win bool) {
-	c.Mux.Lock()
-	c.Cache[nodename]["mem_read"] += app["mem_read"]
-	c.Cache[nodename]["mem_write"] += app["mem_write"]
-	//TODO
-	// handle c6res addition
-	if win {
-		c.Cache[nodename]["c6res"] -= (100 - app["c6res"]) / float64(100*numCores)
-		if c.Cache[nodename]["c6res"] <= 0 {
-			c.Cache[nodename]["c6res"] = 0.000000000000001
+	return age, true
+}
+
+// GetLastKnown returns the given metrics of a node and the age of the oldest
+// of them, if all of them are present and at most maxAge old, whether they
+// have expired or not. Expired metrics are only kept for the retention of the
+// cache.
+func (c *Cache) GetLastKnown(node string, maxAge time.Duration, metrics ...string) (map[string]float64, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	res := make(map[string]float64, len(metrics))
+	var age time.Duration
+	for _, metric := range metrics {
+		e, ok := c.nodes[node][metric]
+		if !ok || now.Sub(e.updated) > maxAge {
+			return nil, 0, false
 		}
+		if a := now.Sub(e.updated); a > age {
+			age = a
+		}
+		res[metric] = c.adjust(node, metric, e)
 	}
+	return res, age, true
+}
+
+// Set stores a metric of a node with the default TTL of the cache.
+func (c *Cache) Set(node, metric string, value float64) {
+	c.SetWithTTL(node, metric, value, c.ttl)
+}
+
+// SetWithTTL stores a metric of a node that expires after ttl.
+func (c *Cache) SetWithTTL(node, metric string, value float64, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.set(node, metric, entry{value: value, updated: now, ttl: ttl})
+	c.prune(now)
+}
+
+// set stores an entry. The caller must hold c.mu.
+func (c *Cache) set(node, metric string, e entry) {
+	if _, ok := c.nodes[node]; !ok {
+		c.nodes[node] = map[string]entry{}
+	}
+	c.nodes[node][metric] = e
+}
+
+// Update stores the metrics of a node read from the monitoring database.
+func (c *Cache) Update(node string, metrics map[string]float64) {
+	c.UpdateWithTTL(node, metrics, c.ttl)
+}
+
+// UpdateWithTTL stores the metrics of a node, expiring after ttl.
+func (c *Cache) UpdateWithTTL(node string, metrics map[string]float64, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for metric, value := range metrics {
+		c.set(node, metric, entry{value: value, updated: now, ttl: ttl})
+	}
+	c.prune(now)
+}
 
-	//TODO
-	// handle ipc addition
-	c.Mux.Unlock()
-	//klog.Infof("After application metrics addition")
-	c.printCached(nodename)
+// Invalidate drops the metrics of a node.
+func (c *Cache) Invalidate(node string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.nodes, node)
 }
 
-func (c *MlabCache) printCached(nodename string) {
-	//klog.Infof("IPC: %v, Reads: %v,  Writes: %v, C6res: %v", c.Cache[nodename]["ipc"], c.Cache[nodename]["mem_read"],
-	//c.Cache[nodename]["mem_write"], c.Cache[nodename]["c6res"])
+// Clear drops the metrics of every node. The assumed pods are kept.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nodes = map[string]map[string]entry{}
 }
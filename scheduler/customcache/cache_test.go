@@ -0,0 +1,110 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package customcache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestCache returns a Cache with a TTL of 10s and a clock under the
+// control of the test.
+func newTestCache() (*Cache, *time.Time) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New(10 * time.Second)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c, now := newTestCache()
+	if _, ok := c.Get("kube-01", "ipc"); ok {
+		t.Errorf("expected an unknown node to be missing")
+	}
+
+	c.Update("kube-01", map[string]float64{"ipc": 2, "c6res": 0.5})
+	*now = now.Add(5 * time.Second)
+	c.SetWithTTL("kube-01", "mem_read", 0.25, time.Second)
+	if val, ok := c.Get("kube-01", "ipc"); !ok || val != 2 {
+		t.Errorf("expected ipc 2, got %v, %v", val, ok)
+	}
+
+	*now = now.Add(time.Second)
+	if _, ok := c.Get("kube-01", "mem_read"); ok {
+		t.Errorf("expected mem_read to expire after its own TTL")
+	}
+	if _, ok := c.GetAll("kube-01", "ipc", "mem_read"); ok {
+		t.Errorf("expected GetAll to fail with a missing metric")
+	}
+	if res, ok := c.GetAll("kube-01", "ipc", "c6res"); !ok || !reflect.DeepEqual(res, map[string]float64{"ipc": 2, "c6res": 0.5}) {
+		t.Errorf("unexpected metrics %v, %v", res, ok)
+	}
+
+	*now = now.Add(4 * time.Second)
+	if _, ok := c.Get("kube-01", "ipc"); ok {
+		t.Errorf("expected ipc to expire after the default TTL")
+	}
+	if _, ok := c.nodes["kube-01"]["ipc"]; ok {
+		t.Errorf("expected ipc to be dropped when read")
+	}
+}
+
+func TestCacheLastKnown(t *testing.T) {
+	c, now := newTestCache()
+	c.SetRetention(time.Minute)
+	c.Update("kube-01", map[string]float64{"ipc": 2, "c6res": 0.5})
+
+	*now = now.Add(30 * time.Second)
+	if _, ok := c.Get("kube-01", "ipc"); ok {
+		t.Errorf("expected ipc to expire after the default TTL")
+	}
+	if res, age, ok := c.GetLastKnown("kube-01", time.Minute, "ipc", "c6res"); !ok || age != 30*time.Second || !reflect.DeepEqual(res, map[string]float64{"ipc": 2, "c6res": 0.5}) {
+		t.Errorf("expected the expired metrics read 30s ago, got %v, %v, %v", res, age, ok)
+	}
+	if _, _, ok := c.GetLastKnown("kube-01", 20*time.Second, "ipc"); ok {
+		t.Errorf("expected ipc to be older than 20s")
+	}
+	if _, _, ok := c.GetLastKnown("kube-01", time.Minute, "ipc", "mem_read"); ok {
+		t.Errorf("expected GetLastKnown to fail with a missing metric")
+	}
+
+	*now = now.Add(30 * time.Second)
+	c.Get("kube-01", "ipc")
+	if _, _, ok := c.GetLastKnown("kube-01", time.Hour, "ipc"); ok {
+		t.Errorf("expected ipc to be dropped after the retention")
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	c, _ := newTestCache()
+	c.Set("kube-01", "ipc", 1)
+	c.Set("kube-02", "ipc", 1)
+
+	c.Invalidate("kube-01")
+	if _, ok := c.Get("kube-01", "ipc"); ok {
+		t.Errorf("expected kube-01 to be invalidated")
+	}
+	if _, ok := c.Get("kube-02", "ipc"); !ok {
+		t.Errorf("expected kube-02 to be kept")
+	}
+
+	c.Clear()
+	if _, ok := c.Get("kube-02", "ipc"); ok {
+		t.Errorf("expected kube-02 to be cleared")
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package customcache
+
+import (
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// settleWindow is how long after a pod is assumed the monitoring data is
+// expected to reflect its load, i.e. the window the metrics are averaged
+// over.
+const settleWindow = 20 * time.Second
+
+// assumedPod is the expected load of a pod placed on a node since its
+// metrics were last read.
+type assumedPod struct {
+	uid  types.UID
+	node string
+	// socketNodes are the nodes sharing the socket of node, node included.
+	socketNodes map[string]bool
+	// socketCores is the number of cores of the socket.
+	socketCores int
+	profile     map[string]float64
+	assumed     time.Time
+}
+
+// weight returns the fraction of the load of the pod missing from a metric
+// read at updated. It decays linearly to zero over settleWindow.
+func (p *assumedPod) weight(updated time.Time) float64 {
+	d := updated.Sub(p.assumed)
+	switch {
+	case d <= 0:
+		return 1
+	case d >= settleWindow:
+		return 0
+	default:
+		return 1 - float64(d)/float64(settleWindow)
+	}
+}
+
+// adjust returns a metric of a node read at updated with the missing load of
+// the pod added. Memory traffic adds up over the socket, the ipc of the
+// socket moves towards the ipc of the pod, and the pod is expected to keep a
+// core of its node busy outside of C6.
+func (p *assumedPod) adjust(node, metric string, value float64, updated time.Time) float64 {
+	if !p.socketNodes[node] {
+		return value
+	}
+	w := p.weight(updated)
+	if w == 0 {
+		return value
+	}
+	switch metric {
+	case "mem_read", "mem_write":
+		value += w * p.profile[metric]
+	case "ipc":
+		if p.socketCores > 0 {
+			value += w * (p.profile["ipc"] - value) / float64(p.socketCores)
+		}
+	case "c6res":
+		// c6res is summed over the cores of the node, and the profile holds
+		// the C6 residency of the pod in percent.
+		if node == p.node {
+			value -= w * (100 - p.profile["c6res"]) / 100
+			if value <= 0 {
+				value = minC6res
+			}
+		}
+	}
+	return value
+}
+
+// Assume adds the expected load of a pod placed on node to the metrics of
+// the node and of the nodes sharing its socket in topo, until monitoring
+// data read long enough after now reflects it or the pod is forgotten.
+func (c *Cache) Assume(uid types.UID, node string, profile map[string]float64, topo topology.Interface) {
+	p := &assumedPod{
+		uid:         uid,
+		node:        node,
+		socketNodes: map[string]bool{node: true},
+		profile:     profile,
+	}
+	if topo != nil {
+		if n, ok := topo.Node(node); ok {
+			for _, name := range topo.SocketNodes(n.Server, n.Socket) {
+				p.socketNodes[name] = true
+				if sn, ok := topo.Node(name); ok {
+					p.socketCores += len(sn.Cores)
+				}
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p.assumed = c.now()
+	c.assumed[uid] = p
+	c.prune(p.assumed)
+}
+
+// Renew restarts the settling of the load of an assumed pod, e.g. while it
+// waits at permit and no monitoring data can reflect it. A pod not assumed,
+// or already forgotten, is left out.
+func (c *Cache) Renew(uid types.UID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.assumed[uid]; ok {
+		p.assumed = c.now()
+	}
+}
+
+// Forget drops the load of a pod, e.g. because its binding failed or it was
+// deleted.
+func (c *Cache) Forget(uid types.UID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.assumed, uid)
+}
+
+// adjust returns a metric of a node with the missing load of the assumed
+// pods added, oldest first. The caller must hold c.mu.
+func (c *Cache) adjust(node, metric string, e entry) float64 {
+	if len(c.assumed) == 0 {
+		return e.value
+	}
+	pods := make([]*assumedPod, 0, len(c.assumed))
+	for _, p := range c.assumed {
+		pods = append(pods, p)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if !pods[i].assumed.Equal(pods[j].assumed) {
+			return pods[i].assumed.Before(pods[j].assumed)
+		}
+		return pods[i].uid < pods[j].uid
+	})
+	value := e.value
+	for _, p := range pods {
+		value = p.adjust(node, metric, value, e.updated)
+	}
+	return value
+}
+
+// prune drops the pods whose load is reflected by every cached metric of
+// their socket, and will be by any metric read from now on. The caller must
+// hold c.mu.
+func (c *Cache) prune(now time.Time) {
+	for uid, p := range c.assumed {
+		if now.Sub(p.assumed) < settleWindow {
+			continue
+		}
+		settled := true
+		for node := range p.socketNodes {
+			for _, e := range c.nodes[node] {
+				if !e.expired(now) && p.weight(e.updated) > 0 {
+					settled = false
+				}
+			}
+		}
+		if settled {
+			delete(c.assumed, uid)
+		}
+	}
+}
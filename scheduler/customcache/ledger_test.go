@@ -0,0 +1,136 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package customcache
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var testProfile = map[string]float64{"ipc": 1, "mem_read": 0.5, "mem_write": 0.25, "c6res": 60}
+
+func expectMetrics(t *testing.T, c *Cache, node string, expected map[string]float64) {
+	t.Helper()
+	for metric, val := range expected {
+		got, ok := c.Get(node, metric)
+		if !ok || math.Abs(got-val) > 1e-9 {
+			t.Errorf("expected %s %v for %s, got %v, %v", metric, val, node, got, ok)
+		}
+	}
+}
+
+func TestAssume(t *testing.T) {
+	measured := map[string]float64{"ipc": 2, "mem_read": 1, "mem_write": 1, "c6res": 1.5}
+	tests := []struct {
+		name     string
+		elapsed  time.Duration
+		expected map[string]map[string]float64
+	}{
+		{
+			// The socket of kube-01 has 4 cores, and the pod keeps 40% of a
+			// core of kube-01 out of C6.
+			name: "metrics read before the pod",
+			expected: map[string]map[string]float64{
+				"kube-01": {"ipc": 1.75, "mem_read": 1.5, "mem_write": 1.25, "c6res": 1.1},
+				"kube-02": {"ipc": 1.75, "mem_read": 1.5, "mem_write": 1.25, "c6res": 1.5},
+				"kube-03": measured,
+			},
+		},
+		{
+			name:    "metrics partly reflecting the pod",
+			elapsed: settleWindow / 2,
+			expected: map[string]map[string]float64{
+				"kube-01": {"ipc": 1.875, "mem_read": 1.25, "mem_write": 1.125, "c6res": 1.3},
+				"kube-02": {"ipc": 1.875, "mem_read": 1.25, "mem_write": 1.125, "c6res": 1.5},
+				"kube-03": measured,
+			},
+		},
+		{
+			name:    "metrics reflecting the pod",
+			elapsed: settleWindow,
+			expected: map[string]map[string]float64{
+				"kube-01": measured,
+				"kube-02": measured,
+				"kube-03": measured,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, now := newTestCache()
+			c.ttl = time.Minute
+			c.Assume("pod-1", "kube-01", testProfile, newTestTopology())
+			*now = now.Add(test.elapsed)
+			for _, node := range []string{"kube-01", "kube-02", "kube-03"} {
+				c.Update(node, measured)
+			}
+			for node, expected := range test.expected {
+				expectMetrics(t, c, node, expected)
+			}
+			if pruned := len(c.assumed) == 0; pruned != (test.elapsed >= settleWindow) {
+				t.Errorf("unexpected assumed pods %v", c.assumed)
+			}
+		})
+	}
+}
+
+func TestAssumeBusyNode(t *testing.T) {
+	c, _ := newTestCache()
+	c.Update("kube-01", map[string]float64{"c6res": 0.2})
+	c.Assume("pod-1", "kube-01", testProfile, newTestTopology())
+	expectMetrics(t, c, "kube-01", map[string]float64{"c6res": minC6res})
+}
+
+func TestForget(t *testing.T) {
+	c, now := newTestCache()
+	c.ttl = time.Minute
+	c.Update("kube-01", map[string]float64{"mem_read": 1})
+	c.Assume("pod-1", "kube-01", testProfile, newTestTopology())
+	c.Assume("pod-2", "kube-01", testProfile, nil)
+	expectMetrics(t, c, "kube-01", map[string]float64{"mem_read": 2})
+
+	c.Forget("pod-1")
+	expectMetrics(t, c, "kube-01", map[string]float64{"mem_read": 1.5})
+
+	// The load of a pod is kept until metrics read after it reflect it.
+	*now = now.Add(settleWindow)
+	c.Update("kube-02", map[string]float64{"mem_read": 1})
+	if _, ok := c.assumed["pod-2"]; !ok {
+		t.Errorf("expected pod-2 to be kept")
+	}
+	expectMetrics(t, c, "kube-01", map[string]float64{"mem_read": 1.5})
+}
+
+func TestRenew(t *testing.T) {
+	c, now := newTestCache()
+	c.ttl = time.Minute
+	c.Assume("pod-1", "kube-01", testProfile, newTestTopology())
+	c.Assume("pod-2", "kube-01", testProfile, newTestTopology())
+	c.Forget("pod-2")
+
+	// The renewed load is missing from the metrics read since.
+	*now = now.Add(settleWindow / 2)
+	c.Renew("pod-1")
+	c.Renew("pod-2")
+	*now = now.Add(settleWindow / 2)
+	c.Update("kube-01", map[string]float64{"mem_read": 1})
+	expectMetrics(t, c, "kube-01", map[string]float64{"mem_read": 1.25})
+	if _, ok := c.assumed["pod-2"]; ok {
+		t.Errorf("expected the forgotten pod-2 not to be renewed")
+	}
+}
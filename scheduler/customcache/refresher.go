@@ -0,0 +1,203 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package customcache
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+const (
+	// DefaultRefreshPeriod is how often the metrics of every node are read
+	// from the monitoring database.
+	DefaultRefreshPeriod = 5 * time.Second
+
+	// maxRefreshBackoff bounds the delay between refreshes while the
+	// monitoring database fails.
+	maxRefreshBackoff = 2 * time.Minute
+	// refreshJitter is the maximum fraction of the delay added to it, so that
+	// the schedulers of a cluster do not query the database in lockstep.
+	refreshJitter = 0.2
+	// refreshTTLPeriods is the number of refresh periods a refreshed metric
+	// is kept, so that it survives a failed refresh.
+	refreshTTLPeriods = 3
+	// refreshWindow is the period over which the refreshed metrics are
+	// averaged.
+	refreshWindow = 20 * time.Second
+)
+
+// SocketMetrics are the metrics of a socket, cached for each of its nodes.
+var SocketMetrics = []string{"ipc", "mem_read", "mem_write"}
+
+var (
+	extraSocketMetricsMutex sync.RWMutex
+	extraSocketMetrics      = sets.NewString()
+)
+
+// RegisterSocketMetrics adds metrics of a socket that the refreshers cache
+// besides the SocketMetrics, for the priorities that refer to them. They are
+// read in a query of their own, so that a metric some servers do not report
+// leaves the SocketMetrics of those servers cached.
+func RegisterSocketMetrics(names ...string) {
+	extraSocketMetricsMutex.Lock()
+	defer extraSocketMetricsMutex.Unlock()
+	extraSocketMetrics.Insert(names...)
+}
+
+// registeredSocketMetrics returns the sorted registered metrics that are not
+// SocketMetrics.
+func registeredSocketMetrics() []string {
+	extraSocketMetricsMutex.RLock()
+	defer extraSocketMetricsMutex.RUnlock()
+	return extraSocketMetrics.Difference(sets.NewString(SocketMetrics...)).List()
+}
+
+// Refresher periodically reads the metrics of every node of the topology
+// into a Cache, so that scoring a node does not wait for the monitoring
+// database.
+type Refresher struct {
+	cache    *Cache
+	topology topology.Interface
+	source   monitoring.MetricsSource
+	period   time.Duration
+}
+
+// NewRefresher returns a Refresher that reads the metrics of the nodes of
+// topo from source into cache every period, which must be positive.
+func NewRefresher(cache *Cache, topo topology.Interface, source monitoring.MetricsSource, period time.Duration) *Refresher {
+	return &Refresher{
+		cache:    cache,
+		topology: topo,
+		source:   source,
+		period:   period,
+	}
+}
+
+// Run refreshes the cache until stopCh is closed. While the monitoring
+// database fails, the delay between refreshes doubles up to
+// maxRefreshBackoff.
+func (r *Refresher) Run(stopCh <-chan struct{}) {
+	delay := r.period
+	for {
+		if err := r.Refresh(); err != nil {
+			delay *= 2
+			if delay > maxRefreshBackoff {
+				delay = maxRefreshBackoff
+			}
+			klog.Errorf("Error while refreshing the hardware metrics, retrying in %v: %v", delay, err)
+		} else {
+			delay = r.period
+		}
+
+		t := time.NewTimer(wait.Jitter(delay, refreshJitter))
+		select {
+		case <-stopCh:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// socketKey identifies a socket of a server.
+type socketKey struct {
+	server string
+	socket int
+}
+
+// Refresh reads the metrics of every node once. Nodes without usable
+// samples are skipped; the error of the monitoring database, if any, is
+// returned and recorded in the cache as an UnavailableError, so that the
+// priorities tell an outage from nodes without metrics.
+func (r *Refresher) Refresh() error {
+	ttl := refreshTTLPeriods * r.period
+	names := r.topology.NodeNames()
+	sort.Strings(names)
+
+	sockets := map[socketKey][]string{}
+	var errs []error
+	for _, name := range names {
+		node, ok := r.topology.Node(name)
+		if !ok {
+			continue
+		}
+		key := socketKey{server: node.Server, socket: node.Socket}
+		sockets[key] = append(sockets[key], name)
+
+		if len(node.Cores) == 0 {
+			continue
+		}
+		average, err := r.source.CoreMetrics(node.Server, node.Socket, node.Cores, []string{"c6res"}, refreshWindow)
+		if err != nil {
+			if err := r.handle(name, err); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		// The C6 residency is cached summed over the cores of the node.
+		r.cache.SetWithTTL(name, "c6res", average["c6res"]*float64(len(node.Cores)), ttl)
+	}
+
+	extra := registeredSocketMetrics()
+	for key, nodes := range sockets {
+		for _, names := range [][]string{SocketMetrics, extra} {
+			if len(names) == 0 {
+				continue
+			}
+			metrics, err := r.source.SocketMetrics(key.server, key.socket, names, refreshWindow)
+			if err != nil {
+				if err := r.handle(nodes[0], err); err != nil {
+					errs = append(errs, err)
+				}
+				continue
+			}
+			for _, name := range nodes {
+				r.cache.UpdateWithTTL(name, metrics, ttl)
+			}
+		}
+	}
+	err := utilerrors.NewAggregate(errs)
+	r.cache.SetUnavailable(unavailable(err))
+	return err
+}
+
+// unavailable returns err, an error of the monitoring database, as an
+// UnavailableError, or nil if err is nil.
+func unavailable(err error) error {
+	if err == nil || monitoring.IsUnavailable(err) {
+		return err
+	}
+	return &monitoring.UnavailableError{Err: err}
+}
+
+// handle logs an error of the query of the metrics of a node and returns it
+// if it is an error of the monitoring database rather than missing data.
+func (r *Refresher) handle(node string, err error) error {
+	if _, ok := monitoring.ReasonOf(err); ok {
+		klog.V(4).Infof("No usable metrics for node %v: %v", node, err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package customcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring/fake"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+func newTestTopology() topology.Interface {
+	return topology.New(
+		[]topology.Server{{UUID: "server-a"}},
+		[]topology.Node{
+			{Name: "kube-01", Server: "server-a", Socket: 0, Cores: []int{0, 1}},
+			{Name: "kube-02", Server: "server-a", Socket: 0, Cores: []int{2, 3}},
+			{Name: "kube-03", Server: "server-a", Socket: 1, Cores: []int{4, 5}},
+		},
+	)
+}
+
+func newTestSource() *fake.MetricsSource {
+	source := &fake.MetricsSource{
+		Sockets: map[fake.Socket]map[string]float64{
+			{Server: "server-a", Socket: 0}: {"ipc": 2, "mem_read": 0.5, "mem_write": 0.5},
+		},
+		Cores: map[fake.Core]map[string]float64{},
+	}
+	for core := 0; core < 4; core++ {
+		source.Cores[fake.Core{Server: "server-a", Socket: 0, Core: core}] = map[string]float64{"c6res": 0.25}
+	}
+	return source
+}
+
+func TestRefresh(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    monitoring.MetricsSource
+		expected  map[string]map[string]float64
+		expectErr bool
+	}{
+		{
+			// Socket 1 has no samples, which is not an error of the database.
+			name:   "missing samples",
+			source: newTestSource(),
+			expected: map[string]map[string]float64{
+				"kube-01": {"ipc": 2, "mem_read": 0.5, "mem_write": 0.5, "c6res": 0.5},
+				"kube-02": {"ipc": 2, "mem_read": 0.5, "mem_write": 0.5, "c6res": 0.5},
+				"kube-03": {},
+			},
+		},
+		{
+			name:      "database unreachable",
+			source:    &fake.MetricsSource{Err: fmt.Errorf("connection refused")},
+			expected:  map[string]map[string]float64{"kube-01": {}, "kube-02": {}, "kube-03": {}},
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, now := newTestCache()
+			r := NewRefresher(c, newTestTopology(), test.source, time.Second)
+			if err := r.Refresh(); (err != nil) != test.expectErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// The snapshots tell an outage from the missing samples.
+			if err := c.Snapshot().Unavailable(); monitoring.IsUnavailable(err) != test.expectErr {
+				t.Errorf("unexpected unavailability of the database: %v", err)
+			}
+			for node, metrics := range test.expected {
+				if len(c.nodes[node]) != len(metrics) {
+					t.Errorf("expected %v for %s, got %v", metrics, node, c.nodes[node])
+				}
+				for metric, expected := range metrics {
+					if val, ok := c.Get(node, metric); !ok || val != expected {
+						t.Errorf("expected %s %v for %s, got %v, %v", metric, expected, node, val, ok)
+					}
+				}
+			}
+
+			// Refreshed metrics outlive a failed refresh, but not two.
+			*now = now.Add(2 * time.Second)
+			if age, ok := c.Age("kube-01", "ipc", "c6res"); len(test.expected["kube-01"]) > 0 && (!ok || age != 2*time.Second) {
+				t.Errorf("expected metrics 2s old, got %v, %v", age, ok)
+			}
+			*now = now.Add(time.Second)
+			if _, ok := c.Get("kube-01", "ipc"); ok {
+				t.Errorf("expected the refreshed metrics to expire")
+			}
+
+			// The database answers again.
+			NewRefresher(c, newTestTopology(), newTestSource(), time.Second).Refresh()
+			if err := c.Unavailable(); err != nil {
+				t.Errorf("expected the database available, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRefresherRun(t *testing.T) {
+	c := New(DefaultTTL)
+	r := NewRefresher(c, newTestTopology(), newTestSource(), time.Millisecond)
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		r.Run(stopCh)
+		close(done)
+	}()
+
+	if err := wait.Poll(time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+		_, ok := c.Get("kube-01", "c6res")
+		return ok, nil
+	}); err != nil {
+		t.Errorf("expected the cache to be refreshed: %v", err)
+	}
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Errorf("expected the refresher to stop")
+	}
+}
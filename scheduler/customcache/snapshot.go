@@ -0,0 +1,142 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package customcache
+
+import "time"
+
+// Reader reads the metrics of the nodes. It is implemented by Cache, and by
+// Snapshot without locking.
+type Reader interface {
+	// Get returns a metric of a node and whether it is present and fresh.
+	Get(node, metric string) (float64, bool)
+	// GetAll returns the given metrics of a node, if all of them are present
+	// and fresh.
+	GetAll(node string, metrics ...string) (map[string]float64, bool)
+	// GetLastKnown returns the given metrics of a node and the age of the
+	// oldest of them, if all of them are present and at most maxAge old.
+	GetLastKnown(node string, maxAge time.Duration, metrics ...string) (map[string]float64, time.Duration, bool)
+	// Unavailable returns the error of the monitoring database at the last
+	// refresh, nil if it answered, so that missing metrics can be told from
+	// an outage.
+	Unavailable() error
+}
+
+var (
+	_ Reader = &Cache{}
+	_ Reader = &Snapshot{}
+)
+
+// snapshotEntry is a metric of a Snapshot, adjusted for the pods assumed when
+// it was taken.
+type snapshotEntry struct {
+	value   float64
+	updated time.Time
+	fresh   bool
+}
+
+// Snapshot is an immutable copy of the metrics of a Cache, including the
+// expected load of the pods assumed when it was taken and the expired metrics
+// it retains. A snapshot is taken once per scheduling cycle, so that the
+// priority functions scoring the nodes in parallel read consistent metrics
+// without contending for the cache. The priorities read no other metrics: those
+// refreshed during the cycle are for the next cycles. It is safe for
+// concurrent use. A nil Snapshot holds no metric.
+type Snapshot struct {
+	taken       time.Time
+	nodes       map[string]map[string]snapshotEntry
+	unavailable error
+}
+
+// Snapshot returns a copy of the current metrics of the cache.
+func (c *Cache) Snapshot() *Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	s := &Snapshot{taken: now, nodes: make(map[string]map[string]snapshotEntry, len(c.nodes)), unavailable: c.unavailable}
+	for node, metrics := range c.nodes {
+		entries := make(map[string]snapshotEntry, len(metrics))
+		for metric, e := range metrics {
+			expired := e.expired(now)
+			if expired && now.Sub(e.updated) >= c.retention {
+				continue
+			}
+			entries[metric] = snapshotEntry{value: c.adjust(node, metric, e), updated: e.updated, fresh: !expired}
+		}
+		s.nodes[node] = entries
+	}
+	return s
+}
+
+// Get returns a metric of a node and whether it was present and fresh.
+func (s *Snapshot) Get(node, metric string) (float64, bool) {
+	if s == nil {
+		return 0, false
+	}
+	e, ok := s.nodes[node][metric]
+	if !ok || !e.fresh {
+		return 0, false
+	}
+	return e.value, true
+}
+
+// GetAll returns the given metrics of a node, if all of them were present and
+// fresh.
+func (s *Snapshot) GetAll(node string, metrics ...string) (map[string]float64, bool) {
+	if s == nil {
+		return nil, false
+	}
+	res := make(map[string]float64, len(metrics))
+	for _, metric := range metrics {
+		e, ok := s.nodes[node][metric]
+		if !ok || !e.fresh {
+			return nil, false
+		}
+		res[metric] = e.value
+	}
+	return res, true
+}
+
+// Unavailable returns the error of the monitoring database at the last
+// refresh before the snapshot was taken, nil if it answered.
+func (s *Snapshot) Unavailable() error {
+	if s == nil {
+		return nil
+	}
+	return s.unavailable
+}
+
+// GetLastKnown returns the given metrics of a node and the age of the oldest
+// of them when the snapshot was taken, if all of them were present and at
+// most maxAge old, whether they had expired or not.
+func (s *Snapshot) GetLastKnown(node string, maxAge time.Duration, metrics ...string) (map[string]float64, time.Duration, bool) {
+	if s == nil {
+		return nil, 0, false
+	}
+	res := make(map[string]float64, len(metrics))
+	var age time.Duration
+	for _, metric := range metrics {
+		e, ok := s.nodes[node][metric]
+		if !ok || s.taken.Sub(e.updated) > maxAge {
+			return nil, 0, false
+		}
+		if a := s.taken.Sub(e.updated); a > age {
+			age = a
+		}
+		res[metric] = e.value
+	}
+	return res, age, true
+}
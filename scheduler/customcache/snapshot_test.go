@@ -0,0 +1,57 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package customcache
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	c, now := newTestCache()
+	c.SetRetention(time.Minute)
+	c.Assume("pod-1", "kube-01", testProfile, newTestTopology())
+	c.Update("kube-01", map[string]float64{"ipc": 2, "c6res": 1.5})
+	c.UpdateWithTTL("kube-02", map[string]float64{"c6res": 0.5}, 0)
+
+	s := c.Snapshot()
+	// The snapshot is not affected by the later changes of the cache.
+	c.Forget("pod-1")
+	c.Update("kube-01", map[string]float64{"ipc": 4})
+	*now = now.Add(time.Hour)
+
+	// The snapshot holds the expected load of the assumed pod, as in
+	// TestAssume.
+	if res, ok := s.GetAll("kube-01", "ipc", "c6res"); !ok || math.Abs(res["ipc"]-1.75) > 1e-9 || math.Abs(res["c6res"]-1.1) > 1e-9 {
+		t.Errorf("expected ipc 1.75 and c6res 1.1, got %v, %v", res, ok)
+	}
+	if _, ok := s.Get("kube-02", "c6res"); ok {
+		t.Errorf("expected the c6res of kube-02 to be expired")
+	}
+	if res, age, ok := s.GetLastKnown("kube-02", time.Minute, "c6res"); !ok || age != 0 || res["c6res"] != 0.5 {
+		t.Errorf("expected the last known c6res 0.5 of kube-02, got %v, %v, %v", res, age, ok)
+	}
+	if _, ok := s.Get("kube-03", "ipc"); ok {
+		t.Errorf("expected an unknown node to be missing")
+	}
+
+	var empty *Snapshot
+	if _, ok := empty.GetAll("kube-01", "ipc"); ok {
+		t.Errorf("expected a nil snapshot to hold no metric")
+	}
+}
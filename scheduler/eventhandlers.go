@@ -185,6 +185,8 @@ func (sched *Scheduler) deletePodFromSchedulingQueue(obj interface{}) {
 	if err := sched.config.SchedulingQueue.Delete(pod); err != nil {
 		utilruntime.HandleError(fmt.Errorf("unable to dequeue %T: %v", obj, err))
 	}
+	// The pod may have been deleted while it was being bound.
+	sched.forgetAssumedLoad(pod)
 	if sched.config.VolumeBinder != nil {
 		// Volume binder only wants to keep unassigned pods
 		sched.config.VolumeBinder.DeletePodBindings(pod)
@@ -227,6 +229,10 @@ func (sched *Scheduler) updatePodInCache(oldObj, newObj interface{}) {
 	}
 
 	sched.config.SchedulingQueue.AssignedPodUpdated(newPod)
+
+	if sched.config.Profiler != nil && !podCompleted(oldPod) && podCompleted(newPod) {
+		sched.config.Profiler.Finished(newPod)
+	}
 }
 
 func (sched *Scheduler) deletePodFromCache(obj interface{}) {
@@ -253,6 +259,11 @@ func (sched *Scheduler) deletePodFromCache(obj interface{}) {
 	if err := sched.config.SchedulerCache.RemovePod(pod); err != nil {
 		klog.Errorf("scheduler cache RemovePod failed: %v", err)
 	}
+	if sched.config.Profiler != nil {
+		// The pod may be deleted before its completion is observed.
+		sched.config.Profiler.Finished(pod)
+	}
+	sched.forgetAssumedLoad(pod)
 
 	sched.config.SchedulingQueue.MoveAllToActiveQueue()
 }
@@ -262,6 +273,12 @@ func assignedPod(pod *v1.Pod) bool {
 	return len(pod.Spec.NodeName) != 0
 }
 
+// podCompleted returns true if all the containers of the pod terminated and
+// won't be restarted.
+func podCompleted(pod *v1.Pod) bool {
+	return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+}
+
 // responsibleForPod returns true if the pod has asked to be scheduled by the given scheduler.
 func responsibleForPod(pod *v1.Pod, schedulerName string) bool {
 	return schedulerName == pod.Spec.SchedulerName
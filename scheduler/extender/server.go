@@ -0,0 +1,359 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package extender serves the hardware-counter predicates and priorities of
+// the scheduler as an HTTP scheduler extender, so that a stock kube-scheduler
+// can use them through the extenders of its policy.
+package extender
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/priorities"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/core"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+const (
+	// FilterVerb is the verb of the filter call, to set as the FilterVerb of
+	// the extender in the policy of the scheduler.
+	FilterVerb = "filter"
+	// PrioritizeVerb is the verb of the prioritize call.
+	PrioritizeVerb = "prioritize"
+	// BindVerb is the verb of the bind call, served if the Server has a
+	// client.
+	BindVerb = "bind"
+)
+
+// DefaultPriorities are the priorities scoring the nodes unless the Config
+// sets others.
+var DefaultPriorities = []schedulerapi.PriorityPolicy{{Name: priorities.CustomRequestedPriority, Weight: 1}}
+
+// Config configures a Server.
+type Config struct {
+	// Topology places the nodes on the sockets of the servers.
+	Topology topology.Interface
+	// MetricsSource reads the hardware counters of the sockets, nil if the
+	// monitoring backend is disabled.
+	MetricsSource monitoring.MetricsSource
+	// MetricsCache caches the counters read from MetricsSource and holds the
+	// load of the pods bound since.
+	MetricsCache *customcache.Cache
+	// Degradation sets how the nodes are scored while MetricsSource is
+	// unavailable.
+	Degradation *monitoring.Degradation
+	// Profiles are the profiles of the applications, the benchmarks if nil.
+	Profiles profiles.Lister
+	// Priorities are the priorities scoring the nodes, among
+	// CustomRequestedPriority and EnergyPriority, DefaultPriorities if empty.
+	// The scores of each priority are normalized with MinMax unless it sets
+	// a Normalization, so that they weigh as the priorities of the
+	// scheduler.
+	Priorities []schedulerapi.PriorityPolicy
+	// NodeLister looks up the nodes of the calls of a NodeCacheCapable
+	// extender, nil if the scheduler sends the nodes.
+	NodeLister corelisters.NodeLister
+	// Client binds the pods, nil if the bind verb is not served.
+	Client clientset.Interface
+}
+
+// Server is an http.Handler serving the filter, prioritize and bind verbs of
+// a scheduler extender at the root of its URL. The nodes are filtered by the
+// CheckMemoryBandwidth predicate and scored by the priorities of its Config.
+type Server struct {
+	config       Config
+	predicates   map[string]predicates.FitPredicate
+	prioritizers []priorities.PriorityConfig
+	metadata     priorities.PriorityMetadataProducer
+	mux          *http.ServeMux
+}
+
+var _ http.Handler = &Server{}
+
+// NewServer returns a Server of config.
+func NewServer(config Config) (*Server, error) {
+	if config.Profiles == nil {
+		config.Profiles = profiles.NewStore()
+	}
+	if len(config.Priorities) == 0 {
+		config.Priorities = DefaultPriorities
+	}
+	s := &Server{
+		config: config,
+		predicates: map[string]predicates.FitPredicate{
+			predicates.CheckMemoryBandwidthPred: predicates.NewMemoryBandwidthPredicate(config.Topology, config.MetricsCache, config.Profiles),
+		},
+		metadata: priorities.NewMetricsPriorityMetadataProducer(config.MetricsCache),
+		mux:      http.NewServeMux(),
+	}
+	for _, policy := range config.Priorities {
+		prioritizer, err := s.prioritizer(policy)
+		if err != nil {
+			return nil, err
+		}
+		s.prioritizers = append(s.prioritizers, prioritizer)
+	}
+	s.mux.HandleFunc("/"+FilterVerb, s.serveFilter)
+	s.mux.HandleFunc("/"+PrioritizeVerb, s.servePrioritize)
+	if config.Client != nil {
+		s.mux.HandleFunc("/"+BindVerb, s.serveBind)
+	}
+	return s, nil
+}
+
+// prioritizer returns the priority config of policy. The priorities that
+// need the pods running on the nodes are not supported, as the extender
+// does not know them.
+func (s *Server) prioritizer(policy schedulerapi.PriorityPolicy) (priorities.PriorityConfig, error) {
+	config := priorities.PriorityConfig{Name: policy.Name, Weight: policy.Weight}
+	if config.Weight == 0 {
+		config.Weight = 1
+	}
+	switch policy.Name {
+	case priorities.CustomRequestedPriority:
+		score := priorities.DefaultCustomScore
+		if policy.Argument != nil && policy.Argument.CustomScore != nil {
+			var err error
+			if score, err = priorities.NewCustomScore(policy.Argument.CustomScore); err != nil {
+				return config, fmt.Errorf("invalid %s arguments: %v", policy.Name, err)
+			}
+		}
+		config.Map, config.Reduce = priorities.NewCustomRequestedPriority(s.config.Topology, score, s.config.Degradation)
+	case priorities.EnergyPriority:
+		config.Map, config.Reduce = priorities.NewEnergyPriority(s.config.Topology, s.config.Profiles)
+	default:
+		return config, fmt.Errorf("priority %q is not supported by the extender", policy.Name)
+	}
+	normalization := policy.Normalization
+	if normalization == "" {
+		normalization = schedulerapi.NormalizeMinMax
+	}
+	config.Reduce = priorities.NewNormalizedReduce(normalization, config.Reduce)
+	return config, nil
+}
+
+// ServeHTTP serves a call of the scheduler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// nodes returns the nodes of args, looked up by name in the node lister if
+// the scheduler only sent their names.
+func (s *Server) nodes(args *schedulerapi.ExtenderArgs) ([]*v1.Node, error) {
+	if args.Nodes != nil {
+		nodes := make([]*v1.Node, 0, len(args.Nodes.Items))
+		for i := range args.Nodes.Items {
+			nodes = append(nodes, &args.Nodes.Items[i])
+		}
+		return nodes, nil
+	}
+	if args.NodeNames == nil {
+		return nil, nil
+	}
+	if s.config.NodeLister == nil {
+		return nil, fmt.Errorf("the extender has no node cache, it must not be NodeCacheCapable")
+	}
+	nodes := make([]*v1.Node, 0, len(*args.NodeNames))
+	for _, name := range *args.NodeNames {
+		node, err := s.config.NodeLister.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// nodeInfos returns the NodeInfo of each node, by name. The NodeInfos hold
+// no pod.
+func nodeInfos(nodes []*v1.Node) map[string]*schedulernodeinfo.NodeInfo {
+	infos := make(map[string]*schedulernodeinfo.NodeInfo, len(nodes))
+	for _, node := range nodes {
+		info := schedulernodeinfo.NewNodeInfo()
+		info.SetNode(node)
+		infos[node.Name] = info
+	}
+	return infos
+}
+
+// Filter returns the nodes of args the pod fits on and the reasons the
+// others were filtered out.
+func (s *Server) Filter(args *schedulerapi.ExtenderArgs) *schedulerapi.ExtenderFilterResult {
+	if args.Pod == nil {
+		return &schedulerapi.ExtenderFilterResult{Error: "no pod given"}
+	}
+	nodes, err := s.nodes(args)
+	if err != nil {
+		return &schedulerapi.ExtenderFilterResult{Error: err.Error()}
+	}
+	infos := nodeInfos(nodes)
+	var fit []*v1.Node
+	failed := schedulerapi.FailedNodesMap{}
+	for _, node := range nodes {
+		var reasons []string
+		for _, name := range predicates.Ordering() {
+			predicate, ok := s.predicates[name]
+			if !ok {
+				continue
+			}
+			fits, failures, err := predicate(args.Pod, nil, infos[node.Name])
+			if err != nil {
+				return &schedulerapi.ExtenderFilterResult{Error: err.Error()}
+			}
+			if !fits {
+				for _, failure := range failures {
+					reasons = append(reasons, failure.GetReason())
+				}
+			}
+		}
+		if len(reasons) > 0 {
+			failed[node.Name] = strings.Join(reasons, ", ")
+			continue
+		}
+		fit = append(fit, node)
+	}
+	klog.V(4).Infof("Pod %v/%v fits on %d of %d nodes", args.Pod.Namespace, args.Pod.Name, len(fit), len(nodes))
+
+	result := &schedulerapi.ExtenderFilterResult{FailedNodes: failed}
+	if args.Nodes != nil {
+		result.Nodes = &v1.NodeList{}
+		for _, node := range fit {
+			result.Nodes.Items = append(result.Nodes.Items, *node)
+		}
+	} else {
+		names := make([]string, 0, len(fit))
+		for _, node := range fit {
+			names = append(names, node.Name)
+		}
+		result.NodeNames = &names
+	}
+	return result
+}
+
+// Prioritize scores the nodes of args for the pod. The hardware counters are
+// read from a snapshot of the cache taken for the call.
+func (s *Server) Prioritize(args *schedulerapi.ExtenderArgs) (schedulerapi.HostPriorityList, error) {
+	if args.Pod == nil {
+		return nil, fmt.Errorf("no pod given")
+	}
+	nodes, err := s.nodes(args)
+	if err != nil {
+		return nil, err
+	}
+	infos := nodeInfos(nodes)
+	return core.PrioritizeNodes(args.Pod, infos, s.metadata(args.Pod, infos), s.prioritizers, nodes, nil)
+}
+
+// Bind binds the pod of args to its node, and accounts for the expected load
+// of the pod until the monitoring data reflects it.
+func (s *Server) Bind(args *schedulerapi.ExtenderBindingArgs) error {
+	if s.config.Client == nil {
+		return fmt.Errorf("the extender does not bind pods")
+	}
+	pod, err := s.config.Client.CoreV1().Pods(args.PodNamespace).Get(args.PodName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if pod.UID != args.PodUID {
+		return fmt.Errorf("pod %v/%v has UID %v, expected %v", args.PodNamespace, args.PodName, pod.UID, args.PodUID)
+	}
+	if s.config.MetricsCache != nil {
+		s.config.MetricsCache.Assume(pod.UID, args.Node, s.config.Profiles.ForPod(pod).Metrics, s.config.Topology)
+	}
+	binding := &v1.Binding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: args.PodNamespace, Name: args.PodName, UID: args.PodUID},
+		Target:     v1.ObjectReference{Kind: "Node", Name: args.Node},
+	}
+	if err := s.config.Client.CoreV1().Pods(args.PodNamespace).Bind(binding); err != nil {
+		if s.config.MetricsCache != nil {
+			s.config.MetricsCache.Forget(pod.UID)
+		}
+		return err
+	}
+	klog.V(2).Infof("Bound pod %v/%v to node %v", args.PodNamespace, args.PodName, args.Node)
+	return nil
+}
+
+func (s *Server) serveFilter(w http.ResponseWriter, r *http.Request) {
+	var args schedulerapi.ExtenderArgs
+	if !decode(w, r, &args) {
+		return
+	}
+	encode(w, s.Filter(&args))
+}
+
+func (s *Server) servePrioritize(w http.ResponseWriter, r *http.Request) {
+	var args schedulerapi.ExtenderArgs
+	if !decode(w, r, &args) {
+		return
+	}
+	result, err := s.Prioritize(&args)
+	if err != nil {
+		klog.Errorf("Unable to prioritize the nodes: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	encode(w, result)
+}
+
+func (s *Server) serveBind(w http.ResponseWriter, r *http.Request) {
+	var args schedulerapi.ExtenderBindingArgs
+	if !decode(w, r, &args) {
+		return
+	}
+	result := &schedulerapi.ExtenderBindingResult{}
+	if err := s.Bind(&args); err != nil {
+		klog.Errorf("Unable to bind pod %v/%v to node %v: %v", args.PodNamespace, args.PodName, args.Node, err)
+		result.Error = err.Error()
+	}
+	encode(w, result)
+}
+
+// decode reads the arguments of a call, replying with an error if they are
+// invalid.
+func decode(w http.ResponseWriter, r *http.Request, args interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(args); err != nil {
+		http.Error(w, fmt.Sprintf("invalid arguments: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// encode replies with the result of a call.
+func encode(w http.ResponseWriter, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		klog.Errorf("Unable to write the result: %v", err)
+	}
+}
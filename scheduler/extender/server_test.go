@@ -0,0 +1,245 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package extender
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/priorities"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/core"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring/synthetic"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+var streamProfile = map[string]float64{"ipc": 0.8, "mem_read": 1, "mem_write": 0.5, "c6res": 0}
+
+// newTestConfig returns a server of two sockets with the memory bandwidth of
+// a stream and a half, hosting kube-01 and kube-02 on socket 0, running two
+// streams, and kube-03 on the idle socket 1. The cache holds the counters
+// synthesised for the sockets.
+func newTestConfig(t *testing.T) Config {
+	topo := topology.New(
+		[]topology.Server{{UUID: "server-a", Links: 2, LinkSpeed: 10, MaxFrequency: 2, MemoryBandwidth: 2}},
+		[]topology.Node{
+			{Name: "kube-01", Server: "server-a", Socket: 0, Cores: []int{0, 1}},
+			{Name: "kube-02", Server: "server-a", Socket: 0, Cores: []int{2, 3}},
+			{Name: "kube-03", Server: "server-a", Socket: 1, Cores: []int{4, 5}},
+		},
+	)
+	source := synthetic.NewSource(topo, synthetic.DefaultContentionModel)
+	source.Add("kube-01", "stream-0", streamProfile)
+	source.Add("kube-02", "stream-1", streamProfile)
+	metricsCache := customcache.New(customcache.DefaultTTL)
+	if err := customcache.NewRefresher(metricsCache, topo, source, time.Minute).Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	store := profiles.NewStore()
+	store.Load(&profiles.Config{Applications: map[string]*profiles.ApplicationProfile{"stream": {Metrics: streamProfile}}})
+	return Config{
+		Topology:      topo,
+		MetricsSource: source,
+		MetricsCache:  metricsCache,
+		Profiles:      store,
+	}
+}
+
+func newTestPod() *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default",
+		Name:      "stream",
+		UID:       "stream-uid",
+		Labels:    map[string]string{profiles.ApplicationKey: "stream"},
+	}}
+}
+
+func newTestNodes() []*v1.Node {
+	var nodes []*v1.Node
+	for _, name := range []string{"kube-01", "kube-02", "kube-03", "kube-09"} {
+		nodes = append(nodes, &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return nodes
+}
+
+// newTestExtender serves config and returns the client of the scheduler.
+func newTestExtender(t *testing.T, config Config, extenderConfig schedulerapi.ExtenderConfig) (algorithm.SchedulerExtender, func()) {
+	server, err := NewServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	extenderConfig.URLPrefix = ts.URL
+	extender, err := core.NewHTTPExtender(&extenderConfig)
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+	return extender, ts.Close
+}
+
+func nodeNames(nodes []*v1.Node) []string {
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	return names
+}
+
+func TestExtender(t *testing.T) {
+	for _, nodeCacheCapable := range []bool{false, true} {
+		config := newTestConfig(t)
+		nodes := newTestNodes()
+		if nodeCacheCapable {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			for _, node := range nodes {
+				indexer.Add(node)
+			}
+			config.NodeLister = corelisters.NewNodeLister(indexer)
+		}
+		extender, stop := newTestExtender(t, config, schedulerapi.ExtenderConfig{
+			FilterVerb:       FilterVerb,
+			PrioritizeVerb:   PrioritizeVerb,
+			Weight:           2,
+			NodeCacheCapable: nodeCacheCapable,
+		})
+		defer stop()
+
+		// The stream would saturate the memory bandwidth of socket 0. kube-09
+		// is not part of the topology.
+		fit, failed, err := extender.Filter(newTestPod(), nodes, schedulernodeinfo.CreateNodeNameToInfoMap(nil, nodes))
+		if err != nil {
+			t.Fatalf("node cache capable %v: unexpected error: %v", nodeCacheCapable, err)
+		}
+		if names := nodeNames(fit); !reflect.DeepEqual([]string{"kube-03", "kube-09"}, names) {
+			t.Errorf("node cache capable %v: expected the pod to fit on kube-03 and kube-09, got %v", nodeCacheCapable, names)
+		}
+		reason := predicates.ErrMemoryBandwidthSaturated.GetReason()
+		if expected := (schedulerapi.FailedNodesMap{"kube-01": reason, "kube-02": reason}); !reflect.DeepEqual(expected, failed) {
+			t.Errorf("node cache capable %v: expected failed nodes %v, got %v", nodeCacheCapable, expected, failed)
+		}
+
+		// The idle socket scores best, the nodes outside of the topology
+		// worst.
+		list, weight, err := extender.Prioritize(newTestPod(), nodes)
+		if err != nil {
+			t.Fatalf("node cache capable %v: unexpected error: %v", nodeCacheCapable, err)
+		}
+		if weight != 2 {
+			t.Errorf("node cache capable %v: expected a weight of 2, got %d", nodeCacheCapable, weight)
+		}
+		scores := map[string]float64{}
+		for _, hp := range *list {
+			scores[hp.Host] = hp.Score
+		}
+		if scores["kube-03"] != schedulerapi.MaxPriority || scores["kube-09"] != 0 {
+			t.Errorf("node cache capable %v: expected kube-03 to score %d and kube-09 0, got %v", nodeCacheCapable, schedulerapi.MaxPriority, scores)
+		}
+		if scores["kube-01"] != scores["kube-02"] || scores["kube-01"] <= 0 || scores["kube-01"] >= schedulerapi.MaxPriority {
+			t.Errorf("node cache capable %v: expected the nodes of the loaded socket to score in between, got %v", nodeCacheCapable, scores)
+		}
+	}
+}
+
+func TestExtenderUnknownNode(t *testing.T) {
+	config := newTestConfig(t)
+	config.NodeLister = corelisters.NewNodeLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}))
+	extender, stop := newTestExtender(t, config, schedulerapi.ExtenderConfig{
+		FilterVerb:       FilterVerb,
+		PrioritizeVerb:   PrioritizeVerb,
+		NodeCacheCapable: true,
+	})
+	defer stop()
+
+	nodes := newTestNodes()
+	if _, _, err := extender.Filter(newTestPod(), nodes, schedulernodeinfo.CreateNodeNameToInfoMap(nil, nodes)); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected the nodes not to be found, got %v", err)
+	}
+	if _, _, err := extender.Prioritize(newTestPod(), nodes); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestExtenderBind(t *testing.T) {
+	pod := newTestPod()
+	config := newTestConfig(t)
+	client := fake.NewSimpleClientset(pod)
+	config.Client = client
+	extender, stop := newTestExtender(t, config, schedulerapi.ExtenderConfig{BindVerb: BindVerb})
+	defer stop()
+
+	before, _ := config.MetricsCache.Get("kube-03", "mem_read")
+	binding := &v1.Binding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name, UID: pod.UID},
+		Target:     v1.ObjectReference{Kind: "Node", Name: "kube-03"},
+	}
+	if err := extender.Bind(binding); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var bound bool
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "create" && action.GetSubresource() == "binding" {
+			bound = true
+		}
+	}
+	if !bound {
+		t.Errorf("expected the pod to be bound, got actions %v", client.Actions())
+	}
+	// The stream is expected to load the socket of kube-03.
+	if after, _ := config.MetricsCache.Get("kube-03", "mem_read"); after != before+streamProfile["mem_read"] {
+		t.Errorf("expected the memory traffic of kube-03 to grow from %v by %v, got %v", before, streamProfile["mem_read"], after)
+	}
+
+	binding.UID = "other"
+	if err := extender.Bind(binding); err == nil {
+		t.Errorf("expected an error binding a pod of another UID")
+	}
+}
+
+func TestExtenderWithoutBind(t *testing.T) {
+	extender, stop := newTestExtender(t, newTestConfig(t), schedulerapi.ExtenderConfig{BindVerb: BindVerb})
+	defer stop()
+	pod := newTestPod()
+	binding := &v1.Binding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name, UID: pod.UID},
+		Target:     v1.ObjectReference{Kind: "Node", Name: "kube-03"},
+	}
+	if err := extender.Bind(binding); err == nil {
+		t.Errorf("expected the bind verb not to be served without a client")
+	}
+}
+
+func TestNewServerUnsupportedPriority(t *testing.T) {
+	config := newTestConfig(t)
+	config.Priorities = []schedulerapi.PriorityPolicy{{Name: priorities.LLCContentionPriority, Weight: 1}}
+	if _, err := NewServer(config); err == nil {
+		t.Errorf("expected %s not to be supported", priorities.LLCContentionPriority)
+	}
+}
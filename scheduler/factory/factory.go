@@ -54,10 +54,15 @@ import (
 	"k8s.io/kubernetes/pkg/scheduler/api/validation"
 	"k8s.io/kubernetes/pkg/scheduler/apis/config"
 	"k8s.io/kubernetes/pkg/scheduler/core"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/hardwarecounters"
 	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
 	internalcache "k8s.io/kubernetes/pkg/scheduler/internal/cache"
 	cachedebugger "k8s.io/kubernetes/pkg/scheduler/internal/cache/debugger"
 	internalqueue "k8s.io/kubernetes/pkg/scheduler/internal/queue"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
 	"k8s.io/kubernetes/pkg/scheduler/volumebinder"
 )
 
@@ -123,8 +128,30 @@ type Config struct {
 	// Disable pod preemption or not.
 	DisablePreemption bool
 
+	// AnnotateDecisions annotates the bound pods with the explanation of their
+	// scheduling decision.
+	AnnotateDecisions bool
+
 	// SchedulingQueue holds pods to be scheduled
 	SchedulingQueue internalqueue.SchedulingQueue
+
+	// MetricsRefresher keeps the hardware counters used by the custom
+	// priorities up to date. Nil if there is no monitoring backend.
+	MetricsRefresher *customcache.Refresher
+
+	// MetricsCache holds the hardware counters used by the custom priorities,
+	// and the expected load of the pods assumed since they were read.
+	MetricsCache *customcache.Cache
+
+	// Topology describes where nodes are pinned on the physical servers.
+	Topology topology.Interface
+
+	// Profiles are the expected loads of the applications.
+	Profiles profiles.Lister
+
+	// Profiler learns the profiles from the pods that run to completion. Nil
+	// if there is no monitoring backend.
+	Profiler *profiles.Profiler
 }
 
 // PodPreemptor has methods needed to delete a pod and to update 'NominatedPod'
@@ -221,6 +248,27 @@ type configFactory struct {
 	podQueue internalqueue.SchedulingQueue
 
 	enableNonPreempting bool
+
+	// topology describes where nodes are pinned on the physical servers.
+	topology *topology.Source
+
+	// metricsSource provides the hardware counters of the physical servers.
+	metricsSource monitoring.MetricsSource
+
+	// metricsCache holds the hardware counters read by the custom priorities
+	// between scheduling cycles.
+	metricsCache *customcache.Cache
+
+	// metricsRefreshPeriod is how often metricsCache is refreshed in the
+	// background. Zero disables the refresh.
+	metricsRefreshPeriod time.Duration
+
+	// degradation is how the custom priorities score the nodes while
+	// metricsSource is unavailable.
+	degradation *monitoring.Degradation
+
+	// profiles are the expected loads of the applications.
+	profiles profiles.Lister
 }
 
 // ConfigFactoryArgs is a set arguments passed to NewConfigFactory.
@@ -245,6 +293,11 @@ type ConfigFactoryArgs struct {
 	Registry                       framework.Registry
 	Plugins                        *config.Plugins
 	PluginConfig                   []config.PluginConfig
+	TopologyConfigFile             string
+	MetricsSource                  monitoring.MetricsSource
+	MetricsRefreshPeriod           time.Duration
+	Degradation                    *monitoring.Degradation
+	Profiles                       profiles.Lister
 }
 
 // NewConfigFactory initializes the default implementation of a Configurator. To encourage eventual privatization of the struct type, we only
@@ -256,7 +309,42 @@ func NewConfigFactory(args *ConfigFactoryArgs) Configurator {
 	}
 	schedulerCache := internalcache.New(30*time.Second, stopEverything)
 
-	framework, err := framework.NewFramework(args.Registry, args.Plugins, args.PluginConfig)
+	metricsCache := customcache.New(customcache.DefaultTTL)
+	if args.Degradation != nil && args.Degradation.Mode == monitoring.LastKnownGood {
+		// Keep the expired counters as the last known good ones.
+		metricsCache.SetRetention(args.Degradation.MaxAge)
+	}
+	profileLister := args.Profiles
+	if profileLister == nil {
+		// Serve the benchmark profiles.
+		profileLister = profiles.NewStore()
+	}
+	// Setup the topology, kept in sync with the file and the node metadata.
+	topologySource, err := topology.NewSource(args.TopologyConfigFile, &nodeLister{args.NodeInformer.Lister()})
+	if err != nil {
+		klog.Fatalf("error initializing the topology: %v", err)
+	}
+	go topologySource.Run(stopEverything)
+
+	// Register the hardware-counter plugins unless the registry overrides
+	// them, and account for the assumed load whatever the configuration.
+	registry := framework.Registry{}
+	for name, factory := range hardwarecounters.NewRegistry(&hardwarecounters.Handle{
+		Topology:     topologySource,
+		MetricsCache: metricsCache,
+		Profiles:     profileLister,
+		StopCh:       stopEverything,
+	}) {
+		registry[name] = factory
+	}
+	for name, factory := range args.Registry {
+		registry[name] = factory
+	}
+	plugins, err := hardwarecounters.WithDefaults(args.Plugins)
+	if err != nil {
+		klog.Fatalf("error initializing the scheduling framework: %v", err)
+	}
+	framework, err := framework.NewFramework(registry, plugins, args.PluginConfig)
 	if err != nil {
 		klog.Fatalf("error initializing the scheduling framework: %v", err)
 	}
@@ -288,7 +376,14 @@ func NewConfigFactory(args *ConfigFactoryArgs) Configurator {
 		percentageOfNodesToScore:       args.PercentageOfNodesToScore,
 		bindTimeoutSeconds:             args.BindTimeoutSeconds,
 		enableNonPreempting:            utilfeature.DefaultFeatureGate.Enabled(features.NonPreemptingPriority),
+		metricsSource:                  args.MetricsSource,
+		metricsCache:                   metricsCache,
+		metricsRefreshPeriod:           args.MetricsRefreshPeriod,
+		degradation:                    args.Degradation,
+		profiles:                       profileLister,
+		topology:                       topologySource,
 	}
+
 	// Setup volume binder
 	c.volumeBinder = volumebinder.NewVolumeBinder(args.Client, args.NodeInformer, args.PvcInformer, args.PvInformer, args.StorageClassInformer, time.Duration(args.BindTimeoutSeconds)*time.Second)
 	c.scheduledPodsHasSynced = args.PodInformer.Informer().HasSynced
@@ -347,7 +442,7 @@ func (c *configFactory) CreateFromProvider(providerName string) (*Config, error)
 	if err != nil {
 		return nil, err
 	}
-	return c.CreateFromKeys(provider.FitPredicateKeys, provider.PriorityFunctionKeys, []algorithm.SchedulerExtender{})
+	return c.createFromKeys(provider.FitPredicateKeys, provider.PriorityFunctionKeys, nil, provider.ScoringStages, []algorithm.SchedulerExtender{})
 }
 
 // Creates a scheduler from the configuration file
@@ -375,6 +470,7 @@ func (c *configFactory) CreateFromConfig(policy schedulerapi.Policy) (*Config, e
 	}
 
 	priorityKeys := sets.NewString()
+	scoringStages := policy.ScoringStages
 	if policy.Priorities == nil {
 		klog.V(2).Infof("Using priorities from algorithm provider '%v'", DefaultProvider)
 		provider, err := GetAlgorithmProvider(DefaultProvider)
@@ -382,6 +478,9 @@ func (c *configFactory) CreateFromConfig(policy schedulerapi.Policy) (*Config, e
 			return nil, err
 		}
 		priorityKeys = provider.PriorityFunctionKeys
+		if scoringStages == nil {
+			scoringStages = provider.ScoringStages
+		}
 	} else {
 		for _, priority := range policy.Priorities {
 			klog.V(2).Infof("Registering priority: %s", priority.Name)
@@ -425,11 +524,18 @@ func (c *configFactory) CreateFromConfig(policy schedulerapi.Policy) (*Config, e
 		c.alwaysCheckAllPredicates = policy.AlwaysCheckAllPredicates
 	}
 
-	return c.CreateFromKeys(predicateKeys, priorityKeys, extenders)
+	return c.createFromKeys(predicateKeys, priorityKeys, policy.Priorities, scoringStages, extenders)
 }
 
 // Creates a scheduler from a set of registered fit predicate keys and priority keys.
 func (c *configFactory) CreateFromKeys(predicateKeys, priorityKeys sets.String, extenders []algorithm.SchedulerExtender) (*Config, error) {
+	return c.createFromKeys(predicateKeys, priorityKeys, nil, nil, extenders)
+}
+
+// createFromKeys creates a scheduler scoring the nodes in the given stages.
+// Without stages, the nodes are scored once by the priority functions of
+// priorityKeys, normalized as set by priorityPolicies.
+func (c *configFactory) createFromKeys(predicateKeys, priorityKeys sets.String, priorityPolicies []schedulerapi.PriorityPolicy, scoringStages []schedulerapi.ScoringStage, extenders []algorithm.SchedulerExtender) (*Config, error) {
 	klog.V(2).Infof("Creating scheduler with fit predicates '%v' and priority functions '%v'", predicateKeys, priorityKeys)
 
 	if c.GetHardPodAffinitySymmetricWeight() < 1 || c.GetHardPodAffinitySymmetricWeight() > 100 {
@@ -445,6 +551,12 @@ func (c *configFactory) CreateFromKeys(predicateKeys, priorityKeys sets.String,
 	if err != nil {
 		return nil, err
 	}
+	priorityConfigs = normalizePriorityConfigs(priorityConfigs, priorityPolicies)
+
+	stages, err := c.getScoringStages(scoringStages, priorityConfigs)
+	if err != nil {
+		return nil, err
+	}
 
 	priorityMetaProducer, err := c.GetPriorityMetadataProducer()
 	if err != nil {
@@ -472,8 +584,16 @@ func (c *configFactory) CreateFromKeys(predicateKeys, priorityKeys sets.String,
 		c.disablePreemption,
 		c.percentageOfNodesToScore,
 		c.enableNonPreempting,
+		c.topology,
+		c.metricsCache,
+		stages,
 	)
 
+	var refresher *customcache.Refresher
+	if c.metricsSource != nil && c.metricsRefreshPeriod > 0 {
+		refresher = customcache.NewRefresher(c.metricsCache, c.topology, c.metricsSource, c.metricsRefreshPeriod)
+	}
+
 	return &Config{
 		SchedulerCache: c.schedulerCache,
 		// The scheduler only needs to consider schedulable nodes.
@@ -486,11 +606,15 @@ func (c *configFactory) CreateFromKeys(predicateKeys, priorityKeys sets.String,
 		WaitForCacheSync: func() bool {
 			return cache.WaitForCacheSync(c.StopEverything, c.scheduledPodsHasSynced)
 		},
-		NextPod:         internalqueue.MakeNextPodFunc(c.podQueue),
-		Error:           MakeDefaultErrorFunc(c.client, c.podQueue, c.schedulerCache, c.StopEverything),
-		StopEverything:  c.StopEverything,
-		VolumeBinder:    c.volumeBinder,
-		SchedulingQueue: c.podQueue,
+		NextPod:          internalqueue.MakeNextPodFunc(c.podQueue),
+		Error:            MakeDefaultErrorFunc(c.client, c.podQueue, c.schedulerCache, c.StopEverything),
+		StopEverything:   c.StopEverything,
+		VolumeBinder:     c.volumeBinder,
+		SchedulingQueue:  c.podQueue,
+		MetricsRefresher: refresher,
+		MetricsCache:     c.metricsCache,
+		Topology:         c.topology,
+		Profiles:         c.profiles,
 	}, nil
 }
 
@@ -529,6 +653,86 @@ func (c *configFactory) GetPriorityFunctionConfigs(priorityKeys sets.String) ([]
 	return getPriorityFunctionConfigs(priorityKeys, *pluginArgs)
 }
 
+// getScoringStages returns the prioritizers of the scoring stages. The stages
+// without priorities run the given priority configs.
+func (c *configFactory) getScoringStages(scoringStages []schedulerapi.ScoringStage, priorityConfigs []priorities.PriorityConfig) ([]core.ScoringStage, error) {
+	if len(scoringStages) == 0 {
+		return nil, nil
+	}
+	pluginArgs, err := c.getPluginArgs()
+	if err != nil {
+		return nil, err
+	}
+	return getScoringStages(scoringStages, priorityConfigs, *pluginArgs)
+}
+
+// getScoringStages returns the prioritizers of the scoring stages built with
+// args. The stages without priorities run the given priority configs.
+func getScoringStages(scoringStages []schedulerapi.ScoringStage, priorityConfigs []priorities.PriorityConfig, pluginArgs PluginFactoryArgs) ([]core.ScoringStage, error) {
+	if len(scoringStages) == 0 {
+		return nil, nil
+	}
+	var err error
+	stages := make([]core.ScoringStage, 0, len(scoringStages))
+	for _, stage := range scoringStages {
+		prioritizers := priorityConfigs
+		if len(stage.Priorities) != 0 {
+			if prioritizers, err = getStagePriorityConfigs(stage.Priorities, pluginArgs); err != nil {
+				return nil, fmt.Errorf("scoring stage %s: %v", stage.Name, err)
+			}
+		}
+		klog.V(2).Infof("Creating scoring stage '%v' with priority functions '%v' and selection %+v", stage.Name, stage.Priorities, stage.Selection)
+		stages = append(stages, core.ScoringStage{
+			Name:         stage.Name,
+			Prioritizers: prioritizers,
+			Selection:    stage.Selection,
+		})
+	}
+	return stages, nil
+}
+
+// CreatePrioritizers returns the priority functions, the scoring stages and
+// the priority metadata producer of policy, or of the DefaultProvider if
+// policy is nil, built with args. It lets tools such as the simulator score
+// the nodes as the scheduler would, without a configFactory.
+func CreatePrioritizers(policy *schedulerapi.Policy, args PluginFactoryArgs) ([]priorities.PriorityConfig, []core.ScoringStage, priorities.PriorityMetadataProducer, error) {
+	provider, err := GetAlgorithmProvider(DefaultProvider)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	priorityKeys, scoringStages := provider.PriorityFunctionKeys, provider.ScoringStages
+	var priorityPolicies []schedulerapi.PriorityPolicy
+	if policy != nil {
+		if err := validation.ValidatePolicy(*policy); err != nil {
+			return nil, nil, nil, err
+		}
+		if policy.Priorities != nil {
+			priorityKeys, scoringStages, priorityPolicies = sets.NewString(), nil, policy.Priorities
+			for _, priority := range policy.Priorities {
+				priorityKeys.Insert(RegisterCustomPriorityFunction(priority))
+			}
+		}
+		if policy.ScoringStages != nil {
+			scoringStages = policy.ScoringStages
+		}
+	}
+
+	priorityConfigs, err := getPriorityFunctionConfigs(priorityKeys, args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	priorityConfigs = normalizePriorityConfigs(priorityConfigs, priorityPolicies)
+	stages, err := getScoringStages(scoringStages, priorityConfigs, args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	priorityMetaProducer, err := getPriorityMetadataProducer(args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return priorityConfigs, stages, priorityMetaProducer, nil
+}
+
 func (c *configFactory) GetPriorityMetadataProducer() (priorities.PriorityMetadataProducer, error) {
 	pluginArgs, err := c.getPluginArgs()
 	if err != nil {
@@ -570,6 +774,10 @@ func (c *configFactory) getPluginArgs() (*PluginFactoryArgs, error) {
 		StorageClassInfo:               &predicates.CachedStorageClassInfo{StorageClassLister: c.storageClassLister},
 		VolumeBinder:                   c.volumeBinder,
 		HardPodAffinitySymmetricWeight: c.hardPodAffinitySymmetricWeight,
+		Topology:                       c.topology,
+		MetricsCache:                   c.metricsCache,
+		Degradation:                    c.degradation,
+		Profiles:                       c.profiles,
 	}, nil
 }
 
@@ -194,6 +194,71 @@ func TestCreateFromConfigWithUnspecifiedPredicatesOrPriorities(t *testing.T) {
 	}
 }
 
+// Test configures the scoring stages from a policy. A stage without
+// priorities runs the priorities of the policy.
+func TestCreateFromConfigWithScoringStages(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory := newConfigFactory(client, v1.DefaultHardPodAffinitySymmetricWeight, stopCh)
+
+	RegisterPriorityFunction("PriorityOne", PriorityOne, 1)
+	RegisterPriorityFunction("PriorityTwo", PriorityTwo, 1)
+
+	configData := []byte(`{
+		"kind" : "Policy",
+		"apiVersion" : "v1",
+		"predicates" : [],
+		"priorities" : [
+			{"name" : "PriorityOne", "weight" : 2}
+		],
+		"scoringStages" : [
+			{"name" : "socket", "priorities" : [
+				{"name" : "PriorityTwo", "weight" : 5, "normalization" : "MinMax"},
+				{"name" : "IPCPriority", "weight" : 1, "argument" : {"customScore" : {"expression" : "ipc * c6factor"}}, "normalization" : "Rank"}
+			], "selection" : {"rule" : "KeepTopK", "k" : 2}},
+			{"name" : "stock"}
+		]
+	}`)
+	var policy schedulerapi.Policy
+	if err := runtime.DecodeInto(latestschedulerapi.Codec, configData, &policy); err != nil {
+		t.Fatalf("Invalid configuration: %v", err)
+	}
+	if n := policy.ScoringStages[0].Priorities[1].Normalization; n != schedulerapi.NormalizeRank {
+		t.Errorf("Expected IPCPriority to be normalized by rank, got %q", n)
+	}
+	if _, err := factory.CreateFromConfig(policy); err != nil {
+		t.Fatalf("Failed to create scheduler from configuration: %v", err)
+	}
+
+	c := factory.(*configFactory)
+	priorityConfigs, err := c.GetPriorityFunctionConfigs(sets.NewString("PriorityOne"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stages, err := c.getScoringStages(policy.ScoringStages, priorityConfigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stages) != 2 {
+		t.Fatalf("Expected 2 scoring stages, got %d", len(stages))
+	}
+	if p := stages[0].Prioritizers; len(p) != 2 || p[0].Name != "PriorityTwo" || p[0].Weight != 5 || p[1].Name != "IPCPriority" || p[1].Map == nil || p[1].Reduce == nil {
+		t.Errorf("Expected PriorityTwo with weight 5 and the normalized IPCPriority in the first stage, got %+v", p)
+	}
+	if sel := stages[0].Selection; sel.Rule != schedulerapi.KeepTopK || sel.K != 2 {
+		t.Errorf("Expected to keep the top 2 nodes, got %+v", sel)
+	}
+	if p := stages[1].Prioritizers; len(p) != 1 || p[0].Name != "PriorityOne" || p[0].Weight != 2 {
+		t.Errorf("Expected the priorities of the policy in the last stage, got %+v", p)
+	}
+
+	policy.ScoringStages = []schedulerapi.ScoringStage{{Name: "unknown", Priorities: []schedulerapi.PriorityPolicy{{Name: "PriorityThree", Weight: 1}}}}
+	if _, err := factory.CreateFromConfig(policy); err == nil {
+		t.Errorf("Expected an error for an unknown priority in a scoring stage")
+	}
+}
+
 // Test configures a scheduler from a policy that contains empty
 // predicate/priority.
 // Empty predicate/priority sets will be used.
@@ -479,26 +544,26 @@ func TestInvalidFactoryArgs(t *testing.T) {
 func newConfigFactory(client clientset.Interface, hardPodAffinitySymmetricWeight int32, stopCh <-chan struct{}) Configurator {
 	informerFactory := informers.NewSharedInformerFactory(client, 0)
 	return NewConfigFactory(&ConfigFactoryArgs{
-		v1.DefaultSchedulerName,
-		client,
-		informerFactory.Core().V1().Nodes(),
-		informerFactory.Core().V1().Pods(),
-		informerFactory.Core().V1().PersistentVolumes(),
-		informerFactory.Core().V1().PersistentVolumeClaims(),
-		informerFactory.Core().V1().ReplicationControllers(),
-		informerFactory.Apps().V1().ReplicaSets(),
-		informerFactory.Apps().V1().StatefulSets(),
-		informerFactory.Core().V1().Services(),
-		informerFactory.Policy().V1beta1().PodDisruptionBudgets(),
-		informerFactory.Storage().V1().StorageClasses(),
-		hardPodAffinitySymmetricWeight,
-		disablePodPreemption,
-		schedulerapi.DefaultPercentageOfNodesToScore,
-		bindTimeoutSeconds,
-		stopCh,
-		framework.NewRegistry(),
-		nil,
-		[]config.PluginConfig{},
+		SchedulerName:                  v1.DefaultSchedulerName,
+		Client:                         client,
+		NodeInformer:                   informerFactory.Core().V1().Nodes(),
+		PodInformer:                    informerFactory.Core().V1().Pods(),
+		PvInformer:                     informerFactory.Core().V1().PersistentVolumes(),
+		PvcInformer:                    informerFactory.Core().V1().PersistentVolumeClaims(),
+		ReplicationControllerInformer:  informerFactory.Core().V1().ReplicationControllers(),
+		ReplicaSetInformer:             informerFactory.Apps().V1().ReplicaSets(),
+		StatefulSetInformer:            informerFactory.Apps().V1().StatefulSets(),
+		ServiceInformer:                informerFactory.Core().V1().Services(),
+		PdbInformer:                    informerFactory.Policy().V1beta1().PodDisruptionBudgets(),
+		StorageClassInformer:           informerFactory.Storage().V1().StorageClasses(),
+		HardPodAffinitySymmetricWeight: hardPodAffinitySymmetricWeight,
+		DisablePreemption:              disablePodPreemption,
+		PercentageOfNodesToScore:       schedulerapi.DefaultPercentageOfNodesToScore,
+		BindTimeoutSeconds:             bindTimeoutSeconds,
+		StopCh:                         stopCh,
+		Registry:                       framework.NewRegistry(),
+		Plugins:                        nil,
+		PluginConfig:                   []config.PluginConfig{},
 	})
 }
 
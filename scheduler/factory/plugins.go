@@ -28,6 +28,10 @@ import (
 	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
 	"k8s.io/kubernetes/pkg/scheduler/algorithm/priorities"
 	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
 	"k8s.io/kubernetes/pkg/scheduler/volumebinder"
 
 	"k8s.io/klog"
@@ -48,6 +52,10 @@ type PluginFactoryArgs struct {
 	StorageClassInfo               predicates.StorageClassInfo
 	VolumeBinder                   *volumebinder.VolumeBinder
 	HardPodAffinitySymmetricWeight int32
+	Topology                       topology.Interface
+	MetricsCache                   *customcache.Cache
+	Degradation                    *monitoring.Degradation
+	Profiles                       profiles.Lister
 }
 
 // PriorityMetadataProducerFactory produces PriorityMetadataProducer from the given args.
@@ -113,6 +121,9 @@ const (
 type AlgorithmProviderConfig struct {
 	FitPredicateKeys     sets.String
 	PriorityFunctionKeys sets.String
+	// ScoringStages score the nodes in successive stages. If empty, the nodes
+	// are scored once by the priority functions of PriorityFunctionKeys.
+	ScoringStages []schedulerapi.ScoringStage
 }
 
 // RegisterFitPredicate registers a fit predicate with the algorithm
@@ -322,6 +333,13 @@ func SocketRegisterPriorityFunction2(
 	})
 }
 
+// SocketRegisterPriorityConfigFactory registers a priority config factory that
+// scores the sockets of the servers rather than the nodes.
+func SocketRegisterPriorityConfigFactory(name string, pcf PriorityConfigFactory) string {
+	pcf.isSocket = true
+	return RegisterPriorityConfigFactory(name, pcf)
+}
+
 //------------------------------------------------------------------------------------------------
 //------------------------------------------------------------------------------------------------
 // ---------START OF CUSTOMIZATION----------------------------------------------------------------
@@ -402,6 +420,28 @@ func RegisterCustomPriorityFunction(policy schedulerapi.PriorityPolicy) string {
 				},
 				Weight: policy.Weight,
 			}
+		} else if policy.Argument.CustomScore != nil {
+			score, err := priorities.NewCustomScore(policy.Argument.CustomScore)
+			if err != nil {
+				klog.Fatalf("invalid CustomRequestedPriority arguments: %v", err)
+			}
+			pcf = &PriorityConfigFactory{
+				MapReduceFunction: func(args PluginFactoryArgs) (priorities.PriorityMapFunction, priorities.PriorityReduceFunction) {
+					return priorities.NewCustomRequestedPriority(args.Topology, score, args.Degradation)
+				},
+				Weight: policy.Weight,
+			}
+		} else if policy.Argument.LLCContention != nil {
+			contention, err := priorities.NewLLCContention(policy.Argument.LLCContention)
+			if err != nil {
+				klog.Fatalf("invalid LLCContentionPriority arguments: %v", err)
+			}
+			pcf = &PriorityConfigFactory{
+				MapReduceFunction: func(args PluginFactoryArgs) (priorities.PriorityMapFunction, priorities.PriorityReduceFunction) {
+					return priorities.NewLLCContentionPriority(args.Topology, args.Profiles, contention)
+				},
+				Weight: policy.Weight,
+			}
 		}
 	} else if existingPcf, ok := priorityFunctionMap[policy.Name]; ok {
 		klog.V(2).Infof("Priority type %s already registered, reusing.", policy.Name)
@@ -454,6 +494,20 @@ func RegisterAlgorithmProvider(name string, predicateKeys, priorityKeys sets.Str
 	return name
 }
 
+// RegisterAlgorithmProviderScoringStages sets the scoring stages of a registered
+// algorithm provider. This should be called from the init function in a
+// provider plugin, after registering the provider.
+func RegisterAlgorithmProviderScoringStages(name string, stages []schedulerapi.ScoringStage) {
+	schedulerFactoryMutex.Lock()
+	defer schedulerFactoryMutex.Unlock()
+	provider, ok := algorithmProviderMap[name]
+	if !ok {
+		klog.Fatalf("Algorithm provider %s is not registered", name)
+	}
+	provider.ScoringStages = stages
+	algorithmProviderMap[name] = provider
+}
+
 // GetAlgorithmProvider should not be used to modify providers. It is publicly visible for testing.
 func GetAlgorithmProvider(name string) (*AlgorithmProviderConfig, error) {
 	schedulerFactoryMutex.RLock()
@@ -542,6 +596,60 @@ func getPriorityFunctionConfigs(names sets.String, args PluginFactoryArgs) ([]pr
 	return configs, nil
 }
 
+// getStagePriorityConfigs returns the priority configs of a scoring stage,
+// with the weights of the stage.
+func getStagePriorityConfigs(policies []schedulerapi.PriorityPolicy, args PluginFactoryArgs) ([]priorities.PriorityConfig, error) {
+	var configs []priorities.PriorityConfig
+	for _, policy := range policies {
+		name := policy.Name
+		if policy.Argument != nil {
+			name = RegisterCustomPriorityFunction(policy)
+		}
+		config, err := getPriorityFunctionConfigs(sets.NewString(name), args)
+		if err != nil {
+			return nil, err
+		}
+		// The same priority function may be weighted and normalized
+		// differently in each stage.
+		config[0].Weight = policy.Weight
+		configs = append(configs, normalizePriorityConfig(config[0], policy.Normalization))
+	}
+	if err := validateSelectedConfigs(configs); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// normalizePriorityConfigs normalizes the scores of the priority configs as
+// set by the policies of the same name. The registered priority functions are
+// left as they are, so that the normalization of a policy does not carry over
+// to the providers or to the next policy.
+func normalizePriorityConfigs(configs []priorities.PriorityConfig, policies []schedulerapi.PriorityPolicy) []priorities.PriorityConfig {
+	methods := map[string]schedulerapi.ScoreNormalization{}
+	for _, policy := range policies {
+		methods[policy.Name] = policy.Normalization
+	}
+	normalized := make([]priorities.PriorityConfig, 0, len(configs))
+	for _, config := range configs {
+		normalized = append(normalized, normalizePriorityConfig(config, methods[config.Name]))
+	}
+	return normalized
+}
+
+// normalizePriorityConfig returns a copy of config normalizing its scores with
+// the given method, or config itself if the method is empty.
+func normalizePriorityConfig(config priorities.PriorityConfig, method schedulerapi.ScoreNormalization) priorities.PriorityConfig {
+	if method == "" {
+		return config
+	}
+	if config.Function != nil {
+		config.Function = priorities.NewNormalizedFunction(method, config.Function)
+	} else {
+		config.Reduce = priorities.NewNormalizedReduce(method, config.Reduce)
+	}
+	return config
+}
+
 // validateSelectedConfigs validates the config weights to avoid the overflow.
 func validateSelectedConfigs(configs []priorities.PriorityConfig) error {
 	var totalPriority int
@@ -590,6 +698,12 @@ func validatePriorityOrDie(priority schedulerapi.PriorityPolicy) {
 		if priority.Argument.RequestedToCapacityRatioArguments != nil {
 			numArgs++
 		}
+		if priority.Argument.CustomScore != nil {
+			numArgs++
+		}
+		if priority.Argument.LLCContention != nil {
+			numArgs++
+		}
 		if numArgs != 1 {
 			klog.Fatalf("Exactly 1 priority argument is required, numArgs: %v, Priority: %s", numArgs, priority.Name)
 		}
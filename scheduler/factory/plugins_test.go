@@ -17,11 +17,15 @@ limitations under the License.
 package factory
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/kubernetes/pkg/scheduler/algorithm/priorities"
 	"k8s.io/kubernetes/pkg/scheduler/api"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
 )
 
 func TestAlgorithmNameValidation(t *testing.T) {
@@ -103,3 +107,30 @@ func TestBuildScoringFunctionShapeFromRequestedToCapacityRatioArguments(t *testi
 	})
 	assert.Equal(t, expectedShape, builtShape)
 }
+
+// Test registers a policy normalizing a priority twice, as on a reload: the
+// registered priority keeps its raw scores and the normalization is applied
+// once, to the configs of the policy only.
+func TestNormalizePriorityConfigs(t *testing.T) {
+	RegisterPriorityFunction("RawScores", func(pod *v1.Pod, nodeNameToInfo map[string]*schedulernodeinfo.NodeInfo, nodes []*v1.Node) (api.HostPriorityList, error) {
+		return api.HostPriorityList{{Host: "a", Score: 20}, {Host: "b", Score: 40}}, nil
+	}, 1)
+	policy := api.PriorityPolicy{Name: "RawScores", Weight: 2, Normalization: api.NormalizeMinMax}
+	raw := api.HostPriorityList{{Host: "a", Score: 20}, {Host: "b", Score: 40}}
+	normalized := api.HostPriorityList{{Host: "a", Score: 0}, {Host: "b", Score: api.MaxPriority}}
+
+	for i := 0; i < 2; i++ {
+		name := RegisterCustomPriorityFunction(policy)
+		configs, err := getPriorityFunctionConfigs(sets.NewString(name), PluginFactoryArgs{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scores, _ := configs[0].Function(nil, nil, nil); !reflect.DeepEqual(scores, raw) {
+			t.Errorf("Expected the registered priority to keep its raw scores, got %v", scores)
+		}
+		configs = normalizePriorityConfigs(configs, []api.PriorityPolicy{policy})
+		if scores, _ := configs[0].Function(nil, nil, nil); !reflect.DeepEqual(scores, normalized) {
+			t.Errorf("Expected the scores of the policy normalized, got %v", scores)
+		}
+	}
+}
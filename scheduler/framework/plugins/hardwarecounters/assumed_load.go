@@ -0,0 +1,68 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hardwarecounters
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
+)
+
+// AssumedLoadName is the name of the AssumedLoad plugin used in Registry and
+// configurations.
+const AssumedLoadName = "AssumedLoad"
+
+// AssumedLoad adds the expected load of a pod, from its profile, to the
+// metrics cache when it is reserved on a node, until the monitoring data
+// reflects it, and drops it when the pod is unreserved.
+type AssumedLoad struct {
+	handle *Handle
+}
+
+var _ = framework.ReservePlugin(&AssumedLoad{})
+var _ = framework.UnreservePlugin(&AssumedLoad{})
+
+// newAssumedLoad initializes an AssumedLoad plugin. It takes no arguments.
+func (h *Handle) newAssumedLoad(_ *runtime.Unknown, _ framework.FrameworkHandle) (framework.Plugin, error) {
+	return &AssumedLoad{handle: h}, nil
+}
+
+// Name returns name of the plugin. It is used in logs, etc.
+func (al *AssumedLoad) Name() string {
+	return AssumedLoadName
+}
+
+// Reserve accounts for the load of the pod on nodeName.
+func (al *AssumedLoad) Reserve(pc *framework.PluginContext, pod *v1.Pod, nodeName string) *framework.Status {
+	if pod == nil {
+		return framework.NewStatus(framework.Error, "pod cannot be nil")
+	}
+	if al.handle.MetricsCache == nil || al.handle.Profiles == nil {
+		return nil
+	}
+	profile := al.handle.Profiles.ForPod(pod)
+	al.handle.MetricsCache.Assume(pod.UID, nodeName, profile.Metrics, al.handle.Topology)
+	return nil
+}
+
+// Unreserve drops the load of the pod.
+func (al *AssumedLoad) Unreserve(pc *framework.PluginContext, pod *v1.Pod, nodeName string) {
+	if pod == nil || al.handle.MetricsCache == nil {
+		return
+	}
+	al.handle.MetricsCache.Forget(pod.UID)
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hardwarecounters
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
+)
+
+// BandwidthBudgetName is the name of the BandwidthBudget plugin used in
+// Registry and configurations.
+const BandwidthBudgetName = "BandwidthBudget"
+
+const (
+	// DefaultBudget is the fraction of the memory bandwidth of a socket
+	// its projected memory traffic may reach.
+	DefaultBudget = 1.0
+	// DefaultHoldTimeout is how long a pod is held before it is rejected.
+	DefaultHoldTimeout = 2 * time.Minute
+	// DefaultRecheckPeriod is how often the held pods are checked against
+	// the budget.
+	DefaultRecheckPeriod = time.Second
+)
+
+// BandwidthBudgetArgs are the arguments of the BandwidthBudget plugin, as
+// JSON in its PluginConfig. Zero values take the defaults.
+type BandwidthBudgetArgs struct {
+	// Budget is the fraction of the memory bandwidth of a socket its
+	// projected memory traffic may reach.
+	Budget float64 `json:"budget,omitempty"`
+	// Timeout is how long a pod is held before it is rejected.
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// Period is how often the held pods are checked against the budget.
+	Period metav1.Duration `json:"period,omitempty"`
+}
+
+// heldPod is a pod held by a BandwidthBudget.
+type heldPod struct {
+	uid      types.UID
+	node     string
+	socket   string
+	load     float64
+	deadline time.Time
+	// seen is whether the pod was found waiting in the framework.
+	seen bool
+}
+
+// BandwidthBudget holds a pod at Permit while the projected memory traffic
+// of the socket of its node, read from the metrics cache with the load of
+// the pod assumed, is over a budget of the memory bandwidth of the socket.
+// The held pods are allowed in the order they came, as the traffic of their
+// socket goes down, and rejected after a timeout. Nodes outside of the
+// topology, on servers of unknown memory bandwidth or without cached metrics
+// are within budget.
+type BandwidthBudget struct {
+	handle          *Handle
+	frameworkHandle framework.FrameworkHandle
+	args            BandwidthBudgetArgs
+
+	mu   sync.Mutex
+	held []*heldPod
+}
+
+var _ = framework.PermitPlugin(&BandwidthBudget{})
+
+// newBandwidthBudget initializes a BandwidthBudget plugin from its arguments
+// and starts checking the held pods until the handle is stopped.
+func (h *Handle) newBandwidthBudget(configuration *runtime.Unknown, fh framework.FrameworkHandle) (framework.Plugin, error) {
+	args, err := decodeBandwidthBudgetArgs(configuration)
+	if err != nil {
+		return nil, err
+	}
+	bb := &BandwidthBudget{handle: h, frameworkHandle: fh, args: *args}
+	stopCh := h.StopCh
+	if stopCh == nil {
+		stopCh = wait.NeverStop
+	}
+	go wait.Until(bb.release, args.Period.Duration, stopCh)
+	return bb, nil
+}
+
+// decodeBandwidthBudgetArgs decodes the arguments of a BandwidthBudget and
+// sets their defaults.
+func decodeBandwidthBudgetArgs(configuration *runtime.Unknown) (*BandwidthBudgetArgs, error) {
+	args := &BandwidthBudgetArgs{}
+	if configuration != nil && len(configuration.Raw) > 0 {
+		if err := json.Unmarshal(configuration.Raw, args); err != nil {
+			return nil, fmt.Errorf("invalid arguments of plugin %q: %v", BandwidthBudgetName, err)
+		}
+	}
+	if args.Budget < 0 || args.Timeout.Duration < 0 || args.Period.Duration < 0 {
+		return nil, fmt.Errorf("invalid arguments of plugin %q: negative budget, timeout or period", BandwidthBudgetName)
+	}
+	if args.Budget == 0 {
+		args.Budget = DefaultBudget
+	}
+	if args.Timeout.Duration == 0 {
+		args.Timeout.Duration = DefaultHoldTimeout
+	}
+	if args.Period.Duration == 0 {
+		args.Period.Duration = DefaultRecheckPeriod
+	}
+	return args, nil
+}
+
+// Name returns name of the plugin. It is used in logs, etc.
+func (bb *BandwidthBudget) Name() string {
+	return BandwidthBudgetName
+}
+
+// Permit holds the pod if its socket is over budget, or if pods held before
+// it on the socket are still waiting.
+func (bb *BandwidthBudget) Permit(pc *framework.PluginContext, pod *v1.Pod, nodeName string) (*framework.Status, time.Duration) {
+	if pod == nil {
+		return framework.NewStatus(framework.Error, "pod cannot be nil"), 0
+	}
+	socket, traffic, budget, ok := bb.socket(nodeName)
+	if !ok {
+		return nil, 0
+	}
+
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	var queued bool
+	for _, p := range bb.held {
+		if p.socket == socket {
+			queued = true
+			break
+		}
+	}
+	if !queued && traffic <= budget {
+		return nil, 0
+	}
+	klog.V(4).Infof("Holding pod %v/%v at permit: the memory traffic of the socket of node %v is %v, over its budget %v",
+		pod.Namespace, pod.Name, nodeName, traffic, budget)
+	bb.held = append(bb.held, &heldPod{
+		uid:      pod.UID,
+		node:     nodeName,
+		socket:   socket,
+		load:     bb.load(pod),
+		deadline: time.Now().Add(bb.args.Timeout.Duration),
+	})
+	return framework.NewStatus(framework.Wait, fmt.Sprintf("memory traffic of the socket of node %v over budget", nodeName)), bb.args.Timeout.Duration
+}
+
+// release allows the held pods whose socket is back within budget, in the
+// order they came, and drops those no longer waiting. The load of the held
+// pods is renewed so that it stays in the cache while they wait, but a pod
+// only counts against the pods held before it. A pod rejected meanwhile has
+// been forgotten at unreserve, and stays so.
+func (bb *BandwidthBudget) release() {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	now := time.Now()
+	waiting := map[types.UID]framework.WaitingPod{}
+	held := bb.held[:0]
+	for _, p := range bb.held {
+		wp := bb.frameworkHandle.GetWaitingPod(p.uid)
+		if wp == nil {
+			// The pod may not be waiting yet right after Permit returns.
+			if p.seen || now.After(p.deadline) {
+				continue
+			}
+		} else {
+			p.seen = true
+			waiting[p.uid] = wp
+			if bb.handle.MetricsCache != nil {
+				bb.handle.MetricsCache.Renew(p.uid)
+			}
+		}
+		held = append(held, p)
+	}
+	bb.held = held
+
+	// The traffic of the sockets without the pods still held.
+	pending := map[string]float64{}
+	for _, p := range bb.held {
+		pending[p.socket] += p.load
+	}
+	blocked := map[string]bool{}
+	held = bb.held[:0]
+	for _, p := range bb.held {
+		wp, ok := waiting[p.uid]
+		if !ok || blocked[p.socket] {
+			held = append(held, p)
+			continue
+		}
+		_, traffic, budget, found := bb.socket(p.node)
+		if found && traffic-pending[p.socket]+p.load > budget {
+			blocked[p.socket] = true
+			held = append(held, p)
+			continue
+		}
+		pending[p.socket] -= p.load
+		if wp.Allow() {
+			klog.V(4).Infof("Allowing pod %v/%v held at permit: the socket of node %v is within budget", wp.GetPod().Namespace, wp.GetPod().Name, p.node)
+		}
+	}
+	bb.held = held
+}
+
+// socket returns a key of the socket of a node, its memory traffic and its
+// budget, or false if the node is outside of the topology, on a server of
+// unknown memory bandwidth or without cached metrics. The traffic is read from
+// the counters of the node, or else of another node of its socket.
+func (bb *BandwidthBudget) socket(node string) (string, float64, float64, bool) {
+	if bb.handle.Topology == nil || bb.handle.MetricsCache == nil {
+		return "", 0, 0, false
+	}
+	n, ok := bb.handle.Topology.Node(node)
+	if !ok {
+		return "", 0, 0, false
+	}
+	server, ok := bb.handle.Topology.Server(n.Server)
+	if !ok || server.MemoryBandwidth <= 0 {
+		return "", 0, 0, false
+	}
+	key := fmt.Sprintf("%s/%d", n.Server, n.Socket)
+	budget := bb.args.Budget * float64(server.MemoryBandwidth)
+	names := []string{n.Name}
+	for _, name := range bb.handle.Topology.SocketNodes(n.Server, n.Socket) {
+		if name != n.Name {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if metrics, ok := bb.handle.MetricsCache.GetAll(name, "mem_read", "mem_write"); ok {
+			return key, metrics["mem_read"] + metrics["mem_write"], budget, true
+		}
+	}
+	return "", 0, 0, false
+}
+
+// load returns the memory traffic of the profile of a pod.
+func (bb *BandwidthBudget) load(pod *v1.Pod) float64 {
+	if bb.handle.Profiles == nil {
+		return 0
+	}
+	metrics := bb.handle.Profiles.ForPod(pod).Metrics
+	return metrics["mem_read"] + metrics["mem_write"]
+}
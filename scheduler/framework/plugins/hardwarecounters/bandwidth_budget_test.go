@@ -0,0 +1,200 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hardwarecounters
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
+)
+
+func TestDecodeBandwidthBudgetArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		expected  *BandwidthBudgetArgs
+		expectErr bool
+	}{
+		{
+			name: "defaults",
+			expected: &BandwidthBudgetArgs{
+				Budget:  DefaultBudget,
+				Timeout: metav1.Duration{Duration: DefaultHoldTimeout},
+				Period:  metav1.Duration{Duration: DefaultRecheckPeriod},
+			},
+		},
+		{
+			name: "arguments",
+			raw:  `{"budget": 0.8, "timeout": "30s", "period": "100ms"}`,
+			expected: &BandwidthBudgetArgs{
+				Budget:  0.8,
+				Timeout: metav1.Duration{Duration: 30 * time.Second},
+				Period:  metav1.Duration{Duration: 100 * time.Millisecond},
+			},
+		},
+		{
+			name:      "malformed",
+			raw:       `{"budget": "high"}`,
+			expectErr: true,
+		},
+		{
+			name:      "negative budget",
+			raw:       `{"budget": -1}`,
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args, err := decodeBandwidthBudgetArgs(&runtime.Unknown{Raw: []byte(test.raw)})
+			if test.expectErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(test.expected, args) {
+				t.Errorf("expected %+v, got %+v", test.expected, args)
+			}
+		})
+	}
+}
+
+// newBudgetFramework returns a framework running AssumedLoad and
+// BandwidthBudget, configured with args.
+func newBudgetFramework(t *testing.T, h *Handle, args string) framework.Framework {
+	plugins, err := WithDefaults(&config.Plugins{
+		Permit: &config.PluginSet{Enabled: []config.Plugin{{Name: BandwidthBudgetName}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pluginConfig := []config.PluginConfig{{Name: BandwidthBudgetName, Args: runtime.Unknown{Raw: []byte(args)}}}
+	fwk, err := framework.NewFramework(NewRegistry(h), plugins, pluginConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fwk
+}
+
+func TestBandwidthBudget(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	h := newTestHandle(stopCh)
+	fwk := newBudgetFramework(t, h, `{"period": "10ms"}`)
+
+	// Run reserve and permit for a pod, as the scheduler does.
+	schedule := func(uid, node string) <-chan *framework.Status {
+		pod := newTestPod(uid)
+		pod.Spec.NodeName = node
+		pc := framework.NewPluginContext()
+		if status := fwk.RunReservePlugins(pc, pod, node); !status.IsSuccess() {
+			t.Fatalf("unexpected status at reserve: %v", status)
+		}
+		ch := make(chan *framework.Status, 1)
+		go func() {
+			ch <- fwk.RunPermitPlugins(pc, pod, node)
+		}()
+		return ch
+	}
+	expectPermitted := func(name string, ch <-chan *framework.Status) {
+		select {
+		case status := <-ch:
+			if !status.IsSuccess() {
+				t.Errorf("expected %v permitted, got %v", name, status)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("expected %v permitted, still waiting", name)
+		}
+	}
+
+	// The socket of kube-01 goes from 1 to 1.8 of its bandwidth of 2.
+	expectPermitted("the first pod", schedule("stream-0", "kube-01"))
+	// It would go to 2.6.
+	held := schedule("stream-1", "kube-01")
+	// The other socket is within budget.
+	expectPermitted("a pod on the other socket", schedule("stream-2", "kube-02"))
+	select {
+	case status := <-held:
+		t.Fatalf("expected the second pod held, got %v", status)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The traffic goes down once the first pod is gone.
+	fwk.RunUnreservePlugins(framework.NewPluginContext(), newTestPod("stream-0"), "kube-01")
+	expectPermitted("the second pod", held)
+}
+
+func TestBandwidthBudgetTimeout(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	h := newTestHandle(stopCh)
+	fwk := newBudgetFramework(t, h, `{"budget": 0.5, "timeout": "50ms", "period": "10ms"}`)
+
+	pod := newTestPod("stream-0")
+	pc := framework.NewPluginContext()
+	if status := fwk.RunReservePlugins(pc, pod, "kube-01"); !status.IsSuccess() {
+		t.Fatalf("unexpected status at reserve: %v", status)
+	}
+	if status := fwk.RunPermitPlugins(pc, pod, "kube-01"); status.Code() != framework.Unschedulable {
+		t.Errorf("expected the pod rejected after the timeout, got %v", status)
+	}
+}
+
+// fakeWaitingPods is a framework handle with the given pods waiting.
+type fakeWaitingPods struct {
+	framework.FrameworkHandle
+	pods map[types.UID]framework.WaitingPod
+}
+
+func (f *fakeWaitingPods) GetWaitingPod(uid types.UID) framework.WaitingPod {
+	return f.pods[uid]
+}
+
+type fakeWaitingPod struct {
+	pod *v1.Pod
+}
+
+func (p *fakeWaitingPod) GetPod() *v1.Pod    { return p.pod }
+func (p *fakeWaitingPod) Allow() bool        { return true }
+func (p *fakeWaitingPod) Reject(string) bool { return true }
+
+func TestBandwidthBudgetReleaseForgottenPod(t *testing.T) {
+	h := newTestHandle(nil)
+	pod := newTestPod("stream-0")
+	fh := &fakeWaitingPods{pods: map[types.UID]framework.WaitingPod{pod.UID: &fakeWaitingPod{pod: pod}}}
+	bb := &BandwidthBudget{handle: h, frameworkHandle: fh, args: BandwidthBudgetArgs{Budget: 0.5}}
+	h.MetricsCache.Assume(pod.UID, "kube-01", h.Profiles.ForPod(pod).Metrics, h.Topology)
+	bb.held = []*heldPod{{uid: pod.UID, node: "kube-01", socket: "server-a/0", load: bb.load(pod), deadline: time.Now().Add(time.Minute)}}
+
+	// The pod times out at permit and is unreserved before it is released.
+	h.MetricsCache.Forget(pod.UID)
+	bb.release()
+	if got := traffic(t, h, "kube-01"); got != 1 {
+		t.Errorf("expected the load of the forgotten pod gone, got traffic %v", got)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package hardwarecounters provides the scheduling framework plugins of the
+// hardware-counter logic: AssumedLoad accounts for the load of a pod in the
+// metrics cache from Reserve until the monitoring data reflects it, and
+// rolls it back at Unreserve, and BandwidthBudget holds pods at Permit while
+// the memory traffic of their socket is over budget.
+package hardwarecounters
+
+import (
+	"fmt"
+
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// Handle gives the plugins access to the state of the scheduler that the
+// framework does not expose.
+type Handle struct {
+	// Topology maps the nodes to their sockets.
+	Topology topology.Interface
+	// MetricsCache holds the counters of the nodes and the load of the
+	// assumed pods.
+	MetricsCache *customcache.Cache
+	// Profiles serves the profiles of the applications run by the pods.
+	Profiles profiles.Lister
+	// StopCh stops the background work of the plugins.
+	StopCh <-chan struct{}
+}
+
+// NewRegistry returns the factories of the plugins of the package, built on
+// h.
+func NewRegistry(h *Handle) framework.Registry {
+	return framework.Registry{
+		AssumedLoadName:     h.newAssumedLoad,
+		BandwidthBudgetName: h.newBandwidthBudget,
+	}
+}
+
+// WithDefaults returns a copy of plugins with AssumedLoad enabled at Reserve
+// and Unreserve, unless it is disabled there by name or by "*", so that the
+// metrics cache accounts for the assumed pods whatever the configuration. It
+// fails if BandwidthBudget is enabled at Permit without AssumedLoad at
+// Reserve, since the budget would ignore the pods being bound.
+func WithDefaults(plugins *config.Plugins) (*config.Plugins, error) {
+	res := &config.Plugins{}
+	if plugins != nil {
+		*res = *plugins
+	}
+	res.Reserve = withDefault(res.Reserve, AssumedLoadName)
+	res.Unreserve = withDefault(res.Unreserve, AssumedLoadName)
+	if enabled(res.Permit, BandwidthBudgetName) && !enabled(res.Reserve, AssumedLoadName) {
+		return nil, fmt.Errorf("plugin %q enabled at permit requires plugin %q enabled at reserve", BandwidthBudgetName, AssumedLoadName)
+	}
+	return res, nil
+}
+
+// withDefault returns a copy of set with the named plugin enabled, unless it
+// is already enabled or disabled.
+func withDefault(set *config.PluginSet, name string) *config.PluginSet {
+	res := &config.PluginSet{}
+	if set != nil {
+		if enabled(set, name) {
+			return set
+		}
+		for _, p := range set.Disabled {
+			if p.Name == name || p.Name == "*" {
+				return set
+			}
+		}
+		*res = *set
+		res.Enabled = append([]config.Plugin(nil), set.Enabled...)
+	}
+	res.Enabled = append(res.Enabled, config.Plugin{Name: name})
+	return res
+}
+
+// enabled returns whether the named plugin is enabled in set.
+func enabled(set *config.PluginSet, name string) bool {
+	if set == nil {
+		return false
+	}
+	for _, p := range set.Enabled {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
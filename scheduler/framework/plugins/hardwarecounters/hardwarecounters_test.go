@@ -0,0 +1,178 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hardwarecounters
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// newTestHandle returns a handle of a server with two sockets of a node each
+// and a memory bandwidth of 2, both sockets at a memory traffic of 1.
+func newTestHandle(stopCh <-chan struct{}) *Handle {
+	topo := topology.New(
+		[]topology.Server{{UUID: "server-a", MemoryBandwidth: 2}},
+		[]topology.Node{
+			{Name: "kube-01", Server: "server-a", Socket: 0, Cores: []int{0, 1}},
+			{Name: "kube-02", Server: "server-a", Socket: 1, Cores: []int{2, 3}},
+		},
+	)
+	store := profiles.NewStore()
+	store.Load(&profiles.Config{Applications: map[string]*profiles.ApplicationProfile{
+		"stream": {Metrics: map[string]float64{"ipc": 0.5, "mem_read": 0.6, "mem_write": 0.2}},
+	}})
+	cache := customcache.New(customcache.DefaultTTL)
+	cache.Update("kube-01", map[string]float64{"mem_read": 0.7, "mem_write": 0.3})
+	cache.Update("kube-02", map[string]float64{"mem_read": 0.7, "mem_write": 0.3})
+	return &Handle{Topology: topo, MetricsCache: cache, Profiles: store, StopCh: stopCh}
+}
+
+func newTestPod(uid string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default",
+		Name:      uid,
+		UID:       types.UID(uid),
+		Labels:    map[string]string{profiles.ApplicationKey: "stream"},
+	}}
+}
+
+func traffic(t *testing.T, h *Handle, node string) float64 {
+	metrics, ok := h.MetricsCache.GetAll(node, "mem_read", "mem_write")
+	if !ok {
+		t.Fatalf("expected metrics cached for %v", node)
+	}
+	return metrics["mem_read"] + metrics["mem_write"]
+}
+
+func TestAssumedLoad(t *testing.T) {
+	h := newTestHandle(nil)
+	plugins, err := WithDefaults(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fwk, err := framework.NewFramework(NewRegistry(h), plugins, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := newTestPod("stream-uid")
+	pc := framework.NewPluginContext()
+
+	if status := fwk.RunReservePlugins(pc, pod, "kube-01"); !status.IsSuccess() {
+		t.Fatalf("unexpected status at reserve: %v", status)
+	}
+	if got := traffic(t, h, "kube-01"); got < 1.79 || got > 1.81 {
+		t.Errorf("expected the load of the pod added to the traffic of its socket, got %v", got)
+	}
+	if got := traffic(t, h, "kube-02"); got != 1 {
+		t.Errorf("expected the other socket unchanged, got %v", got)
+	}
+
+	fwk.RunUnreservePlugins(pc, pod, "kube-01")
+	if got := traffic(t, h, "kube-01"); got != 1 {
+		t.Errorf("expected the load of the pod dropped at unreserve, got %v", got)
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	assumedLoad := []config.Plugin{{Name: AssumedLoadName}}
+	tests := []struct {
+		name      string
+		plugins   *config.Plugins
+		reserve   []config.Plugin
+		unreserve []config.Plugin
+		expectErr bool
+	}{
+		{
+			name:      "nil configuration",
+			reserve:   assumedLoad,
+			unreserve: assumedLoad,
+		},
+		{
+			name: "other plugins enabled",
+			plugins: &config.Plugins{
+				Reserve: &config.PluginSet{Enabled: []config.Plugin{{Name: "foo"}}},
+			},
+			reserve:   []config.Plugin{{Name: "foo"}, {Name: AssumedLoadName}},
+			unreserve: assumedLoad,
+		},
+		{
+			name: "already enabled",
+			plugins: &config.Plugins{
+				Reserve: &config.PluginSet{Enabled: []config.Plugin{{Name: AssumedLoadName}, {Name: "foo"}}},
+			},
+			reserve:   []config.Plugin{{Name: AssumedLoadName}, {Name: "foo"}},
+			unreserve: assumedLoad,
+		},
+		{
+			name: "disabled",
+			plugins: &config.Plugins{
+				Reserve:   &config.PluginSet{Disabled: []config.Plugin{{Name: AssumedLoadName}}},
+				Unreserve: &config.PluginSet{Disabled: []config.Plugin{{Name: "*"}}},
+			},
+		},
+		{
+			name: "budget with the assumed load",
+			plugins: &config.Plugins{
+				Permit: &config.PluginSet{Enabled: []config.Plugin{{Name: BandwidthBudgetName}}},
+			},
+			reserve:   assumedLoad,
+			unreserve: assumedLoad,
+		},
+		{
+			name: "budget without the assumed load",
+			plugins: &config.Plugins{
+				Reserve: &config.PluginSet{Disabled: []config.Plugin{{Name: "*"}}},
+				Permit:  &config.PluginSet{Enabled: []config.Plugin{{Name: BandwidthBudgetName}}},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := test.plugins.DeepCopy()
+			res, err := WithDefaults(test.plugins)
+			if test.expectErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := res.Reserve.Enabled; !reflect.DeepEqual(got, test.reserve) {
+				t.Errorf("expected %v enabled at reserve, got %v", test.reserve, got)
+			}
+			if got := res.Unreserve.Enabled; !reflect.DeepEqual(got, test.unreserve) {
+				t.Errorf("expected %v enabled at unreserve, got %v", test.unreserve, got)
+			}
+			if !reflect.DeepEqual(before, test.plugins) {
+				t.Errorf("expected the configuration unchanged, got %+v", test.plugins)
+			}
+		})
+	}
+}
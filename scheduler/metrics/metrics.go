@@ -192,6 +192,28 @@ var (
 			Help:      "Total preemption attempts in the cluster till now",
 		})
 
+	HardwareMetricsAge = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Subsystem: SchedulerSubsystem,
+			Name:      "hardware_metrics_age_seconds",
+			Help:      "Age in seconds of the oldest hardware counter used to select the host of a pod",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+		})
+
+	DegradedScores = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: SchedulerSubsystem,
+			Name:      "degraded_scores_total",
+			Help:      "Number of nodes scored without fresh hardware counters while the monitoring backend was unavailable, by degradation mode",
+		}, []string{"mode"})
+
+	MonitoringCircuitBreakerState = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: SchedulerSubsystem,
+			Name:      "monitoring_circuit_breaker_state",
+			Help:      "State of the circuit breaker of the monitoring backend: 0 closed, 1 open, 2 half-open",
+		})
+
 	pendingPods = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Subsystem: SchedulerSubsystem,
@@ -220,6 +242,9 @@ var (
 		DeprecatedSchedulingAlgorithmPremptionEvaluationDuration,
 		PreemptionVictims,
 		PreemptionAttempts,
+		HardwareMetricsAge,
+		DegradedScores,
+		MonitoringCircuitBreakerState,
 		pendingPods,
 	}
 )
@@ -0,0 +1,196 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every query through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every query.
+	BreakerOpen
+	// BreakerHalfOpen lets a single query through to probe the backend.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "Closed"
+	case BreakerOpen:
+		return "Open"
+	case BreakerHalfOpen:
+		return "HalfOpen"
+	}
+	return "Unknown"
+}
+
+// CircuitBreaker wraps a MetricsSource and stops querying it after a number
+// of consecutive failures, so that an outage costs neither a timeout per
+// node scored nor load on a struggling backend. Once the open timeout
+// elapses, a single query probes the backend: its success closes the
+// circuit, its failure opens it again.
+//
+// Every error of the source is a failure, except for DataErrors, which are
+// about the samples of a server rather than about the backend. Failures and
+// rejected queries are returned as UnavailableErrors. It is safe for
+// concurrent use.
+type CircuitBreaker struct {
+	source        MetricsSource
+	config        CircuitBreakerConfig
+	onStateChange func(state BreakerState, err error)
+	now           func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	// probing is set while the query probing the backend is in flight.
+	probing bool
+}
+
+var _ MetricsSource = &CircuitBreaker{}
+
+// NewCircuitBreaker returns a closed CircuitBreaker guarding source.
+// onStateChange, if not nil, is called with the new state and the error
+// that caused it every time the state changes.
+func NewCircuitBreaker(source MetricsSource, config CircuitBreakerConfig, onStateChange func(state BreakerState, err error)) *CircuitBreaker {
+	return &CircuitBreaker{
+		source:        source,
+		config:        config,
+		onStateChange: onStateChange,
+		now:           time.Now,
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// SocketMetrics returns the metrics of a socket unless the circuit is open.
+func (b *CircuitBreaker) SocketMetrics(server string, socket int, metrics []string, window time.Duration) (map[string]float64, error) {
+	return b.call(func() (map[string]float64, error) {
+		return b.source.SocketMetrics(server, socket, metrics, window)
+	})
+}
+
+// CoreMetrics returns the metrics of a set of cores unless the circuit is
+// open.
+func (b *CircuitBreaker) CoreMetrics(server string, socket int, cores []int, metrics []string, window time.Duration) (map[string]float64, error) {
+	return b.call(func() (map[string]float64, error) {
+		return b.source.CoreMetrics(server, socket, cores, metrics, window)
+	})
+}
+
+// CoreMetricsRange returns the metrics of a set of cores between start and
+// end unless the circuit is open.
+func (b *CircuitBreaker) CoreMetricsRange(server string, socket int, cores []int, metrics []string, start, end time.Time) (map[string]float64, error) {
+	return b.call(func() (map[string]float64, error) {
+		return b.source.CoreMetricsRange(server, socket, cores, metrics, start, end)
+	})
+}
+
+// call runs query if the breaker allows it and records its outcome.
+func (b *CircuitBreaker) call(query func() (map[string]float64, error)) (map[string]float64, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	res, err := query()
+	if _, isDataError := ReasonOf(err); err != nil && !isDataError {
+		err = &UnavailableError{Err: err}
+		b.record(err)
+		return nil, err
+	}
+	b.record(nil)
+	return res, err
+}
+
+// allow returns an UnavailableError if the query must be rejected.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	var changed bool
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.config.OpenTimeout {
+			b.mu.Unlock()
+			return &UnavailableError{Err: ErrCircuitOpen}
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		changed = true
+	case BreakerHalfOpen:
+		if b.probing {
+			b.mu.Unlock()
+			return &UnavailableError{Err: ErrCircuitOpen}
+		}
+		b.probing = true
+	}
+	b.mu.Unlock()
+
+	if changed {
+		b.notify(BreakerHalfOpen, nil)
+	}
+	return nil
+}
+
+// record accounts for the outcome of a query, nil if it succeeded.
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	from := b.state
+	b.probing = false
+	if err == nil {
+		b.failures = 0
+		b.state = BreakerClosed
+	} else {
+		b.failures++
+		if b.state == BreakerHalfOpen || b.failures >= b.config.FailureThreshold {
+			b.state = BreakerOpen
+			b.openedAt = b.now()
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	if from != to {
+		b.notify(to, err)
+	}
+}
+
+func (b *CircuitBreaker) notify(state BreakerState, err error) {
+	switch state {
+	case BreakerOpen:
+		klog.Errorf("Opening the circuit breaker of the monitoring backend for %v: %v", b.config.OpenTimeout, err)
+	case BreakerHalfOpen:
+		klog.Infof("Probing the monitoring backend")
+	case BreakerClosed:
+		klog.Infof("Closing the circuit breaker of the monitoring backend")
+	}
+	if b.onStateChange != nil {
+		b.onStateChange(state, err)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// failingSource fails every query with err, if set.
+type failingSource struct {
+	err     error
+	queries int
+}
+
+func (s *failingSource) SocketMetrics(server string, socket int, metrics []string, window time.Duration) (map[string]float64, error) {
+	s.queries++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return map[string]float64{}, nil
+}
+
+func (s *failingSource) CoreMetrics(server string, socket int, cores []int, metrics []string, window time.Duration) (map[string]float64, error) {
+	return s.SocketMetrics(server, socket, metrics, window)
+}
+
+func (s *failingSource) CoreMetricsRange(server string, socket int, cores []int, metrics []string, start, end time.Time) (map[string]float64, error) {
+	return s.SocketMetrics(server, socket, metrics, end.Sub(start))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	source := &failingSource{err: fmt.Errorf("connection refused")}
+	var states []BreakerState
+	b := NewCircuitBreaker(source, CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: 30 * time.Second}, func(state BreakerState, err error) {
+		states = append(states, state)
+	})
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	query := func() error {
+		_, err := b.SocketMetrics("uuid-a", 0, []string{"ipc"}, time.Second)
+		return err
+	}
+
+	// DataErrors are about the samples, not about the backend.
+	source.err = newDataError(NoData, "no samples")
+	for i := 0; i < 3; i++ {
+		if err := query(); IsUnavailable(err) {
+			t.Fatalf("expected a data error, got %v", err)
+		}
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected data errors to keep the circuit closed")
+	}
+
+	source.err = fmt.Errorf("connection refused")
+	for i := 0; i < 2; i++ {
+		if err := query(); !IsUnavailable(err) {
+			t.Errorf("expected the backend to be unavailable, got %v", err)
+		}
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected the circuit to open after 2 failures, got %v", b.State())
+	}
+	queries := source.queries
+	if err := query(); !IsUnavailable(err) {
+		t.Errorf("expected the open circuit to reject the query, got %v", err)
+	}
+	if source.queries != queries {
+		t.Errorf("expected the open circuit not to query the backend")
+	}
+
+	// The probe fails and opens the circuit again.
+	now = now.Add(30 * time.Second)
+	if err := query(); !IsUnavailable(err) {
+		t.Errorf("expected the probe to fail, got %v", err)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected the failed probe to open the circuit, got %v", b.State())
+	}
+
+	// The probe succeeds and closes the circuit.
+	source.err = nil
+	now = now.Add(30 * time.Second)
+	if err := query(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected the successful probe to close the circuit, got %v", b.State())
+	}
+
+	expected := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if !reflect.DeepEqual(expected, states) {
+		t.Errorf("expected the state changes %v, got %v", expected, states)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	source := &failingSource{}
+	b := NewCircuitBreaker(source, CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Second}, nil)
+	b.state = BreakerHalfOpen
+	b.probing = true
+	if _, err := b.CoreMetrics("uuid-a", 0, []int{0}, []string{"c6res"}, time.Second); !IsUnavailable(err) {
+		t.Errorf("expected a single probe at a time, got %v", err)
+	}
+	if source.queries != 0 {
+		t.Errorf("expected the backend not to be queried during the probe")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"fmt"
+	"time"
+)
+
+// DegradationMode is how the hardware-counter priorities score the nodes
+// while the monitoring backend is unavailable.
+type DegradationMode string
+
+const (
+	// FallbackToResources scores the nodes with the stock resource
+	// priorities instead. It is the default.
+	FallbackToResources DegradationMode = "FallbackToResources"
+	// LastKnownGood scores the nodes with the last counters read from the
+	// backend, up to a maximum age. If any node has no such counters, all
+	// the nodes fall back to the resource priorities, so that their scores
+	// compare.
+	LastKnownGood DegradationMode = "LastKnownGood"
+	// FailClosed leaves the pods unschedulable until the backend recovers.
+	// The nodes missing counters while the backend answers, e.g. before the
+	// first refresh, fall back to the resource priorities.
+	FailClosed DegradationMode = "FailClosed"
+)
+
+const (
+	// DefaultLastKnownGoodMaxAge is the default maximum age of the counters
+	// used by LastKnownGood.
+	DefaultLastKnownGoodMaxAge = 2 * time.Minute
+
+	// DefaultFailureThreshold is the default number of consecutive failed
+	// queries that open the circuit breaker.
+	DefaultFailureThreshold = 5
+	// DefaultOpenTimeout is the default time the circuit breaker stays open
+	// before it lets a query through to probe the backend.
+	DefaultOpenTimeout = 30 * time.Second
+)
+
+// Degradation configures the scoring of the nodes while the monitoring
+// backend is unavailable.
+type Degradation struct {
+	Mode DegradationMode `yaml:"mode"`
+	// MaxAge bounds the age of the counters used by LastKnownGood, e.g. "2m".
+	MaxAge time.Duration `yaml:"maxAge"`
+}
+
+// Validate checks the degradation mode and its maximum age.
+func (d *Degradation) Validate() error {
+	switch d.Mode {
+	case FallbackToResources, LastKnownGood, FailClosed:
+	default:
+		return fmt.Errorf("unknown degradation mode %q", d.Mode)
+	}
+	if d.MaxAge <= 0 {
+		return fmt.Errorf("degradation max age must be positive, got %v", d.MaxAge)
+	}
+	return nil
+}
+
+// CircuitBreakerConfig configures the CircuitBreaker guarding the backend.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed queries that open
+	// the circuit.
+	FailureThreshold int `yaml:"failureThreshold"`
+	// OpenTimeout is how long the circuit stays open before a query is let
+	// through to probe the backend, e.g. "30s".
+	OpenTimeout time.Duration `yaml:"openTimeout"`
+}
+
+// Validate checks that the breaker can open and close again.
+func (c *CircuitBreakerConfig) Validate() error {
+	if c.FailureThreshold <= 0 {
+		return fmt.Errorf("circuit breaker failure threshold must be positive, got %d", c.FailureThreshold)
+	}
+	if c.OpenTimeout <= 0 {
+		return fmt.Errorf("circuit breaker open timeout must be positive, got %v", c.OpenTimeout)
+	}
+	return nil
+}
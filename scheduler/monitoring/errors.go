@@ -0,0 +1,94 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"errors"
+	"fmt"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// DataErrorReason is the reason why the samples returned by a query cannot be
+// used.
+type DataErrorReason string
+
+const (
+	// NoData means that the query returned no sample.
+	NoData DataErrorReason = "NoData"
+	// StaleData means that the most recent sample is too old to describe the
+	// current state of the server.
+	StaleData DataErrorReason = "StaleData"
+	// PartialWindow means that too few samples cover the requested window.
+	PartialWindow DataErrorReason = "PartialWindow"
+	// BadData means that a sample is not a number.
+	BadData DataErrorReason = "BadData"
+)
+
+// DataError is returned when a query succeeds but its samples cannot be
+// used.
+type DataError struct {
+	Reason  DataErrorReason
+	Message string
+}
+
+func (e *DataError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Reason, e.Message)
+}
+
+func newDataError(reason DataErrorReason, format string, args ...interface{}) *DataError {
+	return &DataError{Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+// ReasonOf returns the reason of err if it is a DataError.
+func ReasonOf(err error) (DataErrorReason, bool) {
+	if e, ok := err.(*DataError); ok {
+		return e.Reason, true
+	}
+	return "", false
+}
+
+// ErrCircuitOpen is returned, wrapped in an UnavailableError, for the queries
+// the CircuitBreaker rejects.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// UnavailableError is returned when the monitoring backend cannot be queried
+// at all, e.g. because it is unreachable or the circuit breaker is open, as
+// opposed to a DataError about the samples of a single server.
+type UnavailableError struct {
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("monitoring backend is unavailable: %v", e.Err)
+}
+
+// IsUnavailable returns whether err, or one of the errors it aggregates, is an
+// UnavailableError.
+func IsUnavailable(err error) bool {
+	switch e := err.(type) {
+	case *UnavailableError:
+		return true
+	case utilerrors.Aggregate:
+		for _, err := range e.Errors() {
+			if IsUnavailable(err) {
+				return true
+			}
+		}
+	}
+	return false
+}
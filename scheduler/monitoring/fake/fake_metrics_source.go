@@ -0,0 +1,114 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake provides an in-memory monitoring.MetricsSource for tests.
+package fake
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+)
+
+// Socket identifies a socket of a server.
+type Socket struct {
+	Server string
+	Socket int
+}
+
+// Core identifies a core of a socket of a server.
+type Core struct {
+	Server string
+	Socket int
+	Core   int
+}
+
+// Range is a time range queried from a MetricsSource.
+type Range struct {
+	Start time.Time
+	End   time.Time
+}
+
+// MetricsSource is a monitoring.MetricsSource returning fixed metrics. The
+// window and the ranges are ignored, but the ranges are recorded.
+type MetricsSource struct {
+	// Sockets holds the metrics of each socket.
+	Sockets map[Socket]map[string]float64
+	// Cores holds the metrics of each core.
+	Cores map[Core]map[string]float64
+	// Err, if set, is returned by every call.
+	Err error
+
+	mu     sync.Mutex
+	ranges []Range
+}
+
+var _ monitoring.MetricsSource = &MetricsSource{}
+
+// SocketMetrics returns the stored metrics of the socket.
+func (s *MetricsSource) SocketMetrics(server string, socket int, metrics []string, window time.Duration) (map[string]float64, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	values, ok := s.Sockets[Socket{Server: server, Socket: socket}]
+	if !ok {
+		return nil, &monitoring.DataError{Reason: monitoring.NoData, Message: fmt.Sprintf("no data for socket %d of server %s", socket, server)}
+	}
+	res := make(map[string]float64, len(metrics))
+	for _, metric := range metrics {
+		res[metric] = values[metric]
+	}
+	return res, nil
+}
+
+// CoreMetrics returns the average of the stored metrics of the cores.
+func (s *MetricsSource) CoreMetrics(server string, socket int, cores []int, metrics []string, window time.Duration) (map[string]float64, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	if len(cores) == 0 {
+		return nil, fmt.Errorf("no cores given")
+	}
+	res := make(map[string]float64, len(metrics))
+	for _, core := range cores {
+		values, ok := s.Cores[Core{Server: server, Socket: socket, Core: core}]
+		if !ok {
+			return nil, &monitoring.DataError{Reason: monitoring.NoData, Message: fmt.Sprintf("no data for core %d of server %s", core, server)}
+		}
+		for _, metric := range metrics {
+			res[metric] += values[metric] / float64(len(cores))
+		}
+	}
+	return res, nil
+}
+
+// CoreMetricsRange records the range and returns the average of the stored
+// metrics of the cores.
+func (s *MetricsSource) CoreMetricsRange(server string, socket int, cores []int, metrics []string, start, end time.Time) (map[string]float64, error) {
+	s.mu.Lock()
+	s.ranges = append(s.ranges, Range{Start: start, End: end})
+	s.mu.Unlock()
+	return s.CoreMetrics(server, socket, cores, metrics, end.Sub(start))
+}
+
+// Ranges returns the ranges queried so far, in order.
+func (s *MetricsSource) Ranges() []Range {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Range(nil), s.ranges...)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+// healthCheckPeriod is how often the backend is pinged.
+const healthCheckPeriod = 10 * time.Second
+
+// HealthCheckedBackend wraps a Backend and pings it periodically. While the
+// backend is unreachable, queries fail immediately instead of costing a
+// timeout for every node being scored.
+type HealthCheckedBackend struct {
+	Backend
+
+	mu  sync.RWMutex
+	err error
+}
+
+// NewHealthCheckedBackend returns a HealthCheckedBackend wrapping b. The
+// backend is assumed healthy until the first check.
+func NewHealthCheckedBackend(b Backend) *HealthCheckedBackend {
+	return &HealthCheckedBackend{Backend: b}
+}
+
+// Run checks the backend periodically until stopCh is closed, then closes it.
+func (h *HealthCheckedBackend) Run(stopCh <-chan struct{}) {
+	wait.Until(h.Check, healthCheckPeriod, stopCh)
+	if err := h.Backend.Close(); err != nil {
+		klog.Errorf("Error while closing the monitoring backend: %v", err)
+	}
+}
+
+// Check pings the backend and records the result.
+func (h *HealthCheckedBackend) Check() {
+	err := h.Backend.Ping()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch {
+	case err != nil && h.err == nil:
+		klog.Errorf("Monitoring backend is unreachable: %v", err)
+	case err == nil && h.err != nil:
+		klog.Infof("Monitoring backend is reachable again")
+	}
+	h.err = err
+}
+
+// Healthy returns the error of the last failed check, if the backend has not
+// recovered since.
+func (h *HealthCheckedBackend) Healthy() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.err != nil {
+		return fmt.Errorf("monitoring backend is unreachable: %v", h.err)
+	}
+	return nil
+}
+
+// SocketMetrics returns the metrics of a socket if the backend is healthy.
+func (h *HealthCheckedBackend) SocketMetrics(server string, socket int, metrics []string, window time.Duration) (map[string]float64, error) {
+	if err := h.Healthy(); err != nil {
+		return nil, err
+	}
+	return h.Backend.SocketMetrics(server, socket, metrics, window)
+}
+
+// CoreMetrics returns the metrics of a set of cores if the backend is healthy.
+func (h *HealthCheckedBackend) CoreMetrics(server string, socket int, cores []int, metrics []string, window time.Duration) (map[string]float64, error) {
+	if err := h.Healthy(); err != nil {
+		return nil, err
+	}
+	return h.Backend.CoreMetrics(server, socket, cores, metrics, window)
+}
+
+// CoreMetricsRange returns the metrics of a set of cores between start and end
+// if the backend is healthy.
+func (h *HealthCheckedBackend) CoreMetricsRange(server string, socket int, cores []int, metrics []string, start, end time.Time) (map[string]float64, error) {
+	if err := h.Healthy(); err != nil {
+		return nil, err
+	}
+	return h.Backend.CoreMetricsRange(server, socket, cores, metrics, start, end)
+}
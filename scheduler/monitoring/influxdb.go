@@ -0,0 +1,343 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+	client "github.com/influxdata/influxdb1-client/v2"
+	"k8s.io/klog"
+)
+
+// InfluxDB is a Backend reading the socket_metrics and core_metrics
+// measurements of an InfluxDB v1 database. Its HTTP connections are pooled
+// and shared by all the queries.
+type InfluxDB struct {
+	cfg    *Config
+	client client.Client
+	now    func() time.Time
+}
+
+var _ Backend = &InfluxDB{}
+
+// NewInfluxDB returns an InfluxDB Backend for the given config.
+func NewInfluxDB(cfg *Config) (*InfluxDB, error) {
+	c, err := client.NewHTTPClient(client.HTTPConfig{
+		Addr:     cfg.Address(),
+		Username: cfg.Database.Username,
+		Password: cfg.Database.Password,
+		Timeout:  cfg.MonitoringSpecs.QueryTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error while connecting to InfluxDB: %v", err)
+	}
+	return &InfluxDB{cfg: cfg, client: c, now: time.Now}, nil
+}
+
+// Ping checks that the database is reachable.
+func (s *InfluxDB) Ping() error {
+	_, _, err := s.client.Ping(s.cfg.MonitoringSpecs.QueryTimeout)
+	return err
+}
+
+// Close releases the connections to the database.
+func (s *InfluxDB) Close() error {
+	return s.client.Close()
+}
+
+func (s *InfluxDB) query(b *selectBuilder) (*client.Response, error) {
+	command, params, err := b.build()
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.client.Query(client.NewQueryWithParameters(command, s.cfg.Database.Name, "", params))
+	if err != nil {
+		return nil, fmt.Errorf("error while executing the query: %v", err)
+	}
+	if err := response.Error(); err != nil {
+		return nil, fmt.Errorf("error while executing the query: %v", err)
+	}
+	return response, nil
+}
+
+// SocketMetrics returns the weighted average of the given metrics of a
+// socket of a server over the last window.
+func (s *InfluxDB) SocketMetrics(server string, socket int, metrics []string, window time.Duration) (map[string]float64, error) {
+	b := newSelect("socket_metrics", metrics...).
+		where("uuid", server).
+		where("socket_id", strconv.Itoa(socket)).
+		within(window)
+	response, err := s.query(b)
+	if err != nil {
+		return nil, err
+	}
+
+	// Calculate the average for the metrics provided
+	return calculateWeightedAverage(response, metrics, s.window(window))
+}
+
+// CoreMetrics returns the weighted average of the given metrics over the last
+// window, averaged over the given cores of a socket.
+func (s *InfluxDB) CoreMetrics(server string, socket int, cores []int, metrics []string, window time.Duration) (map[string]float64, error) {
+	return s.coreMetrics(server, socket, cores, metrics, s.window(window))
+}
+
+// CoreMetricsRange returns the average of the given metrics between start and
+// end, averaged over the given cores of a socket.
+func (s *InfluxDB) CoreMetricsRange(server string, socket int, cores []int, metrics []string, start, end time.Time) (map[string]float64, error) {
+	return s.coreMetrics(server, socket, cores, metrics, newPastWindow(s.cfg, start, end))
+}
+
+func (s *InfluxDB) coreMetrics(server string, socket int, cores []int, metrics []string, w sampleWindow) (map[string]float64, error) {
+	if len(cores) == 0 {
+		return nil, fmt.Errorf("no cores given")
+	}
+	ids := make([]string, len(cores))
+	for i, core := range cores {
+		ids[i] = strconv.Itoa(core)
+	}
+	// One series per core, so that a missing sample only affects its own core.
+	b := newSelect("core_metrics", metrics...).
+		where("uuid", server).
+		where("socket_id", strconv.Itoa(socket)).
+		whereIn("core_id", ids).
+		group("core_id")
+	if w.past {
+		b.between(w.now.Add(-w.length), w.now)
+	} else {
+		b.within(w.length)
+	}
+	response, err := s.query(b)
+	if err != nil {
+		return nil, err
+	}
+
+	// Calculate the average for the metrics provided
+	return calculateWeightedAverageCores(response, metrics, ids, w)
+}
+
+// staleIntervals is the number of sampling intervals after which the most
+// recent sample of a series is considered stale.
+const staleIntervals = 3
+
+// sampleWindow describes the samples a query is expected to return.
+type sampleWindow struct {
+	now      time.Time
+	length   time.Duration
+	interval time.Duration
+	// past is set for a window ending at now in the past, e.g. the run of a
+	// pod. Its samples are not expected to be recent and weigh the same.
+	past bool
+}
+
+// window returns the sampleWindow of a query over the last length.
+func (s *InfluxDB) window(length time.Duration) sampleWindow {
+	return newSampleWindow(s.cfg, s.now(), length)
+}
+
+// newSampleWindow returns the sampleWindow of a query over the length before
+// now, sampled every interval of cfg.
+func newSampleWindow(cfg *Config, now time.Time, length time.Duration) sampleWindow {
+	return sampleWindow{
+		now:      now,
+		length:   length,
+		interval: time.Duration(cfg.MonitoringSpecs.TimeInterval * float32(time.Second)),
+	}
+}
+
+// newPastWindow returns the sampleWindow of a query between start and end,
+// sampled every interval of cfg.
+func newPastWindow(cfg *Config, start, end time.Time) sampleWindow {
+	w := newSampleWindow(cfg, end, end.Sub(start))
+	w.past = true
+	return w
+}
+
+// average returns the average of samples, ordered from the most recent to
+// the oldest, weighted by recency unless the window is in the past.
+func (w sampleWindow) average(samples []float64) float64 {
+	if w.past {
+		return average(samples)
+	}
+	return weightedAverage(samples)
+}
+
+// minSamples returns the number of samples below which the window is
+// considered partial. Without a sampling interval any sample will do.
+func (w sampleWindow) minSamples() int {
+	if w.interval <= 0 {
+		return 1
+	}
+	expected := int(w.length / w.interval)
+	if expected < 2 {
+		return 1
+	}
+	return expected / 2
+}
+
+// calculateWeightedAverage returns the weighted average of each metric over
+// the points of the first series of the response.
+func calculateWeightedAverage(response *client.Response, metrics []string, w sampleWindow) (map[string]float64, error) {
+	for i := range response.Results {
+		if len(response.Results[i].Series) > 0 {
+			return seriesAverage(&response.Results[i].Series[0], metrics, w)
+		}
+	}
+	return nil, newDataError(NoData, "no series returned")
+}
+
+// calculateWeightedAverageCores returns the weighted average of each metric
+// per core, averaged over the cores with usable samples. Series of other
+// cores are ignored. If no core has usable samples, the error of the last one
+// is returned.
+func calculateWeightedAverageCores(response *client.Response, metrics []string, cores []string, w sampleWindow) (map[string]float64, error) {
+	wanted := make(map[string]bool, len(cores))
+	for _, core := range cores {
+		wanted[core] = true
+	}
+	res := make(map[string]float64, len(metrics))
+	found := 0
+	var lastErr error = newDataError(NoData, "no series returned for cores %v", cores)
+	for i := range response.Results {
+		for j := range response.Results[i].Series {
+			rows := &response.Results[i].Series[j]
+			core := rows.Tags["core_id"]
+			if !wanted[core] {
+				continue
+			}
+			averages, err := seriesAverage(rows, metrics, w)
+			if err != nil {
+				if _, ok := ReasonOf(err); !ok {
+					return nil, fmt.Errorf("core %s: %v", core, err)
+				}
+				klog.V(4).Infof("Ignoring core %s: %v", core, err)
+				lastErr = err
+				continue
+			}
+			for metric, val := range averages {
+				res[metric] += val
+			}
+			found++
+		}
+	}
+	if found == 0 {
+		return nil, lastErr
+	}
+	for metric := range res {
+		res[metric] /= float64(found)
+	}
+	return res, nil
+}
+
+// seriesAverage returns the average of the given columns of a series whose
+// points are ordered from the most recent to the oldest. Null
+// values are skipped and the weights of the remaining samples are
+// normalised.
+func seriesAverage(rows *models.Row, metrics []string, w sampleWindow) (map[string]float64, error) {
+	columns := make(map[string]int, len(rows.Columns))
+	for i, column := range rows.Columns {
+		columns[column] = i
+	}
+	if len(rows.Values) == 0 {
+		return nil, newDataError(NoData, "series %s has no points", rows.Name)
+	}
+	if err := checkStaleness(rows.Values[0], columns, w); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]float64, len(metrics))
+	for _, metric := range metrics {
+		i, ok := columns[metric]
+		if !ok {
+			return nil, newDataError(NoData, "missing column %s", metric)
+		}
+		samples := make([]float64, 0, len(rows.Values))
+		for _, values := range rows.Values {
+			if i >= len(values) || values[i] == nil {
+				continue
+			}
+			val, err := toFloat(values[i])
+			if err != nil {
+				return nil, newDataError(BadData, "%s: %v", metric, err)
+			}
+			samples = append(samples, val)
+		}
+		if len(samples) == 0 {
+			return nil, newDataError(NoData, "%s has only null values", metric)
+		}
+		if err := w.checkCoverage(metric, len(samples)); err != nil {
+			return nil, err
+		}
+		res[metric] = w.average(samples)
+	}
+	return res, nil
+}
+
+// checkStaleness returns a StaleData error if the time of the most recent
+// point is more than staleIntervals sampling intervals old.
+func checkStaleness(newest []interface{}, columns map[string]int, w sampleWindow) error {
+	i, ok := columns["time"]
+	if !ok || w.interval <= 0 || w.now.IsZero() || i >= len(newest) {
+		return nil
+	}
+	str, ok := newest[i].(string)
+	if !ok {
+		return fmt.Errorf("invalid time %v", newest[i])
+	}
+	t, err := time.Parse(time.RFC3339Nano, str)
+	if err != nil {
+		return fmt.Errorf("invalid time %v: %v", str, err)
+	}
+	return w.checkNewest(t)
+}
+
+// checkNewest returns a StaleData error if the most recent sample, taken at
+// t, is more than staleIntervals sampling intervals old.
+func (w sampleWindow) checkNewest(t time.Time) error {
+	if w.interval <= 0 || w.now.IsZero() || w.past {
+		return nil
+	}
+	if age := w.now.Sub(t); age > staleIntervals*w.interval {
+		return newDataError(StaleData, "the most recent sample is %v old", age)
+	}
+	return nil
+}
+
+// checkCoverage returns a PartialWindow error if a metric has too few
+// samples to cover the window.
+func (w sampleWindow) checkCoverage(metric string, samples int) error {
+	if min := w.minSamples(); samples < min {
+		return newDataError(PartialWindow, "%s has %d samples over %v, at least %d are needed", metric, samples, w.length, min)
+	}
+	return nil
+}
+
+// toFloat converts a value decoded from a response to a float64.
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case float64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("invalid value %v of type %T", value, value)
+	}
+}
@@ -0,0 +1,451 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+// newTestConfig returns a Config pointing at the given test server.
+func newTestConfig(t *testing.T, server *httptest.Server, dbType string) *Config {
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{}
+	cfg.Server.Host = host
+	cfg.Server.Port = port
+	cfg.Database.Type = dbType
+	cfg.Database.Name = "evolve"
+	cfg.MonitoringSpecs.TimeInterval = 1
+	cfg.MonitoringSpecs.QueryTimeout = DefaultQueryTimeout
+	return cfg
+}
+
+func TestInfluxDB(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		query    func(s *InfluxDB) (map[string]float64, error)
+		expected map[string]float64
+		wantErr  bool
+	}{
+		{
+			name: "socket metrics",
+			response: `{"results":[{"statement_id":0,"series":[{"name":"socket_metrics",
+				"columns":["time","ipc","mem_read"],
+				"values":[["2020-01-01T00:00:02Z",2,0.5],["2020-01-01T00:00:01Z",1,0.5]]}]}]}`,
+			query: func(s *InfluxDB) (map[string]float64, error) {
+				return s.SocketMetrics("uuid-a", 1, []string{"ipc", "mem_read"}, 2*time.Second)
+			},
+			expected: map[string]float64{"ipc": 5.0 / 3, "mem_read": 0.5},
+		},
+		{
+			name: "core metrics",
+			response: `{"results":[{"statement_id":0,"series":[
+				{"name":"core_metrics","tags":{"core_id":"4"},"columns":["time","c6res"],
+					"values":[["2020-01-01T00:00:02Z",1],["2020-01-01T00:00:01Z",0]]},
+				{"name":"core_metrics","tags":{"core_id":"5"},"columns":["time","c6res"],
+					"values":[["2020-01-01T00:00:02Z",3],["2020-01-01T00:00:01Z",0]]}]}]}`,
+			query: func(s *InfluxDB) (map[string]float64, error) {
+				return s.CoreMetrics("uuid-a", 0, []int{4, 5}, []string{"c6res"}, 2*time.Second)
+			},
+			expected: map[string]float64{"c6res": 4.0 / 3},
+		},
+		{
+			name: "core metrics with a missing sample and another core",
+			response: `{"results":[{"statement_id":0,"series":[
+				{"name":"core_metrics","tags":{"core_id":"4"},"columns":["time","c6res"],
+					"values":[["2020-01-01T00:00:02Z",3],["2020-01-01T00:00:01Z",0]]},
+				{"name":"core_metrics","tags":{"core_id":"5"},"columns":["time","c6res"],
+					"values":[["2020-01-01T00:00:01Z",1]]},
+				{"name":"core_metrics","tags":{"core_id":"6"},"columns":["time","c6res"],
+					"values":[["2020-01-01T00:00:02Z",100]]}]}]}`,
+			query: func(s *InfluxDB) (map[string]float64, error) {
+				return s.CoreMetrics("uuid-a", 0, []int{4, 5}, []string{"c6res"}, 2*time.Second)
+			},
+			expected: map[string]float64{"c6res": 1.5},
+		},
+		{
+			name: "core metrics over a past range",
+			response: `{"results":[{"statement_id":0,"series":[
+				{"name":"core_metrics","tags":{"core_id":"4"},"columns":["time","c6res"],
+					"values":[["2019-01-01T00:00:02Z",3],["2019-01-01T00:00:01Z",0]]},
+				{"name":"core_metrics","tags":{"core_id":"5"},"columns":["time","c6res"],
+					"values":[["2019-01-01T00:00:02Z",1],["2019-01-01T00:00:01Z",0]]}]}]}`,
+			query: func(s *InfluxDB) (map[string]float64, error) {
+				start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+				return s.CoreMetricsRange("uuid-a", 0, []int{4, 5}, []string{"c6res"}, start, start.Add(2*time.Second))
+			},
+			// Unweighted, and not stale although a year old.
+			expected: map[string]float64{"c6res": 1},
+		},
+		{
+			name:     "no series",
+			response: `{"results":[{"statement_id":0}]}`,
+			query: func(s *InfluxDB) (map[string]float64, error) {
+				return s.SocketMetrics("uuid-a", 0, []string{"ipc"}, 2*time.Second)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "query error",
+			response: `{"results":[{"statement_id":0,"error":"database not found: evolve"}]}`,
+			query: func(s *InfluxDB) (map[string]float64, error) {
+				return s.SocketMetrics("uuid-a", 0, []string{"ipc"}, 2*time.Second)
+			},
+			wantErr: true,
+		},
+		{
+			name: "no cores",
+			query: func(s *InfluxDB) (map[string]float64, error) {
+				return s.CoreMetrics("uuid-a", 0, nil, []string{"c6res"}, 2*time.Second)
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, test.response)
+			}))
+			defer server.Close()
+
+			s, err := NewInfluxDB(newTestConfig(t, server, InfluxDBType))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			s.now = func() time.Time { return time.Date(2020, 1, 1, 0, 0, 2, 0, time.UTC) }
+			res, err := test.query(s)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !closeTo(res, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, res)
+			}
+		})
+	}
+}
+
+func TestInfluxDBQueryParameters(t *testing.T) {
+	var query, params string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.FormValue("q")
+		params = r.FormValue("params")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"results":[{"statement_id":0}]}`)
+	}))
+	defer server.Close()
+
+	s, err := NewInfluxDB(newTestConfig(t, server, InfluxDBType))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	injected := `uuid-a' OR 1=1`
+	s.CoreMetrics(injected, 0, []int{4}, []string{"c6res"}, 2*time.Second)
+	expectedQuery := `SELECT "c6res" FROM "core_metrics" WHERE "uuid" = $uuid0 AND "socket_id" = $socket_id1 AND ("core_id" = $core_id2) AND time > now() - 2000ms GROUP BY "core_id" ORDER BY time DESC`
+	if query != expectedQuery {
+		t.Errorf("expected query %s, got %s", expectedQuery, query)
+	}
+	expectedParams := `{"core_id2":"4","socket_id1":"0","uuid0":"uuid-a' OR 1=1"}`
+	if params != expectedParams {
+		t.Errorf("expected params %s, got %s", expectedParams, params)
+	}
+}
+
+func TestSelectBuilder(t *testing.T) {
+	tests := []struct {
+		name           string
+		builder        *selectBuilder
+		expectedQuery  string
+		expectedParams map[string]interface{}
+		expectErr      bool
+	}{
+		{
+			name:           "no predicates",
+			builder:        newSelect("socket_metrics", "ipc", "mem_read"),
+			expectedQuery:  `SELECT "ipc", "mem_read" FROM "socket_metrics" ORDER BY time DESC`,
+			expectedParams: map[string]interface{}{},
+		},
+		{
+			name:          "time range",
+			builder:       newSelect("socket_metrics", "ipc").where("uuid", "a").within(1500 * time.Millisecond),
+			expectedQuery: `SELECT "ipc" FROM "socket_metrics" WHERE "uuid" = $uuid0 AND time > now() - 1500ms ORDER BY time DESC`,
+			expectedParams: map[string]interface{}{
+				"uuid0": "a",
+			},
+		},
+		{
+			name: "explicit time range",
+			builder: newSelect("core_metrics", "c6res").where("uuid", "a").
+				between(time.Unix(1577836800, 0), time.Unix(1577836860, 0)),
+			expectedQuery: `SELECT "c6res" FROM "core_metrics" WHERE "uuid" = $uuid0 AND time >= 1577836800000000000 AND time <= 1577836860000000000 ORDER BY time DESC`,
+			expectedParams: map[string]interface{}{
+				"uuid0": "a",
+			},
+		},
+		{
+			name:          "group by core",
+			builder:       newSelect("core_metrics", "c6res").whereIn("core_id", []string{"4", "5"}).group("core_id"),
+			expectedQuery: `SELECT "c6res" FROM "core_metrics" WHERE ("core_id" = $core_id0 OR "core_id" = $core_id1) GROUP BY "core_id" ORDER BY time DESC`,
+			expectedParams: map[string]interface{}{
+				"core_id0": "4",
+				"core_id1": "5",
+			},
+		},
+		{
+			name:      "no fields",
+			builder:   newSelect("socket_metrics"),
+			expectErr: true,
+		},
+		{
+			name:      "invalid field",
+			builder:   newSelect("socket_metrics", `ipc" FROM x; DROP DATABASE evolve --`),
+			expectErr: true,
+		},
+		{
+			name:      "invalid tag",
+			builder:   newSelect("socket_metrics", "ipc").where(`uuid" = '' OR "a`, "a"),
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query, params, err := test.builder.build()
+			if (err != nil) != test.expectErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if query != test.expectedQuery {
+				t.Errorf("expected query %s, got %s", test.expectedQuery, query)
+			}
+			if !reflect.DeepEqual(params, test.expectedParams) {
+				t.Errorf("expected params %v, got %v", test.expectedParams, params)
+			}
+		})
+	}
+}
+
+// newTestResponse returns a response holding the given series.
+func newTestResponse(series ...models.Row) *client.Response {
+	return &client.Response{Results: []client.Result{{Series: series}}}
+}
+
+// newTestSeries returns a series of c6res values tagged with a core, most
+// recent first, one second apart from 00:00:10.
+func newTestSeries(core string, values ...interface{}) models.Row {
+	row := models.Row{
+		Name:    "core_metrics",
+		Tags:    map[string]string{"core_id": core},
+		Columns: []string{"time", "c6res"},
+	}
+	for i, val := range values {
+		t := time.Date(2020, 1, 1, 0, 0, 10-i, 0, time.UTC).Format(time.RFC3339Nano)
+		row.Values = append(row.Values, []interface{}{t, val})
+	}
+	return row
+}
+
+// testWindow covers the 4 seconds up to 00:00:10, sampled every second.
+var testWindow = sampleWindow{
+	now:      time.Date(2020, 1, 1, 0, 0, 10, 0, time.UTC),
+	length:   4 * time.Second,
+	interval: time.Second,
+}
+
+func TestCalculateWeightedAverage(t *testing.T) {
+	tests := []struct {
+		name           string
+		response       *client.Response
+		window         sampleWindow
+		expected       map[string]float64
+		expectedReason DataErrorReason
+		expectErr      bool
+	}{
+		{
+			name:     "full window",
+			response: newTestResponse(newTestSeries("0", json.Number("4"), json.Number("2"), json.Number("0"), json.Number("0"))),
+			window:   testWindow,
+			expected: map[string]float64{"c6res": 2.2},
+		},
+		{
+			name:     "null values are skipped",
+			response: newTestResponse(newTestSeries("0", json.Number("3"), nil, json.Number("0"))),
+			window:   testWindow,
+			expected: map[string]float64{"c6res": 2},
+		},
+		{
+			name:           "no results",
+			response:       &client.Response{},
+			window:         testWindow,
+			expectedReason: NoData,
+		},
+		{
+			name:           "no series",
+			response:       newTestResponse(),
+			window:         testWindow,
+			expectedReason: NoData,
+		},
+		{
+			name:           "no points",
+			response:       newTestResponse(newTestSeries("0")),
+			window:         testWindow,
+			expectedReason: NoData,
+		},
+		{
+			name:           "only null values",
+			response:       newTestResponse(newTestSeries("0", nil, nil)),
+			window:         testWindow,
+			expectedReason: NoData,
+		},
+		{
+			name:           "missing column",
+			response:       newTestResponse(models.Row{Columns: []string{"time", "ipc"}, Values: [][]interface{}{{"2020-01-01T00:00:10Z", json.Number("1")}}}),
+			window:         testWindow,
+			expectedReason: NoData,
+		},
+		{
+			name:           "stale data",
+			response:       newTestResponse(newTestSeries("0", json.Number("1"), json.Number("1"))),
+			window:         sampleWindow{now: testWindow.now.Add(time.Minute), length: 4 * time.Second, interval: time.Second},
+			expectedReason: StaleData,
+		},
+		{
+			name:           "partial window",
+			response:       newTestResponse(newTestSeries("0", json.Number("1"))),
+			window:         testWindow,
+			expectedReason: PartialWindow,
+		},
+		{
+			name:     "zero interval",
+			response: newTestResponse(newTestSeries("0", json.Number("1"))),
+			window:   sampleWindow{now: testWindow.now, length: 4 * time.Second},
+			expected: map[string]float64{"c6res": 1},
+		},
+		{
+			name:           "invalid value",
+			response:       newTestResponse(newTestSeries("0", "idle", json.Number("1"))),
+			window:         testWindow,
+			expectedReason: BadData,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := calculateWeightedAverage(test.response, []string{"c6res"}, test.window)
+			checkDataError(t, err, test.expectedReason, test.expectErr)
+			if !closeTo(res, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, res)
+			}
+		})
+	}
+}
+
+func TestCalculateWeightedAverageCores(t *testing.T) {
+	tests := []struct {
+		name           string
+		response       *client.Response
+		expected       map[string]float64
+		expectedReason DataErrorReason
+	}{
+		{
+			name: "all cores",
+			response: newTestResponse(
+				newTestSeries("4", json.Number("1"), json.Number("1")),
+				newTestSeries("5", json.Number("3"), json.Number("0"))),
+			expected: map[string]float64{"c6res": 1.5},
+		},
+		{
+			name: "core without points",
+			response: newTestResponse(
+				newTestSeries("4", json.Number("1"), json.Number("1")),
+				newTestSeries("5")),
+			expected: map[string]float64{"c6res": 1},
+		},
+		{
+			name: "missing core and another core",
+			response: newTestResponse(
+				newTestSeries("4", json.Number("1"), json.Number("1")),
+				newTestSeries("6", json.Number("100"), json.Number("100"))),
+			expected: map[string]float64{"c6res": 1},
+		},
+		{
+			name:           "no cores with data",
+			response:       newTestResponse(newTestSeries("6", json.Number("100"))),
+			expectedReason: NoData,
+		},
+		{
+			name: "partial windows only",
+			response: newTestResponse(
+				newTestSeries("4", json.Number("1")),
+				newTestSeries("5", nil, json.Number("1"))),
+			expectedReason: PartialWindow,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := calculateWeightedAverageCores(test.response, []string{"c6res"}, []string{"4", "5"}, testWindow)
+			checkDataError(t, err, test.expectedReason, false)
+			if !closeTo(res, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, res)
+			}
+		})
+	}
+}
+
+// checkDataError checks that err is a DataError with the given reason, or any
+// other error if expectErr is set.
+func checkDataError(t *testing.T, err error, expectedReason DataErrorReason, expectErr bool) {
+	t.Helper()
+	reason, ok := ReasonOf(err)
+	switch {
+	case len(expectedReason) > 0 && reason != expectedReason:
+		t.Errorf("expected a %s error, got %v", expectedReason, err)
+	case expectErr && (err == nil || ok):
+		t.Errorf("expected an error other than a DataError, got %v", err)
+	case len(expectedReason) == 0 && !expectErr && err != nil:
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func closeTo(got, want map[string]float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for k, v := range want {
+		if math.Abs(got[k]-v) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// identifierRegexp matches the measurement, field and tag names that may be
+// spliced into a statement. Everything else is passed as a bound parameter.
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// selectBuilder builds an InfluxQL SELECT statement whose values are bound
+// parameters.
+type selectBuilder struct {
+	measurement string
+	fields      []string
+	conditions  []string
+	params      map[string]interface{}
+	since       time.Duration
+	from, to    time.Time
+	groupBy     []string
+}
+
+func newSelect(measurement string, fields ...string) *selectBuilder {
+	return &selectBuilder{
+		measurement: measurement,
+		fields:      fields,
+		params:      map[string]interface{}{},
+	}
+}
+
+// where adds a `tag = value` predicate.
+func (b *selectBuilder) where(tag string, value string) *selectBuilder {
+	param := b.bind(tag, value)
+	b.conditions = append(b.conditions, fmt.Sprintf("%q = $%s", tag, param))
+	return b
+}
+
+// whereIn adds a predicate matching any of the values of tag.
+func (b *selectBuilder) whereIn(tag string, values []string) *selectBuilder {
+	terms := make([]string, len(values))
+	for i, value := range values {
+		terms[i] = fmt.Sprintf("%q = $%s", tag, b.bind(tag, value))
+	}
+	b.conditions = append(b.conditions, "("+strings.Join(terms, " OR ")+")")
+	return b
+}
+
+// within restricts the statement to the points of the last window.
+func (b *selectBuilder) within(window time.Duration) *selectBuilder {
+	b.since = window
+	return b
+}
+
+// between restricts the statement to the points between start and end.
+func (b *selectBuilder) between(start, end time.Time) *selectBuilder {
+	b.from, b.to = start, end
+	return b
+}
+
+// group groups the result in one series per value of tags.
+func (b *selectBuilder) group(tags ...string) *selectBuilder {
+	b.groupBy = append(b.groupBy, tags...)
+	return b
+}
+
+// bind stores value as a parameter and returns its name.
+func (b *selectBuilder) bind(tag string, value string) string {
+	name := fmt.Sprintf("%s%d", tag, len(b.params))
+	b.params[name] = value
+	return name
+}
+
+// build returns the statement and its parameters, most recent points first.
+func (b *selectBuilder) build() (string, map[string]interface{}, error) {
+	if len(b.fields) == 0 {
+		return "", nil, fmt.Errorf("no fields selected")
+	}
+	identifiers := append([]string{b.measurement}, b.fields...)
+	identifiers = append(identifiers, b.groupBy...)
+	for _, id := range identifiers {
+		if !identifierRegexp.MatchString(id) {
+			return "", nil, fmt.Errorf("invalid identifier %q", id)
+		}
+	}
+	for param := range b.params {
+		if !identifierRegexp.MatchString(param) {
+			return "", nil, fmt.Errorf("invalid tag %q", param)
+		}
+	}
+
+	quoted := make([]string, len(b.fields))
+	for i, field := range b.fields {
+		quoted[i] = fmt.Sprintf("%q", field)
+	}
+	var command strings.Builder
+	fmt.Fprintf(&command, "SELECT %s FROM %q", strings.Join(quoted, ", "), b.measurement)
+
+	conditions := b.conditions
+	if b.since > 0 {
+		conditions = append(conditions, fmt.Sprintf("time > now() - %dms", int64(b.since/time.Millisecond)))
+	}
+	if !b.from.IsZero() || !b.to.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("time >= %d AND time <= %d", b.from.UnixNano(), b.to.UnixNano()))
+	}
+	if len(conditions) > 0 {
+		fmt.Fprintf(&command, " WHERE %s", strings.Join(conditions, " AND "))
+	}
+	if len(b.groupBy) > 0 {
+		tags := make([]string, len(b.groupBy))
+		for i, tag := range b.groupBy {
+			tags[i] = fmt.Sprintf("%q", tag)
+		}
+		fmt.Fprintf(&command, " GROUP BY %s", strings.Join(tags, ", "))
+	}
+	command.WriteString(" ORDER BY time DESC")
+	return command.String(), b.params, nil
+}
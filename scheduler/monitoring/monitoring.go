@@ -0,0 +1,198 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package monitoring provides access to the hardware counters (ipc, memory
+// traffic, C-state residency, ...) collected on the physical servers that
+// host the kube nodes.
+package monitoring
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// DefaultConfigFile is the default location of the monitoring config.
+	DefaultConfigFile = "/etc/kubernetes/scheduler-monitoringDB.yaml"
+
+	// InfluxDBType selects the InfluxDB v1 backend. It is the default.
+	InfluxDBType = "influxdb"
+	// PrometheusType selects the Prometheus HTTP API backend.
+	PrometheusType = "prometheus"
+
+	// DefaultQueryTimeout is the default timeout of a query to the backend.
+	DefaultQueryTimeout = 2 * time.Second
+)
+
+// MetricsSource is implemented by the monitoring backends. Averages over the
+// last window are weighted by recency: the most recent sample in the window
+// weighs the most. Averages over a range in the past are not weighted.
+type MetricsSource interface {
+	// SocketMetrics returns the weighted average of the given metrics of a
+	// socket of a server over the last window.
+	SocketMetrics(server string, socket int, metrics []string, window time.Duration) (map[string]float64, error)
+	// CoreMetrics returns the weighted average of the given metrics over the
+	// last window, averaged over the given cores of a socket.
+	CoreMetrics(server string, socket int, cores []int, metrics []string, window time.Duration) (map[string]float64, error)
+	// CoreMetricsRange returns the average of the given metrics between start
+	// and end, averaged over the given cores of a socket.
+	CoreMetricsRange(server string, socket int, cores []int, metrics []string, start, end time.Time) (map[string]float64, error)
+}
+
+// Backend is a MetricsSource holding long-lived connections to the
+// monitoring database. It is safe for concurrent use.
+type Backend interface {
+	MetricsSource
+	// Ping checks that the database is reachable.
+	Ping() error
+	// Close releases the connections to the database.
+	Close() error
+}
+
+// Config is the configuration of the monitoring backend.
+type Config struct {
+	Server struct {
+		Port string `yaml:"port"`
+		Host string `yaml:"host"`
+	} `yaml:"server"`
+	Database struct {
+		Type     string `yaml:"type"`
+		Name     string `yaml:"name"`
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+	} `yaml:"database"`
+	MonitoringSpecs struct {
+		// TimeInterval is the sampling interval of the metrics in seconds.
+		TimeInterval float32 `yaml:"interval"`
+		// QueryTimeout bounds every query to the database, e.g. "500ms".
+		QueryTimeout time.Duration `yaml:"timeout"`
+	} `yaml:"monitoring"`
+	// Degradation is how the nodes are scored while the backend is
+	// unavailable.
+	Degradation Degradation `yaml:"degradation"`
+	// CircuitBreaker stops querying the backend after repeated failures.
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuitBreaker"`
+}
+
+// Address returns the HTTP address of the monitoring backend.
+func (c *Config) Address() string {
+	return "http://" + c.Server.Host + ":" + c.Server.Port
+}
+
+// Validate checks that the configuration describes a usable backend.
+func (c *Config) Validate() error {
+	if len(c.Server.Host) == 0 || len(c.Server.Port) == 0 {
+		return fmt.Errorf("monitoring server host and port are required")
+	}
+	switch c.Database.Type {
+	case "", InfluxDBType:
+		if len(c.Database.Name) == 0 {
+			return fmt.Errorf("database name is required for %s", InfluxDBType)
+		}
+	case PrometheusType:
+	default:
+		return fmt.Errorf("unknown monitoring database type %q", c.Database.Type)
+	}
+	if c.MonitoringSpecs.TimeInterval <= 0 {
+		return fmt.Errorf("monitoring interval must be positive, got %v", c.MonitoringSpecs.TimeInterval)
+	}
+	if c.MonitoringSpecs.QueryTimeout < 0 {
+		return fmt.Errorf("query timeout must not be negative, got %v", c.MonitoringSpecs.QueryTimeout)
+	}
+	if err := c.Degradation.Validate(); err != nil {
+		return err
+	}
+	return c.CircuitBreaker.Validate()
+}
+
+// ReadConfig reads and validates the monitoring configuration from a YAML
+// file.
+func ReadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file for the monitoring database not found: %v", err)
+	}
+	defer f.Close()
+
+	cfg := &Config{}
+	if err := yaml.NewDecoder(f).Decode(cfg); err != nil {
+		return nil, fmt.Errorf("unable to decode the monitoring config: %v", err)
+	}
+	if cfg.MonitoringSpecs.QueryTimeout == 0 {
+		cfg.MonitoringSpecs.QueryTimeout = DefaultQueryTimeout
+	}
+	if len(cfg.Degradation.Mode) == 0 {
+		cfg.Degradation.Mode = FallbackToResources
+	}
+	if cfg.Degradation.MaxAge == 0 {
+		cfg.Degradation.MaxAge = DefaultLastKnownGoodMaxAge
+	}
+	if cfg.CircuitBreaker.FailureThreshold == 0 {
+		cfg.CircuitBreaker.FailureThreshold = DefaultFailureThreshold
+	}
+	if cfg.CircuitBreaker.OpenTimeout == 0 {
+		cfg.CircuitBreaker.OpenTimeout = DefaultOpenTimeout
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid monitoring config %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// New returns the Backend selected by the database type of cfg.
+func New(cfg *Config) (Backend, error) {
+	switch cfg.Database.Type {
+	case "", InfluxDBType:
+		s, err := NewInfluxDB(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case PrometheusType:
+		return NewPrometheus(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown monitoring database type %q", cfg.Database.Type)
+	}
+}
+
+// average returns the plain average of samples.
+func average(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, val := range samples {
+		sum += val
+	}
+	return sum / float64(len(samples))
+}
+
+// weightedAverage returns the average of samples, ordered from the most
+// recent to the oldest, with linearly decreasing weights.
+func weightedAverage(samples []float64) float64 {
+	n := len(samples)
+	if n == 0 {
+		return 0
+	}
+	sum := 0.0
+	for j, val := range samples {
+		sum += val * float64(n-j)
+	}
+	return sum / float64(n*(n+1)/2)
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monitoring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name                string
+		data                string
+		expectedTimeout     time.Duration
+		expectedDegradation *Degradation
+		expectErr           bool
+	}{
+		{
+			name:            "default timeout",
+			data:            "server: {host: influx, port: \"8086\"}\ndatabase: {name: evolve}\nmonitoring: {interval: 0.5}\n",
+			expectedTimeout: DefaultQueryTimeout,
+		},
+		{
+			name:            "prometheus with timeout",
+			data:            "server: {host: prom, port: \"9090\"}\ndatabase: {type: prometheus}\nmonitoring: {interval: 1, timeout: 500ms}\n",
+			expectedTimeout: 500 * time.Millisecond,
+		},
+		{
+			name:                "last known good",
+			data:                "server: {host: influx, port: \"8086\"}\ndatabase: {name: evolve}\nmonitoring: {interval: 0.5}\ndegradation: {mode: LastKnownGood, maxAge: 5m}\n",
+			expectedTimeout:     DefaultQueryTimeout,
+			expectedDegradation: &Degradation{Mode: LastKnownGood, MaxAge: 5 * time.Minute},
+		},
+		{
+			name:      "unknown degradation mode",
+			data:      "server: {host: influx, port: \"8086\"}\ndatabase: {name: evolve}\nmonitoring: {interval: 0.5}\ndegradation: {mode: Neutral}\n",
+			expectErr: true,
+		},
+		{
+			name:      "negative open timeout",
+			data:      "server: {host: influx, port: \"8086\"}\ndatabase: {name: evolve}\nmonitoring: {interval: 0.5}\ncircuitBreaker: {openTimeout: -1s}\n",
+			expectErr: true,
+		},
+		{
+			name:      "missing host",
+			data:      "server: {port: \"8086\"}\ndatabase: {name: evolve}\nmonitoring: {interval: 0.5}\n",
+			expectErr: true,
+		},
+		{
+			name:      "missing database name",
+			data:      "server: {host: influx, port: \"8086\"}\nmonitoring: {interval: 0.5}\n",
+			expectErr: true,
+		},
+		{
+			name:      "unknown type",
+			data:      "server: {host: influx, port: \"8086\"}\ndatabase: {type: mysql, name: evolve}\nmonitoring: {interval: 0.5}\n",
+			expectErr: true,
+		},
+		{
+			name:      "zero interval",
+			data:      "server: {host: influx, port: \"8086\"}\ndatabase: {name: evolve}\n",
+			expectErr: true,
+		},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("config-%d.yaml", i))
+			if err := ioutil.WriteFile(path, []byte(test.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := ReadConfig(path)
+			if (err != nil) != test.expectErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if cfg.MonitoringSpecs.QueryTimeout != test.expectedTimeout {
+				t.Errorf("expected timeout %v, got %v", test.expectedTimeout, cfg.MonitoringSpecs.QueryTimeout)
+			}
+			expectedDegradation := test.expectedDegradation
+			if expectedDegradation == nil {
+				expectedDegradation = &Degradation{Mode: FallbackToResources, MaxAge: DefaultLastKnownGoodMaxAge}
+			}
+			if cfg.Degradation != *expectedDegradation {
+				t.Errorf("expected degradation %+v, got %+v", *expectedDegradation, cfg.Degradation)
+			}
+		})
+	}
+}
+
+type fakeBackend struct {
+	pingErr error
+	queries int
+}
+
+func (b *fakeBackend) SocketMetrics(server string, socket int, metrics []string, window time.Duration) (map[string]float64, error) {
+	b.queries++
+	return map[string]float64{}, nil
+}
+
+func (b *fakeBackend) CoreMetrics(server string, socket int, cores []int, metrics []string, window time.Duration) (map[string]float64, error) {
+	b.queries++
+	return map[string]float64{}, nil
+}
+
+func (b *fakeBackend) CoreMetricsRange(server string, socket int, cores []int, metrics []string, start, end time.Time) (map[string]float64, error) {
+	b.queries++
+	return map[string]float64{}, nil
+}
+
+func (b *fakeBackend) Ping() error  { return b.pingErr }
+func (b *fakeBackend) Close() error { return nil }
+
+func TestHealthCheckedBackend(t *testing.T) {
+	b := &fakeBackend{}
+	h := NewHealthCheckedBackend(b)
+
+	if _, err := h.SocketMetrics("uuid-a", 0, []string{"ipc"}, time.Second); err != nil {
+		t.Errorf("unexpected error before the first check: %v", err)
+	}
+
+	b.pingErr = fmt.Errorf("connection refused")
+	h.Check()
+	if _, err := h.SocketMetrics("uuid-a", 0, []string{"ipc"}, time.Second); err == nil {
+		t.Errorf("expected an error while the backend is unreachable")
+	}
+	if _, err := h.CoreMetrics("uuid-a", 0, []int{0}, []string{"c6res"}, time.Second); err == nil {
+		t.Errorf("expected an error while the backend is unreachable")
+	}
+	if b.queries != 1 {
+		t.Errorf("expected queries to fail fast, the backend got %d queries", b.queries)
+	}
+
+	b.pingErr = nil
+	h.Check()
+	if _, err := h.CoreMetrics("uuid-a", 0, []int{0}, []string{"c6res"}, time.Second); err != nil {
+		t.Errorf("unexpected error after recovery: %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// Prometheus is a Backend reading from the Prometheus HTTP API. Each
+// metric is expected as a gauge named after the InfluxDB measurement and
+// the metric, e.g. socket_metrics_ipc{uuid, socket_id} and
+// core_metrics_c6res{uuid, socket_id, core_id}.
+type Prometheus struct {
+	cfg    *Config
+	client *http.Client
+	now    func() time.Time
+}
+
+var _ Backend = &Prometheus{}
+
+// NewPrometheus returns a Prometheus Backend for the given config.
+func NewPrometheus(cfg *Config) *Prometheus {
+	return &Prometheus{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.MonitoringSpecs.QueryTimeout},
+		now:    time.Now,
+	}
+}
+
+// Ping checks that the Prometheus server is reachable.
+func (s *Prometheus) Ping() error {
+	resp, err := s.client.Get(s.cfg.Address() + "/-/healthy")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("prometheus is unhealthy: %s", resp.Status)
+	}
+	return nil
+}
+
+// Close releases the idle connections to the Prometheus server.
+func (s *Prometheus) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// promResponse is the subset of a query response returning a range
+// vector used here.
+type promResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string       `json:"resultType"`
+		Result     []promSeries `json:"result"`
+	} `json:"data"`
+}
+
+// promSeries is a series of a range vector.
+type promSeries struct {
+	Metric map[string]string `json:"metric"`
+	// Values are [timestamp, "value"] pairs, oldest first.
+	Values [][2]interface{} `json:"values"`
+}
+
+// SocketMetrics returns the weighted average of the given metrics of a
+// socket of a server over the last window.
+func (s *Prometheus) SocketMetrics(server string, socket int, metrics []string, window time.Duration) (map[string]float64, error) {
+	w := newSampleWindow(s.cfg, s.now(), window)
+	res := make(map[string]float64, len(metrics))
+	for _, metric := range metrics {
+		selector := fmt.Sprintf("socket_metrics_%s{uuid=%s,socket_id=\"%d\"}", metric, strconv.Quote(server), socket)
+		series, err := s.query(selector, w)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", metric, err)
+		}
+		if len(series) == 0 {
+			return nil, newDataError(NoData, "%s: no series returned", metric)
+		}
+		if len(series) != 1 {
+			return nil, fmt.Errorf("%s: expected one series, got %d", metric, len(series))
+		}
+		val, err := promSeriesAverage(&series[0], metric, w)
+		if err != nil {
+			return nil, err
+		}
+		res[metric] = val
+	}
+	return res, nil
+}
+
+// CoreMetrics returns the weighted average of the given metrics over the last
+// window, averaged over the given cores of a socket. Cores without usable
+// samples are ignored, and if no core has usable samples the error of the
+// last one is returned.
+func (s *Prometheus) CoreMetrics(server string, socket int, cores []int, metrics []string, window time.Duration) (map[string]float64, error) {
+	return s.coreMetrics(server, socket, cores, metrics, newSampleWindow(s.cfg, s.now(), window))
+}
+
+// CoreMetricsRange returns the average of the given metrics between start and
+// end, averaged over the given cores of a socket.
+func (s *Prometheus) CoreMetricsRange(server string, socket int, cores []int, metrics []string, start, end time.Time) (map[string]float64, error) {
+	return s.coreMetrics(server, socket, cores, metrics, newPastWindow(s.cfg, start, end))
+}
+
+func (s *Prometheus) coreMetrics(server string, socket int, cores []int, metrics []string, w sampleWindow) (map[string]float64, error) {
+	if len(cores) == 0 {
+		return nil, fmt.Errorf("no cores given")
+	}
+	ids := make([]string, len(cores))
+	for i, core := range cores {
+		ids[i] = strconv.Itoa(core)
+	}
+	res := make(map[string]float64, len(metrics))
+	for _, metric := range metrics {
+		// One series per core, so that a missing sample only affects its own core.
+		selector := fmt.Sprintf("core_metrics_%s{uuid=%s,socket_id=\"%d\",core_id=~\"%s\"}", metric, strconv.Quote(server), socket, strings.Join(ids, "|"))
+		series, err := s.query(selector, w)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", metric, err)
+		}
+		var sum float64
+		found := 0
+		var lastErr error = newDataError(NoData, "%s: no series returned for cores %v", metric, cores)
+		for i := range series {
+			core := series[i].Metric["core_id"]
+			val, err := promSeriesAverage(&series[i], metric, w)
+			if err != nil {
+				if _, ok := ReasonOf(err); !ok {
+					return nil, fmt.Errorf("core %s: %v", core, err)
+				}
+				klog.V(4).Infof("Ignoring core %s: %v", core, err)
+				lastErr = err
+				continue
+			}
+			sum += val
+			found++
+		}
+		if found == 0 {
+			return nil, lastErr
+		}
+		res[metric] = sum / float64(found)
+	}
+	return res, nil
+}
+
+// query returns the raw samples of the series of selector over the window,
+// with the times they were scraped at. Unlike a range query evaluated at
+// steps, the instant query of a range vector doesn't repeat the last sample
+// of a series that stopped being scraped, so that stale and partial windows
+// are detected as with InfluxDB.
+func (s *Prometheus) query(selector string, w sampleWindow) ([]promSeries, error) {
+	params := url.Values{}
+	params.Set("query", fmt.Sprintf("%s[%dms]", selector, int64(w.length/time.Millisecond)))
+	params.Set("time", strconv.FormatFloat(float64(w.now.UnixNano())/float64(time.Second), 'f', -1, 64))
+
+	resp, err := s.client.Get(s.cfg.Address() + "/api/v1/query?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("error while executing the query: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result promResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("unable to decode the response: %v", err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("query failed: %s", result.Error)
+	}
+	return result.Data.Result, nil
+}
+
+// promSeriesAverage returns the average of the samples of a series, after
+// checking that they are recent and cover the window.
+func promSeriesAverage(series *promSeries, metric string, w sampleWindow) (float64, error) {
+	values := series.Values
+	if len(values) == 0 {
+		return 0, newDataError(NoData, "%s: series has no samples", metric)
+	}
+	samples := make([]float64, len(values))
+	var newest time.Time
+	for i, pair := range values {
+		ts, ok := pair[0].(float64)
+		if !ok {
+			return 0, newDataError(BadData, "%s: invalid sample %v", metric, pair)
+		}
+		str, ok := pair[1].(string)
+		if !ok {
+			return 0, newDataError(BadData, "%s: invalid sample %v", metric, pair)
+		}
+		val, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return 0, newDataError(BadData, "%s: invalid sample %v: %v", metric, pair, err)
+		}
+		// most recent first
+		samples[len(values)-1-i] = val
+		if t := time.Unix(0, int64(ts*float64(time.Second))); t.After(newest) {
+			newest = t
+		}
+	}
+	if err := w.checkNewest(newest); err != nil {
+		return 0, err
+	}
+	if err := w.checkCoverage(metric, len(samples)); err != nil {
+		return 0, err
+	}
+	return w.average(samples), nil
+}
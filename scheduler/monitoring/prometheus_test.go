@@ -0,0 +1,198 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheus(t *testing.T) {
+	matrix := func(series ...string) string {
+		return `{"status":"success","data":{"resultType":"matrix","result":[` + strings.Join(series, ",") + `]}}`
+	}
+	core := func(id, values string) string {
+		return `{"metric":{"core_id":"` + id + `"},"values":` + values + `}`
+	}
+	tests := []struct {
+		name           string
+		responses      map[string]string
+		query          func(s *Prometheus) (map[string]float64, error)
+		expected       map[string]float64
+		wantErr        bool
+		expectedReason DataErrorReason
+	}{
+		{
+			name: "socket metrics",
+			responses: map[string]string{
+				`socket_metrics_ipc{uuid="uuid-a",socket_id="1"}[2000ms]`:      matrix(`{"metric":{},"values":[[1577836799,"1"],[1577836800,"2"]]}`),
+				`socket_metrics_mem_read{uuid="uuid-a",socket_id="1"}[2000ms]`: matrix(`{"metric":{},"values":[[1577836799,"0.5"],[1577836800,"0.5"]]}`),
+			},
+			query: func(s *Prometheus) (map[string]float64, error) {
+				return s.SocketMetrics("uuid-a", 1, []string{"ipc", "mem_read"}, 2*time.Second)
+			},
+			expected: map[string]float64{"ipc": 5.0 / 3, "mem_read": 0.5},
+		},
+		{
+			name: "core metrics",
+			responses: map[string]string{
+				`core_metrics_c6res{uuid="uuid-a",socket_id="0",core_id=~"4|5"}[2000ms]`: matrix(
+					core("4", `[[1577836799,"0"],[1577836800,"3"]]`),
+					core("5", `[[1577836799,"0"],[1577836800,"1"]]`),
+				),
+			},
+			query: func(s *Prometheus) (map[string]float64, error) {
+				return s.CoreMetrics("uuid-a", 0, []int{4, 5}, []string{"c6res"}, 2*time.Second)
+			},
+			expected: map[string]float64{"c6res": 4.0 / 3},
+		},
+		{
+			name: "core metrics over a past range",
+			responses: map[string]string{
+				`core_metrics_c6res{uuid="uuid-a",socket_id="0",core_id=~"4|5"}[2000ms]`: matrix(
+					core("4", `[[1577836799,"0"],[1577836800,"3"]]`),
+					core("5", `[[1577836799,"0"],[1577836800,"1"]]`),
+				),
+			},
+			query: func(s *Prometheus) (map[string]float64, error) {
+				end := time.Unix(1577836800, 0)
+				return s.CoreMetricsRange("uuid-a", 0, []int{4, 5}, []string{"c6res"}, end.Add(-2*time.Second), end)
+			},
+			expected: map[string]float64{"c6res": 1},
+		},
+		{
+			name: "stale core ignored",
+			responses: map[string]string{
+				`core_metrics_c6res{uuid="uuid-a",socket_id="0",core_id=~"4|5"}[2000ms]`: matrix(
+					core("4", `[[1577836790,"5"]]`),
+					core("5", `[[1577836799,"0"],[1577836800,"3"]]`),
+				),
+			},
+			query: func(s *Prometheus) (map[string]float64, error) {
+				return s.CoreMetrics("uuid-a", 0, []int{4, 5}, []string{"c6res"}, 2*time.Second)
+			},
+			expected: map[string]float64{"c6res": 2},
+		},
+		{
+			name: "no series",
+			responses: map[string]string{
+				`socket_metrics_ipc{uuid="uuid-a",socket_id="0"}[2000ms]`: matrix(),
+			},
+			query: func(s *Prometheus) (map[string]float64, error) {
+				return s.SocketMetrics("uuid-a", 0, []string{"ipc"}, 2*time.Second)
+			},
+			wantErr:        true,
+			expectedReason: NoData,
+		},
+		{
+			name: "stale data",
+			responses: map[string]string{
+				`socket_metrics_ipc{uuid="uuid-a",socket_id="0"}[2000ms]`: matrix(`{"metric":{},"values":[[1577836790,"1"]]}`),
+			},
+			query: func(s *Prometheus) (map[string]float64, error) {
+				return s.SocketMetrics("uuid-a", 0, []string{"ipc"}, 2*time.Second)
+			},
+			wantErr:        true,
+			expectedReason: StaleData,
+		},
+		{
+			name: "partial window",
+			responses: map[string]string{
+				`socket_metrics_ipc{uuid="uuid-a",socket_id="0"}[10000ms]`: matrix(`{"metric":{},"values":[[1577836799,"1"],[1577836800,"2"]]}`),
+			},
+			query: func(s *Prometheus) (map[string]float64, error) {
+				return s.SocketMetrics("uuid-a", 0, []string{"ipc"}, 10*time.Second)
+			},
+			wantErr:        true,
+			expectedReason: PartialWindow,
+		},
+		{
+			name: "stale cores",
+			responses: map[string]string{
+				`core_metrics_c6res{uuid="uuid-a",socket_id="0",core_id=~"4|5"}[2000ms]`: matrix(
+					core("4", `[[1577836790,"5"]]`),
+					core("5", `[[1577836790,"5"]]`),
+				),
+			},
+			query: func(s *Prometheus) (map[string]float64, error) {
+				return s.CoreMetrics("uuid-a", 0, []int{4, 5}, []string{"c6res"}, 2*time.Second)
+			},
+			wantErr:        true,
+			expectedReason: StaleData,
+		},
+		{
+			name: "invalid sample",
+			responses: map[string]string{
+				`socket_metrics_ipc{uuid="uuid-a",socket_id="0"}[2000ms]`: matrix(`{"metric":{},"values":[[1577836799,"1"],[1577836800,"idle"]]}`),
+			},
+			query: func(s *Prometheus) (map[string]float64, error) {
+				return s.SocketMetrics("uuid-a", 0, []string{"ipc"}, 2*time.Second)
+			},
+			wantErr:        true,
+			expectedReason: BadData,
+		},
+		{
+			name: "query error",
+			responses: map[string]string{
+				`socket_metrics_ipc{uuid="uuid-a",socket_id="0"}[2000ms]`: `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+			},
+			query: func(s *Prometheus) (map[string]float64, error) {
+				return s.SocketMetrics("uuid-a", 0, []string{"ipc"}, 2*time.Second)
+			},
+			wantErr: true,
+		},
+	}
+	now := time.Unix(1577836800, 0)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/query" {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				if at := r.URL.Query().Get("time"); at != "1577836800" {
+					t.Errorf("unexpected evaluation time %s", at)
+				}
+				response, ok := test.responses[r.URL.Query().Get("query")]
+				if !ok {
+					t.Errorf("unexpected query %s", r.URL.Query().Get("query"))
+				}
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, response)
+			}))
+			defer server.Close()
+
+			s := NewPrometheus(newTestConfig(t, server, PrometheusType))
+			s.now = func() time.Time { return now }
+			res, err := test.query(s)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.expectedReason != "" {
+				if reason, ok := ReasonOf(err); !ok || reason != test.expectedReason {
+					t.Errorf("expected a %v error, got %v", test.expectedReason, err)
+				}
+			}
+			if !closeTo(res, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, res)
+			}
+		})
+	}
+}
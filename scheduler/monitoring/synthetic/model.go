@@ -0,0 +1,234 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package synthetic provides a monitoring.MetricsSource whose hardware
+// counters are synthesised from the profiles of the pods running on each
+// socket, so that the counters react to the placements in tests and
+// simulations.
+package synthetic
+
+import (
+	"math"
+
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// Pod is a pod running on a socket.
+type Pod struct {
+	// Core is the id of the core the pod is pinned on.
+	Core int
+	// Metrics are the counters of the application of the pod in isolation,
+	// as in its profile.
+	Metrics map[string]float64
+}
+
+// Socket is the load of a socket.
+type Socket struct {
+	// Server is the server of the socket.
+	Server *topology.Server
+	// Cores are the ids of the cores of the socket.
+	Cores []int
+	// Pods are the pods running on the socket.
+	Pods []Pod
+}
+
+// Model synthesises the hardware counters of a socket from its load.
+type Model interface {
+	// SocketMetrics returns the counters of the socket, at least ipc,
+	// mem_read and mem_write.
+	SocketMetrics(s *Socket) map[string]float64
+	// CoreMetrics returns the counters of a core of the socket, at least
+	// c6res, the fraction of the time the core spends in C6.
+	CoreMetrics(s *Socket, core int) map[string]float64
+	// Slowdowns returns how many times slower than in isolation each pod of
+	// the socket runs.
+	Slowdowns(s *Socket) []float64
+}
+
+// DefaultIdleMetrics are the counters of a socket running no pod.
+var DefaultIdleMetrics = map[string]float64{
+	"ipc":       0.5,
+	"mem_read":  0.01,
+	"mem_write": 0.005,
+}
+
+// DefaultContentionModel is a ContentionModel of a socket whose memory
+// bandwidth is saturated by about ten memory-bound benchmarks.
+var DefaultContentionModel = &ContentionModel{
+	Idle:               DefaultIdleMetrics,
+	MemoryBandwidth:    2,
+	RemoteFraction:     0.2,
+	LinkTraffic:        0.05,
+	Sensitivity:        0.5,
+	MemoryBoundTraffic: 0.1,
+}
+
+// ContentionModel models the contention of the pods of a socket for its
+// memory bandwidth, its interconnect and its cores.
+//
+// The memory traffic of the pods in isolation adds up to the demand of the
+// socket. The memory pressure is the highest of the demand over the memory
+// bandwidth of the socket and of its remote part over the bandwidth of the
+// interconnect. As the pressure builds up the pods slow down by
+// 1 + Sensitivity * pressure², and once the socket is saturated by the
+// pressure times as much, each in proportion to how memory-bound it is. The
+// pods sharing a core also share its time. A pod slowed down by the memory
+// retires fewer instructions per cycle, and any slowed-down pod generates
+// less traffic. A core is out of C6 for the time its pods keep it busy. The
+// other metrics of the profiles add up over the socket.
+type ContentionModel struct {
+	// Idle are the counters of a socket running no pod.
+	Idle map[string]float64
+	// MemoryBandwidth is the memory bandwidth of a socket, in the unit of
+	// mem_read and mem_write, unless its server sets one.
+	MemoryBandwidth float64
+	// RemoteFraction is the fraction of the memory traffic served by the
+	// other sockets over the interconnect.
+	RemoteFraction float64
+	// LinkTraffic is the memory traffic carried by a GT/s of interconnect.
+	// The interconnect of servers of unknown link speed never saturates.
+	LinkTraffic float64
+	// Sensitivity is how fast the pods slow down as the memory pressure
+	// builds up.
+	Sensitivity float64
+	// MemoryBoundTraffic is the memory traffic of a pod that is half
+	// memory-bound.
+	MemoryBoundTraffic float64
+}
+
+var _ Model = &ContentionModel{}
+
+// traffic returns the memory traffic of a pod in isolation.
+func traffic(metrics map[string]float64) float64 {
+	return metrics["mem_read"] + metrics["mem_write"]
+}
+
+// busy returns the fraction of the time a pod keeps its core out of C6. The
+// profiles hold the C6 residency in percent.
+func busy(metrics map[string]float64) float64 {
+	return math.Min(math.Max((100-metrics["c6res"])/100, 0), 1)
+}
+
+// pressure returns the memory pressure of the socket.
+func (m *ContentionModel) pressure(s *Socket) float64 {
+	demand := traffic(m.Idle)
+	for _, p := range s.Pods {
+		demand += traffic(p.Metrics)
+	}
+	bandwidth := m.MemoryBandwidth
+	if s.Server != nil && s.Server.MemoryBandwidth > 0 {
+		bandwidth = float64(s.Server.MemoryBandwidth)
+	}
+	var pressure float64
+	if bandwidth > 0 {
+		pressure = demand / bandwidth
+	}
+	if s.Server != nil && m.LinkTraffic > 0 {
+		if links := float64(s.Server.LinkBandwidth()) * m.LinkTraffic; links > 0 {
+			pressure = math.Max(pressure, m.RemoteFraction*demand/links)
+		}
+	}
+	return pressure
+}
+
+// memorySlowdowns returns how many times slower each pod of the socket runs
+// because of the memory pressure.
+func (m *ContentionModel) memorySlowdowns(s *Socket) []float64 {
+	p := m.pressure(s)
+	factor := (1 + m.Sensitivity*math.Pow(math.Min(p, 1), 2)) * math.Max(p, 1)
+	slowdowns := make([]float64, len(s.Pods))
+	for i, pod := range s.Pods {
+		var bound float64
+		if t := traffic(pod.Metrics); t > 0 {
+			bound = t / (t + m.MemoryBoundTraffic)
+		}
+		slowdowns[i] = 1 + bound*(factor-1)
+	}
+	return slowdowns
+}
+
+// Slowdowns returns how many times slower than in isolation each pod of the
+// socket runs, because of the memory pressure and of the pods sharing its
+// core.
+func (m *ContentionModel) Slowdowns(s *Socket) []float64 {
+	shares := map[int]float64{}
+	for _, p := range s.Pods {
+		shares[p.Core] += busy(p.Metrics)
+	}
+	slowdowns := m.memorySlowdowns(s)
+	for i, p := range s.Pods {
+		slowdowns[i] *= math.Max(shares[p.Core], 1)
+	}
+	return slowdowns
+}
+
+// SocketMetrics returns the counters of the socket.
+func (m *ContentionModel) SocketMetrics(s *Socket) map[string]float64 {
+	memory := m.memorySlowdowns(s)
+	slowdowns := m.Slowdowns(s)
+	res := make(map[string]float64, len(m.Idle))
+	for metric, value := range m.Idle {
+		res[metric] = value
+	}
+	var ipc float64
+	for i, p := range s.Pods {
+		for metric, value := range p.Metrics {
+			switch metric {
+			case "ipc", "c6res":
+			case "mem_read", "mem_write":
+				res[metric] += value / slowdowns[i]
+			default:
+				res[metric] += value
+			}
+		}
+		ipc += p.Metrics["ipc"] / memory[i]
+	}
+	if len(s.Pods) > 0 {
+		res["ipc"] = ipc / float64(len(s.Pods))
+	}
+	return res
+}
+
+// CoreMetrics returns the counters of a core of the socket.
+func (m *ContentionModel) CoreMetrics(s *Socket, core int) map[string]float64 {
+	memory := m.memorySlowdowns(s)
+	slowdowns := m.Slowdowns(s)
+	res := map[string]float64{"ipc": m.Idle["ipc"]}
+	var pods int
+	var ipc, busyness float64
+	for i, p := range s.Pods {
+		if p.Core != core {
+			continue
+		}
+		for metric, value := range p.Metrics {
+			switch metric {
+			case "ipc", "c6res":
+			case "mem_read", "mem_write":
+				res[metric] += value / slowdowns[i]
+			default:
+				res[metric] += value
+			}
+		}
+		pods++
+		ipc += p.Metrics["ipc"] / memory[i]
+		busyness += busy(p.Metrics)
+	}
+	if pods > 0 {
+		res["ipc"] = ipc / float64(pods)
+	}
+	res["c6res"] = 1 - math.Min(busyness, 1)
+	return res
+}
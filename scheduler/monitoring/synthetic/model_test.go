@@ -0,0 +1,153 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synthetic
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// memoryPod is a fully memory-bound pod with a memory traffic of 1, alone on
+// its core.
+func memoryPod(core int) Pod {
+	return Pod{Core: core, Metrics: map[string]float64{"ipc": 1, "mem_read": 1, "c6res": 0}}
+}
+
+func expectFloats(t *testing.T, expected, got []float64) {
+	if len(expected) != len(got) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if math.Abs(expected[i]-got[i]) > 1e-9 {
+			t.Errorf("expected %v, got %v", expected, got)
+			return
+		}
+	}
+}
+
+func TestContentionModelSlowdowns(t *testing.T) {
+	model := &ContentionModel{MemoryBandwidth: 2, Sensitivity: 0.5}
+	tests := []struct {
+		socket   *Socket
+		expected []float64
+		name     string
+	}{
+		{
+			socket:   &Socket{},
+			expected: []float64{},
+			name:     "idle socket",
+		},
+		{
+			// Pressure 0.5: 1 + 0.5 * 0.5².
+			socket:   &Socket{Pods: []Pod{memoryPod(0)}},
+			expected: []float64{1.125},
+			name:     "light pressure",
+		},
+		{
+			// Pressure 1: 1 + 0.5, the compute-bound pod is not slowed down.
+			socket: &Socket{Pods: []Pod{
+				memoryPod(0),
+				memoryPod(1),
+				{Core: 2, Metrics: map[string]float64{"ipc": 2, "c6res": 0}},
+			}},
+			expected: []float64{1.5, 1.5, 1},
+			name:     "saturated socket",
+		},
+		{
+			// Pressure 2: 1.5 * 2.
+			socket:   &Socket{Pods: []Pod{memoryPod(0), memoryPod(1), memoryPod(2), memoryPod(3)}},
+			expected: []float64{3, 3, 3, 3},
+			name:     "oversubscribed socket",
+		},
+		{
+			// The server doubles the bandwidth, pressure 1.
+			socket: &Socket{
+				Server: &topology.Server{MemoryBandwidth: 4},
+				Pods:   []Pod{memoryPod(0), memoryPod(1), memoryPod(2), memoryPod(3)},
+			},
+			expected: []float64{1.5, 1.5, 1.5, 1.5},
+			name:     "server memory bandwidth",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectFloats(t, test.expected, model.Slowdowns(test.socket))
+		})
+	}
+}
+
+func TestContentionModelInterconnect(t *testing.T) {
+	// Half of a demand of 2 goes over an interconnect carrying 1: pressure 1.
+	model := &ContentionModel{RemoteFraction: 0.5, LinkTraffic: 0.1, Sensitivity: 0.5}
+	socket := &Socket{
+		Server: &topology.Server{Links: 1, LinkSpeed: 10},
+		Pods:   []Pod{memoryPod(0), memoryPod(1)},
+	}
+	expectFloats(t, []float64{1.5, 1.5}, model.Slowdowns(socket))
+
+	// Servers of unknown link speed never saturate.
+	socket.Server = &topology.Server{}
+	expectFloats(t, []float64{1, 1}, model.Slowdowns(socket))
+}
+
+func TestContentionModelSharedCores(t *testing.T) {
+	model := &ContentionModel{}
+	busy := Pod{Core: 0, Metrics: map[string]float64{"ipc": 2, "c6res": 0}}
+	halfBusy := Pod{Core: 1, Metrics: map[string]float64{"ipc": 2, "c6res": 50}}
+	socket := &Socket{Cores: []int{0, 1}, Pods: []Pod{busy, busy, halfBusy, halfBusy}}
+
+	// The busy pods share their core, the half-busy ones fit on theirs.
+	expectFloats(t, []float64{2, 2, 1, 1}, model.Slowdowns(socket))
+	for core, c6res := range map[int]float64{0: 0, 1: 0} {
+		if got := model.CoreMetrics(socket, core)["c6res"]; got != c6res {
+			t.Errorf("core %d: expected a C6 residency of %v, got %v", core, c6res, got)
+		}
+	}
+
+	socket.Pods = []Pod{halfBusy}
+	if got := model.CoreMetrics(socket, 1)["c6res"]; got != 0.5 {
+		t.Errorf("expected a C6 residency of 0.5, got %v", got)
+	}
+	if got := model.CoreMetrics(socket, 0)["c6res"]; got != 1 {
+		t.Errorf("expected an idle core to stay in C6, got %v", got)
+	}
+}
+
+func TestContentionModelMetrics(t *testing.T) {
+	model := &ContentionModel{Idle: DefaultIdleMetrics, MemoryBandwidth: 2, Sensitivity: 0.5}
+	socket := &Socket{Cores: []int{0, 1, 2, 3}}
+	if got := model.SocketMetrics(socket); !reflect.DeepEqual(DefaultIdleMetrics, got) {
+		t.Errorf("expected the idle metrics, got %v", got)
+	}
+	if got := model.CoreMetrics(socket, 0); got["ipc"] != DefaultIdleMetrics["ipc"] || got["c6res"] != 1 {
+		t.Errorf("expected an idle core, got %v", got)
+	}
+
+	// Without the idle traffic, four memory-bound pods slow down by 3: they
+	// retire a third of their instructions and issue a third of their
+	// accesses.
+	model.Idle = nil
+	socket.Pods = []Pod{memoryPod(0), memoryPod(1), memoryPod(2), memoryPod(3)}
+	got := model.SocketMetrics(socket)
+	expectFloats(t, []float64{1.0 / 3, 4.0 / 3, 0}, []float64{got["ipc"], got["mem_read"], got["mem_write"]})
+	core := model.CoreMetrics(socket, 2)
+	expectFloats(t, []float64{1.0 / 3, 1.0 / 3, 0}, []float64{core["ipc"], core["mem_read"], core["c6res"]})
+}
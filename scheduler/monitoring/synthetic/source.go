@@ -0,0 +1,172 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synthetic
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// runningPod is a pod added to a Source.
+type runningPod struct {
+	uid     types.UID
+	metrics map[string]float64
+}
+
+// Source is a monitoring.MetricsSource synthesising the counters of the
+// sockets of a topology from the pods running on their nodes with a Model.
+// The pods of a node are pinned on its cores in turn, in the order they were
+// added.
+// The window is ignored: the counters follow the pods instantly. It is safe
+// for concurrent use.
+type Source struct {
+	topology topology.Interface
+	model    Model
+
+	mu      sync.RWMutex
+	running map[string][]runningPod
+}
+
+var _ monitoring.MetricsSource = &Source{}
+
+// NewSource returns a Source of the sockets of topo running no pod.
+func NewSource(topo topology.Interface, model Model) *Source {
+	return &Source{
+		topology: topo,
+		model:    model,
+		running:  map[string][]runningPod{},
+	}
+}
+
+// Add starts a pod on node, with the counters of its profile.
+func (s *Source) Add(node string, uid types.UID, profile map[string]float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running[node] = append(s.running[node], runningPod{uid: uid, metrics: profile})
+}
+
+// Remove stops a pod.
+func (s *Source) Remove(node string, uid types.UID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pods := s.running[node]
+	for i, p := range pods {
+		if p.uid == uid {
+			s.running[node] = append(pods[:i:i], pods[i+1:]...)
+			return
+		}
+	}
+}
+
+// Pods returns the number of pods running on node.
+func (s *Source) Pods(node string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.running[node])
+}
+
+// Slowdowns returns how many times slower than in isolation each pod of the
+// socket runs, by uid.
+func (s *Source) Slowdowns(server string, socket int) map[types.UID]float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	load, uids := s.socket(server, socket)
+	slowdowns := s.model.Slowdowns(load)
+	res := make(map[types.UID]float64, len(uids))
+	for i, uid := range uids {
+		res[uid] = slowdowns[i]
+	}
+	return res
+}
+
+// socket returns the load of a socket and the uids of its pods. The caller
+// must hold s.mu.
+func (s *Source) socket(server string, socket int) (*Socket, []types.UID) {
+	load := &Socket{}
+	load.Server, _ = s.topology.Server(server)
+	var uids []types.UID
+	for _, name := range s.topology.SocketNodes(server, socket) {
+		n, ok := s.topology.Node(name)
+		if !ok {
+			continue
+		}
+		load.Cores = append(load.Cores, n.Cores...)
+		for i, p := range s.running[name] {
+			core := -1
+			if len(n.Cores) > 0 {
+				core = n.Cores[i%len(n.Cores)]
+			}
+			load.Pods = append(load.Pods, Pod{Core: core, Metrics: p.metrics})
+			uids = append(uids, p.uid)
+		}
+	}
+	return load, uids
+}
+
+// SocketMetrics returns the synthetic counters of a socket.
+func (s *Source) SocketMetrics(server string, socket int, metrics []string, window time.Duration) (map[string]float64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	load, _ := s.socket(server, socket)
+	if len(load.Cores) == 0 {
+		return nil, &monitoring.DataError{Reason: monitoring.NoData, Message: fmt.Sprintf("no core on socket %d of server %s", socket, server)}
+	}
+	values := s.model.SocketMetrics(load)
+	res := make(map[string]float64, len(metrics))
+	for _, metric := range metrics {
+		res[metric] = values[metric]
+	}
+	return res, nil
+}
+
+// CoreMetrics returns the synthetic counters of the given cores of a socket,
+// averaged over the cores.
+func (s *Source) CoreMetrics(server string, socket int, cores []int, metrics []string, window time.Duration) (map[string]float64, error) {
+	if len(cores) == 0 {
+		return nil, fmt.Errorf("no cores given")
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	load, _ := s.socket(server, socket)
+	known := make(map[int]bool, len(load.Cores))
+	for _, core := range load.Cores {
+		known[core] = true
+	}
+	res := make(map[string]float64, len(metrics))
+	for _, core := range cores {
+		if !known[core] {
+			return nil, &monitoring.DataError{Reason: monitoring.NoData, Message: fmt.Sprintf("no core %d on socket %d of server %s", core, socket, server)}
+		}
+		values := s.model.CoreMetrics(load, core)
+		for _, metric := range metrics {
+			res[metric] += values[metric] / float64(len(cores))
+		}
+	}
+	return res, nil
+}
+
+// CoreMetricsRange returns the synthetic counters of the given cores of a
+// socket, averaged over the cores. The range is ignored: the counters are
+// those of the pods running now.
+func (s *Source) CoreMetricsRange(server string, socket int, cores []int, metrics []string, start, end time.Time) (map[string]float64, error) {
+	return s.CoreMetrics(server, socket, cores, metrics, end.Sub(start))
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synthetic
+
+import (
+	"testing"
+
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+func newTestTopology() topology.Interface {
+	return topology.New(
+		[]topology.Server{{UUID: "server-a", Links: 2, LinkSpeed: 10}},
+		[]topology.Node{
+			{Name: "kube-01", Server: "server-a", Socket: 0, Cores: []int{0, 1}},
+			{Name: "kube-02", Server: "server-a", Socket: 0, Cores: []int{2, 3}},
+			{Name: "kube-03", Server: "server-a", Socket: 1},
+		},
+	)
+}
+
+func TestSource(t *testing.T) {
+	source := NewSource(newTestTopology(), &ContentionModel{})
+	busy := map[string]float64{"ipc": 2, "mem_read": 0.5, "c6res": 0}
+
+	// The pods of a node are pinned on its cores in turn, the third one
+	// shares the first core.
+	source.Add("kube-01", "pod-a", busy)
+	source.Add("kube-01", "pod-b", busy)
+	source.Add("kube-01", "pod-c", busy)
+	source.Add("kube-02", "pod-d", busy)
+	if pods := source.Pods("kube-01"); pods != 3 {
+		t.Errorf("expected 3 pods on kube-01, got %d", pods)
+	}
+	expected := map[string]float64{"pod-a": 2, "pod-b": 1, "pod-c": 2, "pod-d": 1}
+	slowdowns := source.Slowdowns("server-a", 0)
+	if len(slowdowns) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, slowdowns)
+	}
+	for uid, slowdown := range slowdowns {
+		if expected[string(uid)] != slowdown {
+			t.Errorf("expected %v, got %v", expected, slowdowns)
+		}
+	}
+
+	metrics, err := source.SocketMetrics("server-a", 0, []string{"ipc", "mem_read"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 2 || metrics["ipc"] != 2 || metrics["mem_read"] != 0.25+0.5+0.25+0.5 {
+		t.Errorf("unexpected socket metrics %v", metrics)
+	}
+	// Cores 0, 1 and 2 are busy.
+	metrics, err = source.CoreMetrics("server-a", 0, []int{0, 1, 2, 3}, []string{"c6res"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics["c6res"] != 0.25 {
+		t.Errorf("expected a C6 residency of 0.25, got %v", metrics["c6res"])
+	}
+
+	source.Remove("kube-01", "pod-a")
+	source.Remove("kube-01", "pod-c")
+	source.Remove("kube-01", "unknown")
+	if pods := source.Pods("kube-01"); pods != 1 {
+		t.Errorf("expected 1 pod on kube-01, got %d", pods)
+	}
+	// The remaining pod takes over the first core.
+	metrics, err = source.CoreMetrics("server-a", 0, []int{1}, []string{"c6res"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics["c6res"] != 1 {
+		t.Errorf("expected core 1 to be idle, got %v", metrics["c6res"])
+	}
+}
+
+func TestSourceNoData(t *testing.T) {
+	source := NewSource(newTestTopology(), &ContentionModel{})
+	if _, err := source.SocketMetrics("server-a", 1, []string{"ipc"}, 0); err == nil {
+		t.Errorf("expected an error for a socket without cores")
+	} else if reason, ok := monitoring.ReasonOf(err); !ok || reason != monitoring.NoData {
+		t.Errorf("expected no data, got %v", err)
+	}
+	if _, err := source.SocketMetrics("server-b", 0, []string{"ipc"}, 0); err == nil {
+		t.Errorf("expected an error for an unknown server")
+	}
+	if _, err := source.CoreMetrics("server-a", 0, []int{0, 4}, []string{"c6res"}, 0); err == nil {
+		t.Errorf("expected an error for a core of another socket")
+	} else if reason, ok := monitoring.ReasonOf(err); !ok || reason != monitoring.NoData {
+		t.Errorf("expected no data, got %v", err)
+	}
+	if _, err := source.CoreMetrics("server-a", 0, nil, []string{"c6res"}, 0); err == nil {
+		t.Errorf("expected an error without cores")
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// HardwareCounterOptions are the flags of the hardware-counter scheduling,
+// added to the command line of the scheduler and turned into the Options of
+// New.
+type HardwareCounterOptions struct {
+	// TopologyConfigFile is the path of the topology file.
+	TopologyConfigFile string
+	// MonitoringConfigFile is the path of the monitoring database config.
+	// The hardware-counter priorities are disabled if it is empty, or if it
+	// is the default path and the file is missing.
+	MonitoringConfigFile string
+	// MetricsRefreshPeriod is how often the hardware counters are refreshed.
+	MetricsRefreshPeriod time.Duration
+	// ProfileConfigMap is the namespace/name of the ConfigMap of the
+	// application profiles, the benchmark profiles if empty.
+	ProfileConfigMap string
+	// AnnotateDecisions is whether the bound pods are annotated with the
+	// explanation of their scheduling decision.
+	AnnotateDecisions bool
+}
+
+// NewHardwareCounterOptions returns the default HardwareCounterOptions.
+func NewHardwareCounterOptions() *HardwareCounterOptions {
+	return &HardwareCounterOptions{
+		TopologyConfigFile:   topology.DefaultConfigFile,
+		MonitoringConfigFile: monitoring.DefaultConfigFile,
+		MetricsRefreshPeriod: customcache.DefaultRefreshPeriod,
+	}
+}
+
+// AddFlags adds the flags of the options to fs.
+func (o *HardwareCounterOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.TopologyConfigFile, "topology-config", o.TopologyConfigFile, "topology file of the cluster.")
+	fs.StringVar(&o.MonitoringConfigFile, "monitoring-config", o.MonitoringConfigFile, "config file of the monitoring database. The hardware-counter priorities are disabled if empty, or if the default file is missing.")
+	fs.DurationVar(&o.MetricsRefreshPeriod, "metrics-refresh-period", o.MetricsRefreshPeriod, "how often the hardware counters are refreshed from the monitoring database.")
+	fs.StringVar(&o.ProfileConfigMap, "profiles-configmap", o.ProfileConfigMap, "namespace/name of the ConfigMap of the application profiles; the benchmark profiles if empty.")
+	fs.BoolVar(&o.AnnotateDecisions, "annotate-decisions", o.AnnotateDecisions, "annotate the bound pods with the explanation of their scheduling decision.")
+}
+
+// Validate checks the options, reading the monitoring config file.
+func (o *HardwareCounterOptions) Validate() []error {
+	var errs []error
+	if _, err := readMonitoringConfig(o.MonitoringConfigFile); err != nil {
+		errs = append(errs, err)
+	}
+	if o.MetricsRefreshPeriod <= 0 {
+		errs = append(errs, fmt.Errorf("metrics refresh period must be positive, got %v", o.MetricsRefreshPeriod))
+	}
+	if len(o.ProfileConfigMap) > 0 {
+		if _, _, err := o.profileConfigMap(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Options returns the Options of New set by the flags.
+func (o *HardwareCounterOptions) Options() []Option {
+	opts := []Option{
+		WithTopologyConfigFile(o.TopologyConfigFile),
+		WithMonitoringConfigFile(o.MonitoringConfigFile),
+		WithMetricsRefreshPeriod(o.MetricsRefreshPeriod),
+		WithDecisionAnnotation(o.AnnotateDecisions),
+	}
+	if namespace, name, err := o.profileConfigMap(); err == nil {
+		opts = append(opts, WithProfileConfigMap(namespace, name))
+	}
+	return opts
+}
+
+// profileConfigMap splits the namespace/name of the ConfigMap of the profiles.
+func (o *HardwareCounterOptions) profileConfigMap() (string, string, error) {
+	parts := strings.Split(o.ProfileConfigMap, "/")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", fmt.Errorf("invalid profiles ConfigMap %q, expected namespace/name", o.ProfileConfigMap)
+	}
+	return parts[0], parts[1], nil
+}
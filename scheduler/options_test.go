@@ -0,0 +1,86 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestHardwareCounterOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		expected  schedulerOptions
+		expectErr bool
+	}{
+		{
+			name: "flags",
+			args: []string{"--topology-config=/topology.yaml", "--monitoring-config=", "--metrics-refresh-period=10s", "--profiles-configmap=kube-system/profiles", "--annotate-decisions"},
+			expected: schedulerOptions{
+				topologyConfigFile:        "/topology.yaml",
+				metricsRefreshPeriod:      10 * time.Second,
+				profileConfigMapNamespace: "kube-system",
+				profileConfigMapName:      "profiles",
+				annotateDecisions:         true,
+			},
+		},
+		{
+			name:      "missing monitoring config",
+			args:      []string{"--monitoring-config=/nonexistent/monitoring.yaml"},
+			expectErr: true,
+		},
+		{
+			name:      "invalid profiles ConfigMap",
+			args:      []string{"--monitoring-config=", "--profiles-configmap=profiles"},
+			expectErr: true,
+		},
+		{
+			name:      "non-positive refresh period",
+			args:      []string{"--monitoring-config=", "--metrics-refresh-period=0s"},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o := NewHardwareCounterOptions()
+			fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			o.AddFlags(fs)
+			if err := fs.Parse(test.args); err != nil {
+				t.Fatal(err)
+			}
+			errs := o.Validate()
+			if (len(errs) > 0) != test.expectErr {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			if test.expectErr {
+				return
+			}
+			var options schedulerOptions
+			for _, opt := range o.Options() {
+				opt(&options)
+			}
+			if !reflect.DeepEqual(test.expected, options) {
+				t.Errorf("expected %+v, got %+v", test.expected, options)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package profiles
+
+import "time"
+
+// Benchmarks are the profiles of the benchmark applications used to evaluate
+// the scheduler, measured in isolation. They are served until a ConfigMap of
+// profiles is loaded.
+var Benchmarks = map[string]*ApplicationProfile{
+	"scikit-lasso": {
+		Metrics: map[string]float64{
+			"ipc":       1.87,
+			"mem_read":  0.1753,
+			"mem_write": 0.008856,
+			"c6res":     0.003058,
+		},
+		Duration: 69 * time.Second,
+	},
+	"scikit-ada": {
+		Metrics: map[string]float64{
+			"ipc":       1.10,
+			"mem_read":  0.09868,
+			"mem_write": 0.00669,
+			"c6res":     0,
+		},
+		Duration: 138 * time.Second,
+	},
+	"scikit-rfr": {
+		Metrics: map[string]float64{
+			"ipc":       1.25,
+			"mem_read":  0.0228,
+			"mem_write": 0.00503,
+			"c6res":     0,
+		},
+		Duration: 115 * time.Second,
+	},
+	"scikit-rfc": {
+		Metrics: map[string]float64{
+			"ipc":       1.802,
+			"mem_read":  0.02423,
+			"mem_write": 0.010603,
+			"c6res":     0,
+		},
+		Duration: 38 * time.Second,
+	},
+	"scikit-linregr": {
+		Metrics: map[string]float64{
+			"ipc":       1.9464,
+			"mem_read":  0.040475,
+			"mem_write": 0.01974,
+			"c6res":     0.00928149,
+		},
+		Duration: 45 * time.Second,
+	},
+	"scikit-lda": {
+		Metrics: map[string]float64{
+			"ipc":       1.9162,
+			"mem_read":  0.0541,
+			"mem_write": 0.029381,
+			"c6res":     0.003805,
+		},
+		Duration: 53 * time.Second,
+	},
+	"cloudsuite-data-serving-client": {
+		Metrics: map[string]float64{
+			"ipc":       0.6619,
+			"mem_read":  0,
+			"mem_write": 0,
+			"c6res":     44.48,
+		},
+		Duration: 72 * time.Second,
+	},
+	"cloudsuite-in-memory-analytics": {
+		Metrics: map[string]float64{
+			"ipc":       1.3399,
+			"mem_read":  0.0052142,
+			"mem_write": 0.61361,
+			"c6res":     3.76196,
+		},
+		Duration: 60 * time.Second,
+	},
+	"cloudsuite-web-serving-client": {
+		Metrics: map[string]float64{
+			"ipc":       0.6619,
+			"mem_read":  0,
+			"mem_write": 0,
+			"c6res":     44.48,
+		},
+		Duration: 203 * time.Second,
+	},
+	"spec-sphinx": {
+		Metrics: map[string]float64{
+			"ipc":       2.035,
+			"mem_read":  0.0042372,
+			"mem_write": 0.0021131,
+			"c6res":     0.07497,
+		},
+		Duration: 592 * time.Second,
+	},
+	"spec-cactus": {
+		Metrics: map[string]float64{
+			"ipc":       1.353,
+			"mem_read":  0.07105,
+			"mem_write": 0.0273161,
+			"c6res":     0.0532267,
+		},
+		Duration: 780 * time.Second,
+	},
+	"spec-astar": {
+		Metrics: map[string]float64{
+			"ipc":       0.86314,
+			"mem_read":  0.0063,
+			"mem_write": 0.0032874,
+			"c6res":     0.09115,
+		},
+		Duration: 468 * time.Second,
+	},
+	"spec-leslie": {
+		Metrics: map[string]float64{
+			"ipc":       1.5225,
+			"mem_read":  0.3221,
+			"mem_write": 0.1532,
+			"c6res":     0.1215,
+		},
+		Duration: 378 * time.Second,
+	},
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package profiles
+
+import "fmt"
+
+// L3MissMetric is the metric holding the L3 miss rate of an application.
+const L3MissMetric = "l3m"
+
+// LLCClass is the behaviour of an application towards the last-level cache
+// it shares with the other applications of its socket.
+type LLCClass string
+
+const (
+	// LLCInsensitive applications barely use the last-level cache, they
+	// neither suffer from nor cause contention.
+	LLCInsensitive LLCClass = "insensitive"
+	// LLCSensitive applications reuse a working set that fits in the
+	// last-level cache, and slow down once other applications evict it.
+	LLCSensitive LLCClass = "sensitive"
+	// LLCPolluting applications stream through more data than the last-level
+	// cache holds, evicting the working set of their neighbours.
+	LLCPolluting LLCClass = "polluting"
+)
+
+// LLCClasses are the valid classes of last-level cache behaviour.
+var LLCClasses = []LLCClass{LLCInsensitive, LLCSensitive, LLCPolluting}
+
+// Valid returns whether c is one of the LLCClasses.
+func (c LLCClass) Valid() bool {
+	for _, class := range LLCClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// LLCThresholds are the L3 miss rates, in the unit of the L3MissMetric, that
+// separate the classes of last-level cache behaviour.
+type LLCThresholds struct {
+	// Sensitive is the miss rate from which an application is LLCSensitive.
+	Sensitive float64
+	// Polluting is the miss rate from which an application is LLCPolluting.
+	Polluting float64
+}
+
+// DefaultLLCThresholds are the thresholds used unless the policy sets others.
+var DefaultLLCThresholds = LLCThresholds{Sensitive: 0.5, Polluting: 5}
+
+// Validate checks that the thresholds are non-negative and ordered.
+func (t LLCThresholds) Validate() error {
+	if t.Sensitive < 0 || t.Polluting < 0 {
+		return fmt.Errorf("negative L3 miss rate threshold")
+	}
+	if t.Sensitive > t.Polluting {
+		return fmt.Errorf("the sensitive L3 miss rate %v is above the polluting one %v", t.Sensitive, t.Polluting)
+	}
+	return nil
+}
+
+// Classify returns the class of the last-level cache behaviour of p. The LLC
+// field of the profile takes precedence over its L3 miss rate. Profiles
+// without either are LLCInsensitive.
+func (t LLCThresholds) Classify(p *ApplicationProfile) LLCClass {
+	if p.LLC != "" {
+		return p.LLC
+	}
+	misses, ok := p.Metrics[L3MissMetric]
+	switch {
+	case !ok:
+		return LLCInsensitive
+	case misses >= t.Polluting:
+		return LLCPolluting
+	case misses >= t.Sensitive:
+		return LLCSensitive
+	default:
+		return LLCInsensitive
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package profiles
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	thresholds := LLCThresholds{Sensitive: 1, Polluting: 10}
+	tests := []struct {
+		name     string
+		profile  *ApplicationProfile
+		expected LLCClass
+	}{
+		{
+			name:     "few misses",
+			profile:  &ApplicationProfile{Metrics: map[string]float64{L3MissMetric: 0.2}},
+			expected: LLCInsensitive,
+		},
+		{
+			name:     "moderate misses",
+			profile:  &ApplicationProfile{Metrics: map[string]float64{L3MissMetric: 1}},
+			expected: LLCSensitive,
+		},
+		{
+			name:     "many misses",
+			profile:  &ApplicationProfile{Metrics: map[string]float64{L3MissMetric: 25}},
+			expected: LLCPolluting,
+		},
+		{
+			name:     "no miss rate",
+			profile:  &ApplicationProfile{Metrics: map[string]float64{"ipc": 1}},
+			expected: LLCInsensitive,
+		},
+		{
+			name:     "explicit class",
+			profile:  &ApplicationProfile{Metrics: map[string]float64{L3MissMetric: 25}, LLC: LLCSensitive},
+			expected: LLCSensitive,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if class := thresholds.Classify(test.profile); class != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, class)
+			}
+		})
+	}
+}
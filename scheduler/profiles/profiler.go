@@ -0,0 +1,266 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package profiles
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+const (
+	// minRunDuration is the shortest run the counters are meaningful for.
+	minRunDuration = 10 * time.Second
+	// persistPeriod is how often the learned profiles are persisted.
+	persistPeriod = time.Minute
+	// pendingRuns is the number of finished runs waiting to be observed
+	// above which new ones are dropped.
+	pendingRuns = 100
+)
+
+// ProfileMetrics are the counters a profile is learned from.
+var ProfileMetrics = []string{"ipc", "mem_read", "mem_write", "c6res"}
+
+// Persister saves the learned profiles.
+type Persister interface {
+	Persist(learned map[string]*ApplicationProfile) error
+}
+
+// run is a pod bound by the scheduler, with the application it runs.
+type run struct {
+	app   string
+	node  string
+	bound time.Time
+	// shared is set if another profiled pod ran on the node at the same
+	// time, so that the counters of the node can't be attributed to it.
+	shared bool
+}
+
+// finishedRun is a run that completed between start and end.
+type finishedRun struct {
+	*run
+	start time.Time
+	end   time.Time
+}
+
+// Profiler learns the profiles of the applications from the counters of the
+// cores of their pods, once they run to completion. Pods that never
+// complete, such as those of Deployments, are not learned from.
+type Profiler struct {
+	store     *Store
+	topology  topology.Interface
+	source    monitoring.MetricsSource
+	persister Persister
+	now       func() time.Time
+
+	mu       sync.Mutex
+	runs     map[types.UID]*run
+	finished chan finishedRun
+	dirty    bool
+}
+
+// NewProfiler returns a Profiler updating the learned profiles of store.
+// The persister may be nil, in which case the profiles are only kept in
+// memory.
+func NewProfiler(store *Store, topo topology.Interface, source monitoring.MetricsSource, persister Persister) *Profiler {
+	return &Profiler{
+		store:     store,
+		topology:  topo,
+		source:    source,
+		persister: persister,
+		now:       time.Now,
+		runs:      map[types.UID]*run{},
+		finished:  make(chan finishedRun, pendingRuns),
+	}
+}
+
+// Bound records that pod was bound to node.
+func (p *Profiler) Bound(pod *v1.Pod, node string) {
+	names := ApplicationNames(pod)
+	if len(names) == 0 {
+		return
+	}
+	r := &run{app: names[0], node: node, bound: p.now()}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, other := range p.runs {
+		if other.node == node {
+			other.shared = true
+			r.shared = true
+		}
+	}
+	p.runs[pod.UID] = r
+}
+
+// Forget stops tracking a pod, e.g. because its binding failed or it was
+// deleted before completing.
+func (p *Profiler) Forget(uid types.UID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.runs, uid)
+}
+
+// Finished queues the run of pod to be learned from if it succeeded. The
+// counters are queried by Run, outside of the event handlers.
+func (p *Profiler) Finished(pod *v1.Pod) {
+	p.mu.Lock()
+	r, ok := p.runs[pod.UID]
+	delete(p.runs, pod.UID)
+	p.mu.Unlock()
+	if !ok || pod.Status.Phase != v1.PodSucceeded {
+		return
+	}
+	if r.shared {
+		klog.V(4).Infof("Not learning from pod %v/%v, it shared node %v", pod.Namespace, pod.Name, r.node)
+		return
+	}
+
+	f := finishedRun{run: r, start: r.bound, end: p.now()}
+	if pod.Status.StartTime != nil {
+		f.start = pod.Status.StartTime.Time
+	}
+	if end, ok := finishTime(pod); ok {
+		f.end = end
+	}
+	select {
+	case p.finished <- f:
+	default:
+		klog.Warningf("Too many runs waiting to be profiled, dropping pod %v/%v", pod.Namespace, pod.Name)
+	}
+}
+
+// finishTime returns the time the last container of pod terminated.
+func finishTime(pod *v1.Pod) (time.Time, bool) {
+	var end time.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if t := status.State.Terminated; t != nil && t.FinishedAt.Time.After(end) {
+			end = t.FinishedAt.Time
+		}
+	}
+	return end, !end.IsZero()
+}
+
+// Run learns from the finished runs and persists the learned profiles
+// periodically until stopCh is closed.
+func (p *Profiler) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(persistPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case f := <-p.finished:
+			if err := p.observe(f); err != nil {
+				klog.Warningf("Unable to learn the profile of %v: %v", f.app, err)
+			}
+		case <-ticker.C:
+			p.persist()
+		case <-stopCh:
+			p.persist()
+			return
+		}
+	}
+}
+
+// observe learns from the counters of the cores of a finished run.
+func (p *Profiler) observe(f finishedRun) error {
+	duration := f.end.Sub(f.start)
+	if duration < minRunDuration {
+		return fmt.Errorf("the run lasted %v, at least %v are needed", duration, minRunDuration)
+	}
+	node, ok := p.topology.Node(f.node)
+	if !ok {
+		return fmt.Errorf("node %v is not in the topology", f.node)
+	}
+	metrics, err := p.source.CoreMetricsRange(node.Server, node.Socket, node.Cores, ProfileMetrics, f.start, f.end)
+	if err != nil {
+		return err
+	}
+	profile := p.store.Learn(f.app, &ApplicationProfile{Metrics: metrics, Duration: duration})
+	klog.V(2).Infof("Learned the profile of %v from %d runs: %v, %v", f.app, profile.Runs, profile.Metrics, profile.Duration)
+
+	p.mu.Lock()
+	p.dirty = true
+	p.mu.Unlock()
+	return nil
+}
+
+// persist saves the learned profiles if they changed since the last call.
+func (p *Profiler) persist() {
+	p.mu.Lock()
+	dirty := p.dirty
+	p.dirty = false
+	p.mu.Unlock()
+	if !dirty || p.persister == nil {
+		return
+	}
+	if err := p.persister.Persist(p.store.Learned()); err != nil {
+		klog.Errorf("Unable to persist the learned profiles: %v", err)
+		p.mu.Lock()
+		p.dirty = true
+		p.mu.Unlock()
+	}
+}
+
+// ConfigMapPersister persists the learned profiles in the LearnedConfigMapKey
+// of a ConfigMap, which is created if it doesn't exist.
+type ConfigMapPersister struct {
+	Client    clientset.Interface
+	Namespace string
+	Name      string
+}
+
+var _ Persister = &ConfigMapPersister{}
+
+// Persist saves the learned profiles.
+func (c *ConfigMapPersister) Persist(learned map[string]*ApplicationProfile) error {
+	data, err := yaml.Marshal(learned)
+	if err != nil {
+		return err
+	}
+	configMaps := c.Client.CoreV1().ConfigMaps(c.Namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(c.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			cm = &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: c.Namespace, Name: c.Name},
+				Data:       map[string]string{LearnedConfigMapKey: string(data)},
+			}
+			_, err = configMaps.Create(cm)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[LearnedConfigMapKey] = string(data)
+		_, err = configMaps.Update(cm)
+		return err
+	})
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package profiles
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	monitoringfake "k8s.io/kubernetes/pkg/scheduler/monitoring/fake"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+func TestLearn(t *testing.T) {
+	s := NewStore()
+	s.Load(&Config{Applications: map[string]*ApplicationProfile{
+		"redis": {Metrics: map[string]float64{"ipc": 1, "c6res": 10}, Duration: 100 * time.Second},
+	}})
+
+	p := s.Learn("redis", &ApplicationProfile{Metrics: map[string]float64{"ipc": 2}, Duration: 200 * time.Second})
+	checkProfile(t, &ApplicationProfile{
+		Metrics:  map[string]float64{"ipc": 1.3, "c6res": 10},
+		Duration: 130 * time.Second,
+		Runs:     1,
+	}, p)
+	if got, _ := s.Get("redis"); got != p {
+		t.Errorf("expected the learned profile to take precedence, got %+v", got)
+	}
+
+	p = s.Learn("memcached", &ApplicationProfile{Metrics: map[string]float64{"ipc": 2}, Duration: 200 * time.Second})
+	checkProfile(t, &ApplicationProfile{Metrics: map[string]float64{"ipc": 2}, Duration: 200 * time.Second, Runs: 1}, p)
+
+	// A reload keeps the profiles learned from more runs.
+	s.mergeLearned(map[string]*ApplicationProfile{
+		"redis":     {Metrics: map[string]float64{"ipc": 5}, Runs: 3},
+		"memcached": {Metrics: map[string]float64{"ipc": 5}},
+	})
+	if got, _ := s.Get("redis"); got.Runs != 3 {
+		t.Errorf("expected the persisted profile of redis, got %+v", got)
+	}
+	if got, _ := s.Get("memcached"); got != p {
+		t.Errorf("expected the learned profile of memcached to be kept, got %+v", got)
+	}
+}
+
+// checkProfile compares profiles up to rounding errors.
+func checkProfile(t *testing.T, expected, got *ApplicationProfile) {
+	t.Helper()
+	if got == nil {
+		t.Fatalf("expected %+v, got nil", expected)
+	}
+	equal := len(got.Metrics) == len(expected.Metrics) &&
+		got.Runs == expected.Runs &&
+		math.Abs(float64(got.Duration-expected.Duration)) < float64(time.Millisecond)
+	for metric, val := range expected.Metrics {
+		if v, ok := got.Metrics[metric]; !ok || math.Abs(v-val) > 1e-9 {
+			equal = false
+		}
+	}
+	if !equal {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func newTestProfiler(now time.Time) (*Profiler, *Store, *monitoringfake.MetricsSource) {
+	topo := topology.New(
+		[]topology.Server{{UUID: "server-a"}},
+		[]topology.Node{{Name: "kube-01", Server: "server-a", Socket: 0, Cores: []int{0, 1}}},
+	)
+	source := &monitoringfake.MetricsSource{Cores: map[monitoringfake.Core]map[string]float64{
+		{Server: "server-a", Socket: 0, Core: 0}: {"ipc": 1, "mem_read": 0.2, "mem_write": 0.1, "c6res": 10},
+		{Server: "server-a", Socket: 0, Core: 1}: {"ipc": 3, "mem_read": 0.2, "mem_write": 0.1, "c6res": 30},
+	}}
+	store := NewStore()
+	p := NewProfiler(store, topo, source, nil)
+	p.now = func() time.Time { return now }
+	return p, store, source
+}
+
+func newJobPod(uid, job string, phase v1.PodPhase, start, end time.Time) *v1.Pod {
+	pod := newPod(nil, nil, &metav1.OwnerReference{Kind: "Job", Name: job})
+	pod.UID = types.UID(uid)
+	pod.Status.Phase = phase
+	pod.Status.StartTime = &metav1.Time{Time: start}
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{FinishedAt: metav1.Time{Time: end}}},
+	}}
+	return pod
+}
+
+func TestProfiler(t *testing.T) {
+	now := time.Now()
+	start, end := now.Add(-2*time.Minute), now.Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		pods    []*v1.Pod
+		learned bool
+	}{
+		{
+			name:    "succeeded",
+			pods:    []*v1.Pod{newJobPod("a", "redis", v1.PodSucceeded, start, end)},
+			learned: true,
+		},
+		{
+			name: "failed",
+			pods: []*v1.Pod{newJobPod("a", "redis", v1.PodFailed, start, end)},
+		},
+		{
+			name: "too short",
+			pods: []*v1.Pod{newJobPod("a", "redis", v1.PodSucceeded, start, start.Add(time.Second))},
+		},
+		{
+			name: "shared node",
+			pods: []*v1.Pod{
+				newJobPod("a", "redis", v1.PodSucceeded, start, end),
+				newJobPod("b", "memcached", v1.PodSucceeded, start, end),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, store, source := newTestProfiler(now)
+			for _, pod := range test.pods {
+				p.Bound(pod, "kube-01")
+			}
+			for _, pod := range test.pods {
+				p.Finished(pod)
+			}
+			close(p.finished)
+			for f := range p.finished {
+				p.observe(f)
+			}
+
+			learned := store.Learned()
+			if !test.learned {
+				if len(learned) != 0 {
+					t.Errorf("expected nothing to be learned, got %v", learned)
+				}
+				return
+			}
+			if len(learned) != 1 {
+				t.Fatalf("expected the profile of redis only, got %v", learned)
+			}
+			checkProfile(t, &ApplicationProfile{
+				Metrics:  map[string]float64{"ipc": 2, "mem_read": 0.2, "mem_write": 0.1, "c6res": 20},
+				Duration: time.Minute,
+				Runs:     1,
+			}, learned["redis"])
+			if !p.dirty {
+				t.Errorf("expected the learned profiles to be persisted")
+			}
+			// The counters are those of the run only, not of the time since
+			// it ended.
+			expectedRanges := []monitoringfake.Range{{Start: start, End: end}}
+			if ranges := source.Ranges(); !reflect.DeepEqual(expectedRanges, ranges) {
+				t.Errorf("expected the counters queried over %v, got %v", expectedRanges, ranges)
+			}
+		})
+	}
+}
+
+func TestConfigMapPersister(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	persister := &ConfigMapPersister{Client: client, Namespace: "kube-system", Name: "profiles"}
+	learned := map[string]*ApplicationProfile{
+		"redis": {Metrics: map[string]float64{"ipc": 2}, Duration: time.Minute, Runs: 1},
+	}
+
+	// The ConfigMap is created, then updated without touching the other keys.
+	if err := persister.Persist(learned); err != nil {
+		t.Fatal(err)
+	}
+	cm, err := client.CoreV1().ConfigMaps("kube-system").Get("profiles", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.Data[ConfigMapKey] = testProfiles
+	if _, err := client.CoreV1().ConfigMaps("kube-system").Update(cm); err != nil {
+		t.Fatal(err)
+	}
+	learned["redis"].Runs = 2
+	if err := persister.Persist(learned); err != nil {
+		t.Fatal(err)
+	}
+
+	cm, err = client.CoreV1().ConfigMaps("kube-system").Get("profiles", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.Data[ConfigMapKey] != testProfiles {
+		t.Errorf("expected the profiles to be kept, got %q", cm.Data[ConfigMapKey])
+	}
+	got, err := ParseLearned([]byte(cm.Data[LearnedConfigMapKey]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, learned) {
+		t.Errorf("expected %+v, got %+v", learned["redis"], got["redis"])
+	}
+}
@@ -0,0 +1,348 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package profiles
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// ApplicationKey is the pod label or annotation naming the application
+	// the pod runs. The label takes precedence over the annotation.
+	ApplicationKey = "profile.kube-scheduler/application"
+
+	// ConfigMapKey is the key of the profiles in their ConfigMap.
+	ConfigMapKey = "profiles.yaml"
+	// LearnedConfigMapKey is the key of the learned profiles in the ConfigMap.
+	// It is written by the scheduler.
+	LearnedConfigMapKey = "learned.yaml"
+
+	// learningRate is the weight of a new run in a learned profile.
+	learningRate = 0.3
+
+	// podTemplateHashKey is the label the deployment controller adds to the
+	// pods, and to the name of the ReplicaSets, of a pod template.
+	podTemplateHashKey = "pod-template-hash"
+)
+
+// ApplicationProfile is the expected load of an application, measured with
+// the same hardware counters as the nodes.
+type ApplicationProfile struct {
+	Metrics  map[string]float64 `yaml:"metrics"`
+	Duration time.Duration      `yaml:"duration"`
+	// Runs is the number of runs a learned profile was derived from.
+	Runs int `yaml:"runs,omitempty"`
+	// LLC is the last-level cache behaviour of the application. If empty it
+	// is classified from the L3 miss rate of the profile.
+	LLC LLCClass `yaml:"llc,omitempty"`
+}
+
+// DefaultProfile is the profile of the applications without a known profile,
+// unless the ConfigMap sets another one. It pessimistically assumes a busy,
+// moderately memory-bound core.
+var DefaultProfile = &ApplicationProfile{
+	Metrics: map[string]float64{
+		"ipc":       1,
+		"mem_read":  0.05,
+		"mem_write": 0.02,
+		"c6res":     0,
+	},
+}
+
+// Config is the representation of the profiles in their ConfigMap.
+type Config struct {
+	// Default is the profile of the unknown applications.
+	Default *ApplicationProfile `yaml:"default"`
+	// Applications are the profiles by application name.
+	Applications map[string]*ApplicationProfile `yaml:"applications"`
+}
+
+// Parse decodes and validates the profiles of a ConfigMap.
+func Parse(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("unable to decode the application profiles: %v", err)
+	}
+	if cfg.Default != nil {
+		if err := cfg.Default.validate(); err != nil {
+			return nil, fmt.Errorf("default profile: %v", err)
+		}
+	}
+	for name, p := range cfg.Applications {
+		if p == nil {
+			return nil, fmt.Errorf("application %s has no profile", name)
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("application %s: %v", name, err)
+		}
+	}
+	return cfg, nil
+}
+
+// ParseLearned decodes and validates learned profiles.
+func ParseLearned(data []byte) (map[string]*ApplicationProfile, error) {
+	learned := map[string]*ApplicationProfile{}
+	if err := yaml.UnmarshalStrict(data, &learned); err != nil {
+		return nil, fmt.Errorf("unable to decode the learned profiles: %v", err)
+	}
+	for name, p := range learned {
+		if p == nil {
+			return nil, fmt.Errorf("application %s has no learned profile", name)
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("application %s: %v", name, err)
+		}
+	}
+	return learned, nil
+}
+
+func (p *ApplicationProfile) validate() error {
+	if len(p.Metrics) == 0 {
+		return fmt.Errorf("no metrics")
+	}
+	for metric, val := range p.Metrics {
+		if val < 0 {
+			return fmt.Errorf("negative %s %v", metric, val)
+		}
+	}
+	if p.Duration < 0 {
+		return fmt.Errorf("negative duration %v", p.Duration)
+	}
+	if p.LLC != "" && !p.LLC.Valid() {
+		return fmt.Errorf("unknown llc class %s, expected one of %v", p.LLC, LLCClasses)
+	}
+	return nil
+}
+
+// Lister looks up application profiles. The returned profiles are shared and
+// must not be modified.
+type Lister interface {
+	// Get returns the profile of the named application.
+	Get(name string) (*ApplicationProfile, bool)
+	// ForPod returns the profile of the application run by pod, or the
+	// default profile if the application is unknown.
+	ForPod(pod *v1.Pod) *ApplicationProfile
+}
+
+// Store is a Lister serving the Benchmarks until a ConfigMap of profiles is
+// loaded. Learned profiles take precedence over both. It is meant to be the
+// event handler of an informer watching that ConfigMap only.
+type Store struct {
+	mu           sync.RWMutex
+	applications map[string]*ApplicationProfile
+	learned      map[string]*ApplicationProfile
+	def          *ApplicationProfile
+}
+
+var _ Lister = &Store{}
+
+// NewStore returns a Store serving the Benchmarks and the DefaultProfile.
+func NewStore() *Store {
+	return &Store{
+		applications: Benchmarks,
+		learned:      map[string]*ApplicationProfile{},
+		def:          DefaultProfile,
+	}
+}
+
+// Get returns the profile of the named application.
+func (s *Store) Get(name string) (*ApplicationProfile, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.get(name)
+}
+
+func (s *Store) get(name string) (*ApplicationProfile, bool) {
+	if p, ok := s.learned[name]; ok {
+		return p, true
+	}
+	p, ok := s.applications[name]
+	return p, ok
+}
+
+// ForPod returns the profile of the application run by pod, or the default
+// profile if the application is unknown.
+func (s *Store) ForPod(pod *v1.Pod) *ApplicationProfile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, name := range ApplicationNames(pod) {
+		if p, ok := s.get(name); ok {
+			return p
+		}
+	}
+	klog.V(4).Infof("No profile for pod %v/%v, using the default profile", pod.Namespace, pod.Name)
+	return s.def
+}
+
+// ApplicationNames returns the names the application run by pod may be
+// profiled under, in order of precedence: the ApplicationKey label, the
+// ApplicationKey annotation, then the name of the controller of the pod.
+// The pod-template-hash suffix of a ReplicaSet is dropped, so that the pods
+// of a Deployment are matched by the name of the Deployment.
+func ApplicationNames(pod *v1.Pod) []string {
+	var names []string
+	if name, ok := pod.Labels[ApplicationKey]; ok {
+		names = append(names, name)
+	}
+	if name, ok := pod.Annotations[ApplicationKey]; ok {
+		names = append(names, name)
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		name := owner.Name
+		if hash, ok := pod.Labels[podTemplateHashKey]; ok && owner.Kind == "ReplicaSet" {
+			name = strings.TrimSuffix(name, "-"+hash)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// Load replaces the served profiles with those of cfg. Unknown applications
+// get the default profile of cfg, or the DefaultProfile if it sets none.
+func (s *Store) Load(cfg *Config) {
+	applications, def := cfg.Applications, cfg.Default
+	if applications == nil {
+		applications = map[string]*ApplicationProfile{}
+	}
+	if def == nil {
+		def = DefaultProfile
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applications, s.def = applications, def
+	klog.V(2).Infof("Loaded %d application profiles", len(applications))
+}
+
+// Learn updates the learned profile of the named application with an observed
+// run, and returns it. The first run starts from the known profile of the
+// application, if any.
+func (s *Store) Learn(name string, observed *ApplicationProfile) *ApplicationProfile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.get(name)
+	if !ok {
+		prev = &ApplicationProfile{Metrics: observed.Metrics, Duration: observed.Duration}
+	}
+	// Profiles are shared, the learned one is always a new copy.
+	p := &ApplicationProfile{
+		Metrics:  make(map[string]float64, len(prev.Metrics)),
+		Duration: time.Duration((1-learningRate)*float64(prev.Duration) + learningRate*float64(observed.Duration)),
+		Runs:     prev.Runs + 1,
+		LLC:      prev.LLC,
+	}
+	for metric, val := range prev.Metrics {
+		p.Metrics[metric] = val
+	}
+	for metric, val := range observed.Metrics {
+		if old, ok := prev.Metrics[metric]; ok {
+			val = (1-learningRate)*old + learningRate*val
+		}
+		p.Metrics[metric] = val
+	}
+	s.learned[name] = p
+	return p
+}
+
+// Learned returns a copy of the learned profiles.
+func (s *Store) Learned() map[string]*ApplicationProfile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	learned := make(map[string]*ApplicationProfile, len(s.learned))
+	for name, p := range s.learned {
+		learned[name] = p
+	}
+	return learned
+}
+
+// mergeLearned adds persisted learned profiles, keeping those learned from
+// more runs in memory.
+func (s *Store) mergeLearned(learned map[string]*ApplicationProfile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, p := range learned {
+		if current, ok := s.learned[name]; !ok || current.Runs < p.Runs {
+			s.learned[name] = p
+		}
+	}
+}
+
+// Reset serves the Benchmarks and the DefaultProfile again. Learned profiles
+// are kept.
+func (s *Store) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applications = Benchmarks
+	s.def = DefaultProfile
+}
+
+// OnAdd loads the profiles of an added ConfigMap.
+func (s *Store) OnAdd(obj interface{}) {
+	cm, ok := obj.(*v1.ConfigMap)
+	if !ok {
+		klog.Errorf("cannot convert to *v1.ConfigMap: %v", obj)
+		return
+	}
+	s.loadConfigMap(cm)
+}
+
+// OnUpdate loads the profiles of an updated ConfigMap.
+func (s *Store) OnUpdate(oldObj, newObj interface{}) {
+	s.OnAdd(newObj)
+}
+
+// OnDelete serves the Benchmarks again once the ConfigMap is deleted. Learned
+// profiles are kept.
+func (s *Store) OnDelete(obj interface{}) {
+	klog.Infof("Application profiles deleted, using the benchmark profiles")
+	s.Reset()
+}
+
+// loadConfigMap loads the profiles of cm. Invalid profiles are logged and
+// the previous ones are kept.
+func (s *Store) loadConfigMap(cm *v1.ConfigMap) {
+	if data, ok := cm.Data[LearnedConfigMapKey]; ok {
+		learned, err := ParseLearned([]byte(data))
+		if err != nil {
+			klog.Errorf("ConfigMap %s/%s: %v", cm.Namespace, cm.Name, err)
+		} else {
+			s.mergeLearned(learned)
+		}
+	}
+	data, ok := cm.Data[ConfigMapKey]
+	if !ok {
+		if _, ok := cm.Data[LearnedConfigMapKey]; ok {
+			// Created by the scheduler to persist the learned profiles.
+			return
+		}
+		klog.Errorf("ConfigMap %s/%s has no %s key, keeping the previous application profiles", cm.Namespace, cm.Name, ConfigMapKey)
+		return
+	}
+	cfg, err := Parse([]byte(data))
+	if err != nil {
+		klog.Errorf("ConfigMap %s/%s: %v, keeping the previous application profiles", cm.Namespace, cm.Name, err)
+		return
+	}
+	s.Load(cfg)
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package profiles
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testProfiles = `
+default:
+  metrics: {ipc: 0.5, mem_read: 0.1, mem_write: 0.1, c6res: 10}
+applications:
+  redis:
+    metrics: {ipc: 1.2, mem_read: 0.3, mem_write: 0.2, c6res: 5}
+    duration: 90s
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		expectErr bool
+	}{
+		{
+			name: "valid profiles",
+			data: testProfiles,
+		},
+		{
+			name: "applications only",
+			data: "applications:\n  redis:\n    metrics: {ipc: 1.2}\n",
+		},
+		{
+			name:      "unknown field",
+			data:      "applications:\n  redis:\n    metric: {ipc: 1.2}\n",
+			expectErr: true,
+		},
+		{
+			name:      "application without metrics",
+			data:      "applications:\n  redis:\n    duration: 90s\n",
+			expectErr: true,
+		},
+		{
+			name:      "negative metric",
+			data:      "default:\n  metrics: {ipc: -1}\n",
+			expectErr: true,
+		},
+		{
+			name: "llc class",
+			data: "applications:\n  redis:\n    metrics: {ipc: 1.2}\n    llc: sensitive\n",
+		},
+		{
+			name:      "unknown llc class",
+			data:      "applications:\n  redis:\n    metrics: {ipc: 1.2}\n    llc: thrashing\n",
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.data))
+			if (err != nil) != test.expectErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	cfg, err := Parse([]byte(testProfiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cfg.Applications["redis"].Duration; d != 90*time.Second {
+		t.Errorf("expected a duration of 90s, got %v", d)
+	}
+}
+
+func newPod(labels, annotations map[string]string, owner *metav1.OwnerReference) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "pod",
+		Namespace:   "default",
+		Labels:      labels,
+		Annotations: annotations,
+	}}
+	if owner != nil {
+		controller := true
+		owner.Controller = &controller
+		pod.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	return pod
+}
+
+func TestForPod(t *testing.T) {
+	s := NewStore()
+	cfg, err := Parse([]byte(testProfiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Load(cfg)
+
+	tests := []struct {
+		name     string
+		pod      *v1.Pod
+		expected *ApplicationProfile
+	}{
+		{
+			name:     "label",
+			pod:      newPod(map[string]string{ApplicationKey: "redis"}, nil, nil),
+			expected: cfg.Applications["redis"],
+		},
+		{
+			name:     "annotation",
+			pod:      newPod(nil, map[string]string{ApplicationKey: "redis"}, nil),
+			expected: cfg.Applications["redis"],
+		},
+		{
+			name:     "unknown label falls back to the owner",
+			pod:      newPod(map[string]string{ApplicationKey: "memcached"}, nil, &metav1.OwnerReference{Kind: "Job", Name: "redis"}),
+			expected: cfg.Applications["redis"],
+		},
+		{
+			name: "deployment",
+			pod: newPod(map[string]string{podTemplateHashKey: "5d8f9c7b6"}, nil,
+				&metav1.OwnerReference{Kind: "ReplicaSet", Name: "redis-5d8f9c7b6"}),
+			expected: cfg.Applications["redis"],
+		},
+		{
+			name:     "unknown application",
+			pod:      newPod(nil, nil, &metav1.OwnerReference{Kind: "Job", Name: "memcached"}),
+			expected: cfg.Default,
+		},
+		{
+			name:     "no application",
+			pod:      newPod(nil, nil, nil),
+			expected: cfg.Default,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if p := s.ForPod(test.pod); p != test.expected {
+				t.Errorf("expected profile %+v, got %+v", test.expected, p)
+			}
+		})
+	}
+}
+
+func TestStoreEvents(t *testing.T) {
+	s := NewStore()
+	if _, ok := s.Get("spec-leslie"); !ok {
+		t.Errorf("expected the benchmark profiles before the ConfigMap is loaded")
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "profiles", Namespace: "kube-system"},
+		Data:       map[string]string{ConfigMapKey: testProfiles},
+	}
+	s.OnAdd(cm)
+	if _, ok := s.Get("redis"); !ok {
+		t.Errorf("expected the profiles of the ConfigMap")
+	}
+	if _, ok := s.Get("spec-leslie"); ok {
+		t.Errorf("expected the ConfigMap to replace the benchmark profiles")
+	}
+
+	invalid := cm.DeepCopy()
+	invalid.Data[ConfigMapKey] = "applications: {redis: {}}"
+	s.OnUpdate(cm, invalid)
+	if _, ok := s.Get("redis"); !ok {
+		t.Errorf("expected the previous profiles to be kept after an invalid update")
+	}
+
+	withoutDefault := cm.DeepCopy()
+	withoutDefault.Data[ConfigMapKey] = "applications: {redis: {metrics: {ipc: 1}}}"
+	s.OnUpdate(cm, withoutDefault)
+	if p := s.ForPod(newPod(nil, nil, nil)); p != DefaultProfile {
+		t.Errorf("expected the DefaultProfile, got %+v", p)
+	}
+
+	s.OnDelete(cm)
+	if _, ok := s.Get("spec-leslie"); !ok {
+		t.Errorf("expected the benchmark profiles after the ConfigMap is deleted")
+	}
+}
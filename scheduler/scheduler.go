@@ -17,6 +17,7 @@ limitations under the License.
 package scheduler
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -26,6 +27,7 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	appsinformers "k8s.io/client-go/informers/apps/v1"
@@ -33,19 +35,20 @@ import (
 	policyinformers "k8s.io/client-go/informers/policy/v1beta1"
 	storageinformers "k8s.io/client-go/informers/storage/v1"
 	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
 	latestschedulerapi "k8s.io/kubernetes/pkg/scheduler/api/latest"
 	kubeschedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
 	"k8s.io/kubernetes/pkg/scheduler/core"
-
-	// "k8s.io/kubernetes/pkg/scheduler/customcache"
-	// "github.com/iwita/kube-scheduler/customcache"
-
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
 	"k8s.io/kubernetes/pkg/scheduler/factory"
 	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
 	internalcache "k8s.io/kubernetes/pkg/scheduler/internal/cache"
 	"k8s.io/kubernetes/pkg/scheduler/metrics"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
 	"k8s.io/kubernetes/pkg/scheduler/util"
 )
 
@@ -54,6 +57,16 @@ const (
 	BindTimeoutSeconds = 100
 	// SchedulerError is the reason recorded for events when an error occurs during scheduling a pod.
 	SchedulerError = "SchedulerError"
+	// MonitoringUnavailable is the reason recorded for the pods left
+	// unschedulable while the monitoring backend is unavailable, and for the
+	// events of the outages.
+	MonitoringUnavailable = "MonitoringUnavailable"
+	// MonitoringRecovered is the reason recorded for the events of the
+	// recoveries of the monitoring backend.
+	MonitoringRecovered = "MonitoringRecovered"
+	// explainedNodes is the number of best nodes of each scoring stage kept
+	// in the explanations of the scheduling decisions.
+	explainedNodes = 5
 )
 
 // Scheduler watches for new unscheduled pods. It attempts to find
@@ -73,6 +86,12 @@ type schedulerOptions struct {
 	disablePreemption              bool
 	percentageOfNodesToScore       int32
 	bindTimeoutSeconds             int64
+	topologyConfigFile             string
+	monitoringConfigFile           string
+	metricsRefreshPeriod           time.Duration
+	profileConfigMapNamespace      string
+	profileConfigMapName           string
+	annotateDecisions              bool
 }
 
 // Option configures a Scheduler
@@ -113,12 +132,51 @@ func WithBindTimeoutSeconds(bindTimeoutSeconds int64) Option {
 	}
 }
 
+// WithTopologyConfigFile sets the path of the topology file, the default value is topology.DefaultConfigFile
+func WithTopologyConfigFile(topologyConfigFile string) Option {
+	return func(o *schedulerOptions) {
+		o.topologyConfigFile = topologyConfigFile
+	}
+}
+
+// WithMonitoringConfigFile sets the path of the monitoring database config, the default value is monitoring.DefaultConfigFile. An empty path disables the hardware-counter priorities
+func WithMonitoringConfigFile(monitoringConfigFile string) Option {
+	return func(o *schedulerOptions) {
+		o.monitoringConfigFile = monitoringConfigFile
+	}
+}
+
+// WithMetricsRefreshPeriod sets how often the hardware counters are refreshed in the background, the default value is customcache.DefaultRefreshPeriod
+func WithMetricsRefreshPeriod(metricsRefreshPeriod time.Duration) Option {
+	return func(o *schedulerOptions) {
+		o.metricsRefreshPeriod = metricsRefreshPeriod
+	}
+}
+
+// WithProfileConfigMap sets the ConfigMap holding the application profiles, the default is to use the benchmark profiles
+func WithProfileConfigMap(namespace, name string) Option {
+	return func(o *schedulerOptions) {
+		o.profileConfigMapNamespace = namespace
+		o.profileConfigMapName = name
+	}
+}
+
+// WithDecisionAnnotation sets whether the bound pods are annotated with the explanation of their scheduling decision, the default value is false
+func WithDecisionAnnotation(annotateDecisions bool) Option {
+	return func(o *schedulerOptions) {
+		o.annotateDecisions = annotateDecisions
+	}
+}
+
 var defaultSchedulerOptions = schedulerOptions{
 	schedulerName:                  v1.DefaultSchedulerName,
 	hardPodAffinitySymmetricWeight: v1.DefaultHardPodAffinitySymmetricWeight,
 	disablePreemption:              false,
 	percentageOfNodesToScore:       schedulerapi.DefaultPercentageOfNodesToScore,
 	bindTimeoutSeconds:             BindTimeoutSeconds,
+	topologyConfigFile:             topology.DefaultConfigFile,
+	monitoringConfigFile:           monitoring.DefaultConfigFile,
+	metricsRefreshPeriod:           customcache.DefaultRefreshPeriod,
 }
 
 // New returns a Scheduler
@@ -145,6 +203,11 @@ func New(client clientset.Interface,
 	for _, opt := range opts {
 		opt(&options)
 	}
+	metricsSource, degradation, err := initMetricsSource(options.monitoringConfigFile, options.schedulerName, recorder, stopCh)
+	if err != nil {
+		return nil, err
+	}
+	profileStore, profilesSynced := initProfiles(client, options.profileConfigMapNamespace, options.profileConfigMapName, stopCh)
 	// Set up the configurator which can create schedulers from configs.
 	configurator := factory.NewConfigFactory(&factory.ConfigFactoryArgs{
 		SchedulerName:                  options.schedulerName,
@@ -166,6 +229,11 @@ func New(client clientset.Interface,
 		Registry:                       registry,
 		Plugins:                        plugins,
 		PluginConfig:                   pluginConfig,
+		TopologyConfigFile:             options.topologyConfigFile,
+		MetricsSource:                  metricsSource,
+		MetricsRefreshPeriod:           options.metricsRefreshPeriod,
+		Degradation:                    degradation,
+		Profiles:                       profileStore,
 	})
 	var config *factory.Config
 	source := schedulerAlgorithmSource
@@ -201,7 +269,25 @@ func New(client clientset.Interface,
 	// Additional tweaks to the config produced by the configurator.
 	config.Recorder = recorder
 	config.DisablePreemption = options.disablePreemption
+	config.AnnotateDecisions = options.annotateDecisions
 	config.StopEverything = stopCh
+	if metricsSource != nil {
+		var persister profiles.Persister
+		if len(options.profileConfigMapName) != 0 {
+			persister = &profiles.ConfigMapPersister{
+				Client:    client,
+				Namespace: options.profileConfigMapNamespace,
+				Name:      options.profileConfigMapName,
+			}
+		}
+		config.Profiler = profiles.NewProfiler(profileStore, config.Topology, metricsSource, persister)
+	}
+	if profilesSynced != nil {
+		podsSynced := config.WaitForCacheSync
+		config.WaitForCacheSync = func() bool {
+			return podsSynced() && cache.WaitForCacheSync(stopCh, profilesSynced)
+		}
+	}
 
 	// Create the scheduler.
 	sched := NewFromConfig(config)
@@ -228,6 +314,68 @@ func initPolicyFromFile(policyFile string, policy *schedulerapi.Policy) error {
 	return nil
 }
 
+// readMonitoringConfig reads and validates the monitoring config file. It
+// returns nil, disabling the hardware-counter priorities, if the path is
+// empty or the default file is missing; any other file must be valid.
+func readMonitoringConfig(monitoringConfigFile string) (*monitoring.Config, error) {
+	if len(monitoringConfigFile) == 0 {
+		klog.Warningf("No monitoring config file, the hardware-counter priorities are disabled")
+		return nil, nil
+	}
+	if _, err := os.Stat(monitoringConfigFile); os.IsNotExist(err) && monitoringConfigFile == monitoring.DefaultConfigFile {
+		klog.Warningf("Missing monitoring config file %q, the hardware-counter priorities are disabled", monitoringConfigFile)
+		return nil, nil
+	}
+	return monitoring.ReadConfig(monitoringConfigFile)
+}
+
+// initMetricsSource connects to the monitoring database described by the
+// config file, behind a circuit breaker whose state changes are recorded as
+// events. It returns how the nodes are scored while the database is
+// unavailable. Without the file the hardware-counter priorities are disabled.
+func initMetricsSource(monitoringConfigFile, schedulerName string, recorder record.EventRecorder, stopCh <-chan struct{}) (monitoring.MetricsSource, *monitoring.Degradation, error) {
+	cfg, err := readMonitoringConfig(monitoringConfigFile)
+	if err != nil || cfg == nil {
+		return nil, nil, err
+	}
+	backend, err := monitoring.New(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't connect to the monitoring database: %v", err)
+	}
+	health := monitoring.NewHealthCheckedBackend(backend)
+	go health.Run(stopCh)
+
+	// The outages are not about any pod, the events refer to the scheduler.
+	ref := &v1.ObjectReference{Kind: "Scheduler", Namespace: metav1.NamespaceSystem, Name: schedulerName}
+	source := monitoring.NewCircuitBreaker(health, cfg.CircuitBreaker, func(state monitoring.BreakerState, err error) {
+		metrics.MonitoringCircuitBreakerState.Set(float64(state))
+		switch state {
+		case monitoring.BreakerOpen:
+			recorder.Eventf(ref, v1.EventTypeWarning, MonitoringUnavailable, "Stopped querying the monitoring backend for %v, scoring the nodes with %s: %v", cfg.CircuitBreaker.OpenTimeout, cfg.Degradation.Mode, err)
+		case monitoring.BreakerClosed:
+			recorder.Eventf(ref, v1.EventTypeNormal, MonitoringRecovered, "The monitoring backend is available again")
+		}
+	})
+	return source, &cfg.Degradation, nil
+}
+
+// initProfiles returns the store of the application profiles. If a ConfigMap
+// is given, the store is kept in sync with it by an informer, whose HasSynced
+// is returned.
+func initProfiles(client clientset.Interface, namespace, name string, stopCh <-chan struct{}) (*profiles.Store, cache.InformerSynced) {
+	store := profiles.NewStore()
+	if len(name) == 0 {
+		klog.V(2).Infof("No application profile ConfigMap, using the benchmark profiles")
+		return store, nil
+	}
+	informer := coreinformers.NewFilteredConfigMapInformer(client, namespace, 0, cache.Indexers{}, func(options *metav1.ListOptions) {
+		options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+	})
+	informer.AddEventHandler(store)
+	go informer.Run(stopCh)
+	return store, informer.HasSynced
+}
+
 // initPolicyFromConfigMap initialize policy from configMap
 func initPolicyFromConfigMap(client clientset.Interface, policyRef *kubeschedulerconfig.SchedulerPolicyConfigMapSource, policy *schedulerapi.Policy) error {
 	// Use a policy serialized in a config map value.
@@ -261,7 +409,12 @@ func (sched *Scheduler) Run() {
 	if !sched.config.WaitForCacheSync() {
 		return
 	}
-	//customcache.Timeout := time.NewTicker(time.Duration(10 * time.Second))
+	if sched.config.MetricsRefresher != nil {
+		go sched.config.MetricsRefresher.Run(sched.config.StopEverything)
+	}
+	if sched.config.Profiler != nil {
+		go sched.config.Profiler.Run(sched.config.StopEverything)
+	}
 	go wait.Until(sched.scheduleOne, 0, sched.config.StopEverything)
 }
 
@@ -290,7 +443,12 @@ func (sched *Scheduler) schedule(pod *v1.Pod) (core.ScheduleResult, error) {
 	result, err := sched.config.Algorithm.Schedule(pod, sched.config.NodeLister)
 	if err != nil {
 		pod = pod.DeepCopy()
-		sched.recordSchedulingFailure(pod, err, v1.PodReasonUnschedulable, err.Error())
+		reason := v1.PodReasonUnschedulable
+		if monitoring.IsUnavailable(err) {
+			// The FailClosed degradation mode.
+			reason = MonitoringUnavailable
+		}
+		sched.recordSchedulingFailure(pod, err, reason, err.Error())
 		return core.ScheduleResult{}, err
 	}
 	return result, err
@@ -378,6 +536,7 @@ func (sched *Scheduler) bindVolumes(assumed *v1.Pod) error {
 		if forgetErr := sched.config.SchedulerCache.ForgetPod(assumed); forgetErr != nil {
 			klog.Errorf("scheduler cache ForgetPod failed: %v", forgetErr)
 		}
+		sched.stopProfiling(assumed)
 
 		sched.recordSchedulingFailure(assumed, err, "VolumeBindingFailed", err.Error())
 		return err
@@ -412,10 +571,31 @@ func (sched *Scheduler) assume(assumed *v1.Pod, host string) error {
 	if sched.config.SchedulingQueue != nil {
 		sched.config.SchedulingQueue.DeleteNominatedPodIfExists(assumed)
 	}
+	if sched.config.Profiler != nil {
+		sched.config.Profiler.Bound(assumed, host)
+	}
 
 	return nil
 }
 
+// forgetAssumedLoad drops the expected load of a pod which was deleted, and
+// stops profiling it. The load of a pod whose binding failed is dropped by
+// the AssumedLoad plugin at Unreserve.
+func (sched *Scheduler) forgetAssumedLoad(pod *v1.Pod) {
+	if sched.config.MetricsCache != nil {
+		sched.config.MetricsCache.Forget(pod.UID)
+	}
+	sched.stopProfiling(pod)
+}
+
+// stopProfiling stops profiling a pod whose binding failed or which was
+// deleted.
+func (sched *Scheduler) stopProfiling(pod *v1.Pod) {
+	if sched.config.Profiler != nil {
+		sched.config.Profiler.Forget(pod.UID)
+	}
+}
+
 // bind binds a pod to a given node defined in a binding object.  We expect this to run asynchronously, so we
 // handle binding metrics internally.
 func (sched *Scheduler) bind(assumed *v1.Pod, b *v1.Binding) error {
@@ -431,6 +611,7 @@ func (sched *Scheduler) bind(assumed *v1.Pod, b *v1.Binding) error {
 		if err := sched.config.SchedulerCache.ForgetPod(assumed); err != nil {
 			klog.Errorf("scheduler cache ForgetPod failed: %v", err)
 		}
+		sched.stopProfiling(assumed)
 		sched.recordSchedulingFailure(assumed, err, SchedulerError,
 			fmt.Sprintf("Binding rejected: %v", err))
 		return err
@@ -444,30 +625,36 @@ func (sched *Scheduler) bind(assumed *v1.Pod, b *v1.Binding) error {
 	return nil
 }
 
+// recordExplanation emits the explanation of the scheduling decision of a
+// bound pod as an event.
+func (sched *Scheduler) recordExplanation(pod *v1.Pod, explanation *core.Explanation) {
+	if explanation == nil {
+		return
+	}
+	data, err := json.Marshal(explanation.Compact(explainedNodes))
+	if err != nil {
+		klog.Errorf("Unable to encode the explanation of the scheduling of pod %v/%v: %v", pod.Namespace, pod.Name, err)
+		return
+	}
+	sched.config.Recorder.Event(pod, v1.EventTypeNormal, "ScoringExplained", string(data))
+}
+
+// decisionAnnotations returns the annotations of the binding of a pod. The
+// apiserver copies them to the pod.
+func (sched *Scheduler) decisionAnnotations(explanation *core.Explanation) map[string]string {
+	if !sched.config.AnnotateDecisions || explanation == nil {
+		return nil
+	}
+	data, err := json.Marshal(explanation.Compact(explainedNodes))
+	if err != nil {
+		klog.Errorf("Unable to encode the explanation of a scheduling decision: %v", err)
+		return nil
+	}
+	return map[string]string{core.DecisionAnnotationKey: string(data)}
+}
+
 // scheduleOne does the entire scheduling workflow for a single pod.  It is serialized on the scheduling algorithm's host fitting.
 func (sched *Scheduler) scheduleOne() {
-
-	// // Check if the cache needs update
-	// select {
-	// // clean the cache if 10 seconds are passed
-	// case <-customcache.LabCache.Timeout.C:
-	// 	klog.Infof("Time to erase")
-	// 	customcache.LabCache.CleanCache()
-	// default:
-	// }
-
-	//Check if the cache needs update
-	//klog.Infof("The value of the Ticker: %v", customcache.LabCache.Timeout.C)
-	// select {
-	// // clean the cache if 10 seconds are passed
-	// case <-customcache.LabCache.Timeout.C:
-	// 	klog.Infof("Time to erase: %v", time.Now())
-	// 	//customcache.LabCache.Timeout.Stop()
-	// 	customcache.LabCache.CleanCache()
-	// 	klog.Infof("Cache: %v", customcache.LabCache.Cache)
-	// default:
-	// 	klog.Infof("Cache is Valid, Time: %v", customcache.LabCache.Timeout.C)
-	// }
 	fwk := sched.config.Framework
 
 	pod := sched.config.NextPod()
@@ -578,6 +765,7 @@ func (sched *Scheduler) scheduleOne() {
 			if forgetErr := sched.Cache().ForgetPod(assumedPod); forgetErr != nil {
 				klog.Errorf("scheduler cache ForgetPod failed: %v", forgetErr)
 			}
+			sched.stopProfiling(assumedPod)
 			sched.recordSchedulingFailure(assumedPod, permitStatus.AsError(), reason, permitStatus.Message())
 			// trigger un-reserve plugins to clean up state associated with the reserved Pod
 			fwk.RunUnreservePlugins(pluginContext, assumedPod, scheduleResult.SuggestedHost)
@@ -597,6 +785,7 @@ func (sched *Scheduler) scheduleOne() {
 			if forgetErr := sched.Cache().ForgetPod(assumedPod); forgetErr != nil {
 				klog.Errorf("scheduler cache ForgetPod failed: %v", forgetErr)
 			}
+			sched.stopProfiling(assumedPod)
 			sched.recordSchedulingFailure(assumedPod, prebindStatus.AsError(), reason, prebindStatus.Message())
 			// trigger un-reserve plugins to clean up state associated with the reserved Pod
 			fwk.RunUnreservePlugins(pluginContext, assumedPod, scheduleResult.SuggestedHost)
@@ -604,7 +793,12 @@ func (sched *Scheduler) scheduleOne() {
 		}
 
 		err := sched.bind(assumedPod, &v1.Binding{
-			ObjectMeta: metav1.ObjectMeta{Namespace: assumedPod.Namespace, Name: assumedPod.Name, UID: assumedPod.UID},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   assumedPod.Namespace,
+				Name:        assumedPod.Name,
+				UID:         assumedPod.UID,
+				Annotations: sched.decisionAnnotations(scheduleResult.Explanation),
+			},
 			Target: v1.ObjectReference{
 				Kind: "Node",
 				Name: scheduleResult.SuggestedHost,
@@ -620,6 +814,7 @@ func (sched *Scheduler) scheduleOne() {
 		} else {
 			klog.V(2).Infof("pod %v/%v is bound successfully on node %v, %d nodes evaluated, %d nodes were found feasible", assumedPod.Namespace, assumedPod.Name, scheduleResult.SuggestedHost, scheduleResult.EvaluatedNodes, scheduleResult.FeasibleNodes)
 			metrics.PodScheduleSuccesses.Inc()
+			sched.recordExplanation(assumedPod, scheduleResult.Explanation)
 
 			// Run "postbind" plugins.
 			fwk.RunPostbindPlugins(pluginContext, assumedPod, scheduleResult.SuggestedHost)
@@ -48,12 +48,14 @@ import (
 	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
 	kubeschedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
 	"k8s.io/kubernetes/pkg/scheduler/core"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
 	"k8s.io/kubernetes/pkg/scheduler/factory"
 	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
 	internalcache "k8s.io/kubernetes/pkg/scheduler/internal/cache"
 	fakecache "k8s.io/kubernetes/pkg/scheduler/internal/cache/fake"
 	internalqueue "k8s.io/kubernetes/pkg/scheduler/internal/queue"
 	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
 	"k8s.io/kubernetes/pkg/scheduler/volumebinder"
 )
 
@@ -341,6 +343,76 @@ func TestScheduler(t *testing.T) {
 	}
 }
 
+func TestSchedulerExplainsDecisions(t *testing.T) {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(t.Logf).Stop()
+	testNode := v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "machine1", UID: types.UID("machine1")}}
+	explanation := &core.Explanation{
+		Winner: testNode.Name,
+		Stages: []core.StageExplanation{{Name: "socket", Nodes: []core.NodeScore{{Node: testNode.Name, Score: 10}}}},
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	client := clientsetfake.NewSimpleClientset(&testNode)
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	nl := informerFactory.Core().V1().Nodes().Lister()
+	informerFactory.Start(stop)
+	informerFactory.WaitForCacheSync(stop)
+
+	for _, annotate := range []bool{false, true} {
+		t.Run(fmt.Sprintf("annotate %v", annotate), func(t *testing.T) {
+			var gotBinding *v1.Binding
+			s := NewFromConfig(&factory.Config{
+				SchedulerCache: &fakecache.Cache{
+					AssumeFunc: func(pod *v1.Pod) {},
+					ForgetFunc: func(pod *v1.Pod) {},
+				},
+				NodeLister: &nodeLister{nl},
+				Algorithm:  mockScheduler{core.ScheduleResult{SuggestedHost: testNode.Name, EvaluatedNodes: 1, FeasibleNodes: 1, Explanation: explanation}, nil},
+				GetBinder: func(pod *v1.Pod) factory.Binder {
+					return fakeBinder{func(b *v1.Binding) error {
+						gotBinding = b
+						return nil
+					}}
+				},
+				PodConditionUpdater: fakePodConditionUpdater{},
+				NextPod: func() *v1.Pod {
+					return podWithID("foo", "")
+				},
+				Framework:         EmptyFramework,
+				Recorder:          eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "scheduler"}),
+				VolumeBinder:      volumebinder.NewFakeVolumeBinder(&volumescheduling.FakeVolumeBinderConfig{AllBound: true}),
+				AnnotateDecisions: annotate,
+			})
+			explained := make(chan string, 1)
+			events := eventBroadcaster.StartEventWatcher(func(e *v1.Event) {
+				if e.Reason == "ScoringExplained" {
+					explained <- e.Message
+				}
+			})
+			defer events.Stop()
+			s.scheduleOne()
+
+			expected := `{"winner":"machine1","stages":[{"name":"socket","nodes":[{"node":"machine1","score":10}]}]}`
+			select {
+			case message := <-explained:
+				if message != expected {
+					t.Errorf("expected the explanation %s, got %s", expected, message)
+				}
+			case <-time.After(wait.ForeverTestTimeout):
+				t.Fatalf("timed out waiting for the explanation event")
+			}
+			if gotBinding == nil {
+				t.Fatalf("expected the pod to be bound")
+			}
+			if annotation, ok := gotBinding.Annotations[core.DecisionAnnotationKey]; ok != annotate || (annotate && annotation != expected) {
+				t.Errorf("expected annotation %v, got the annotations %v", annotate, gotBinding.Annotations)
+			}
+		})
+	}
+}
+
 func TestSchedulerNoPhantomPodAfterExpire(t *testing.T) {
 	stop := make(chan struct{})
 	defer close(stop)
@@ -356,6 +428,7 @@ func TestSchedulerNoPhantomPodAfterExpire(t *testing.T) {
 
 	waitPodExpireChan := make(chan struct{})
 	timeout := make(chan struct{})
+	errChan := make(chan error)
 	go func() {
 		for {
 			select {
@@ -365,7 +438,8 @@ func TestSchedulerNoPhantomPodAfterExpire(t *testing.T) {
 			}
 			pods, err := scache.List(labels.Everything())
 			if err != nil {
-				t.Fatalf("cache.List failed: %v", err)
+				errChan <- fmt.Errorf("cache.List failed: %v", err)
+				return
 			}
 			if len(pods) == 0 {
 				close(waitPodExpireChan)
@@ -377,6 +451,8 @@ func TestSchedulerNoPhantomPodAfterExpire(t *testing.T) {
 	// waiting for the assumed pod to expire
 	select {
 	case <-waitPodExpireChan:
+	case err := <-errChan:
+		t.Fatal(err)
 	case <-time.After(wait.ForeverTestTimeout):
 		close(timeout)
 		t.Fatalf("timeout timeout in waiting pod expire after %v", wait.ForeverTestTimeout)
@@ -662,6 +738,9 @@ func setupTestScheduler(queuedPodStore *clientcache.FIFO, scache internalcache.C
 		false,
 		schedulerapi.DefaultPercentageOfNodesToScore,
 		false,
+		topology.New(nil, nil),
+		customcache.New(customcache.DefaultTTL),
+		nil,
 	)
 	bindingChan := make(chan *v1.Binding, 1)
 	errChan := make(chan error, 1)
@@ -716,6 +795,9 @@ func setupTestSchedulerLongBindingWithRetry(queuedPodStore *clientcache.FIFO, sc
 		false,
 		schedulerapi.DefaultPercentageOfNodesToScore,
 		false,
+		topology.New(nil, nil),
+		customcache.New(customcache.DefaultTTL),
+		nil,
 	)
 	bindingChan := make(chan *v1.Binding, 2)
 
@@ -899,6 +981,10 @@ func TestSchedulerWithVolumeBinding(t *testing.T) {
 
 			eventChan := make(chan struct{})
 			events := eventBroadcaster.StartEventWatcher(func(e *v1.Event) {
+				// The bound pods get an explanation after the Scheduled event.
+				if e.Reason == "ScoringExplained" {
+					return
+				}
 				if e, a := item.eventReason, e.Reason; e != a {
 					t.Errorf("expected %v, got %v", e, a)
 				}
@@ -0,0 +1,464 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package simulator replays workload traces through the scheduling algorithm
+// on a virtual clock, so that scoring policies can be compared without
+// running the benchmarks on the testbed.
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/klog"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
+	// Registers the algorithm providers and their priorities.
+	_ "k8s.io/kubernetes/pkg/scheduler/algorithmprovider"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	schedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/core"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/factory"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
+	internalcache "k8s.io/kubernetes/pkg/scheduler/internal/cache"
+	internalqueue "k8s.io/kubernetes/pkg/scheduler/internal/queue"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring/synthetic"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	schedulertesting "k8s.io/kubernetes/pkg/scheduler/testing"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// Epoch is the virtual time a simulation starts at.
+var Epoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// minWork is the work left, in seconds in isolation, below which a pod has
+// finished, so that rounding errors do not delay it.
+const minWork = 1e-6
+
+// Config configures a simulation.
+type Config struct {
+	// Topology holds the servers and the nodes of the simulated cluster.
+	// Every node has a CPU per core, and every pod requests one.
+	Topology *topology.Topology
+	// Profiles are the profiles of the applications, the Benchmarks if nil.
+	// A pod runs for the duration of the profile of its application, slowed
+	// down by the pods it contends with.
+	Profiles profiles.Lister
+	// Policy is the scheduling policy, that of the DefaultProvider if nil.
+	Policy *schedulerapi.Policy
+	// MetricsTTL is how often the counters read by the priorities are
+	// refreshed from the model, customcache.DefaultTTL if zero.
+	MetricsTTL time.Duration
+	// Model synthesises the counters of the sockets and the slowdown of
+	// their pods, synthetic.DefaultContentionModel if nil.
+	Model synthetic.Model
+}
+
+// Placement is a pod of the trace and where it ran. The times are since the
+// start of the simulation.
+type Placement struct {
+	Pod         string        `json:"pod"`
+	Application string        `json:"application"`
+	Node        string        `json:"node"`
+	Server      string        `json:"server"`
+	Socket      int           `json:"socket"`
+	Arrival     time.Duration `json:"arrival"`
+	Start       time.Duration `json:"start"`
+	Finish      time.Duration `json:"finish"`
+}
+
+// SocketLoad is the load of a socket after the pods arriving or finishing at
+// a point in time were placed or removed.
+type SocketLoad struct {
+	At      time.Duration      `json:"at"`
+	Server  string             `json:"server"`
+	Socket  int                `json:"socket"`
+	Pods    int                `json:"pods"`
+	Metrics map[string]float64 `json:"metrics"`
+}
+
+// Result is the outcome of a simulation.
+type Result struct {
+	// Placements are the pods that ran, in the order they were scheduled.
+	Placements []Placement `json:"placements"`
+	// Sockets is the timeline of the load of every socket.
+	Sockets []SocketLoad `json:"sockets"`
+	// Unscheduled are the pods that did not fit on any node, even once every
+	// other pod had finished.
+	Unscheduled []string `json:"unscheduled,omitempty"`
+	// Makespan is the time from the start of the simulation to the finish
+	// of the last pod.
+	Makespan time.Duration `json:"makespan"`
+}
+
+// socketID identifies a socket of a server.
+type socketID struct {
+	server string
+	socket int
+}
+
+// pod is a pod of the simulation.
+type pod struct {
+	pod       *v1.Pod
+	profile   *profiles.ApplicationProfile
+	placement Placement
+	// remaining is the work left to the pod, in seconds in isolation.
+	remaining float64
+	// slowdown is how many times slower than in isolation the pod runs.
+	slowdown float64
+}
+
+// simulation is the state of a simulation.
+type simulation struct {
+	config       Config
+	clock        *clock.FakeClock
+	algorithm    core.ScheduleAlgorithm
+	nodeCache    internalcache.Cache
+	nodeLister   schedulertesting.FakeNodeLister
+	metricsCache *customcache.Cache
+	refresher    *customcache.Refresher
+	source       *synthetic.Source
+	sockets      []socketID
+	// nextRefresh is when the metrics cache is refreshed next.
+	nextRefresh time.Duration
+
+	pending []*pod
+	running []*pod
+	// placed are the pods that started, in the order they were scheduled.
+	placed []*pod
+}
+
+// Run replays trace through the scheduling algorithm of config. The pods are
+// scheduled in the order they arrive, as soon as they fit on a node. They
+// progress through the duration of their profile at the pace the model sets
+// for the pods running alongside them.
+func Run(config Config, trace []Arrival) (*Result, error) {
+	if config.Topology == nil || len(config.Topology.NodeNames()) == 0 {
+		return nil, fmt.Errorf("the topology has no nodes")
+	}
+	if config.Profiles == nil {
+		config.Profiles = profiles.NewStore()
+	}
+	if config.MetricsTTL == 0 {
+		config.MetricsTTL = customcache.DefaultTTL
+	}
+	if config.Model == nil {
+		config.Model = synthetic.DefaultContentionModel
+	}
+	for _, a := range trace {
+		if p, ok := config.Profiles.Get(a.Application); !ok || p.Duration <= 0 {
+			return nil, fmt.Errorf("application %s has no profile with a duration", a.Application)
+		}
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	s, err := newSimulation(config, stop)
+	if err != nil {
+		return nil, err
+	}
+	return s.run(trace)
+}
+
+func newSimulation(config Config, stop <-chan struct{}) (*simulation, error) {
+	s := &simulation{
+		config:    config,
+		clock:     clock.NewFakeClock(Epoch),
+		nodeCache: internalcache.New(time.Duration(0), stop),
+		source:    synthetic.NewSource(config.Topology, config.Model),
+	}
+	s.metricsCache = customcache.NewWithClock(config.MetricsTTL, s.clock)
+	s.refresher = customcache.NewRefresher(s.metricsCache, config.Topology, s.source, config.MetricsTTL)
+
+	seen := map[socketID]bool{}
+	nodes := map[string]*v1.Node{}
+	for _, name := range config.Topology.NodeNames() {
+		n, _ := config.Topology.Node(name)
+		node := &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: v1.NodeStatus{
+				Allocatable: v1.ResourceList{
+					v1.ResourceCPU:    *resource.NewQuantity(int64(len(n.Cores)), resource.DecimalSI),
+					v1.ResourceMemory: resource.MustParse("64Gi"),
+					v1.ResourcePods:   *resource.NewQuantity(110, resource.DecimalSI),
+				},
+				Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+			},
+		}
+		if err := s.nodeCache.AddNode(node); err != nil {
+			return nil, err
+		}
+		s.nodeLister = append(s.nodeLister, node)
+		nodes[name] = node
+		if id := (socketID{server: n.Server, socket: n.Socket}); !seen[id] {
+			seen[id] = true
+			s.sockets = append(s.sockets, id)
+		}
+	}
+	sort.Slice(s.sockets, func(i, j int) bool {
+		if s.sockets[i].server != s.sockets[j].server {
+			return s.sockets[i].server < s.sockets[j].server
+		}
+		return s.sockets[i].socket < s.sockets[j].socket
+	})
+
+	args := factory.PluginFactoryArgs{
+		PodLister:                      s.nodeCache,
+		ServiceLister:                  schedulertesting.FakeServiceLister([]*v1.Service{}),
+		ControllerLister:               schedulertesting.FakeControllerLister([]*v1.ReplicationController{}),
+		ReplicaSetLister:               schedulertesting.FakeReplicaSetLister{},
+		StatefulSetLister:              schedulertesting.FakeStatefulSetLister{},
+		NodeLister:                     s.nodeLister,
+		PDBLister:                      schedulertesting.FakePDBLister{},
+		NodeInfo:                       nodeInfo(nodes),
+		HardPodAffinitySymmetricWeight: v1.DefaultHardPodAffinitySymmetricWeight,
+		Topology:                       config.Topology,
+		MetricsCache:                   s.metricsCache,
+		Profiles:                       config.Profiles,
+	}
+	prioritizers, stages, priorityMetaProducer, err := factory.CreatePrioritizers(config.Policy, args)
+	if err != nil {
+		return nil, err
+	}
+	fwk, err := framework.NewFramework(framework.Registry{}, nil, []schedulerconfig.PluginConfig{})
+	if err != nil {
+		return nil, err
+	}
+	s.algorithm = core.NewGenericScheduler(
+		s.nodeCache,
+		internalqueue.NewSchedulingQueue(stop, fwk),
+		map[string]predicates.FitPredicate{predicates.PodFitsResourcesPred: predicates.PodFitsResources},
+		predicates.EmptyPredicateMetadataProducer,
+		prioritizers,
+		priorityMetaProducer,
+		fwk,
+		nil, nil, nil, nil, false, true,
+		schedulerapi.DefaultPercentageOfNodesToScore, false,
+		config.Topology, s.metricsCache, stages)
+	return s, nil
+}
+
+// run replays the trace until every pod has finished or is left pending with
+// nothing running.
+func (s *simulation) run(trace []Arrival) (*Result, error) {
+	result := &Result{}
+	next := 0
+	var last time.Duration
+	for next < len(trace) || len(s.running) > 0 {
+		now := s.nextEvent(trace, next, last)
+		s.progress(now - last)
+		last = now
+		s.clock.SetTime(Epoch.Add(now))
+		s.finish(now)
+		for ; next < len(trace) && trace[next].At <= now; next++ {
+			s.arrive(next, trace[next])
+		}
+		s.refresh(now)
+		if err := s.schedulePending(now); err != nil {
+			return nil, err
+		}
+		s.updateSlowdowns()
+		result.Sockets = append(result.Sockets, s.sample(now)...)
+	}
+	for _, p := range s.placed {
+		result.Placements = append(result.Placements, p.placement)
+		if p.placement.Finish > result.Makespan {
+			result.Makespan = p.placement.Finish
+		}
+	}
+	for _, p := range s.pending {
+		result.Unscheduled = append(result.Unscheduled, p.pod.Name)
+	}
+	return result, nil
+}
+
+// nextEvent returns the time of the next arrival or completion, the current
+// time being now.
+func (s *simulation) nextEvent(trace []Arrival, next int, now time.Duration) time.Duration {
+	var at time.Duration
+	found := false
+	if next < len(trace) {
+		at, found = trace[next].At, true
+	}
+	for _, p := range s.running {
+		finish := now + time.Duration(math.Ceil(p.remaining*p.slowdown*float64(time.Second)))
+		if !found || finish < at {
+			at, found = finish, true
+		}
+	}
+	return at
+}
+
+// progress advances the running pods by elapsed.
+func (s *simulation) progress(elapsed time.Duration) {
+	for _, p := range s.running {
+		p.remaining -= elapsed.Seconds() / p.slowdown
+	}
+}
+
+// finish removes the pods done by now from the cluster.
+func (s *simulation) finish(now time.Duration) {
+	running := s.running[:0]
+	for _, p := range s.running {
+		if p.remaining > minWork {
+			running = append(running, p)
+			continue
+		}
+		if err := s.nodeCache.RemovePod(p.pod); err != nil {
+			klog.Errorf("Unable to remove pod %v from the cache: %v", p.pod.Name, err)
+		}
+		s.metricsCache.Forget(p.pod.UID)
+		s.source.Remove(p.placement.Node, p.pod.UID)
+		p.placement.Finish = now
+		klog.V(2).Infof("Pod %v finished on node %v at %v", p.pod.Name, p.placement.Node, now)
+	}
+	s.running = running
+}
+
+// updateSlowdowns sets the pace of the running pods for the pods running
+// alongside them.
+func (s *simulation) updateSlowdowns() {
+	slowdowns := map[types.UID]float64{}
+	for _, id := range s.sockets {
+		for uid, slowdown := range s.source.Slowdowns(id.server, id.socket) {
+			slowdowns[uid] = slowdown
+		}
+	}
+	for _, p := range s.running {
+		p.slowdown = 1
+		if slowdown, ok := slowdowns[p.pod.UID]; ok && slowdown > 0 {
+			p.slowdown = slowdown
+		}
+	}
+}
+
+// arrive queues the i-th pod of the trace.
+func (s *simulation) arrive(i int, a Arrival) {
+	profile, _ := s.config.Profiles.Get(a.Application)
+	name := fmt.Sprintf("%s-%d", a.Application, i)
+	s.pending = append(s.pending, &pod{
+		pod: &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: metav1.NamespaceDefault,
+				UID:       types.UID(name),
+				Labels:    map[string]string{profiles.ApplicationKey: a.Application},
+			},
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{{
+					Name: a.Application,
+					Resources: v1.ResourceRequirements{
+						Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+					},
+				}},
+			},
+		},
+		profile:   profile,
+		placement: Placement{Pod: name, Application: a.Application, Arrival: a.At},
+		remaining: profile.Duration.Seconds(),
+		slowdown:  1,
+	})
+}
+
+// refresh reads the counters of the model into the metrics cache, as the
+// refresher of the scheduler does every MetricsTTL.
+func (s *simulation) refresh(now time.Duration) {
+	if now < s.nextRefresh {
+		return
+	}
+	if err := s.refresher.Refresh(); err != nil {
+		klog.Errorf("Unable to refresh the metrics at %v: %v", now, err)
+	}
+	s.nextRefresh = now + s.config.MetricsTTL
+}
+
+// schedulePending schedules the pending pods in the order they arrived. The
+// pods that do not fit stay pending until a pod finishes.
+func (s *simulation) schedulePending(now time.Duration) error {
+	pending := s.pending[:0]
+	for _, p := range s.pending {
+		result, err := s.algorithm.Schedule(p.pod, s.nodeLister)
+		if err != nil {
+			if _, ok := err.(*core.FitError); ok {
+				pending = append(pending, p)
+				continue
+			}
+			return fmt.Errorf("unable to schedule pod %v: %v", p.pod.Name, err)
+		}
+		s.bind(p, result.SuggestedHost, now)
+	}
+	s.pending = pending
+	return nil
+}
+
+// bind starts a pod on host, accounting for it as the scheduler does once
+// it has assumed the pod.
+func (s *simulation) bind(p *pod, host string, now time.Duration) {
+	p.pod.Spec.NodeName = host
+	if err := s.nodeCache.AddPod(p.pod); err != nil {
+		klog.Errorf("Unable to add pod %v to the cache: %v", p.pod.Name, err)
+	}
+	s.metricsCache.Assume(p.pod.UID, host, p.profile.Metrics, s.config.Topology)
+	s.source.Add(host, p.pod.UID, p.profile.Metrics)
+
+	p.placement.Node = host
+	if n, ok := s.config.Topology.Node(host); ok {
+		p.placement.Server, p.placement.Socket = n.Server, n.Socket
+	}
+	p.placement.Start = now
+	s.running = append(s.running, p)
+	s.placed = append(s.placed, p)
+	klog.V(2).Infof("Pod %v started on node %v at %v", p.pod.Name, host, now)
+}
+
+// sample returns the load of every socket.
+func (s *simulation) sample(now time.Duration) []SocketLoad {
+	loads := make([]SocketLoad, 0, len(s.sockets))
+	for _, id := range s.sockets {
+		load := SocketLoad{At: now, Server: id.server, Socket: id.socket}
+		var cores []int
+		for _, name := range s.config.Topology.SocketNodes(id.server, id.socket) {
+			load.Pods += s.source.Pods(name)
+			if n, ok := s.config.Topology.Node(name); ok {
+				cores = append(cores, n.Cores...)
+			}
+		}
+		load.Metrics, _ = s.source.SocketMetrics(id.server, id.socket, customcache.SocketMetrics, 0)
+		if c6, err := s.source.CoreMetrics(id.server, id.socket, cores, []string{"c6res"}, 0); err == nil && load.Metrics != nil {
+			load.Metrics["c6res"] = c6["c6res"]
+		}
+		loads = append(loads, load)
+	}
+	return loads
+}
+
+// nodeInfo looks up the nodes of the simulated cluster.
+type nodeInfo map[string]*v1.Node
+
+func (n nodeInfo) GetNodeInfo(name string) (*v1.Node, error) {
+	node, ok := n[name]
+	if !ok {
+		return nil, fmt.Errorf("node %s not found", name)
+	}
+	return node, nil
+}
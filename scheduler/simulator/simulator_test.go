@@ -0,0 +1,179 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/scheduler/monitoring/synthetic"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+// testConfig is a server with two sockets of two nodes of two cores, running
+// a memory-bound and a compute-bound application. The pods contend only for
+// the cores, so that they run for the duration of their profile.
+func testConfig() Config {
+	store := profiles.NewStore()
+	store.Load(&profiles.Config{Applications: map[string]*profiles.ApplicationProfile{
+		"membound": {
+			Metrics:  map[string]float64{"ipc": 0.8, "mem_read": 1, "mem_write": 0.5, "c6res": 0},
+			Duration: 10 * time.Second,
+		},
+		"cpubound": {
+			Metrics:  map[string]float64{"ipc": 2, "mem_read": 0.01, "mem_write": 0.005, "c6res": 0},
+			Duration: 20 * time.Second,
+		},
+	}})
+	topo := topology.New(
+		[]topology.Server{{UUID: "server-a", Links: 2, LinkSpeed: 10, MaxFrequency: 2}},
+		[]topology.Node{
+			{Name: "kube-01", Server: "server-a", Socket: 0, Cores: []int{0, 1}},
+			{Name: "kube-02", Server: "server-a", Socket: 0, Cores: []int{2, 3}},
+			{Name: "kube-03", Server: "server-a", Socket: 1, Cores: []int{4, 5}},
+			{Name: "kube-04", Server: "server-a", Socket: 1, Cores: []int{6, 7}},
+		},
+	)
+	return Config{Topology: topo, Profiles: store, Model: &synthetic.ContentionModel{Idle: synthetic.DefaultIdleMetrics}}
+}
+
+func TestParseTrace(t *testing.T) {
+	trace, err := ParseTrace(strings.NewReader("# arrival application\n5 cpubound\n0 membound\n\n0.5 cpubound\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Arrival{
+		{At: 0, Application: "membound"},
+		{At: 500 * time.Millisecond, Application: "cpubound"},
+		{At: 5 * time.Second, Application: "cpubound"},
+	}
+	if !reflect.DeepEqual(expected, trace) {
+		t.Errorf("expected %+v, got %+v", expected, trace)
+	}
+
+	for _, input := range []string{"cpubound", "x cpubound", "-1 cpubound", "1 cpubound 2"} {
+		if _, err := ParseTrace(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestRunSpreadsMemoryBoundPods(t *testing.T) {
+	result, err := Run(testConfig(), []Arrival{{At: 0, Application: "membound"}, {At: time.Second, Application: "membound"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Placements) != 2 {
+		t.Fatalf("expected 2 placements, got %+v", result.Placements)
+	}
+	if result.Placements[0].Socket == result.Placements[1].Socket {
+		t.Errorf("expected the memory-bound pods on different sockets, got %+v", result.Placements)
+	}
+	if result.Makespan != 11*time.Second {
+		t.Errorf("expected a makespan of 11s, got %v", result.Makespan)
+	}
+	// A sample per socket at each arrival and completion.
+	if len(result.Sockets) != 2*4 {
+		t.Errorf("expected 8 socket samples, got %d", len(result.Sockets))
+	}
+	for _, load := range result.Sockets[len(result.Sockets)-2:] {
+		if load.Pods != 0 || load.Metrics["c6res"] != 1 {
+			t.Errorf("expected the sockets to be idle at the end, got %+v", load)
+		}
+	}
+}
+
+func TestRunQueuesPodsUntilCoresAreFree(t *testing.T) {
+	var trace []Arrival
+	for i := 0; i < 9; i++ {
+		trace = append(trace, Arrival{At: 0, Application: "cpubound"})
+	}
+	result, err := Run(testConfig(), trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Placements) != 9 || len(result.Unscheduled) != 0 {
+		t.Fatalf("expected every pod to run, got %+v, unscheduled %v", result.Placements, result.Unscheduled)
+	}
+	perNode := map[string]int{}
+	for _, p := range result.Placements[:8] {
+		perNode[p.Node]++
+		if p.Start != 0 || p.Finish != 20*time.Second {
+			t.Errorf("expected %v to run from 0s to 20s, got %v to %v", p.Pod, p.Start, p.Finish)
+		}
+	}
+	for node, pods := range perNode {
+		if pods != 2 {
+			t.Errorf("expected a pod per core of %v, got %d pods", node, pods)
+		}
+	}
+	if last := result.Placements[8]; last.Start != 20*time.Second || last.Arrival != 0 {
+		t.Errorf("expected the ninth pod to wait for a free core, got %+v", last)
+	}
+	if result.Makespan != 40*time.Second {
+		t.Errorf("expected a makespan of 40s, got %v", result.Makespan)
+	}
+}
+
+func TestRunContentionSlowsDownPods(t *testing.T) {
+	config := testConfig()
+	config.Model = synthetic.DefaultContentionModel
+	config.Topology = topology.New(
+		[]topology.Server{{UUID: "server-a", Links: 2, LinkSpeed: 10, MaxFrequency: 2}},
+		[]topology.Node{{Name: "kube-01", Server: "server-a", Socket: 0, Cores: []int{0, 1}}},
+	)
+
+	alone, err := Run(config, []Arrival{{At: 0, Application: "membound"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	together, err := Run(config, []Arrival{{At: 0, Application: "membound"}, {At: 0, Application: "membound"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alone.Makespan < 10*time.Second {
+		t.Errorf("expected a pod to run for at least its profile duration, got %v", alone.Makespan)
+	}
+	if together.Makespan <= alone.Makespan {
+		t.Errorf("expected two memory-bound pods on a socket to run slower than one, got %v and %v", together.Makespan, alone.Makespan)
+	}
+	if first, second := together.Placements[0], together.Placements[1]; first.Finish != second.Finish {
+		t.Errorf("expected identical pods to finish together, got %+v", together.Placements)
+	}
+
+	// The pace of the first pod picks up once the second one is done.
+	staggered, err := Run(config, []Arrival{{At: 0, Application: "membound"}, {At: 5 * time.Second, Application: "membound"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := staggered.Placements[0], staggered.Placements[1]
+	if first.Finish >= second.Finish || first.Finish <= alone.Makespan {
+		t.Errorf("expected the first pod to be slowed down by the second, got %+v", staggered.Placements)
+	}
+	if second.Finish-second.Start >= together.Makespan {
+		t.Errorf("expected the second pod to speed up once alone, got %+v", second)
+	}
+}
+
+func TestRunUnknownApplication(t *testing.T) {
+	if _, err := Run(testConfig(), []Arrival{{Application: "unknown"}}); err == nil {
+		t.Errorf("expected an error for an application without a profile")
+	}
+}
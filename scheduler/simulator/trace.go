@@ -0,0 +1,66 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Arrival is a pod of a workload trace.
+type Arrival struct {
+	// At is the time the pod is created, since the start of the trace.
+	At time.Duration
+	// Application is the name of the profile of the application the pod
+	// runs.
+	Application string
+}
+
+// ParseTrace reads a workload trace, a pod per line as
+//
+//	<arrival in seconds> <application>
+//
+// Empty lines and lines starting with # are ignored. The arrivals are
+// returned sorted by time, pods arriving together in the order of the trace.
+func ParseTrace(r io.Reader) ([]Arrival, error) {
+	var trace []Arrival
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected <arrival> <application>, got %d fields", line, len(fields))
+		}
+		seconds, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("line %d: invalid arrival %q", line, fields[0])
+		}
+		trace = append(trace, Arrival{At: time.Duration(seconds * float64(time.Second)), Application: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read the trace: %v", err)
+	}
+	sort.SliceStable(trace, func(i, j int) bool { return trace[i].At < trace[j].At })
+	return trace, nil
+}
@@ -0,0 +1,377 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package topology
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm"
+)
+
+const (
+	// DefaultConfigFile is the default location of the topology file.
+	DefaultConfigFile = "/etc/kubernetes/scheduler-topology.yaml"
+
+	// ServerUUIDKey is the node label or annotation holding the UUID of the
+	// server that hosts the node.
+	ServerUUIDKey = "topology.kube-scheduler/server-uuid"
+	// SocketKey is the node label or annotation holding the socket id the
+	// node is pinned on.
+	SocketKey = "topology.kube-scheduler/socket"
+	// NUMAKey is the node label or annotation holding the id of the sub-NUMA
+	// cluster of the socket the node is pinned on.
+	NUMAKey = "topology.kube-scheduler/numa"
+	// CPUSetKey is the node annotation holding the cpuset (e.g. "20-23,36-39")
+	// of the cores assigned to the node.
+	CPUSetKey = "topology.kube-scheduler/cpuset"
+
+	// RackKey, LinksKey, LinkSpeedKey, MaxFrequencyKey and MemoryBandwidthKey
+	// are the node labels or annotations describing the server that hosts the
+	// node, with the meaning of the Server fields of the same name. They
+	// override the description of the server in the file, if any.
+	RackKey            = "topology.kube-scheduler/rack"
+	LinksKey           = "topology.kube-scheduler/links"
+	LinkSpeedKey       = "topology.kube-scheduler/link-speed"
+	MaxFrequencyKey    = "topology.kube-scheduler/max-frequency"
+	MemoryBandwidthKey = "topology.kube-scheduler/memory-bandwidth"
+
+	// reloadPeriod is how often the sources are checked for changes.
+	reloadPeriod = 10 * time.Second
+)
+
+// Config is the on-disk representation of the topology.
+type Config struct {
+	Servers []Server     `yaml:"servers"`
+	Nodes   []NodeConfig `yaml:"nodes"`
+}
+
+// NodeConfig is the on-disk representation of a Node.
+type NodeConfig struct {
+	Name   string `yaml:"name"`
+	Server string `yaml:"server"`
+	Socket int    `yaml:"socket"`
+	NUMA   int    `yaml:"numa"`
+	CPUSet string `yaml:"cpuset"`
+}
+
+// Parse decodes and validates a topology file.
+func Parse(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("unable to decode the topology: %v", err)
+	}
+	servers := make(map[string]bool, len(cfg.Servers))
+	for _, s := range cfg.Servers {
+		if len(s.UUID) == 0 {
+			return nil, fmt.Errorf("server without uuid in topology")
+		}
+		servers[s.UUID] = true
+	}
+	for _, n := range cfg.Nodes {
+		if len(n.Name) == 0 {
+			return nil, fmt.Errorf("node without name in topology")
+		}
+		if !servers[n.Server] {
+			return nil, fmt.Errorf("node %s refers to unknown server %q", n.Name, n.Server)
+		}
+		if _, err := parseCores(n.CPUSet); err != nil {
+			return nil, fmt.Errorf("node %s: %v", n.Name, err)
+		}
+	}
+	return cfg, nil
+}
+
+// FromConfig returns the Topology of a parsed topology file, without node
+// metadata.
+func FromConfig(cfg *Config) (*Topology, error) {
+	return build(cfg, nil)
+}
+
+// Source is a topology that is built from a YAML file and, optionally, from
+// node labels and annotations. Node metadata takes precedence over the file,
+// and the servers that are not in the file are described by the metadata of
+// their nodes. Run keeps it up to date with both.
+type Source struct {
+	path       string
+	nodeLister algorithm.NodeLister
+
+	mu      sync.RWMutex
+	current *Topology
+	// modTime is the modification time of the file last loaded.
+	modTime time.Time
+	// fileConfig is the configuration last loaded from the file.
+	fileConfig *Config
+}
+
+var _ Interface = &Source{}
+
+// NewSource returns a Source reading the file at path and the metadata of the
+// nodes listed by nodeLister. Either may be empty and the file may not exist.
+// The topology is loaded before returning.
+func NewSource(path string, nodeLister algorithm.NodeLister) (*Source, error) {
+	s := &Source{
+		path:       path,
+		nodeLister: nodeLister,
+		current:    New(nil, nil),
+		fileConfig: &Config{},
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Run reloads the topology periodically until stopCh is closed.
+func (s *Source) Run(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		if err := s.Reload(); err != nil {
+			klog.Errorf("Unable to reload the topology: %v", err)
+		}
+	}, reloadPeriod, stopCh)
+}
+
+// Reload rebuilds the topology if the file or the node metadata changed.
+// On error the previous topology is kept.
+func (s *Source) Reload() error {
+	fileConfig, err := s.readFile()
+	if err != nil {
+		return err
+	}
+	var nodes []*v1.Node
+	if s.nodeLister != nil {
+		if nodes, err = s.nodeLister.List(); err != nil {
+			return err
+		}
+	}
+	t, err := build(fileConfig, nodes)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fileConfig = fileConfig
+	if !reflect.DeepEqual(s.current, t) {
+		klog.V(2).Infof("Topology updated: %d servers, %d nodes", len(t.servers), len(t.nodes))
+		s.current = t
+	}
+	return nil
+}
+
+// readFile returns the file configuration, re-reading it only when the file
+// has been modified since the last load.
+func (s *Source) readFile() (*Config, error) {
+	if len(s.path) == 0 {
+		return &Config{}, nil
+	}
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		// The file is optional, nodes may be described by their metadata only.
+		klog.V(4).Infof("Topology file %s does not exist", s.path)
+		s.mu.Lock()
+		s.modTime = time.Time{}
+		s.mu.Unlock()
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't stat topology file: %v", err)
+	}
+	s.mu.RLock()
+	cached, modTime := s.fileConfig, s.modTime
+	s.mu.RUnlock()
+	if !modTime.IsZero() && info.ModTime().Equal(modTime) {
+		return cached, nil
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read topology file: %v", err)
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+	return cfg, nil
+}
+
+// build merges the file configuration with the node metadata.
+func build(cfg *Config, nodes []*v1.Node) (*Topology, error) {
+	servers := make([]Server, len(cfg.Servers), len(cfg.Servers)+len(nodes))
+	copy(servers, cfg.Servers)
+	serverIndex := make(map[string]int, len(cfg.Servers))
+	for i, s := range servers {
+		serverIndex[s.UUID] = i
+	}
+	placements := make([]Node, 0, len(cfg.Nodes)+len(nodes))
+	index := make(map[string]int, len(cfg.Nodes))
+	for _, n := range cfg.Nodes {
+		cores, err := parseCores(n.CPUSet)
+		if err != nil {
+			return nil, err
+		}
+		index[n.Name] = len(placements)
+		placements = append(placements, Node{Name: n.Name, Server: n.Server, Socket: n.Socket, NUMA: n.NUMA, Cores: cores})
+	}
+	for _, node := range nodes {
+		p, ok, err := nodeFromMetadata(node)
+		if err != nil {
+			klog.Warningf("Ignoring the topology metadata of node %s: %v", node.Name, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		server := Server{UUID: p.Server}
+		s, found := serverIndex[p.Server]
+		if found {
+			server = servers[s]
+		}
+		if err := serverFromMetadata(node, &server); err != nil {
+			klog.Warningf("Ignoring the topology metadata of node %s: %v", node.Name, err)
+			continue
+		}
+		if found {
+			servers[s] = server
+		} else {
+			serverIndex[p.Server] = len(servers)
+			servers = append(servers, server)
+		}
+		if i, found := index[p.Name]; found {
+			placements[i] = p
+		} else {
+			index[p.Name] = len(placements)
+			placements = append(placements, p)
+		}
+	}
+	return New(servers, placements), nil
+}
+
+// nodeFromMetadata reads the placement of a node from its annotations or
+// labels. It returns false if the node carries no topology metadata.
+func nodeFromMetadata(node *v1.Node) (Node, bool, error) {
+	server, ok := metadataValue(node, ServerUUIDKey)
+	if !ok {
+		return Node{}, false, nil
+	}
+	p := Node{Name: node.Name, Server: server}
+	if socket, ok := metadataValue(node, SocketKey); ok {
+		id, err := strconv.Atoi(socket)
+		if err != nil {
+			return Node{}, false, fmt.Errorf("invalid socket %q", socket)
+		}
+		p.Socket = id
+	}
+	if numa, ok := metadataValue(node, NUMAKey); ok {
+		id, err := strconv.Atoi(numa)
+		if err != nil {
+			return Node{}, false, fmt.Errorf("invalid NUMA cluster %q", numa)
+		}
+		p.NUMA = id
+	}
+	if set, ok := metadataValue(node, CPUSetKey); ok {
+		cores, err := parseCores(set)
+		if err != nil {
+			return Node{}, false, err
+		}
+		p.Cores = cores
+	}
+	return p, true, nil
+}
+
+// serverFromMetadata sets the attributes of server found in the annotations
+// or labels of a node it hosts.
+func serverFromMetadata(node *v1.Node, server *Server) error {
+	if rack, ok := metadataValue(node, RackKey); ok {
+		server.Rack = rack
+	}
+	if links, ok := metadataValue(node, LinksKey); ok {
+		n, err := strconv.Atoi(links)
+		if err != nil {
+			return fmt.Errorf("invalid number of links %q", links)
+		}
+		server.Links = n
+	}
+	for key, field := range map[string]*float32{
+		LinkSpeedKey:       &server.LinkSpeed,
+		MaxFrequencyKey:    &server.MaxFrequency,
+		MemoryBandwidthKey: &server.MemoryBandwidth,
+	} {
+		value, ok := metadataValue(node, key)
+		if !ok {
+			continue
+		}
+		f, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", key, value)
+		}
+		*field = float32(f)
+	}
+	return nil
+}
+
+func metadataValue(node *v1.Node, key string) (string, bool) {
+	if v, ok := node.Annotations[key]; ok {
+		return v, true
+	}
+	v, ok := node.Labels[key]
+	return v, ok
+}
+
+func (s *Source) snapshot() *Topology {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Node returns the placement of the given kube node.
+func (s *Source) Node(name string) (*Node, bool) {
+	return s.snapshot().Node(name)
+}
+
+// Server returns the description of the server with the given UUID.
+func (s *Source) Server(uuid string) (*Server, bool) {
+	return s.snapshot().Server(uuid)
+}
+
+// SocketNodes returns the names of all kube nodes pinned on the given socket
+// of the given server, sorted by name.
+func (s *Source) SocketNodes(server string, socket int) []string {
+	return s.snapshot().SocketNodes(server, socket)
+}
+
+// NodeNames returns the names of all known kube nodes, sorted.
+func (s *Source) NodeNames() []string {
+	return s.snapshot().NodeNames()
+}
+
+// Tree returns the topology as a tree of racks, servers, sockets, NUMA
+// clusters and kube nodes.
+func (s *Source) Tree() *Tree {
+	return s.snapshot().Tree()
+}
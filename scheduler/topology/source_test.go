@@ -0,0 +1,285 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package topology
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	schedulertesting "k8s.io/kubernetes/pkg/scheduler/testing"
+)
+
+const (
+	testServer1 = "e77467ad-636e-4e7e-8bc9-53e46ae51da1"
+	testServer2 = "c4766d29-4dc1-11ea-9d98-0242ac110002"
+)
+
+func TestSourceFromFile(t *testing.T) {
+	s, err := NewSource("testdata/topology.yaml", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := s.NodeNames(), []string{"kube-01", "kube-02", "kube-03", "kube-04", "kube-05", "kube-06", "kube-07", "kube-08"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NodeNames() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name     string
+		node     string
+		expected *Node
+	}{
+		{
+			name:     "single range",
+			node:     "kube-01",
+			expected: &Node{Name: "kube-01", Server: testServer1, Socket: 1, Cores: []int{20, 21, 22, 23}},
+		},
+		{
+			name: "multiple ranges",
+			node: "kube-08",
+			expected: &Node{Name: "kube-08", Server: testServer2, Socket: 1,
+				Cores: []int{20, 21, 22, 23, 36, 37, 38, 39, 40, 41, 42, 43, 44, 45, 46, 47}},
+		},
+		{
+			name: "unknown node",
+			node: "kube-09",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n, ok := s.Node(test.node)
+			if ok != (test.expected != nil) {
+				t.Fatalf("Node(%s) found = %v, want %v", test.node, ok, test.expected != nil)
+			}
+			if ok && !reflect.DeepEqual(n, test.expected) {
+				t.Errorf("Node(%s) = %+v, want %+v", test.node, n, test.expected)
+			}
+		})
+	}
+
+	server, ok := s.Server(testServer1)
+	if !ok {
+		t.Fatalf("server %s not found", testServer1)
+	}
+	if server.Links != 3 || server.LinkSpeed != 10.4 || server.MaxFrequency != 2.2 {
+		t.Errorf("unexpected server %+v", server)
+	}
+	if got, want := s.SocketNodes(testServer2, 0), []string{"kube-05", "kube-07"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SocketNodes() = %v, want %v", got, want)
+	}
+}
+
+func TestSourceNodeMetadata(t *testing.T) {
+	nodes := schedulertesting.FakeNodeLister{
+		// Overrides the placement of kube-01 from the file.
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name: "kube-01",
+			Annotations: map[string]string{
+				ServerUUIDKey: testServer1,
+				SocketKey:     "0",
+				CPUSetKey:     "10-11",
+			},
+		}},
+		// A node that is not in the file, described by labels.
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name: "kube-09",
+			Labels: map[string]string{
+				ServerUUIDKey: testServer2,
+				SocketKey:     "1",
+				NUMAKey:       "1",
+			},
+			Annotations: map[string]string{
+				CPUSetKey: "48,49",
+			},
+		}},
+		// Invalid metadata is ignored.
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name: "kube-10",
+			Annotations: map[string]string{
+				ServerUUIDKey: testServer2,
+				SocketKey:     "first",
+			},
+		}},
+		// A node of a server that is not in the file, described by labels.
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name: "kube-12",
+			Labels: map[string]string{
+				ServerUUIDKey:      "server-c",
+				SocketKey:          "1",
+				CPUSetKey:          "0-3",
+				RackKey:            "rack-1",
+				LinksKey:           "2",
+				LinkSpeedKey:       "9.6",
+				MaxFrequencyKey:    "2.5",
+				MemoryBandwidthKey: "60",
+			},
+		}},
+		// Invalid server metadata is ignored.
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name: "kube-13",
+			Labels: map[string]string{
+				ServerUUIDKey: "server-d",
+				LinksKey:      "two",
+			},
+		}},
+		// Nodes without metadata are ignored.
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "kube-11"}},
+	}
+	s, err := NewSource("testdata/topology.yaml", nodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]*Node{
+		"kube-01": {Name: "kube-01", Server: testServer1, Socket: 0, Cores: []int{10, 11}},
+		"kube-09": {Name: "kube-09", Server: testServer2, Socket: 1, NUMA: 1, Cores: []int{48, 49}},
+		"kube-12": {Name: "kube-12", Server: "server-c", Socket: 1, Cores: []int{0, 1, 2, 3}},
+	}
+	for name, want := range expected {
+		if got, _ := s.Node(name); !reflect.DeepEqual(got, want) {
+			t.Errorf("Node(%s) = %+v, want %+v", name, got, want)
+		}
+	}
+	for _, name := range []string{"kube-10", "kube-11", "kube-13"} {
+		if _, ok := s.Node(name); ok {
+			t.Errorf("expected node %s to be ignored", name)
+		}
+	}
+	if server, _ := s.Server("server-c"); !reflect.DeepEqual(server, &Server{UUID: "server-c", Rack: "rack-1", Links: 2, LinkSpeed: 9.6, MaxFrequency: 2.5, MemoryBandwidth: 60}) {
+		t.Errorf("unexpected server %+v", server)
+	}
+	if _, ok := s.Server("server-d"); ok {
+		t.Errorf("expected the server of an ignored node to be unknown")
+	}
+	// The servers in the file are kept.
+	if server, _ := s.Server(testServer1); server == nil || server.Links != 3 {
+		t.Errorf("unexpected server %+v", server)
+	}
+	if got, want := s.SocketNodes(testServer1, 0), []string{"kube-01", "kube-02", "kube-03"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SocketNodes() = %v, want %v", got, want)
+	}
+}
+
+func TestSourceReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "topology")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "topology.yaml")
+
+	write := func(content string, modTime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	write(`
+servers:
+- uuid: server-a
+nodes:
+- name: node-a
+  server: server-a
+  cpuset: "0-1"
+`, now)
+
+	s, err := NewSource(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := s.NodeNames(), []string{"node-a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("NodeNames() = %v, want %v", got, want)
+	}
+
+	// An invalid file keeps the previous topology.
+	write("nodes:\n- name: node-b\n  server: unknown\n", now.Add(time.Second))
+	if err := s.Reload(); err == nil {
+		t.Errorf("expected an error for a node with an unknown server")
+	}
+	if got, want := s.NodeNames(), []string{"node-a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NodeNames() = %v, want %v", got, want)
+	}
+
+	write(`
+servers:
+- uuid: server-a
+nodes:
+- name: node-a
+  server: server-a
+  cpuset: "0-1"
+- name: node-b
+  server: server-a
+  socket: 1
+  cpuset: "2-3"
+`, now.Add(2*time.Second))
+	if err := s.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := s.NodeNames(), []string{"node-a", "node-b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NodeNames() = %v, want %v", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		expectErr bool
+	}{
+		{
+			name: "valid",
+			data: "servers:\n- uuid: a\nnodes:\n- name: n\n  server: a\n  cpuset: 0-3\n",
+		},
+		{
+			name:      "server without uuid",
+			data:      "servers:\n- links: 2\n",
+			expectErr: true,
+		},
+		{
+			name:      "node without name",
+			data:      "servers:\n- uuid: a\nnodes:\n- server: a\n",
+			expectErr: true,
+		},
+		{
+			name:      "invalid cpuset",
+			data:      "servers:\n- uuid: a\nnodes:\n- name: n\n  server: a\n  cpuset: 3-a\n",
+			expectErr: true,
+		},
+		{
+			name:      "malformed yaml",
+			data:      "servers: [",
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.data))
+			if (err != nil) != test.expectErr {
+				t.Errorf("Parse() error = %v, expectErr %v", err, test.expectErr)
+			}
+		})
+	}
+}
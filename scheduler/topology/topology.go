@@ -0,0 +1,157 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package topology describes how kube nodes (usually VMs) are pinned onto
+// the sockets, NUMA clusters and cores of the physical servers that host
+// them, and the racks of the servers.
+package topology
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+)
+
+// Server describes a physical machine that hosts one or more kube nodes.
+type Server struct {
+	// UUID identifies the server in the monitoring database.
+	UUID string `yaml:"uuid"`
+	// Links is the number of interconnect links between the sockets of the server.
+	Links int `yaml:"links"`
+	// LinkSpeed is the speed of each interconnect link in GT/s.
+	LinkSpeed float32 `yaml:"linkSpeed"`
+	// MaxFrequency is the maximum core frequency of the server in GHz.
+	MaxFrequency float32 `yaml:"maxFrequency"`
+	// Rack is the rack the server is mounted in, if known.
+	Rack string `yaml:"rack"`
+	// MemoryBandwidth is the memory bandwidth of each socket of the server, in
+	// the unit of the mem_read and mem_write metrics. Zero if unknown.
+	MemoryBandwidth float32 `yaml:"memoryBandwidth"`
+}
+
+// LinkBandwidth returns the aggregate interconnect bandwidth of the server.
+func (s *Server) LinkBandwidth() float32 {
+	return float32(s.Links) * s.LinkSpeed
+}
+
+// Node describes where a kube node is pinned on its physical server.
+type Node struct {
+	// Name is the name of the kube node.
+	Name string
+	// Server is the UUID of the server hosting the node.
+	Server string
+	// Socket is the id of the socket the node is pinned on.
+	Socket int
+	// NUMA is the id of the sub-NUMA cluster of the socket the node is pinned
+	// on, 0 without sub-NUMA clustering.
+	NUMA int
+	// Cores are the ids of the physical cores assigned to the node.
+	Cores []int
+}
+
+// Interface is implemented by anything that can answer topology questions
+// for the scheduler. Returned values are shared and must not be modified.
+type Interface interface {
+	// Node returns the placement of the given kube node.
+	Node(name string) (*Node, bool)
+	// Server returns the description of the server with the given UUID.
+	Server(uuid string) (*Server, bool)
+	// SocketNodes returns the names of all kube nodes pinned on the given
+	// socket of the given server, sorted by name.
+	SocketNodes(server string, socket int) []string
+	// NodeNames returns the names of all known kube nodes, sorted.
+	NodeNames() []string
+	// Tree returns the topology as a tree of racks, servers, sockets, NUMA
+	// clusters and kube nodes.
+	Tree() *Tree
+}
+
+// Topology is an immutable snapshot of the cluster topology.
+type Topology struct {
+	servers map[string]*Server
+	nodes   map[string]*Node
+	tree    *Tree
+}
+
+var _ Interface = &Topology{}
+
+// New returns a Topology built from the given servers and nodes.
+func New(servers []Server, nodes []Node) *Topology {
+	t := &Topology{
+		servers: make(map[string]*Server, len(servers)),
+		nodes:   make(map[string]*Node, len(nodes)),
+	}
+	for i := range servers {
+		s := servers[i]
+		t.servers[s.UUID] = &s
+	}
+	for i := range nodes {
+		n := nodes[i]
+		t.nodes[n.Name] = &n
+	}
+	t.tree = newTree(t.servers, t.nodes)
+	return t
+}
+
+// Node returns the placement of the given kube node.
+func (t *Topology) Node(name string) (*Node, bool) {
+	n, ok := t.nodes[name]
+	return n, ok
+}
+
+// Server returns the description of the server with the given UUID.
+func (t *Topology) Server(uuid string) (*Server, bool) {
+	s, ok := t.servers[uuid]
+	return s, ok
+}
+
+// SocketNodes returns the names of all kube nodes pinned on the given socket
+// of the given server, sorted by name.
+func (t *Topology) SocketNodes(server string, socket int) []string {
+	for _, n := range t.nodes {
+		if n.Server == server && n.Socket == socket {
+			g, _ := t.tree.Group(LevelSocket, n.Name)
+			return g.Nodes
+		}
+	}
+	return nil
+}
+
+// NodeNames returns the names of all known kube nodes, sorted.
+func (t *Topology) NodeNames() []string {
+	res := make([]string, 0, len(t.nodes))
+	for name := range t.nodes {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Tree returns the topology as a tree of racks, servers, sockets, NUMA
+// clusters and kube nodes.
+func (t *Topology) Tree() *Tree {
+	return t.tree
+}
+
+// parseCores parses a Linux cpuset list (e.g. "0-3,8,10-11") into core ids.
+func parseCores(s string) ([]int, error) {
+	set, err := cpuset.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cpuset %q: %v", s, err)
+	}
+	return set.ToSlice(), nil
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package topology
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Level is a level of the topology tree.
+type Level string
+
+const (
+	// LevelRack groups the servers of a rack.
+	LevelRack Level = "rack"
+	// LevelServer groups the sockets of a server.
+	LevelServer Level = "server"
+	// LevelSocket groups the NUMA clusters of a socket.
+	LevelSocket Level = "socket"
+	// LevelNUMA groups the kube nodes pinned on a sub-NUMA cluster of a socket.
+	LevelNUMA Level = "numa"
+	// LevelNode is the level of the leaves, the kube nodes.
+	LevelNode Level = "node"
+)
+
+// Levels are the levels of the topology tree, from the root down.
+var Levels = []Level{LevelRack, LevelServer, LevelSocket, LevelNUMA, LevelNode}
+
+// IsLevel returns true if level is a level of the topology tree.
+func IsLevel(level Level) bool {
+	return depth(level) >= 0
+}
+
+func depth(level Level) int {
+	for i, l := range Levels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// Group is a subtree of the topology: a rack, a server, a socket, a NUMA
+// cluster or a kube node. Groups are shared and must not be modified.
+type Group struct {
+	// Level is the level of the group, empty for the root of the tree.
+	Level Level
+	// ID identifies the group among the groups of its level, e.g.
+	// "<uuid>/1" for socket 1 of a server.
+	ID string
+	// Children are the groups of the next level, sorted by ID.
+	Children []*Group
+	// Nodes are the names of the kube nodes in the group, sorted.
+	Nodes []string
+}
+
+// Tree is the topology of the kube nodes as a tree of racks, servers,
+// sockets, NUMA clusters and kube nodes. Servers without a rack are grouped
+// in a rack with an empty ID, and sockets without sub-NUMA clustering have a
+// single NUMA cluster.
+type Tree struct {
+	root *Group
+	// groups are the groups of each level, by the names of their nodes.
+	groups map[Level]map[string]*Group
+}
+
+// newTree returns the tree of the given nodes.
+func newTree(servers map[string]*Server, nodes map[string]*Node) *Tree {
+	t := &Tree{
+		root:   &Group{},
+		groups: make(map[Level]map[string]*Group, len(Levels)),
+	}
+	for _, level := range Levels {
+		t.groups[level] = map[string]*Group{}
+	}
+
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		n := nodes[name]
+		var rack string
+		if s, ok := servers[n.Server]; ok {
+			rack = s.Rack
+		}
+		ids := []string{
+			rack,
+			n.Server,
+			fmt.Sprintf("%s/%d", n.Server, n.Socket),
+			fmt.Sprintf("%s/%d/%d", n.Server, n.Socket, n.NUMA),
+			name,
+		}
+		g := t.root
+		g.Nodes = append(g.Nodes, name)
+		for i, level := range Levels {
+			g = g.child(level, ids[i])
+			g.Nodes = append(g.Nodes, name)
+			t.groups[level][name] = g
+		}
+	}
+	return t
+}
+
+// child returns the child of g with the given ID, adding it if needed.
+func (g *Group) child(level Level, id string) *Group {
+	i := sort.Search(len(g.Children), func(i int) bool { return g.Children[i].ID >= id })
+	if i < len(g.Children) && g.Children[i].ID == id {
+		return g.Children[i]
+	}
+	c := &Group{Level: level, ID: id}
+	g.Children = append(g.Children, nil)
+	copy(g.Children[i+1:], g.Children[i:])
+	g.Children[i] = c
+	return c
+}
+
+// Root returns the root of the tree, grouping all the kube nodes.
+func (t *Tree) Root() *Group {
+	return t.root
+}
+
+// Group returns the group of the given level containing the named kube node.
+func (t *Tree) Group(level Level, node string) (*Group, bool) {
+	g, ok := t.groups[level][node]
+	return g, ok
+}
+
+// Aggregation combines the values of the nodes of a group.
+type Aggregation string
+
+const (
+	// AggregateSum sums the values.
+	AggregateSum Aggregation = "sum"
+	// AggregateMean averages the values.
+	AggregateMean Aggregation = "mean"
+	// AggregateMax keeps the highest value.
+	AggregateMax Aggregation = "max"
+	// AggregateMin keeps the lowest value.
+	AggregateMin Aggregation = "min"
+)
+
+// Aggregate combines the values of the nodes of the group with agg. Nodes
+// without a value are left out. It returns false if no node has a value.
+func (g *Group) Aggregate(value func(node string) (float64, bool), agg Aggregation) (float64, bool) {
+	var res float64
+	n := 0
+	for _, node := range g.Nodes {
+		v, ok := value(node)
+		if !ok {
+			continue
+		}
+		switch {
+		case n == 0:
+			res = v
+		case agg == AggregateMax:
+			res = math.Max(res, v)
+		case agg == AggregateMin:
+			res = math.Min(res, v)
+		default:
+			res += v
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, false
+	}
+	if agg == AggregateMean {
+		res /= float64(n)
+	}
+	return res, true
+}
+
+// GroupScorer scores a group of the tree. Higher is better.
+type GroupScorer func(g *Group) (float64, error)
+
+// Descend walks down the tree from g, keeping at each level that has a scorer
+// the best scoring groups only, and returns the feasible kube nodes of the
+// groups kept at the last level. All the groups are kept at the levels without
+// a scorer. Groups without feasible nodes are never kept.
+func (g *Group) Descend(scorers map[Level]GroupScorer, feasible func(node string) bool) ([]string, error) {
+	frontier := []*Group{g}
+	for len(frontier) > 0 && len(frontier[0].Children) > 0 {
+		var children []*Group
+		for _, f := range frontier {
+			for _, c := range f.Children {
+				if c.hasFeasible(feasible) {
+					children = append(children, c)
+				}
+			}
+		}
+		if len(children) == 0 {
+			return nil, nil
+		}
+		score, ok := scorers[children[0].Level]
+		if !ok {
+			frontier = children
+			continue
+		}
+		frontier = frontier[:0]
+		best := math.Inf(-1)
+		for _, c := range children {
+			s, err := score(c)
+			if err != nil {
+				return nil, fmt.Errorf("unable to score %s %s: %v", c.Level, c.ID, err)
+			}
+			if s > best {
+				best = s
+				frontier = frontier[:0]
+			}
+			if s == best {
+				frontier = append(frontier, c)
+			}
+		}
+	}
+
+	var res []string
+	for _, f := range frontier {
+		for _, node := range f.Nodes {
+			if feasible(node) {
+				res = append(res, node)
+			}
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func (g *Group) hasFeasible(feasible func(node string) bool) bool {
+	for _, node := range g.Nodes {
+		if feasible(node) {
+			return true
+		}
+	}
+	return false
+}
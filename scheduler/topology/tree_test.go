@@ -0,0 +1,163 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package topology
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// newTestTree returns two racks of one server each. Socket 0 of server-a has
+// two sub-NUMA clusters.
+func newTestTree() *Tree {
+	return New(
+		[]Server{{UUID: "server-a", Rack: "rack-1"}, {UUID: "server-b", Rack: "rack-2"}},
+		[]Node{
+			{Name: "kube-01", Server: "server-a", Socket: 0, NUMA: 0},
+			{Name: "kube-02", Server: "server-a", Socket: 0, NUMA: 1},
+			{Name: "kube-03", Server: "server-a", Socket: 0, NUMA: 1},
+			{Name: "kube-04", Server: "server-a", Socket: 1},
+			{Name: "kube-05", Server: "server-b", Socket: 0},
+			{Name: "kube-06", Server: "server-b", Socket: 1},
+		},
+	).Tree()
+}
+
+func TestTreeGroups(t *testing.T) {
+	tree := newTestTree()
+	if got := len(tree.Root().Nodes); got != 6 {
+		t.Errorf("expected 6 nodes in the tree, got %d", got)
+	}
+
+	tests := []struct {
+		level Level
+		node  string
+		id    string
+		nodes []string
+	}{
+		{level: LevelRack, node: "kube-01", id: "rack-1", nodes: []string{"kube-01", "kube-02", "kube-03", "kube-04"}},
+		{level: LevelServer, node: "kube-05", id: "server-b", nodes: []string{"kube-05", "kube-06"}},
+		{level: LevelSocket, node: "kube-02", id: "server-a/0", nodes: []string{"kube-01", "kube-02", "kube-03"}},
+		{level: LevelNUMA, node: "kube-02", id: "server-a/0/1", nodes: []string{"kube-02", "kube-03"}},
+		{level: LevelNode, node: "kube-04", id: "kube-04", nodes: []string{"kube-04"}},
+	}
+	for _, test := range tests {
+		t.Run(string(test.level), func(t *testing.T) {
+			g, ok := tree.Group(test.level, test.node)
+			if !ok {
+				t.Fatalf("expected a %s group for %s", test.level, test.node)
+			}
+			if g.Level != test.level || g.ID != test.id || !reflect.DeepEqual(g.Nodes, test.nodes) {
+				t.Errorf("expected %s %s with %v, got %s %s with %v", test.level, test.id, test.nodes, g.Level, g.ID, g.Nodes)
+			}
+		})
+	}
+
+	if _, ok := tree.Group(LevelSocket, "kube-99"); ok {
+		t.Errorf("expected no group for an unknown node")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	g, _ := newTestTree().Group(LevelSocket, "kube-01")
+	values := map[string]float64{"kube-01": 1, "kube-02": 4}
+	value := func(node string) (float64, bool) {
+		v, ok := values[node]
+		return v, ok
+	}
+
+	for agg, expected := range map[Aggregation]float64{
+		AggregateSum:  5,
+		AggregateMean: 2.5,
+		AggregateMax:  4,
+		AggregateMin:  1,
+	} {
+		if got, ok := g.Aggregate(value, agg); !ok || got != expected {
+			t.Errorf("expected the %s to be %v, got %v", agg, expected, got)
+		}
+	}
+	if _, ok := g.Aggregate(func(string) (float64, bool) { return 0, false }, AggregateSum); ok {
+		t.Errorf("expected no aggregate without values")
+	}
+}
+
+func TestDescend(t *testing.T) {
+	tree := newTestTree()
+	// Racks and servers prefer the second rack, sockets and NUMA clusters
+	// the lowest ids.
+	byID := func(ids map[string]float64) GroupScorer {
+		return func(g *Group) (float64, error) {
+			return ids[g.ID], nil
+		}
+	}
+	all := func(string) bool { return true }
+
+	tests := []struct {
+		name     string
+		scorers  map[Level]GroupScorer
+		feasible func(string) bool
+		expected []string
+	}{
+		{
+			name:     "no scorer",
+			feasible: all,
+			expected: []string{"kube-01", "kube-02", "kube-03", "kube-04", "kube-05", "kube-06"},
+		},
+		{
+			name: "socket only",
+			scorers: map[Level]GroupScorer{
+				LevelSocket: byID(map[string]float64{"server-a/1": 2, "server-b/1": 2}),
+			},
+			feasible: all,
+			expected: []string{"kube-04", "kube-06"},
+		},
+		{
+			name: "rack then NUMA",
+			scorers: map[Level]GroupScorer{
+				LevelRack: byID(map[string]float64{"rack-1": 1}),
+				LevelNUMA: byID(map[string]float64{"server-a/0/1": 1}),
+			},
+			feasible: all,
+			expected: []string{"kube-02", "kube-03"},
+		},
+		{
+			name: "infeasible groups are skipped",
+			scorers: map[Level]GroupScorer{
+				LevelNUMA: byID(map[string]float64{"server-a/0/1": 1}),
+			},
+			feasible: func(node string) bool { return node == "kube-01" || node == "kube-05" },
+			expected: []string{"kube-01", "kube-05"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := tree.Root().Descend(test.scorers, test.feasible)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+
+	failing := map[Level]GroupScorer{LevelServer: func(*Group) (float64, error) { return 0, fmt.Errorf("no data") }}
+	if _, err := tree.Root().Descend(failing, all); err == nil {
+		t.Errorf("expected the error of the scorer")
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2020 Achilleas Tzenetopoulos.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command simulator replays a workload trace through the scheduler on a
+// virtual clock and prints where and when every pod ran. The pods contend for
+// the memory bandwidth, the interconnect and the cores of their socket, as in
+// the default contention model of the synthetic package, unless -contention
+// is false.
+//
+// Usage:
+//
+//	simulator -topology scheduler-topology.yaml [-policy policy.json] [-profiles profiles.yaml] [-contention=false] [-format text|json|run|sockets] [trace]
+//
+// The trace holds a pod per line as "<arrival in seconds> <application>" and
+// is read from the standard input unless a file is given. The run format is
+// the input of the calculator, so that simulated runs are analysed as real
+// ones; the sockets format is a CSV timeline of the load of every socket.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	latestschedulerapi "k8s.io/kubernetes/pkg/scheduler/api/latest"
+	"k8s.io/kubernetes/pkg/scheduler/customcache"
+	"k8s.io/kubernetes/pkg/scheduler/monitoring/synthetic"
+	"k8s.io/kubernetes/pkg/scheduler/profiles"
+	"k8s.io/kubernetes/pkg/scheduler/simulator"
+	"k8s.io/kubernetes/pkg/scheduler/topology"
+)
+
+func main() {
+	klog.InitFlags(nil)
+	topologyFile := flag.String("topology", topology.DefaultConfigFile, "topology file of the simulated cluster")
+	policyFile := flag.String("policy", "", "scheduler policy file; the default algorithm provider if empty")
+	profilesFile := flag.String("profiles", "", "file of application profiles, in the format of the profiles ConfigMap; the built-in benchmarks if empty")
+	metricsTTL := flag.Duration("metrics-ttl", customcache.DefaultTTL, "how often the counters read by the priorities are refreshed")
+	contention := flag.Bool("contention", true, "slow down the pods contending for the memory bandwidth of their socket")
+	format := flag.String("format", "text", "output format: text, json, run or sockets")
+	flag.Parse()
+
+	model := synthetic.Model(synthetic.DefaultContentionModel)
+	if !*contention {
+		model = &synthetic.ContentionModel{Idle: synthetic.DefaultIdleMetrics}
+	}
+	if err := simulate(*topologyFile, *policyFile, *profilesFile, *metricsTTL, model, *format, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "simulator: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func simulate(topologyFile, policyFile, profilesFile string, metricsTTL time.Duration, model synthetic.Model, format string, args []string) error {
+	var write func(io.Writer, *simulator.Result) error
+	switch format {
+	case "text":
+		write = writeText
+	case "json":
+		write = writeJSON
+	case "run":
+		write = writeRun
+	case "sockets":
+		write = writeSockets
+	default:
+		return fmt.Errorf("unknown format %q, expected one of text, json, run, sockets", format)
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("expected at most one trace file, got %d", len(args))
+	}
+
+	config := simulator.Config{MetricsTTL: metricsTTL, Model: model}
+	data, err := ioutil.ReadFile(topologyFile)
+	if err != nil {
+		return err
+	}
+	topoConfig, err := topology.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", topologyFile, err)
+	}
+	if config.Topology, err = topology.FromConfig(topoConfig); err != nil {
+		return fmt.Errorf("%s: %v", topologyFile, err)
+	}
+	if policyFile != "" {
+		data, err := ioutil.ReadFile(policyFile)
+		if err != nil {
+			return err
+		}
+		policy := &schedulerapi.Policy{}
+		if err := runtime.DecodeInto(latestschedulerapi.Codec, data, policy); err != nil {
+			return fmt.Errorf("%s: %v", policyFile, err)
+		}
+		config.Policy = policy
+	}
+	if profilesFile != "" {
+		data, err := ioutil.ReadFile(profilesFile)
+		if err != nil {
+			return err
+		}
+		cfg, err := profiles.Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", profilesFile, err)
+		}
+		store := profiles.NewStore()
+		store.Load(cfg)
+		config.Profiles = store
+	}
+
+	in := os.Stdin
+	if len(args) == 1 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	trace, err := simulator.ParseTrace(in)
+	if err != nil {
+		return err
+	}
+
+	result, err := simulator.Run(config, trace)
+	if err != nil {
+		return err
+	}
+	if len(result.Unscheduled) > 0 {
+		fmt.Fprintf(os.Stderr, "simulator: %d pods never fit on a node: %v\n", len(result.Unscheduled), result.Unscheduled)
+	}
+	return write(os.Stdout, result)
+}
+
+func writeText(w io.Writer, result *simulator.Result) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "POD\tNODE\tSERVER\tSOCKET\tARRIVAL\tSTART\tFINISH")
+	for _, p := range result.Placements {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%v\t%v\t%v\n", p.Pod, p.Node, p.Server, p.Socket, p.Arrival, p.Start, p.Finish)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\nMakespan: %v\n", result.Makespan)
+	return err
+}
+
+func writeJSON(w io.Writer, result *simulator.Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+// writeRun writes the pods in the input format of the calculator, starting
+// the virtual clock at the simulator Epoch.
+func writeRun(w io.Writer, result *simulator.Result) error {
+	for _, p := range result.Placements {
+		start, finish := simulator.Epoch.Add(p.Start), simulator.Epoch.Add(p.Finish)
+		if _, err := fmt.Fprintf(w, "%s %s %s %s\n", p.Pod, formatFloat((p.Finish - p.Start).Seconds()), start.Format(time.RFC3339), finish.Format(time.RFC3339)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeSockets(w io.Writer, result *simulator.Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"at", "server", "socket", "pods", "ipc", "mem_read", "mem_write", "c6res"}); err != nil {
+		return err
+	}
+	for _, load := range result.Sockets {
+		record := []string{
+			formatFloat(load.At.Seconds()),
+			load.Server,
+			strconv.Itoa(load.Socket),
+			strconv.Itoa(load.Pods),
+			formatFloat(load.Metrics["ipc"]),
+			formatFloat(load.Metrics["mem_read"]),
+			formatFloat(load.Metrics["mem_write"]),
+			formatFloat(load.Metrics["c6res"]),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}